@@ -1,10 +1,16 @@
 package api_keychain
 
 import (
+	"math/big"
 	"nbc-backend-api-v2/configs"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
 )
 
 func CheckIfKeychainStaked(stakingPoolId, keychainId int) (bool, error) {
 	return UtilsKOS.CheckIfKeychainStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, keychainId)
 }
+
+func OwnerIDs(address string) ([]*big.Int, error) {
+	return UtilsNFT.OwnerIDsByRole(address, UtilsNFT.RoleBooster)
+}
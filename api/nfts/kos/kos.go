@@ -6,12 +6,13 @@ import (
 	"math/big"
 	"nbc-backend-api-v2/configs"
 	"nbc-backend-api-v2/models"
-	UtilsKeychain "nbc-backend-api-v2/utils/nfts/keychain"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
-	UtilsSuperiorKeychain "nbc-backend-api-v2/utils/nfts/superior_keychain"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 /********************
@@ -21,98 +22,87 @@ CHAIN FUNCTIONS (FOR SIMPLIFICATION IN SINGLE API CALLS)
 /*
 Returns the following:
 
-1. the wallet's owned key, keychain and superior keychain IDs
-2. the metadata for the wallet's owned key ID
+1. the wallet's owned key, keychain and superior keychain IDs, on every chain of the collection registry
+2. the metadata for the wallet's owned keys, keychains and superior keychains
 3. checks if any of the keys, keychains and/or superior keychains are staked (in the staking pool with the `stakingPoolId`)
 */
 func StakerInventory(wallet string, stakingPoolId int) (*models.KOSStakerInventory, error) {
-	ownedKeyIds, err := UtilsKOS.OwnerIDs(wallet)
+	collections, err := UtilsNFT.Collections()
 	if err != nil {
 		return nil, err
 	}
-	ownedKeychainIds, err := UtilsKeychain.OwnerIDs(wallet)
-	if err != nil {
-		return nil, err
-	}
-	ownedSuperiorKeychainIds, err := UtilsSuperiorKeychain.OwnerIDs(wallet)
+	// the owned tokens of each collection, read from every chain at the same time.
+	owned, err := UtilsNFT.GetOwnedTokens(wallet, collections)
 	if err != nil {
 		return nil, err
 	}
 
-	keyIds := make([]int, len(ownedKeyIds))
-	for i, id := range ownedKeyIds {
-		keyIds[i] = int(id.Int64())
-	}
-
-	keyData, err := UtilsKOS.FetchSimplifiedMetadataConcurrent(keyIds)
-	if err != nil {
-		return nil, err
-	}
-
-	var keyMetadataAPI, keychainData, superiorKeychainData []*models.NFTData
-	var wg sync.WaitGroup
+	// the NFT data of the owned tokens, keyed by staking role (aggregated across chains).
+	nftDataByRole := map[UtilsNFT.CollectionRole][]*models.NFTData{}
+	for _, tokens := range owned {
+		role := tokens.Collection.Role
+		// the metadata of a role's tokens is the same on every chain, so it's read from the role's primary collection.
+		primary, err := UtilsNFT.CollectionByRole(role)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, md := range keyData {
-		wg.Add(1)
-		go func(md *models.KOSSimplifiedMetadata) {
-			defer wg.Done()
-			isStaked, err := UtilsKOS.CheckIfKeyStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, md)
+		var nftData []*models.NFTData
+		// keys are returned with their simplified metadata.
+		if role == UtilsNFT.RoleKey {
+			keyData, err := UtilsKOS.FetchSimplifiedMetadataConcurrent(tokens.TokenIDs)
 			if err != nil {
-				log.Printf("Error checking if key is staked for token ID %d: %v\n", md.TokenID, err)
-				return
+				return nil, err
 			}
-			keyMetadataAPI = append(keyMetadataAPI, &models.NFTData{
-				Name:      fmt.Sprintf("Key Of Salvation #%d", md.TokenID),
-				ImageUrl:  md.AnimationUrl,
-				TokenID:   md.TokenID,
-				Metadata:  md,
-				Stakeable: !isStaked,
-			})
-		}(md)
-	}
-
-	for _, id := range ownedKeychainIds {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			isStaked, err := UtilsKOS.CheckIfKeychainStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, id)
+			for _, md := range keyData {
+				nftData = append(nftData, &models.NFTData{
+					Name:     fmt.Sprintf("%s #%d", primary.Name, md.TokenID),
+					ImageUrl: md.AnimationUrl,
+					TokenID:  md.TokenID,
+					Metadata: md,
+				})
+			}
+		} else {
+			nftData, err = primary.Metadata.FetchNFTDataConcurrent(tokens.TokenIDs)
 			if err != nil {
-				log.Printf("Error checking if keychain is staked for token ID %d: %v\n", id, err)
-				return
+				return nil, err
 			}
-			keychainData = append(keychainData, &models.NFTData{
-				Name:      fmt.Sprintf("Keychain #%d", id),
-				ImageUrl:  "https://realmhunter-kos.fra1.digitaloceanspaces.com/keychains/keychain.mp4",
-				TokenID:   id,
-				Stakeable: !isStaked,
-			})
-		}(int(id.Int64()))
+		}
+
+		for _, data := range nftData {
+			data.Chain = tokens.Collection.ChainName
+		}
+		nftDataByRole[role] = append(nftDataByRole[role], nftData...)
 	}
 
-	for _, id := range ownedSuperiorKeychainIds {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			isStaked, err := UtilsKOS.CheckIfSuperiorKeychainStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, id)
-			if err != nil {
-				log.Printf("Error checking if superior keychain is staked for token ID %d: %v\n", id, err)
-				return
-			}
-			superiorKeychainData = append(superiorKeychainData, &models.NFTData{
-				Name:      fmt.Sprintf("Superior Keychain #%d", id),
-				ImageUrl:  "https://realmhunter-kos.fra1.digitaloceanspaces.com/keychains/superiorKeychain.mp4",
-				TokenID:   id,
-				Stakeable: !isStaked,
-			})
-		}(int(id.Int64()))
+	stakeableByRole := map[UtilsNFT.CollectionRole][]*models.NFTData{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for role, nftData := range nftDataByRole {
+		for _, data := range nftData {
+			wg.Add(1)
+			go func(role UtilsNFT.CollectionRole, data *models.NFTData) {
+				defer wg.Done()
+				isStaked, err := UtilsKOS.CheckIfTokenStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, role, data.TokenID)
+				if err != nil {
+					log.Printf("Error checking if %s is staked for token ID %d: %v\n", role, data.TokenID, err)
+					return
+				}
+				data.Stakeable = !isStaked
+				mu.Lock()
+				stakeableByRole[role] = append(stakeableByRole[role], data)
+				mu.Unlock()
+			}(role, data)
+		}
 	}
 
 	wg.Wait()
 
 	return &models.KOSStakerInventory{
-		KeyData:              keyMetadataAPI,
-		KeychainData:         keychainData,
-		SuperiorKeychainData: superiorKeychainData,
+		KeyData:              stakeableByRole[UtilsNFT.RoleKey],
+		KeychainData:         stakeableByRole[UtilsNFT.RoleBooster],
+		SuperiorKeychainData: stakeableByRole[UtilsNFT.RoleMultiplier],
 	}, nil
 	//////////////////// START OF CHANGE //////////////////////////////
 
@@ -314,6 +304,22 @@ func StakerInventory(wallet string, stakingPoolId int) (*models.KOSStakerInvento
 	// }
 }
 
+/*
+Recommends how the wallet should stake its owned (and unstaked) keys, keychains and superior keychains in the staking pool
+with the `stakingPoolId` to get the most points.
+*/
+func RecommendSubpools(wallet string, stakingPoolId int) (*models.SubpoolRecommendation, error) {
+	ownedTokenIds, err := UtilsKOS.OwnedTokenIDs(wallet)
+	if err != nil {
+		return nil, err
+	}
+	keyIds := ownedTokenIds[UtilsNFT.RoleKey]
+	keychainIds := ownedTokenIds[UtilsNFT.RoleBooster]
+	superiorKeychainIds := ownedTokenIds[UtilsNFT.RoleMultiplier]
+
+	return UtilsKOS.GetSubpoolRecommendation(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, wallet, keyIds, keychainIds, superiorKeychainIds)
+}
+
 /*
 Returns all active and closed staking pools, each with their respective staking pool data
 */
@@ -393,6 +399,26 @@ func FetchMetadata(tokenId int) (*models.KOSMetadata, error) {
 	return UtilsKOS.FetchMetadata(tokenId)
 }
 
+func FetchMetadataEach(tokenIds []int) ([]*models.KOSMetadata, []error) {
+	return UtilsKOS.FetchMetadataEach(tokenIds)
+}
+
+func SyncMetadata(concurrency int, refresh bool) (*models.MetadataSyncResult, error) {
+	return UtilsKOS.SyncMetadata(configs.GetCollections(configs.DB, "RHKOSMetadata"), concurrency, refresh)
+}
+
+func SearchTokens(filter *models.KOSTokenFilter, sortBy string, descending bool, page, limit int) (*models.KOSTokenSearch, error) {
+	return UtilsKOS.SearchTokens(configs.GetCollections(configs.DB, "RHKOSMetadata"), configs.GetCollections(configs.DB, "RHStakingPool"), filter, sortBy, descending, page, limit)
+}
+
+func GetKeyScore(tokenId int) (*models.KOSKeyScore, error) {
+	return UtilsKOS.GetKeyScore(configs.GetCollections(configs.DB, "RHKOSMetadata"), tokenId)
+}
+
+func GetKeyRanking(by string, page, limit int) (*models.KOSKeyRanking, error) {
+	return UtilsKOS.GetKeyRanking(configs.GetCollections(configs.DB, "RHKOSMetadata"), by, page, limit)
+}
+
 func GetStakerRECBalance(wallet string) (float64, error) {
 	return UtilsKOS.GetStakerRECBalance(configs.GetCollections(configs.DB, "RHStakerData"), wallet)
 }
@@ -425,19 +451,16 @@ func CheckSubpoolComboEligibility(stakingPoolId, keyCount int, stakerWallet stri
 	return UtilsKOS.CheckSubpoolComboEligibilityAlt(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, stakerWallet, keyCount)
 }
 
-func CalculateSubpoolPoints(keyIds, keychainIds []int, superiorKeychainId int) float64 {
-	metadatas := UtilsKOS.GetMetadataFromIDs(keyIds)
+func CalculateSubpoolPoints(keyIds, keychainIds []int, superiorKeychainId int) (float64, error) {
+	metadatas, err := UtilsKOS.GetMetadataFromIDs(keyIds)
+	if err != nil {
+		return 0, err
+	}
 
-	return UtilsKOS.CalculateSubpoolPoints(metadatas, keychainIds, superiorKeychainId)
+	return UtilsKOS.CalculateSubpoolPoints(metadatas, keychainIds, superiorKeychainId), nil
 }
 
-func BacktrackSubpoolPoints(stakingPoolId, subpoolId int) (*struct {
-	LuckAndLuckBoostSum float64 `json:"luckAndLuckBoostSum"`
-	AngelMultiplier     float64 `json:"angelMultiplier"`
-	KeyCombo            float64 `json:"keyCombo"`
-	KeychainCombo       float64 `json:"keychainCombo"`
-	TotalSubpoolPoints  float64 `json:"totalSubpoolPoints"`
-}, error) {
+func BacktrackSubpoolPoints(stakingPoolId, subpoolId int) (*models.BacktrackedSubpoolPoints, error) {
 	return UtilsKOS.BacktrackSubpoolPoints(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, subpoolId)
 }
 func CalculateSubpoolTokenShare(stakingPoolId, subpoolId int) (float64, error) {
@@ -452,19 +475,73 @@ func GetStakerSubpools(stakerWallet string) ([]*models.StakingSubpoolWithID, err
 	return UtilsKOS.GetStakerSubpools(configs.GetCollections(configs.DB, "RHStakingPool"), stakerWallet)
 }
 
+func GetStaker(wallet string) (*models.Staker, error) {
+	return UtilsKOS.GetStakerFromWallet(configs.GetCollections(configs.DB, "RHStakerData"), wallet)
+}
+
+func GetStakers(wallets []string) ([]*models.Staker, error) {
+	return UtilsKOS.GetStakersFromWallets(configs.GetCollections(configs.DB, "RHStakerData"), wallets)
+}
+
+func GetStakersFromObjIDs(stakerObjIds []primitive.ObjectID) ([]*models.Staker, error) {
+	return UtilsKOS.GetStakersFromObjIDs(configs.GetCollections(configs.DB, "RHStakerData"), stakerObjIds)
+}
+
+/*
+Gets the subpools of staking pool ID `stakingPoolId`.
+`status` is either "active" (only active subpools), "closed" (only closed subpools) or "all".
+*/
+func GetStakingPoolSubpools(stakingPoolId int, status string) ([]*models.StakingSubpool, error) {
+	stakingPool, err := UtilsKOS.GetStakingPoolData(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId)
+	if err != nil {
+		return nil, err
+	}
+
+	subpools := []*models.StakingSubpool{}
+	if status != "closed" {
+		subpools = append(subpools, stakingPool.ActiveSubpools...)
+	}
+	if status != "active" {
+		subpools = append(subpools, stakingPool.ClosedSubpools...)
+	}
+
+	return subpools, nil
+}
+
+func GetStakerPortfolio(wallet string) (*models.StakerPortfolio, error) {
+	return UtilsKOS.GetStakerPortfolio(configs.GetCollections(configs.DB, "RHStakingPool"), configs.GetCollections(configs.DB, "RHStakerData"), wallet, time.Now())
+}
+
+func SimulatePool(stakingPoolId int, wallet string, additions []*models.SubpoolSpec, removals []int, extraCompetitorPoints float64, rewardAmount *float64) (*models.PoolSimulation, error) {
+	return UtilsKOS.SimulatePool(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, wallet, additions, removals, extraCompetitorPoints, rewardAmount)
+}
+
+func GetPoolLeaderboard(stakingPoolId, page, limit int) (*models.PoolLeaderboard, error) {
+	return UtilsKOS.GetPoolLeaderboard(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, page, limit)
+}
+
+func GetRewardLeaderboard(rewardName string, page, limit int) (*models.RewardLeaderboard, error) {
+	return UtilsKOS.GetRewardLeaderboard(configs.GetCollections(configs.DB, "RHStakerData"), rewardName, page, limit)
+}
+
 func CheckPoolTimeAllowanceExceeded(stakingPoolId int) (bool, error) {
 	return UtilsKOS.CheckPoolTimeAllowanceExceeded(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId)
 }
 
 func CheckIfKeysStaked(stakingPoolId int, keyIds []int) (bool, error) {
-	metadatas := UtilsKOS.GetMetadataFromIDs(keyIds)
+	metadatas, err := UtilsKOS.GetMetadataFromIDs(keyIds)
+	if err != nil {
+		return false, err
+	}
 	return UtilsKOS.CheckIfKeysStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, metadatas)
 }
 
 func AddSubpool(keyIds []int, sessionToken, stakerWallet string, stakingPoolId int, keychainIds []int, superiorKeychainId int) error {
-	metadatas := UtilsKOS.GetMetadataFromIDs(keyIds)
+	return UtilsKOS.AddSubpool(configs.GetCollections(configs.DB, "RHStakingPool"), sessionToken, stakingPoolId, stakerWallet, keyIds, keychainIds, superiorKeychainId)
+}
 
-	return UtilsKOS.AddSubpool(configs.GetCollections(configs.DB, "RHStakingPool"), sessionToken, stakingPoolId, stakerWallet, metadatas, keychainIds, superiorKeychainId)
+func AddSubpools(sessionToken, stakerWallet string, stakingPoolId int, specs []*models.SubpoolSpec) (*models.BatchStakeResult, error) {
+	return UtilsKOS.AddSubpools(configs.GetCollections(configs.DB, "RHStakingPool"), sessionToken, stakingPoolId, stakerWallet, specs)
 }
 
 func AddStakingPool(rewardName string, rewardAmount float64) error {
@@ -475,10 +552,47 @@ func ClaimReward(sessionToken, stakerWallet string, stakingPoolId, subpoolId int
 	return UtilsKOS.ClaimReward(configs.GetCollections(configs.DB, "RHStakingPool"), sessionToken, stakerWallet, stakingPoolId, subpoolId)
 }
 
+func ClaimAllRewards(sessionToken, stakerWallet string) (*models.ClaimAllResult, error) {
+	return UtilsKOS.ClaimAllRewards(configs.GetCollections(configs.DB, "RHStakingPool"), sessionToken, stakerWallet)
+}
+
 func UnstakeFromSubpool(sessionToken, wallet string, stakingPoolId, subpoolId int) error {
 	return UtilsKOS.UnstakeFromSubpool(configs.GetCollections(configs.DB, "RHStakingPool"), sessionToken, wallet, stakingPoolId, subpoolId)
 }
 
+func AddDelegation(vault, delegate string, staking, claiming bool, expiry, nonce int64, signature string) (*models.Delegation, error) {
+	delegation := &models.Delegation{
+		Vault:     vault,
+		Delegate:  delegate,
+		Staking:   staking,
+		Claiming:  claiming,
+		Expiry:    time.Unix(expiry, 0),
+		Nonce:     nonce,
+		Signature: signature,
+	}
+	return UtilsKOS.AddDelegation(configs.GetCollections(configs.DB, "RHDelegations"), delegation)
+}
+
+func GetDelegations(wallet string) (*models.StakerDelegations, error) {
+	return UtilsKOS.GetDelegations(configs.GetCollections(configs.DB, "RHDelegations"), wallet)
+}
+
+func EnsureDelegationIndexes() error {
+	return UtilsKOS.EnsureDelegationIndexes(configs.GetCollections(configs.DB, "RHDelegations"))
+}
+
+func IssueSignInNonce(wallet string) (*models.SignInChallenge, error) {
+	return UtilsNFT.IssueSignInNonce(wallet)
+}
+
+func SignOut(wallet, sessionToken string) error {
+	return UtilsNFT.SignOut(wallet, sessionToken)
+}
+
+func EnsureSignInNonceIndexes() error {
+	return UtilsNFT.EnsureSignInNonceIndexes(configs.GetCollections(configs.DB, UtilsNFT.SignInNonceCollection))
+}
+
 func UnstakeFromStakingPool(stakingPoolId int, stakerWallet string) error {
 	return UtilsKOS.UnstakeFromStakingPool(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, stakerWallet)
 }
@@ -510,8 +624,11 @@ func GetAllStakedSuperiorKeychainIDs(stakingPoolId int) ([]int, error) {
 /*
 Gets the detailed subpool points (how it was calculated)
 */
-func DetailedSubpoolPoints(keyIds, keychainIds []int, superiorKeychainId int) *models.DetailedSubpoolPoints {
-	metadatas := UtilsKOS.GetMetadataFromIDs(keyIds)
+func DetailedSubpoolPoints(keyIds, keychainIds []int, superiorKeychainId int) (*models.DetailedSubpoolPoints, error) {
+	metadatas, err := UtilsKOS.GetMetadataFromIDs(keyIds)
+	if err != nil {
+		return nil, err
+	}
 
 	var luckAndLuckBoostSum float64
 	for _, metadata := range metadatas {
@@ -525,8 +642,8 @@ func DetailedSubpoolPoints(keyIds, keychainIds []int, superiorKeychainId int) *m
 		LuckAndLuckBoostSum: luckAndLuckBoostSum,
 		KeyCombo:            keyCombo,
 		KeychainCombo:       keychainCombo,
-		ComboSum:            CalculateSubpoolPoints(keyIds, keychainIds, superiorKeychainId),
-	}
+		ComboSum:            UtilsKOS.CalculateSubpoolPoints(metadatas, keychainIds, superiorKeychainId),
+	}, nil
 }
 
 /*********************
@@ -620,6 +737,30 @@ func RemoveExpiredUnclaimableSubpoolsScheduler() *cron.Cron {
 	return scheduler
 }
 
+/*
+Adds a scheduler to `PublishPoolPhaseTransitions` to run it every minute.
+Each run publishes the phase transitions that happened since the last successful run.
+*/
+func PublishPoolPhaseTransitionsScheduler() *cron.Cron {
+	// skips a run if the previous one is still running, so that `lastRun` is never updated concurrently.
+	scheduler := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	lastRun := time.Now()
+
+	// runs every minute
+	scheduler.AddFunc("*/1 * * * *", func() {
+		now := time.Now()
+		err := UtilsKOS.PublishPoolPhaseTransitions(configs.GetCollections(configs.DB, "RHStakingPool"), lastRun, now)
+		if err != nil {
+			// the transitions are published in the next run instead.
+			log.Printf("unable to publish staking pool phase transitions: %v", err)
+			return
+		}
+		lastRun = now
+	})
+
+	return scheduler
+}
+
 /*********************
 
 END OF CRON SCHEDULER FUNCTIONS
@@ -1,10 +1,16 @@
 package api_superiorkeychain
 
 import (
+	"math/big"
 	"nbc-backend-api-v2/configs"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
 )
 
 func CheckIfSuperiorKeychainStaked(stakingPoolId, superiorKeychainId int) (bool, error) {
 	return UtilsKOS.CheckIfSuperiorKeychainStaked(configs.GetCollections(configs.DB, "RHStakingPool"), stakingPoolId, superiorKeychainId)
 }
+
+func OwnerIDs(address string) ([]*big.Int, error) {
+	return UtilsNFT.OwnerIDsByRole(address, UtilsNFT.RoleMultiplier)
+}
@@ -0,0 +1,68 @@
+package api_notifications
+
+import (
+	"context"
+	"log"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/models"
+	UtilsNotifications "nbc-backend-api-v2/utils/notifications"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func GetPreferences(sessionToken, wallet string) (*models.NotificationPreferences, error) {
+	return UtilsNotifications.GetPreferences(configs.GetCollections(configs.DB, "RHNotificationPreferences"), sessionToken, wallet)
+}
+
+func UpdatePreferences(sessionToken, wallet string, preferences *models.NotificationPreferences) (*models.NotificationPreferences, error) {
+	return UtilsNotifications.UpdatePreferences(configs.GetCollections(configs.DB, "RHNotificationPreferences"), sessionToken, wallet, preferences)
+}
+
+func GetInbox(sessionToken, wallet string, unreadOnly bool, limit int) (*models.NotificationInbox, error) {
+	return UtilsNotifications.GetInbox(configs.GetCollections(configs.DB, "RHNotifications"), sessionToken, wallet, unreadOnly, limit)
+}
+
+func MarkAsRead(sessionToken, wallet string, notificationId primitive.ObjectID) error {
+	return UtilsNotifications.MarkAsRead(configs.GetCollections(configs.DB, "RHNotifications"), sessionToken, wallet, notificationId)
+}
+
+/*
+Subscribes the notifications to the domain events (e.g. to notify stakers when their subpool is banned).
+Must be called before the outbox dispatcher starts.
+*/
+func SubscribeToEvents() {
+	events.Subscribe("notifications", func(ctx context.Context, event *events.OutboxEvent) error {
+		return UtilsNotifications.NotifyEvent(
+			configs.GetCollections(configs.DB, "RHNotificationPreferences"),
+			configs.GetCollections(configs.DB, "RHNotifications"),
+			event,
+		)
+	}, events.EventSubpoolBanned)
+}
+
+/*
+Adds a scheduler to `SendReminders` to run it every 5 mins.
+*/
+func SendRemindersScheduler() *cron.Cron {
+	scheduler := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+
+	// runs every 5 mins
+	scheduler.AddFunc("*/5 * * * *", func() {
+		err := UtilsNotifications.SendReminders(
+			configs.GetCollections(configs.DB, "RHStakingPool"),
+			configs.GetCollections(configs.DB, "RHStakerData"),
+			configs.GetCollections(configs.DB, "RHNotificationPreferences"),
+			configs.GetCollections(configs.DB, "RHNotifications"),
+			time.Now(),
+		)
+		if err != nil {
+			// the reminders that weren't sent are sent in the next run.
+			log.Printf("unable to send notification reminders: %v", err)
+		}
+	})
+
+	return scheduler
+}
@@ -0,0 +1,69 @@
+package api_webhooks
+
+import (
+	"context"
+	"log"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/models"
+	UtilsWebhooks "nbc-backend-api-v2/utils/webhooks"
+
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func AddWebhook(url string, eventTypes []string, secret string) (*models.Webhook, error) {
+	return UtilsWebhooks.AddWebhook(configs.GetCollections(configs.DB, "RHWebhooks"), url, eventTypes, secret)
+}
+
+func GetWebhooks() ([]*models.Webhook, error) {
+	return UtilsWebhooks.GetWebhooks(configs.GetCollections(configs.DB, "RHWebhooks"))
+}
+
+func RemoveWebhook(webhookId primitive.ObjectID) error {
+	return UtilsWebhooks.RemoveWebhook(configs.GetCollections(configs.DB, "RHWebhooks"), webhookId)
+}
+
+func GetDeadDeliveries(webhookId *primitive.ObjectID) ([]*models.WebhookDelivery, error) {
+	return UtilsWebhooks.GetDeadDeliveries(configs.GetCollections(configs.DB, "RHWebhookDeliveries"), webhookId)
+}
+
+func ReplayDelivery(deliveryId primitive.ObjectID) error {
+	return UtilsWebhooks.ReplayDelivery(configs.GetCollections(configs.DB, "RHWebhookDeliveries"), deliveryId)
+}
+
+/*
+Subscribes the webhooks to the domain events, so that a delivery is queued for every matching webhook whenever
+a staking mutation (e.g. `AddSubpool`) writes an event to the outbox. Must be called before the outbox dispatcher starts.
+*/
+func SubscribeToEvents() {
+	events.Subscribe("webhooks", func(ctx context.Context, event *events.OutboxEvent) error {
+		return UtilsWebhooks.QueueDeliveries(
+			configs.GetCollections(configs.DB, "RHWebhooks"),
+			configs.GetCollections(configs.DB, "RHWebhookDeliveries"),
+			event,
+		)
+	})
+}
+
+/*
+Adds a scheduler to `SendDueDeliveries` to run it every 5 seconds.
+*/
+func SendDueDeliveriesScheduler() *cron.Cron {
+	// skips a run if the previous one is still sending, since a slow webhook can take up to 10 seconds to respond.
+	scheduler := cron.New(cron.WithSeconds(), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+
+	// runs every 5 seconds
+	scheduler.AddFunc("*/5 * * * * *", func() {
+		err := UtilsWebhooks.SendDueDeliveries(
+			configs.GetCollections(configs.DB, "RHWebhooks"),
+			configs.GetCollections(configs.DB, "RHWebhookDeliveries"),
+		)
+		if err != nil {
+			// the deliveries are retried in the next run.
+			log.Printf("unable to send webhook deliveries: %v", err)
+		}
+	})
+
+	return scheduler
+}
@@ -0,0 +1,45 @@
+/*
+Syncs the metadata of every Key Of Salvation from IPFS (`KOS_URI`) into `RHKOSMetadata`.
+
+	go run ./cmd/sync_kos_metadata [-concurrency 16] [-refresh]
+
+Without `-refresh`, tokens that are already stored are skipped, so running it again continues an interrupted sync and retries failed tokens.
+With `-refresh`, every token is fetched again and the tokens whose metadata changed (e.g. after `setRevealStage`) are updated.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	ApiKOS "nbc-backend-api-v2/api/nfts/kos"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	"os"
+	"time"
+)
+
+func main() {
+	concurrency := flag.Int("concurrency", UtilsNFT.DefaultMetadataSyncConcurrency, "the number of tokens fetched at the same time")
+	refresh := flag.Bool("refresh", false, "fetch every token again and update the ones whose metadata changed")
+	flag.Parse()
+
+	start := time.Now()
+	result, err := ApiKOS.SyncMetadata(*concurrency, *refresh)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf(
+		"synced the metadata of %d keys in %v: %d added, %d changed, %d unchanged, %d skipped, %d failed, %d malformed",
+		result.Total, time.Since(start).Round(time.Second), result.Added, result.Changed, result.Unchanged, result.Skipped, len(result.FailedTokenIDs), len(result.MalformedTokenIDs),
+	)
+	if len(result.FailedTokenIDs) > 0 {
+		log.Printf("failed keys (run the sync again to retry them): %v", result.FailedTokenIDs)
+	}
+	if len(result.MalformedTokenIDs) > 0 {
+		log.Printf("keys with malformed metadata: %v", result.MalformedTokenIDs)
+	}
+
+	if len(result.FailedTokenIDs) > 0 {
+		os.Exit(1)
+	}
+}
@@ -12,7 +12,7 @@ import (
 )
 
 /*
-`ConnectMongo` connects to the MongoDB database and returns a client instance.
+`ConnectMongo` connects to the MongoDB database, sets `DB` to the client instance and returns it.
 */
 func ConnectMongo() *mongo.Client {
 	client, err := mongo.NewClient(options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
@@ -35,11 +35,12 @@ func ConnectMongo() *mongo.Client {
 	defer cancel()
 
 	fmt.Println("Connected to MongoDB")
+	DB = client
 	return client
 }
 
-// client instance
-var DB *mongo.Client = ConnectMongo()
+// client instance, set by `ConnectMongo` when the server starts (so that packages can be imported without a database, e.g. by tests)
+var DB *mongo.Client
 
 /*
 `GetCollections` returns a collection instance from the database given the collection name.
@@ -0,0 +1,313 @@
+package docs
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"nbc-backend-api-v2/requests"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+)
+
+/*
+`Document` is the root object of an OpenAPI 3 specification.
+*/
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+/*
+`PathItem` holds the operations of a single path, keyed by the lowercase HTTP method (e.g. "get").
+*/
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Style    string  `json:"style,omitempty"`
+	Explode  *bool   `json:"explode,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// converts Fiber route params (`:stakingPoolId`) into OpenAPI path params (`{stakingPoolId}`).
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// the name of the security scheme for routes that require the `session-token` header.
+const sessionTokenScheme = "sessionToken"
+
+/*
+Returns the OpenAPI path of the Fiber path `path`, e.g. `/v1/pools/{stakingPoolId}` for `/v1/pools/:stakingPoolId`.
+*/
+func OpenAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+/*
+Generates the OpenAPI 3 specification from every route registered with `Register`.
+
+If a method and path is registered more than once, only the first registration is documented, since that's the one Fiber serves.
+*/
+func Spec() *Document {
+	builder := &schemaBuilder{components: map[string]*Schema{}}
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "NBC Webapp API",
+			Description: "Every response is wrapped in `{status, code, message, data}`. `code` is only set for errors and is a stable, machine-readable error code.",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]*PathItem{},
+		Components: Components{
+			Schemas: builder.components,
+			Responses: map[string]*Response{
+				"Error": {
+					Description: "the request failed. `code` is a stable error code (e.g. `POOL_NOT_FOUND`).",
+					Content:     jsonContent(errorSchema(&Schema{Nullable: true})),
+				},
+				"ValidationError": {
+					Description: "one or more request fields are invalid (`VALIDATION_FAILED`).",
+					Content: jsonContent(errorSchema(&Schema{
+						Type:       "object",
+						Properties: map[string]*Schema{"errors": {Type: "array", Items: builder.schemaFor(reflect.TypeOf(requests.FieldError{}))}},
+					})),
+				},
+			},
+			SecuritySchemes: map[string]*SecurityScheme{
+				sessionTokenScheme: {
+					Type: "apiKey",
+					In:   "header",
+					Name: requests.SessionTokenHeader,
+					Description: "the session token of the webapp, or a token signed by the wallet itself (e.g. a Safe multisig through EIP-1271): " +
+						"`" + UtilsNFT.SignedSessionPrefix + "<wallet>:<nonce>:<signature of the EIP-712 SignIn message>`, " +
+						"where the nonce and the SignIn message are issued by `POST /v1/stakers/{wallet}/sign-in`.",
+				},
+			},
+		},
+	}
+
+	tags := map[string]bool{}
+	for _, route := range Routes() {
+		if route.Hidden {
+			continue
+		}
+
+		path := OpenAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		if _, exists := (*item)[method]; exists {
+			continue
+		}
+
+		(*item)[method] = builder.operation(route)
+
+		for _, tag := range route.Tags {
+			if !tags[tag] {
+				tags[tag] = true
+				doc.Tags = append(doc.Tags, Tag{Name: tag})
+			}
+		}
+	}
+
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+
+	return doc
+}
+
+/*
+Builds the OpenAPI operation for `route`.
+*/
+func (b *schemaBuilder) operation(route Route) *Operation {
+	op := &Operation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		OperationID: operationID(route),
+		Tags:        route.Tags,
+		Deprecated:  route.Deprecated,
+		Responses: map[string]*Response{
+			"200":     {Description: "success", Content: jsonContent(b.successSchema(route))},
+			"default": {Ref: "#/components/responses/Error"},
+		},
+	}
+
+	if route.Request != nil {
+		b.addRequest(op, reflect.TypeOf(route.Request))
+		op.Responses["422"] = &Response{Ref: "#/components/responses/ValidationError"}
+	}
+
+	return op
+}
+
+/*
+Adds the params, query params, headers and JSON body fields of the request struct type `t` to `op`.
+*/
+func (b *schemaBuilder) addRequest(op *Operation, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	body := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	b.addRequestFields(op, body, t)
+
+	if len(body.Properties) > 0 {
+		op.RequestBody = &RequestBody{Required: true, Content: jsonContent(body)}
+	}
+}
+
+func (b *schemaBuilder) addRequestFields(op *Operation, body *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			b.addRequestFields(op, body, field.Type)
+			continue
+		}
+
+		schema := b.schemaFor(field.Type)
+		applyTags(schema, field)
+		required := hasRule(field.Tag.Get("validate"), "required")
+
+		if name := field.Tag.Get("param"); name != "" {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+		} else if name := field.Tag.Get("query"); name != "" {
+			param := &Parameter{Name: name, In: "query", Required: required, Schema: schema}
+			// lists of IDs are sent comma-separated (e.g. `keyIds=1,25,300`).
+			if schema.Type == "array" {
+				explode := false
+				param.Style, param.Explode = "form", &explode
+			}
+			op.Parameters = append(op.Parameters, param)
+		} else if name := field.Tag.Get("header"); name != "" {
+			if name == requests.SessionTokenHeader {
+				op.Security = []map[string][]string{{sessionTokenScheme: {}}}
+				continue
+			}
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "header", Required: required, Schema: schema})
+		} else if name, _, skip := jsonName(field); !skip {
+			body.Properties[name] = schema
+			if required {
+				body.Required = append(body.Required, name)
+			}
+		}
+	}
+}
+
+/*
+Returns the schema of a successful response for `route`.
+*/
+func (b *schemaBuilder) successSchema(route Route) *Schema {
+	data := &Schema{Nullable: true}
+	if route.Response != nil && route.DataKey != "" {
+		data = &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{route.DataKey: b.schemaFor(reflect.TypeOf(route.Response))},
+			Required:   []string{route.DataKey},
+		}
+	}
+
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":  {Type: "integer", Example: 200},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"status", "message", "data"},
+	}
+}
+
+/*
+Returns the schema of an error response with the given `data` schema.
+*/
+func errorSchema(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":  {Type: "integer", Example: 404},
+			"code":    {Type: "string", Example: "POOL_NOT_FOUND"},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"status", "code", "message"},
+	}
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+/*
+Builds a unique operation ID from the method and path, e.g. `getKosFetchMetadataTokenId`.
+*/
+func operationID(route Route) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(route.Method))
+
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '-' || r == '_'
+	}) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return sb.String()
+}
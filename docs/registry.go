@@ -0,0 +1,79 @@
+package docs
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+`Route` describes a single API route so that it can be documented in the OpenAPI specification.
+*/
+type Route struct {
+	Method      string      // the HTTP method, e.g. `fiber.MethodGet`
+	Path        string      // the full Fiber path, e.g. `/kos/fetch-metadata/:tokenId`
+	Summary     string      // a short summary of what the route does
+	Description string      // (optional) a longer description of the route
+	Tags        []string    // groups the route in the Swagger UI (e.g. "Staking")
+	Request     interface{} // (optional) the request struct from `requests` (with `param`, `query`, `header` and `json` tags)
+	DataKey     string      // the key the result is stored under in the response's `data`, e.g. "inventory"
+	Response    interface{} // (optional) a value of the type stored under `DataKey`. `data` is null if nil
+	Deprecated  bool        // whether the route is deprecated
+	Hidden      bool        // whether the route is left out of the specification (e.g. the docs routes themselves)
+}
+
+var (
+	mu     sync.Mutex
+	routes []Route
+)
+
+/*
+Registers `handler` on `router` and adds `route` to the routes documented in the OpenAPI specification.
+
+`route.Path` must be the full path of the route (including the prefix of `router` if it's a group).
+*/
+func Register(router fiber.Router, route Route, handler fiber.Handler) fiber.Router {
+	mu.Lock()
+	routes = append(routes, route)
+	mu.Unlock()
+
+	return router.Add(route.Method, route.Path, handler)
+}
+
+/*
+Returns a copy of all registered routes, in registration order.
+*/
+func Routes() []Route {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registered := make([]Route, len(routes))
+	copy(registered, routes)
+	return registered
+}
+
+/*
+Returns every route registered on `app` (as "METHOD /path") that is missing from the OpenAPI specification.
+
+Middleware (`app.Use`) and the `HEAD` routes Fiber adds for every `GET` route are ignored.
+*/
+func MissingRoutes(app *fiber.App) []string {
+	documented := map[string]bool{}
+	for _, route := range Routes() {
+		documented[route.Method+" "+route.Path] = true
+	}
+
+	var missing []string
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		key := route.Method + " " + route.Path
+		if !documented[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,290 @@
+package docs
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+`Schema` is an OpenAPI 3 schema object. Only the fields used by this API are included.
+*/
+type Schema struct {
+	Ref              string             `json:"$ref,omitempty"`
+	Type             string             `json:"type,omitempty"`
+	Format           string             `json:"format,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	Enum             []interface{}      `json:"enum,omitempty"`
+	Minimum          *float64           `json:"minimum,omitempty"`
+	Maximum          *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum bool               `json:"exclusiveMinimum,omitempty"`
+	MinItems         *int               `json:"minItems,omitempty"`
+	MaxItems         *int               `json:"maxItems,omitempty"`
+	UniqueItems      bool               `json:"uniqueItems,omitempty"`
+	Pattern          string             `json:"pattern,omitempty"`
+	Default          interface{}        `json:"default,omitempty"`
+	Example          interface{}        `json:"example,omitempty"`
+	Nullable         bool               `json:"nullable,omitempty"`
+}
+
+// the pattern every wallet (`wallet` validate tag) must match.
+const walletPattern = "^0x[0-9a-fA-F]{40}$"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	addressType  = reflect.TypeOf(common.Address{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+)
+
+/*
+Builds schemas from Go types. Named struct types are added to `components` once and referenced with `$ref` afterwards.
+*/
+type schemaBuilder struct {
+	components map[string]*Schema
+}
+
+/*
+Returns the schema for `t`, following the same rules as `encoding/json` (json tags, `omitempty`, embedded structs are flattened).
+*/
+func (b *schemaBuilder) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$", Example: "64c8f1a2e4b0a1b2c3d4e5f6"}
+	case addressType:
+		return &Schema{Type: "string", Pattern: walletPattern}
+	case bigIntType:
+		return &Schema{Type: "integer"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		// anonymous structs are inlined, named structs are stored in `components` and referenced.
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			// reserve the name first so that recursive types don't loop forever.
+			b.components[t.Name()] = &Schema{}
+			*b.components[t.Name()] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	// `interface{}` and anything else can be any value.
+	return &Schema{}
+}
+
+/*
+Returns the object schema of the struct type `t`.
+*/
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	b.addFields(schema, t)
+	return schema
+}
+
+/*
+Adds every exported field of the struct type `t` to `schema`, flattening embedded structs like `encoding/json` does.
+*/
+func (b *schemaBuilder) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(schema, embedded)
+				continue
+			}
+		}
+
+		fieldSchema := b.schemaFor(field.Type)
+		applyTags(fieldSchema, field)
+		schema.Properties[name] = fieldSchema
+
+		if !omitempty && hasRule(field.Tag.Get("validate"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+/*
+Returns the name `encoding/json` uses for `field`, whether it's `omitempty` and whether it's skipped entirely.
+*/
+func jsonName(field reflect.StructField) (name string, omitempty, skip bool) {
+	if !field.IsExported() {
+		return "", false, true
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+
+	return name, omitempty, false
+}
+
+/*
+Applies the `example`, `default` and `validate` tags of `field` to `schema`.
+*/
+func applyTags(schema *Schema, field reflect.StructField) {
+	// a `$ref` cannot have siblings in OpenAPI 3.0, so referenced schemas are left untouched.
+	if schema.Ref != "" {
+		return
+	}
+
+	if example, ok := field.Tag.Lookup("example"); ok {
+		schema.Example = parseTagValue(schema, example)
+	}
+	if def, ok := field.Tag.Lookup("default"); ok {
+		schema.Default = parseTagValue(schema, def)
+	}
+
+	applyValidation(schema, field.Tag.Get("validate"))
+}
+
+/*
+Converts a raw tag value into the type `schema` describes so that it's rendered correctly in the spec (e.g. `25` instead of `"25"`).
+*/
+func parseTagValue(schema *Schema, raw string) interface{} {
+	if schema.Type == "string" {
+		return raw
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return raw
+	}
+
+	return value
+}
+
+/*
+Maps the `validate` tag rules (see `requests`) onto the schema's constraints.
+Rules after `dive` apply to the items of an array.
+*/
+func applyValidation(schema *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+
+	target := schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "dive":
+			if target.Items == nil {
+				return
+			}
+			target = target.Items
+		case "wallet":
+			target.Pattern = walletPattern
+		case "keyid":
+			target.Minimum = float(1)
+			target.Maximum = float(UtilsKOS.KOSCollectionSize)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			if target.Type == "array" {
+				if name == "min" {
+					target.MinItems = &n
+				} else {
+					target.MaxItems = &n
+				}
+			} else if name == "min" {
+				target.Minimum = float(n)
+			} else {
+				target.Maximum = float(n)
+			}
+		case "gt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				target.Minimum = &n
+				target.ExclusiveMinimum = true
+			}
+		case "ne":
+			target.Description = strings.TrimSpace(target.Description + " must not be " + param + ".")
+		case "oneof":
+			for _, option := range strings.Fields(param) {
+				target.Enum = append(target.Enum, parseTagValue(target, option))
+			}
+		case "unique":
+			target.UniqueItems = true
+		}
+	}
+}
+
+/*
+Checks if the comma-separated `validate` tag contains the rule `name` (before any `dive`).
+*/
+func hasRule(tag, name string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			return false
+		}
+		if rule == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func float(n int) *float64 {
+	f := float64(n)
+	return &f
+}
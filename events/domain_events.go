@@ -0,0 +1,186 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+`EventType` is the type of a `DomainEvent`. It's stored with the event in `RHOutbox` and used to route it to subscribers.
+*/
+type EventType string
+
+const (
+	EventStakingPoolCreated EventType = "stakingPoolCreated" // a new staking pool was added
+	EventSubpoolStaked      EventType = "subpoolStaked"      // a staker staked their keys in a new subpool
+	EventSubpoolUnstaked    EventType = "subpoolUnstaked"    // a staker unstaked a subpool before the pool started
+	EventSubpoolBanned      EventType = "subpoolBanned"      // a subpool was banned (e.g. the staker no longer owns the keys)
+	EventPoolClosed         EventType = "poolClosed"         // the pool ended and its active subpools were moved to the closed subpools
+	EventRewardClaimed      EventType = "rewardClaimed"      // a staker claimed the reward of a closed subpool
+	EventRewardExpired      EventType = "rewardExpired"      // the reward of a closed subpool can no longer be claimed
+	EventTotalPointsChanged EventType = "totalPointsChanged" // the total yield points of a staking pool changed
+	EventPoolPhaseChanged   EventType = "poolPhaseChanged"   // a staking pool's entry opened, it started or it ended
+)
+
+/*
+`DomainEvent` is a state change in the staking pools, written to `RHOutbox` together with the change itself.
+Each event type has its own struct (e.g. `SubpoolStakedEvent`).
+*/
+type DomainEvent interface {
+	EventType() EventType
+}
+
+/*
+Emitted by `AddStakingPool`.
+*/
+type StakingPoolCreatedEvent struct {
+	StakingPoolID  int       `bson:"stakingPoolID" json:"stakingPoolId"`
+	RewardName     string    `bson:"rewardName" json:"rewardName"`
+	RewardAmount   float64   `bson:"rewardAmount" json:"rewardAmount"`
+	EntryAllowance time.Time `bson:"entryAllowance" json:"entryAllowance"`
+	StartTime      time.Time `bson:"startTime" json:"startTime"`
+	EndTime        time.Time `bson:"endTime" json:"endTime"`
+}
+
+/*
+Emitted by `AddSubpool`.
+*/
+type SubpoolStakedEvent struct {
+	StakingPoolID      int     `bson:"stakingPoolID" json:"stakingPoolId"`
+	SubpoolID          int     `bson:"subpoolID" json:"subpoolId"`
+	StakerWallet       string  `bson:"stakerWallet" json:"stakerWallet"`
+	KeyIDs             []int   `bson:"keyIDs" json:"keyIds"`
+	KeychainIDs        []int   `bson:"keychainIDs" json:"keychainIds"`
+	SuperiorKeychainID int     `bson:"superiorKeychainID" json:"superiorKeychainId"`
+	SubpoolPoints      float64 `bson:"subpoolPoints" json:"subpoolPoints"`
+}
+
+/*
+Emitted by `UnstakeFromSubpool`, and once for every unstaked subpool by `UnstakeFromStakingPool`.
+*/
+type SubpoolUnstakedEvent struct {
+	StakingPoolID int    `bson:"stakingPoolID" json:"stakingPoolId"`
+	SubpoolID     int    `bson:"subpoolID" json:"subpoolId"`
+	StakerWallet  string `bson:"stakerWallet" json:"stakerWallet"`
+}
+
+/*
+Emitted by `BanSubpool`.
+*/
+type SubpoolBannedEvent struct {
+	StakingPoolID int    `bson:"stakingPoolID" json:"stakingPoolId"`
+	SubpoolID     int    `bson:"subpoolID" json:"subpoolId"`
+	StakerWallet  string `bson:"stakerWallet" json:"stakerWallet"`
+}
+
+/*
+Emitted by `CloseSubpoolsOnStakeEnd` for every staking pool whose active subpools were closed.
+*/
+type PoolClosedEvent struct {
+	StakingPoolID    int   `bson:"stakingPoolID" json:"stakingPoolId"`
+	ClosedSubpoolIDs []int `bson:"closedSubpoolIDs" json:"closedSubpoolIds"`
+}
+
+/*
+Emitted by `ClaimReward`.
+*/
+type RewardClaimedEvent struct {
+	StakingPoolID int     `bson:"stakingPoolID" json:"stakingPoolId"`
+	SubpoolID     int     `bson:"subpoolID" json:"subpoolId"`
+	StakerWallet  string  `bson:"stakerWallet" json:"stakerWallet"`
+	RewardName    string  `bson:"rewardName" json:"rewardName"`
+	Amount        float64 `bson:"amount" json:"amount"`
+}
+
+/*
+Emitted by `RemoveExpiredUnclaimableSubpools` for every subpool whose reward wasn't claimed in time.
+*/
+type RewardExpiredEvent struct {
+	StakingPoolID int    `bson:"stakingPoolID" json:"stakingPoolId"`
+	SubpoolID     int    `bson:"subpoolID" json:"subpoolId"`
+	StakerWallet  string `bson:"stakerWallet" json:"stakerWallet"`
+}
+
+/*
+Emitted by every write that changes the subpools of a staking pool (staking, unstaking, bans and closing), when it changes the pool's `TotalYieldPoints`.
+*/
+type TotalPointsChangedEvent struct {
+	StakingPoolID    int     `bson:"stakingPoolID" json:"stakingPoolId"`
+	TotalYieldPoints float64 `bson:"totalYieldPoints" json:"totalYieldPoints"`
+}
+
+/*
+Emitted once by `PublishPoolPhaseTransitions` for every phase transition of a staking pool.
+*/
+type PoolPhaseChangedEvent struct {
+	StakingPoolID int           `bson:"stakingPoolID" json:"stakingPoolId"`
+	Phase         PoolEventType `bson:"phase" json:"phase"` // `PoolEntryOpened`, `PoolStarted` or `PoolEnded`
+	Time          time.Time     `bson:"time" json:"time"`   // when the transition happened
+}
+
+func (StakingPoolCreatedEvent) EventType() EventType { return EventStakingPoolCreated }
+func (SubpoolStakedEvent) EventType() EventType      { return EventSubpoolStaked }
+func (SubpoolUnstakedEvent) EventType() EventType    { return EventSubpoolUnstaked }
+func (SubpoolBannedEvent) EventType() EventType      { return EventSubpoolBanned }
+func (PoolClosedEvent) EventType() EventType         { return EventPoolClosed }
+func (RewardClaimedEvent) EventType() EventType      { return EventRewardClaimed }
+func (RewardExpiredEvent) EventType() EventType      { return EventRewardExpired }
+func (TotalPointsChangedEvent) EventType() EventType { return EventTotalPointsChanged }
+func (PoolPhaseChangedEvent) EventType() EventType   { return EventPoolPhaseChanged }
+
+// creates an empty event of each type, used to decode the payloads stored in `RHOutbox`.
+var eventFactories = map[EventType]func() DomainEvent{
+	EventStakingPoolCreated: func() DomainEvent { return &StakingPoolCreatedEvent{} },
+	EventSubpoolStaked:      func() DomainEvent { return &SubpoolStakedEvent{} },
+	EventSubpoolUnstaked:    func() DomainEvent { return &SubpoolUnstakedEvent{} },
+	EventSubpoolBanned:      func() DomainEvent { return &SubpoolBannedEvent{} },
+	EventPoolClosed:         func() DomainEvent { return &PoolClosedEvent{} },
+	EventRewardClaimed:      func() DomainEvent { return &RewardClaimedEvent{} },
+	EventRewardExpired:      func() DomainEvent { return &RewardExpiredEvent{} },
+	EventTotalPointsChanged: func() DomainEvent { return &TotalPointsChangedEvent{} },
+	EventPoolPhaseChanged:   func() DomainEvent { return &PoolPhaseChangedEvent{} },
+}
+
+/*
+Returns every domain event type.
+*/
+func EventTypes() []EventType {
+	return []EventType{
+		EventStakingPoolCreated,
+		EventSubpoolStaked,
+		EventSubpoolUnstaked,
+		EventSubpoolBanned,
+		EventPoolClosed,
+		EventRewardClaimed,
+		EventRewardExpired,
+		EventTotalPointsChanged,
+		EventPoolPhaseChanged,
+	}
+}
+
+/*
+Checks whether `eventType` is a known domain event type.
+*/
+func KnownEventType(eventType EventType) bool {
+	_, ok := eventFactories[eventType]
+	return ok
+}
+
+/*
+Decodes the payload of `event` into its typed struct (e.g. `*SubpoolStakedEvent` for `EventSubpoolStaked`).
+*/
+func (event *OutboxEvent) Decode() (DomainEvent, error) {
+	newEvent, ok := eventFactories[event.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", event.Type)
+	}
+
+	domainEvent := newEvent()
+	if err := bson.Unmarshal(event.Payload, domainEvent); err != nil {
+		return nil, fmt.Errorf("unable to decode %s event %s: %w", event.Type, event.ID.Hex(), err)
+	}
+
+	return domainEvent, nil
+}
@@ -0,0 +1,310 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the collection that domain events are written to.
+const OutboxCollection = "RHOutbox"
+
+const (
+	dispatchInterval  = 5 * time.Second  // how often the outbox is polled (it's also polled right after an event is written)
+	dispatchLease     = time.Minute      // how long a claimed event is hidden from other dispatchers while its handlers run
+	handlerTimeout    = 30 * time.Second // how long a single handler may take for a single event
+	initialRetryDelay = 5 * time.Second  // doubles after every failed attempt
+	maxRetryDelay     = time.Hour
+	maxEventsPerRun   = 100 // the max number of events dispatched per `DispatchOutbox` call; the rest are dispatched in the next run
+)
+
+/*
+`OutboxEvent` is a `DomainEvent` as it's stored in `RHOutbox`.
+*/
+type OutboxEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type          EventType          `bson:"type" json:"type"`
+	Payload       bson.Raw           `bson:"payload" json:"-"` // the typed event, see `Decode`
+	OccurredAt    time.Time          `bson:"occurredAt" json:"occurredAt"`
+	DispatchedAt  *time.Time         `bson:"dispatchedAt" json:"-"`        // nil until every subscriber handled the event
+	NextAttemptAt time.Time          `bson:"nextAttemptAt" json:"-"`       // the event isn't dispatched before this time
+	Attempts      int                `bson:"attempts" json:"-"`            // the number of failed dispatch attempts
+	Delivered     []string           `bson:"delivered,omitempty" json:"-"` // the subscribers that already handled the event
+	LastError     string             `bson:"lastError,omitempty" json:"-"` // the errors of the last failed attempt
+}
+
+/*
+Creates the `OutboxEvent` for `event`, ready to be inserted.
+*/
+func NewOutboxEvent(event DomainEvent) (*OutboxEvent, error) {
+	payload, err := bson.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode %s event: %w", event.EventType(), err)
+	}
+
+	now := time.Now()
+	return &OutboxEvent{
+		ID:            primitive.NewObjectID(),
+		Type:          event.EventType(),
+		Payload:       payload,
+		OccurredAt:    now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+/*
+Runs `fn` and writes the domain events it returns to `RHOutbox` in a single MongoDB transaction,
+so that either both the state change and its events are written or neither are.
+
+Every write in `fn` must use the given `ctx` to be part of the transaction.
+`fn` may be run more than once if the transaction is retried, so it must not have any side effects outside of the database.
+Transactions require MongoDB to be run as a replica set (which Atlas always is).
+*/
+func WithOutbox(db *mongo.Database, fn func(ctx mongo.SessionContext) ([]DomainEvent, error)) error {
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(ctx mongo.SessionContext) (interface{}, error) {
+		domainEvents, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(domainEvents) == 0 {
+			return nil, nil
+		}
+
+		outboxEvents := make([]interface{}, len(domainEvents))
+		for i, domainEvent := range domainEvents {
+			outboxEvent, err := NewOutboxEvent(domainEvent)
+			if err != nil {
+				return nil, err
+			}
+			outboxEvents[i] = outboxEvent
+		}
+
+		_, err = db.Collection(OutboxCollection).InsertMany(ctx, outboxEvents)
+		return nil, err
+	})
+	if err != nil {
+		return err
+	}
+
+	// dispatch the new events now instead of waiting for the next poll.
+	select {
+	case dispatchNow <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+/*
+`Handler` handles a single event. Returning an error makes the dispatcher retry the event later (for this handler only).
+
+Events are delivered at least once: a handler may receive the same event more than once (e.g. if the dispatcher crashes
+right after the handler returned), so handlers must be idempotent, e.g. by using `event.ID` as a deduplication key.
+Events aren't guaranteed to be delivered in order.
+*/
+type Handler func(ctx context.Context, event *OutboxEvent) error
+
+type subscriber struct {
+	name   string
+	types  map[EventType]bool // nil for every event type
+	handle Handler
+}
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   []*subscriber
+
+	dispatchNow = make(chan struct{}, 1)
+)
+
+/*
+Subscribes `handle` to the domain events of `types` (or every event if no types are given).
+
+`name` identifies the subscriber in `RHOutbox` (to keep track of which subscribers handled an event),
+so it must be unique and must not change between deployments. Subscribers should be added at startup,
+before `StartOutboxDispatcher` is called; events dispatched before a subscriber is added aren't delivered to it.
+*/
+func Subscribe(name string, handle Handler, types ...EventType) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	for _, sub := range subscribers {
+		if sub.name == name {
+			panic(fmt.Sprintf("events: subscriber %q is already subscribed", name))
+		}
+	}
+
+	sub := &subscriber{name: name, handle: handle}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]bool, len(types))
+		for _, eventType := range types {
+			sub.types[eventType] = true
+		}
+	}
+	subscribers = append(subscribers, sub)
+}
+
+/*
+Starts dispatching the events in `collection` (must be RHOutbox) to the subscribers in the background.
+*/
+func StartOutboxDispatcher(collection *mongo.Collection) {
+	go func() {
+		ticker := time.NewTicker(dispatchInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := DispatchOutbox(collection); err != nil {
+				log.Printf("unable to dispatch the outbox events: %v", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-dispatchNow:
+			}
+		}
+	}()
+}
+
+/*
+Dispatches every due event in `collection` (must be RHOutbox) to its subscribers.
+
+Each event is claimed before it's dispatched, so more than one instance of the API can dispatch the same outbox.
+If a subscriber fails, the event is retried later with an exponential backoff, but only for the subscribers that failed.
+*/
+func DispatchOutbox(collection *mongo.Collection) error {
+	if collection.Name() != OutboxCollection {
+		return fmt.Errorf("collection must be %s", OutboxCollection)
+	}
+
+	for i := 0; i < maxEventsPerRun; i++ {
+		event, err := claimOutboxEvent(collection)
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			return nil
+		}
+
+		if err := dispatchOutboxEvent(collection, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Claims the oldest due event by pushing its `NextAttemptAt` back by `dispatchLease`. Returns nil if no event is due.
+If the dispatcher stops before the event is dispatched, the event is claimed again once the lease expires.
+*/
+func claimOutboxEvent(collection *mongo.Collection) (*OutboxEvent, error) {
+	now := time.Now()
+
+	filter := bson.M{"dispatchedAt": nil, "nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"nextAttemptAt": now.Add(dispatchLease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"occurredAt": 1}).SetReturnDocument(options.After)
+
+	var event OutboxEvent
+	if err := collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&event); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+/*
+Delivers `event` to every subscriber that hasn't handled it yet and records the result.
+*/
+func dispatchOutboxEvent(collection *mongo.Collection, event *OutboxEvent) error {
+	delivered := make(map[string]bool, len(event.Delivered))
+	for _, name := range event.Delivered {
+		delivered[name] = true
+	}
+
+	subscribersMu.RLock()
+	subs := subscribers
+	subscribersMu.RUnlock()
+
+	var handled []string
+	var failures []string
+	for _, sub := range subs {
+		if delivered[sub.name] || (sub.types != nil && !sub.types[event.Type]) {
+			continue
+		}
+
+		if err := handleOutboxEvent(sub, event); err != nil {
+			log.Printf("subscriber %s failed to handle %s event %s: %v", sub.name, event.Type, event.ID.Hex(), err)
+			failures = append(failures, fmt.Sprintf("%s: %v", sub.name, err))
+			continue
+		}
+		handled = append(handled, sub.name)
+	}
+
+	update := bson.M{}
+	if len(handled) > 0 {
+		update["$addToSet"] = bson.M{"delivered": bson.M{"$each": handled}}
+	}
+
+	if len(failures) == 0 {
+		update["$set"] = bson.M{"dispatchedAt": time.Now()}
+		update["$unset"] = bson.M{"lastError": ""}
+	} else {
+		update["$set"] = bson.M{
+			"nextAttemptAt": time.Now().Add(retryDelay(event.Attempts)),
+			"lastError":     strings.Join(failures, "; "),
+		}
+		update["$inc"] = bson.M{"attempts": 1}
+	}
+
+	_, err := collection.UpdateOne(context.Background(), bson.M{"_id": event.ID}, update)
+	return err
+}
+
+/*
+Calls `sub.handle`, turning a panic into an error so that a single bad handler can't stop the dispatcher.
+*/
+func handleOutboxEvent(sub *subscriber, event *OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+	defer cancel()
+
+	return sub.handle(ctx, event)
+}
+
+/*
+Returns how long to wait before retrying an event that has already failed `attempts` times.
+*/
+func retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 0; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
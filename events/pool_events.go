@@ -0,0 +1,243 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+`PoolEventType` is the type of a `PoolEvent`, sent to clients as the event name.
+*/
+type PoolEventType string
+
+const (
+	SubpoolAdded       PoolEventType = "subpoolAdded"       // a staker added a subpool to the pool
+	SubpoolUnstaked    PoolEventType = "subpoolUnstaked"    // a staker unstaked one (or all) of their subpools
+	SubpoolBanned      PoolEventType = "subpoolBanned"      // a subpool was banned (e.g. the staker no longer owns the keys)
+	TotalPointsChanged PoolEventType = "totalPointsChanged" // the pool's `TotalYieldPoints` changed
+	PoolEntryOpened    PoolEventType = "poolEntryOpened"    // stakers are allowed to enter the pool (`EntryAllowance` passed)
+	PoolStarted        PoolEventType = "poolStarted"        // staking started, entry is no longer allowed (`StartTime` passed)
+	PoolEnded          PoolEventType = "poolEnded"          // the pool closed (`EndTime` passed)
+)
+
+/*
+`PoolEvent` is something that happened in a staking pool. Only the fields relevant to `Type` are set.
+*/
+type PoolEvent struct {
+	Type             PoolEventType `json:"type" example:"subpoolAdded"`
+	StakingPoolID    int           `json:"stakingPoolId" example:"3"`
+	SubpoolID        int           `json:"subpoolId,omitempty" example:"12"`                                            // set for subpool events
+	StakerWallet     string        `json:"stakerWallet,omitempty" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"` // set for subpool events
+	SubpoolPoints    float64       `json:"subpoolPoints,omitempty" example:"623.86"`                                    // set for `SubpoolAdded`
+	TotalYieldPoints float64       `json:"totalYieldPoints,omitempty" example:"18234.5"`                                // set for `TotalPointsChanged`
+	Time             time.Time     `json:"time"`                                                                        // when the event happened
+}
+
+// the number of events buffered for each subscriber. events are dropped for subscribers that fall further behind.
+const subscriberBuffer = 64
+
+/*
+`PoolSubscription` receives the events of a single staking pool (or every pool) on `C` until `Close` is called.
+*/
+type PoolSubscription struct {
+	C <-chan PoolEvent
+
+	ch            chan PoolEvent
+	stakingPoolId int
+	closeOnce     sync.Once
+}
+
+var (
+	poolMu          sync.RWMutex
+	poolSubscribers = map[*PoolSubscription]struct{}{}
+)
+
+/*
+Subscribes to the events of staking pool `stakingPoolId`. Use 0 to subscribe to the events of every staking pool.
+The subscription must be closed once it's no longer needed.
+*/
+func SubscribePool(stakingPoolId int) *PoolSubscription {
+	ch := make(chan PoolEvent, subscriberBuffer)
+	sub := &PoolSubscription{C: ch, ch: ch, stakingPoolId: stakingPoolId}
+
+	poolMu.Lock()
+	poolSubscribers[sub] = struct{}{}
+	poolMu.Unlock()
+
+	return sub
+}
+
+/*
+Unsubscribes and closes `C`. Safe to call more than once.
+*/
+func (s *PoolSubscription) Close() {
+	s.closeOnce.Do(func() {
+		poolMu.Lock()
+		delete(poolSubscribers, s)
+		poolMu.Unlock()
+
+		close(s.ch)
+	})
+}
+
+/*
+Publishes `event` to every subscriber of its staking pool. `Time` defaults to now.
+
+Publishing never blocks: if a subscriber's buffer is full, the event is dropped for that subscriber.
+Events are only kept in memory, so clients should refetch the pool data after reconnecting.
+*/
+func PublishPool(event PoolEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+
+	for sub := range poolSubscribers {
+		if sub.stakingPoolId != 0 && sub.stakingPoolId != event.StakingPoolID {
+			continue
+		}
+
+		select {
+		case sub.ch <- event:
+		default:
+			log.Printf("dropped %s event of staking pool %d for a slow subscriber", event.Type, event.StakingPoolID)
+		}
+	}
+}
+
+/*
+Returns the pool event of a domain event that changes a staking pool's subpools (staked, unstaked or banned),
+its total points or its phase. Returns false for every other domain event.
+*/
+func PoolEventFromDomainEvent(event DomainEvent, occurredAt time.Time) (PoolEvent, bool) {
+	switch e := event.(type) {
+	case *SubpoolStakedEvent:
+		return PoolEvent{Type: SubpoolAdded, StakingPoolID: e.StakingPoolID, SubpoolID: e.SubpoolID, StakerWallet: e.StakerWallet, SubpoolPoints: e.SubpoolPoints, Time: occurredAt}, true
+	case *SubpoolUnstakedEvent:
+		return PoolEvent{Type: SubpoolUnstaked, StakingPoolID: e.StakingPoolID, SubpoolID: e.SubpoolID, StakerWallet: e.StakerWallet, Time: occurredAt}, true
+	case *SubpoolBannedEvent:
+		return PoolEvent{Type: SubpoolBanned, StakingPoolID: e.StakingPoolID, SubpoolID: e.SubpoolID, StakerWallet: e.StakerWallet, Time: occurredAt}, true
+	case *TotalPointsChangedEvent:
+		return PoolEvent{Type: TotalPointsChanged, StakingPoolID: e.StakingPoolID, TotalYieldPoints: e.TotalYieldPoints, Time: occurredAt}, true
+	case *PoolPhaseChangedEvent:
+		return PoolEvent{Type: e.Phase, StakingPoolID: e.StakingPoolID, Time: e.Time}, true
+	}
+
+	return PoolEvent{}, false
+}
+
+// the domain events that the pool stream pushes to the pools' subscribers, see `PoolEventFromDomainEvent`.
+var poolStreamTypes = []EventType{EventSubpoolStaked, EventSubpoolUnstaked, EventSubpoolBanned, EventTotalPointsChanged, EventPoolPhaseChanged}
+
+// how long to wait before reopening the pool stream after an error.
+const poolStreamRetryDelay = 5 * time.Second
+
+/*
+`changeStream` is the part of a `*mongo.ChangeStream` that the pool stream reads, so that it can be replaced in tests.
+*/
+type changeStream interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	ResumeToken() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
+}
+
+/*
+Opens a change stream of the events inserted into the outbox, starting after `resumeAfter` (or now if it's nil).
+*/
+type watchFunc func(ctx context.Context, resumeAfter bson.Raw) (changeStream, error)
+
+/*
+Starts pushing the pool events written to `collection` (must be RHOutbox) to the pools' subscribers in the background,
+so that they come from the same outbox events as the webhooks and notifications.
+
+Every instance of the API watches the outbox itself (rather than subscribing to the dispatcher, which hands each event
+to a single instance), so that the clients of every instance receive every event.
+*/
+func StartPoolStream(collection *mongo.Collection) {
+	go streamPoolEvents(context.Background(), watchOutbox(collection), PublishPool)
+}
+
+func watchOutbox(collection *mongo.Collection) watchFunc {
+	return func(ctx context.Context, resumeAfter bson.Raw) (changeStream, error) {
+		match := bson.D{
+			{Key: "operationType", Value: "insert"},
+			{Key: "fullDocument.type", Value: bson.D{{Key: "$in", Value: poolStreamTypes}}},
+		}
+		opts := options.ChangeStream()
+		if resumeAfter != nil {
+			opts.SetResumeAfter(resumeAfter)
+		}
+
+		return collection.Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}}, opts)
+	}
+}
+
+/*
+Publishes the pool events of the outbox until `ctx` is done, reopening the change stream where it stopped after an error.
+If it can't be reopened there (e.g. the oplog no longer has the events), it's reopened from now; clients refetch the pool data after reconnecting anyway.
+*/
+func streamPoolEvents(ctx context.Context, watch watchFunc, publish func(PoolEvent)) {
+	var resumeToken bson.Raw
+	for ctx.Err() == nil {
+		next, err := readPoolEvents(ctx, watch, resumeToken, publish)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("pool stream stopped: %v", err)
+			if bytes.Equal(next, resumeToken) {
+				// nothing was read since the stream was reopened, so it may not be resumable from there.
+				next = nil
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(poolStreamRetryDelay):
+			}
+		}
+		resumeToken = next
+	}
+}
+
+/*
+Reads the change stream opened after `resumeAfter` until it ends, publishing the pool event of every outbox event.
+Returns the resume token of the last event read (`resumeAfter` if there were none).
+*/
+func readPoolEvents(ctx context.Context, watch watchFunc, resumeAfter bson.Raw, publish func(PoolEvent)) (bson.Raw, error) {
+	stream, err := watch(ctx, resumeAfter)
+	if err != nil {
+		return resumeAfter, err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		resumeAfter = stream.ResumeToken()
+
+		var change struct {
+			FullDocument OutboxEvent `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			log.Printf("unable to decode an outbox change: %v", err)
+			continue
+		}
+		domainEvent, err := change.FullDocument.Decode()
+		if err != nil {
+			log.Printf("unable to decode %s event %s: %v", change.FullDocument.Type, change.FullDocument.ID.Hex(), err)
+			continue
+		}
+
+		if poolEvent, ok := PoolEventFromDomainEvent(domainEvent, change.FullDocument.OccurredAt); ok {
+			publish(poolEvent)
+		}
+	}
+
+	return resumeAfter, stream.Err()
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+An outbox whose change streams return `events` (in order) until `failAt` changes were read, if it's set.
+*/
+type testOutbox struct {
+	events []*OutboxEvent
+	failAt int
+}
+
+func (o *testOutbox) watch(ctx context.Context, resumeAfter bson.Raw) (changeStream, error) {
+	stream := &testStream{outbox: o}
+	if resumeAfter != nil {
+		id := resumeAfter.Lookup("_data").ObjectID()
+		for i, event := range o.events {
+			if event.ID == id {
+				stream.next = i + 1
+			}
+		}
+		if stream.next == 0 {
+			return nil, errors.New("resume token not found")
+		}
+	}
+
+	return stream, nil
+}
+
+type testStream struct {
+	outbox *testOutbox
+	next   int
+	read   int
+	err    error
+}
+
+func (s *testStream) Next(ctx context.Context) bool {
+	if s.outbox.failAt > 0 && s.read == s.outbox.failAt {
+		s.err = errors.New("connection reset")
+		return false
+	}
+	if s.next >= len(s.outbox.events) {
+		return false
+	}
+	s.next++
+	s.read++
+	return true
+}
+
+func (s *testStream) Decode(val interface{}) error {
+	change, err := bson.Marshal(bson.M{"operationType": "insert", "fullDocument": s.outbox.events[s.next-1]})
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(change, val)
+}
+
+func (s *testStream) ResumeToken() bson.Raw {
+	token, _ := bson.Marshal(bson.M{"_data": s.outbox.events[s.next-1].ID})
+	return token
+}
+
+func (s *testStream) Err() error                      { return s.err }
+func (s *testStream) Close(ctx context.Context) error { return nil }
+
+func newTestOutboxEvent(t *testing.T, event DomainEvent) *OutboxEvent {
+	t.Helper()
+	outboxEvent, err := NewOutboxEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the change streams return the events as they're stored.
+	outboxEvent.OccurredAt = outboxEvent.OccurredAt.Truncate(time.Millisecond).UTC()
+	return outboxEvent
+}
+
+func TestPoolStreamReachesEveryInstance(t *testing.T) {
+	wallet := "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"
+	staked := newTestOutboxEvent(t, SubpoolStakedEvent{StakingPoolID: 3, SubpoolID: 12, StakerWallet: wallet, SubpoolPoints: 623.86})
+	claimed := newTestOutboxEvent(t, RewardClaimedEvent{StakingPoolID: 3, SubpoolID: 12})
+	totalPoints := newTestOutboxEvent(t, TotalPointsChangedEvent{StakingPoolID: 3, TotalYieldPoints: 18234.5})
+	banned := newTestOutboxEvent(t, SubpoolBannedEvent{StakingPoolID: 4, SubpoolID: 1, StakerWallet: wallet})
+	startTime := time.Date(2023, 8, 8, 0, 0, 0, 0, time.UTC)
+	started := newTestOutboxEvent(t, PoolPhaseChangedEvent{StakingPoolID: 4, Phase: PoolStarted, Time: startTime})
+	outbox := &testOutbox{events: []*OutboxEvent{staked, claimed, totalPoints, banned, started}}
+
+	want := []PoolEvent{
+		{Type: SubpoolAdded, StakingPoolID: 3, SubpoolID: 12, StakerWallet: wallet, SubpoolPoints: 623.86, Time: staked.OccurredAt},
+		{Type: TotalPointsChanged, StakingPoolID: 3, TotalYieldPoints: 18234.5, Time: totalPoints.OccurredAt},
+		{Type: SubpoolBanned, StakingPoolID: 4, SubpoolID: 1, StakerWallet: wallet, Time: banned.OccurredAt},
+		{Type: PoolStarted, StakingPoolID: 4, Time: startTime},
+	}
+
+	// every instance watches the outbox itself, so both receive every event (and the claim isn't a pool event).
+	for _, instance := range []string{"first", "second"} {
+		var published []PoolEvent
+		if _, err := readPoolEvents(context.Background(), outbox.watch, nil, func(event PoolEvent) { published = append(published, event) }); err != nil {
+			t.Fatalf("%s instance: unexpected error: %v", instance, err)
+		}
+		if !reflect.DeepEqual(published, want) {
+			t.Errorf("%s instance published %+v, want %+v", instance, published, want)
+		}
+	}
+}
+
+func TestPoolStreamResumesAfterError(t *testing.T) {
+	outbox := &testOutbox{failAt: 1}
+	for i := 1; i <= 3; i++ {
+		outbox.events = append(outbox.events, newTestOutboxEvent(t, SubpoolUnstakedEvent{StakingPoolID: 3, SubpoolID: i}))
+	}
+
+	var subpoolIds []int
+	publish := func(event PoolEvent) { subpoolIds = append(subpoolIds, event.SubpoolID) }
+
+	resumeToken, err := readPoolEvents(context.Background(), outbox.watch, nil, publish)
+	if err == nil {
+		t.Fatal("expected the stream to fail")
+	}
+
+	// the stream is reopened after the last event that was read, so no event is skipped or published twice.
+	outbox.failAt = 0
+	if _, err := readPoolEvents(context.Background(), outbox.watch, resumeToken, publish); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(subpoolIds, want) {
+		t.Errorf("published subpools %v, want %v", subpoolIds, want)
+	}
+}
+
+func TestPoolStreamFiltersByPool(t *testing.T) {
+	sub := SubscribePool(3)
+	defer sub.Close()
+
+	PublishPool(PoolEvent{Type: SubpoolBanned, StakingPoolID: 4, SubpoolID: 1})
+	PublishPool(PoolEvent{Type: SubpoolUnstaked, StakingPoolID: 3, SubpoolID: 12})
+
+	event := <-sub.C
+	if event.StakingPoolID != 3 || event.SubpoolID != 12 {
+		t.Errorf("got %+v, want the event of subpool 12 of staking pool 3", event)
+	}
+	select {
+	case event := <-sub.C:
+		t.Errorf("unexpected pool event %+v", event)
+	default:
+	}
+}
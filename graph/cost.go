@@ -0,0 +1,187 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"nbc-backend-api-v2/utils"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	MaxQueryDepth = 8     // the maximum nesting of fields in a query (`pool { subpools { subpoolId } }` has a depth of 3)
+	MaxQueryCost  = 10000 // the maximum estimated cost of a query
+)
+
+// returned when a query is deeper or more expensive than allowed.
+var ErrQueryTooComplex = utils.NewDomainError(utils.KindUnprocessable, "QUERY_TOO_COMPLEX", "query is too complex")
+
+// the assumed number of items of a list field that's missing from `listSizes`.
+const defaultListSize = 20
+
+/*
+The assumed number of items returned by each list of objects (keyed by "Type.field").
+The cost of the selections inside a list is multiplied by its size.
+*/
+var listSizes = map[string]int{
+	"Query.pools":               20,
+	"StakingPool.subpools":      200,
+	"Staker.subpools":           50,
+	"StakingSubpool.stakedKeys": 15,
+	"Ownership.keys":            50,
+}
+
+/*
+The cost of fields that need more than an in-memory lookup (keyed by "Type.field"). Every other field costs 1.
+*/
+var fieldCosts = map[string]int{
+	"Query.pools":                   5,
+	"Query.pool":                    2,
+	"Query.subpool":                 2,
+	"StakingPool.totalTokenReward":  2,
+	"StakingSubpool.tokenShare":     5,
+	"Staker.subpools":               5,
+	"Staker.totalSubpoolPoints":     3,
+	"Staker.tokenShare":             10,
+	"Ownership.keyIds":              20, // read from the chain
+	"Ownership.keys":                20,
+	"Ownership.keychainIds":         20,
+	"Ownership.superiorKeychainIds": 20,
+}
+
+/*
+Estimates the cost of the operation `operationName` in `doc` and returns `ErrQueryTooComplex` if it exceeds
+`MaxQueryCost` or `MaxQueryDepth`. Introspection fields (`__schema`, `__type`, `__typename`) are free.
+
+`doc` must already be validated (so that every field exists and fragments don't form cycles).
+*/
+func checkCost(doc *ast.Document, operationName string) error {
+	var operation *ast.OperationDefinition
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		}
+	}
+	// graphql-go reports a missing operation itself
+	if operation == nil {
+		return nil
+	}
+
+	c := &costCalculator{fragments: fragments}
+	cost, depth := c.selectionSet(schema.QueryType(), operation.SelectionSet, 1)
+
+	if depth > MaxQueryDepth {
+		return fmt.Errorf("%w: depth of %d exceeds the maximum of %d", ErrQueryTooComplex, depth, MaxQueryDepth)
+	}
+	if cost > MaxQueryCost {
+		return fmt.Errorf("%w: estimated cost of %d exceeds the maximum of %d", ErrQueryTooComplex, cost, MaxQueryCost)
+	}
+
+	return nil
+}
+
+type costCalculator struct {
+	fragments map[string]*ast.FragmentDefinition
+}
+
+/*
+Returns the cost and depth of the selections in `set` on the object type `parent` at depth `depth`.
+*/
+func (c *costCalculator) selectionSet(parent *graphql.Object, set *ast.SelectionSet, depth int) (cost, maxDepth int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var selectionCost, selectionDepth int
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			selectionCost, selectionDepth = c.field(parent, selection, depth)
+		case *ast.InlineFragment:
+			selectionCost, selectionDepth = c.selectionSet(c.fragmentType(parent, selection.TypeCondition), selection.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[selection.Name.Value]; ok {
+				selectionCost, selectionDepth = c.selectionSet(c.fragmentType(parent, fragment.TypeCondition), fragment.SelectionSet, depth)
+			}
+		}
+
+		cost += selectionCost
+		if selectionDepth > maxDepth {
+			maxDepth = selectionDepth
+		}
+	}
+
+	return cost, maxDepth
+}
+
+func (c *costCalculator) field(parent *graphql.Object, field *ast.Field, depth int) (int, int) {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return 0, 0
+	}
+
+	def, ok := parent.Fields()[name]
+	if !ok {
+		return 0, 0
+	}
+
+	key := parent.Name() + "." + name
+	cost, ok := fieldCosts[key]
+	if !ok {
+		cost = 1
+	}
+
+	// unwrap non-null and list types to get to the object type (if any)
+	fieldType, isList := def.Type, false
+	for {
+		if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+			fieldType = nonNull.OfType
+		} else if list, ok := fieldType.(*graphql.List); ok {
+			fieldType, isList = list.OfType, true
+		} else {
+			break
+		}
+	}
+
+	object, ok := fieldType.(*graphql.Object)
+	if !ok {
+		return cost, depth
+	}
+
+	childCost, childDepth := c.selectionSet(object, field.SelectionSet, depth+1)
+	if isList {
+		size, ok := listSizes[key]
+		if !ok {
+			size = defaultListSize
+		}
+		childCost *= size
+	}
+	if childDepth < depth {
+		childDepth = depth
+	}
+
+	return cost + childCost, childDepth
+}
+
+/*
+Returns the type of a fragment with type condition `condition`. All types in the schema are objects,
+so a fragment's type is either its type condition or the type it's spread in.
+*/
+func (c *costCalculator) fragmentType(parent *graphql.Object, condition *ast.Named) *graphql.Object {
+	if condition != nil {
+		if object, ok := schema.Type(condition.Name.Value).(*graphql.Object); ok {
+			return object
+		}
+	}
+
+	return parent
+}
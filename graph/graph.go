@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"context"
+	"log"
+	"math/big"
+
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+/*
+The root of the resolvers: the stores they read from. Every query executed by a resolver gets it in its context,
+together with the query's dataloaders.
+*/
+type Resolver struct {
+	store    Store
+	metadata MetadataStore
+}
+
+/*
+Creates a resolver that reads from `store` and `metadata` (e.g. in-memory stores in tests).
+*/
+func NewResolver(store Store, metadata MetadataStore) *Resolver {
+	return &Resolver{store: store, metadata: metadata}
+}
+
+// the resolver of `/graphql`, which reads from the database, the chain and IPFS.
+var defaultResolver = NewResolver(apiStore{}, apiStore{})
+
+/*
+Executes a GraphQL query with the resolver of `/graphql` (see `Resolver.Execute`).
+*/
+func Execute(ctx context.Context, query string, variables map[string]interface{}, operationName string) *graphql.Result {
+	return defaultResolver.Execute(ctx, query, variables, operationName)
+}
+
+/*
+Executes a GraphQL query against the pools, subpools, stakers and Keys Of Salvation.
+
+The query is parsed and validated first, then rejected if it's too deep or too expensive (see `checkCost`) before anything is resolved.
+Every query gets its own dataloaders, so lookups are only batched and cached within a single query.
+
+Errors caused by a domain error (e.g. `POOL_NOT_FOUND`) have its code in `extensions.code`.
+*/
+func (r *Resolver) Execute(ctx context.Context, query string, variables map[string]interface{}, operationName string) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	if err := checkCost(doc, operationName); err != nil {
+		return &graphql.Result{Errors: withCodes(gqlerrors.FormatErrors(err))}
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: operationName,
+		Args:          variables,
+		Context:       withLoaders(ctx, r),
+	})
+	result.Errors = withCodes(result.Errors)
+
+	return result
+}
+
+/*
+Adds the code of the underlying domain error (if any) to the `extensions` of each error.
+
+Server errors (internal and upstream domain errors, and any other error returned by a resolver) are logged
+and keep only their public message, the same way `responses.ErrorHandler` returns them.
+*/
+func withCodes(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i, err := range errs {
+		original := originalError(err)
+		code := "INTERNAL_ERROR"
+		message := "internal server error"
+
+		if domainErr, ok := utils.AsDomainError(original); ok {
+			code = domainErr.Code
+			message = domainErr.Message
+			if domainErr.Kind != utils.KindInternal && domainErr.Kind != utils.KindUpstream {
+				// a client error, so its details (e.g. the ID that wasn't found) are kept.
+				message = errs[i].Message
+			}
+		} else if isQueryError(original) {
+			// errors of the query itself (syntax, validation) don't have a code.
+			continue
+		}
+
+		if message != errs[i].Message {
+			log.Printf("graphql %v: %v", errs[i].Path, original)
+			errs[i].Message = message
+		}
+		if errs[i].Extensions == nil {
+			errs[i].Extensions = map[string]interface{}{}
+		}
+		errs[i].Extensions["code"] = code
+	}
+
+	return errs
+}
+
+/*
+Checks whether `err` (see `originalError`) is an error of graphql-go itself rather than one returned by a resolver.
+*/
+func isQueryError(err error) bool {
+	switch err.(type) {
+	case gqlerrors.FormattedError, *gqlerrors.Error:
+		return true
+	}
+	return false
+}
+
+/*
+Returns the error returned by the resolver that caused `err`.
+graphql-go wraps resolver errors (once more for errors returned by thunks) and doesn't support `errors.Unwrap`.
+*/
+func originalError(err error) error {
+	for err != nil {
+		var next error
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			next = e.OriginalError()
+		case *gqlerrors.Error:
+			next = e.OriginalError
+		default:
+			return err
+		}
+
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+
+	return nil
+}
+
+/*
+Converts the token IDs returned by the `OwnerIDs` functions into ints.
+*/
+func toInts(ids []*big.Int, err error) ([]int, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return UtilsNFT.TokenIDsToInts(ids)
+}
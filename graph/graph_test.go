@@ -0,0 +1,322 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+An in-memory `Store` and `MetadataStore` that records how often the batched lookups are called.
+*/
+type memStore struct {
+	pools   []*models.StakingPool
+	stakers []*models.Staker
+	owned   map[UtilsNFT.CollectionRole][]int64
+
+	mu              sync.Mutex
+	metadataBatches [][]int
+	stakerBatches   int
+}
+
+func (m *memStore) GetAllStakingPools() ([]*models.StakingPool, error) {
+	return m.pools, nil
+}
+
+func (m *memStore) FetchStakingPoolData() (*models.AllStakingPools, error) {
+	return &models.AllStakingPools{OngoingPools: m.pools}, nil
+}
+
+func (m *memStore) GetStakingPoolData(stakingPoolId int) (*models.StakingPool, error) {
+	for _, pool := range m.pools {
+		if pool.StakingPoolID == stakingPoolId {
+			return pool, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no staking pool with ID %d exists", UtilsKOS.ErrPoolNotFound, stakingPoolId)
+}
+
+func (m *memStore) GetStakers(wallets []string) ([]*models.Staker, error) {
+	m.mu.Lock()
+	m.stakerBatches++
+	m.mu.Unlock()
+
+	var stakers []*models.Staker
+	for _, staker := range m.stakers {
+		for _, wallet := range wallets {
+			if strings.EqualFold(staker.Wallet, wallet) {
+				stakers = append(stakers, staker)
+			}
+		}
+	}
+	return stakers, nil
+}
+
+func (m *memStore) GetStakersFromObjIDs(stakerObjIds []primitive.ObjectID) ([]*models.Staker, error) {
+	m.mu.Lock()
+	m.stakerBatches++
+	m.mu.Unlock()
+
+	var stakers []*models.Staker
+	for _, staker := range m.stakers {
+		for _, id := range stakerObjIds {
+			if staker.ID == id {
+				stakers = append(stakers, staker)
+			}
+		}
+	}
+	return stakers, nil
+}
+
+func (m *memStore) GetStakerSubpools(wallet string) ([]*models.StakingSubpoolWithID, error) {
+	var subpools []*models.StakingSubpoolWithID
+	for _, pool := range m.pools {
+		for _, subpool := range poolSubpools(pool, "all") {
+			if strings.EqualFold(subpool.StakerWallet, wallet) {
+				subpools = append(subpools, subpool)
+			}
+		}
+	}
+	return subpools, nil
+}
+
+func (m *memStore) GetTotalTokenReward(stakingPoolId int) (float64, error) {
+	return 0, nil
+}
+
+func (m *memStore) CalculateSubpoolTokenShare(stakingPoolId, subpoolId int) (float64, error) {
+	return 0, nil
+}
+
+func (m *memStore) CalculateStakerTotalSubpoolPoints(stakingPoolId int, wallet string) (float64, error) {
+	return 0, nil
+}
+
+func (m *memStore) CalcTotalTokenShare(stakingPoolId int, wallet string) (float64, error) {
+	return 0, nil
+}
+
+func (m *memStore) OwnerIDs(address string, role UtilsNFT.CollectionRole) ([]*big.Int, error) {
+	ids := make([]*big.Int, len(m.owned[role]))
+	for i, id := range m.owned[role] {
+		ids[i] = big.NewInt(id)
+	}
+	return ids, nil
+}
+
+func (m *memStore) FetchMetadata(tokenIds []int) ([]*models.KOSMetadata, []error) {
+	m.mu.Lock()
+	m.metadataBatches = append(m.metadataBatches, append([]int{}, tokenIds...))
+	m.mu.Unlock()
+
+	metadatas := make([]*models.KOSMetadata, len(tokenIds))
+	errs := make([]error, len(tokenIds))
+	for i, tokenId := range tokenIds {
+		metadatas[i] = &models.KOSMetadata{
+			Name: fmt.Sprintf("Key Of Salvation #%d", tokenId),
+			Attributes: []models.Attribute{
+				{TraitType: "House", Value: "Glory"},
+				{TraitType: "Type", Value: "Brawler"},
+				{TraitType: "Luck", Value: float64(tokenId % 100)},
+				{TraitType: "Luck Boost", Value: float64(10)},
+			},
+		}
+	}
+	return metadatas, errs
+}
+
+func newMemStore() *memStore {
+	alice := &models.Staker{ID: primitive.NewObjectID(), Wallet: "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"}
+	bob := &models.Staker{ID: primitive.NewObjectID(), Wallet: "0x1f2e3d4c5b6a79880716253443526170819a0b1c"}
+
+	keys := func(ids ...int) []*models.KOSSimplifiedMetadata {
+		metadatas := make([]*models.KOSSimplifiedMetadata, len(ids))
+		for i, id := range ids {
+			metadatas[i] = &models.KOSSimplifiedMetadata{TokenID: id}
+		}
+		return metadatas
+	}
+
+	pool := &models.StakingPool{
+		StakingPoolID: 1,
+		Reward:        models.Reward{Name: "REC", Amount: 100000},
+		ActiveSubpools: []*models.StakingSubpool{
+			{SubpoolID: 1, Staker: &alice.ID, StakedKeys: keys(25, 1402), SubpoolPoints: 100},
+			{SubpoolID: 2, Staker: &bob.ID, StakedKeys: keys(3310), SubpoolPoints: 50},
+		},
+		ClosedSubpools: []*models.StakingSubpool{
+			{SubpoolID: 3, Staker: &alice.ID, StakedKeys: keys(25), SubpoolPoints: 25},
+		},
+	}
+
+	return &memStore{
+		pools:   []*models.StakingPool{pool},
+		stakers: []*models.Staker{alice, bob},
+		owned: map[UtilsNFT.CollectionRole][]int64{
+			UtilsNFT.RoleKey:        {7, 25},
+			UtilsNFT.RoleBooster:    {45},
+			UtilsNFT.RoleMultiplier: {},
+		},
+	}
+}
+
+func TestExecuteBatchesLoads(t *testing.T) {
+	store := newMemStore()
+	resolver := NewResolver(store, store)
+
+	result := resolver.Execute(context.Background(), `{
+		pool(stakingPoolId: 1) {
+			subpools {
+				subpoolId
+				stakerWallet
+				stakedKeys { name houseTrait luckBoostTrait }
+			}
+		}
+	}`, nil, "")
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+
+	subpools := result.Data.(map[string]interface{})["pool"].(map[string]interface{})["subpools"].([]interface{})
+	if len(subpools) != 3 {
+		t.Fatalf("got %d subpools, want 3", len(subpools))
+	}
+	first := subpools[0].(map[string]interface{})
+	if first["stakerWallet"] != store.stakers[0].Wallet {
+		t.Errorf("stakerWallet = %v, want %s", first["stakerWallet"], store.stakers[0].Wallet)
+	}
+	key := first["stakedKeys"].([]interface{})[0].(map[string]interface{})
+	if key["name"] != "Key Of Salvation #25" || key["houseTrait"] != "Glory" || key["luckBoostTrait"] != 1.1 {
+		t.Errorf("unexpected key: %v", key)
+	}
+
+	// every key is fetched in a single batch, with token 25 (staked in two subpools) only once.
+	if len(store.metadataBatches) != 1 {
+		t.Fatalf("metadata fetched in %d batches, want 1: %v", len(store.metadataBatches), store.metadataBatches)
+	}
+	batch := store.metadataBatches[0]
+	sort.Ints(batch)
+	if !reflect.DeepEqual(batch, []int{25, 1402, 3310}) {
+		t.Errorf("metadata batch = %v, want [25 1402 3310]", batch)
+	}
+	// the wallets of all subpools' stakers are looked up at once.
+	if store.stakerBatches != 1 {
+		t.Errorf("stakers fetched in %d batches, want 1", store.stakerBatches)
+	}
+}
+
+func TestExecuteOwnership(t *testing.T) {
+	store := newMemStore()
+
+	result := NewResolver(store, store).Execute(context.Background(), `{
+		ownership(address: "0x8D1A1B2C3D4E5F60718293A4B5C6D7E8F9012345") { address keyIds keychainIds superiorKeychainIds }
+	}`, nil, "")
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+
+	ownership := result.Data.(map[string]interface{})["ownership"].(map[string]interface{})
+	if ownership["address"] != "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345" {
+		t.Errorf("address = %v, want the lowercase wallet", ownership["address"])
+	}
+	for field, want := range map[string][]interface{}{
+		"keyIds":              {7, 25},
+		"keychainIds":         {45},
+		"superiorKeychainIds": {},
+	} {
+		if !reflect.DeepEqual(ownership[field], want) {
+			t.Errorf("%s = %v, want %v", field, ownership[field], want)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	store := newMemStore()
+	resolver := NewResolver(store, store)
+
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{"unknown pool", `{ pool(stakingPoolId: 9) { stakingPoolId } }`, "POOL_NOT_FOUND"},
+		{"unknown subpool", `{ subpool(stakingPoolId: 1, subpoolId: 9) { subpoolId } }`, "SUBPOOL_NOT_FOUND"},
+		{"invalid wallet", `{ staker(wallet: "0x123") { wallet } }`, "VALIDATION_FAILED"},
+		{"invalid token", `{ token(tokenId: 0) { name } }`, "VALIDATION_FAILED"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := resolver.Execute(context.Background(), test.query, nil, "")
+			if len(result.Errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(result.Errors), result.Errors)
+			}
+			if code := result.Errors[0].Extensions["code"]; code != test.code {
+				t.Errorf("code = %v, want %s", code, test.code)
+			}
+		})
+	}
+}
+
+/*
+A store whose staking pools can't be read.
+*/
+type failingStore struct {
+	*memStore
+	err error
+}
+
+func (f *failingStore) GetAllStakingPools() ([]*models.StakingPool, error) {
+	return nil, f.err
+}
+
+func TestExecuteRedactsServerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		message string
+	}{
+		{"database unavailable", fmt.Errorf("%w: server selection error: 10.0.0.5:27017", utils.ErrDatabaseUnavailable), "DATABASE_UNAVAILABLE", utils.ErrDatabaseUnavailable.Message},
+		{"unknown error", errors.New("open abi/KeyOfSalvation.json: no such file or directory"), "INTERNAL_ERROR", "internal server error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newMemStore()
+
+			result := NewResolver(&failingStore{store, test.err}, store).Execute(context.Background(), `{ pools { stakingPoolId } }`, nil, "")
+			if len(result.Errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(result.Errors), result.Errors)
+			}
+			if code, message := result.Errors[0].Extensions["code"], result.Errors[0].Message; code != test.code || message != test.message {
+				t.Errorf("got %v %q, want %s %q", code, message, test.code, test.message)
+			}
+		})
+	}
+}
+
+func TestExecuteUnknownStaker(t *testing.T) {
+	store := newMemStore()
+
+	result := NewResolver(store, store).Execute(context.Background(), `{
+		staker(wallet: "0x0000000000000000000000000000000000000001") { wallet }
+	}`, nil, "")
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if staker := result.Data.(map[string]interface{})["staker"]; staker != nil {
+		t.Errorf("staker = %v, want null", staker)
+	}
+}
@@ -0,0 +1,214 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"nbc-backend-api-v2/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+Loads the values of `keys` in a single batch.
+Both returned slices must have the same length as `keys` (`values[i]` and `errs[i]` belong to `keys[i]`).
+*/
+type batchFunc[K comparable, V any] func(keys []K) (values []V, errs []error)
+
+type loaderResult[V any] struct {
+	value V
+	err   error
+}
+
+/*
+`loader` batches and caches lookups for the duration of a single GraphQL request (a dataloader).
+
+`Load` doesn't fetch anything; it queues the key and returns a thunk. graphql-go resolves all fields at the same depth
+before calling any of their thunks, so the first thunk that is called fetches every queued key at once
+(e.g. the staker of all 100 subpools of a pool in one query instead of 100).
+*/
+type loader[K comparable, V any] struct {
+	batch   batchFunc[K, V]
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]bool
+	results map[K]*loaderResult[V]
+}
+
+func newLoader[K comparable, V any](batch batchFunc[K, V]) *loader[K, V] {
+	return &loader[K, V]{
+		batch:   batch,
+		queued:  map[K]bool{},
+		results: map[K]*loaderResult[V]{},
+	}
+}
+
+/*
+Queues `key` and returns a thunk that returns its value.
+*/
+func (l *loader[K, V]) Load(key K) func() (interface{}, error) {
+	l.queue(key)
+
+	return func() (interface{}, error) {
+		res := l.get(key)
+		return res.value, res.err
+	}
+}
+
+/*
+Queues all `keys` and returns a thunk that returns their values (in the same order).
+*/
+func (l *loader[K, V]) LoadMany(keys []K) func() (interface{}, error) {
+	for _, key := range keys {
+		l.queue(key)
+	}
+
+	return func() (interface{}, error) {
+		values := make([]V, len(keys))
+		for i, key := range keys {
+			res := l.get(key)
+			if res.err != nil {
+				return nil, res.err
+			}
+			values[i] = res.value
+		}
+		return values, nil
+	}
+}
+
+func (l *loader[K, V]) queue(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.results[key]; ok || l.queued[key] {
+		return
+	}
+	l.queued[key] = true
+	l.pending = append(l.pending, key)
+}
+
+/*
+Returns the result for `key`, fetching all queued keys first if `key` hasn't been fetched yet.
+*/
+func (l *loader[K, V]) get(key K) *loaderResult[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if res, ok := l.results[key]; ok {
+		return res
+	}
+
+	keys := l.pending
+	l.pending, l.queued = nil, map[K]bool{}
+	values, errs := l.batch(keys)
+	for i, k := range keys {
+		l.results[k] = &loaderResult[V]{value: values[i], err: errs[i]}
+	}
+
+	// only happens if `key` was never queued
+	if _, ok := l.results[key]; !ok {
+		values, errs := l.batch([]K{key})
+		l.results[key] = &loaderResult[V]{value: values[0], err: errs[0]}
+	}
+
+	return l.results[key]
+}
+
+/*
+The loaders of a single GraphQL request. A new set is created for every request so that nothing is cached across requests
+(apart from the metadata cache in `utils/nfts/kos`).
+*/
+type loaders struct {
+	resolver *Resolver // the stores the loaders (and the resolvers) read from
+
+	metadata    *loader[int, *models.KOSMetadata]           // Key Of Salvation metadata by token ID
+	stakers     *loader[string, *models.Staker]             // stakers by (lowercase) wallet. nil if the wallet isn't a staker
+	stakersByID *loader[primitive.ObjectID, *models.Staker] // stakers by object ID (subpools only store the staker's object ID)
+}
+
+type loadersKey struct{}
+
+func newLoaders(r *Resolver) *loaders {
+	return &loaders{
+		resolver:    r,
+		metadata:    newLoader(r.metadata.FetchMetadata),
+		stakers:     newLoader(r.batchStakers),
+		stakersByID: newLoader(r.batchStakersByID),
+	}
+}
+
+func withLoaders(ctx context.Context, r *Resolver) context.Context {
+	return context.WithValue(ctx, loadersKey{}, newLoaders(r))
+}
+
+/*
+Returns the loaders of the current request.
+*/
+func loadersFrom(ctx context.Context) *loaders {
+	if l, ok := ctx.Value(loadersKey{}).(*loaders); ok {
+		return l
+	}
+
+	// resolvers are always called through `Execute`, but don't panic if they aren't.
+	return newLoaders(defaultResolver)
+}
+
+/*
+Returns the store of the resolver executing the current request.
+*/
+func storeFrom(ctx context.Context) Store {
+	return loadersFrom(ctx).resolver.store
+}
+
+/*
+Fetches the stakers of all `wallets` in a single query.
+*/
+func (r *Resolver) batchStakers(wallets []string) ([]*models.Staker, []error) {
+	values := make([]*models.Staker, len(wallets))
+	errs := make([]error, len(wallets))
+
+	stakers, err := r.store.GetStakers(wallets)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return values, errs
+	}
+
+	byWallet := make(map[string]*models.Staker, len(stakers))
+	for _, staker := range stakers {
+		byWallet[strings.ToLower(staker.Wallet)] = staker
+	}
+	for i, wallet := range wallets {
+		values[i] = byWallet[strings.ToLower(wallet)]
+	}
+
+	return values, errs
+}
+
+/*
+Fetches the stakers with the object IDs `stakerObjIds` in a single query.
+*/
+func (r *Resolver) batchStakersByID(stakerObjIds []primitive.ObjectID) ([]*models.Staker, []error) {
+	values := make([]*models.Staker, len(stakerObjIds))
+	errs := make([]error, len(stakerObjIds))
+
+	stakers, err := r.store.GetStakersFromObjIDs(stakerObjIds)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return values, errs
+	}
+
+	byID := make(map[primitive.ObjectID]*models.Staker, len(stakers))
+	for _, staker := range stakers {
+		byID[staker.ID] = staker
+	}
+	for i, stakerObjId := range stakerObjIds {
+		values[i] = byID[stakerObjId]
+	}
+
+	return values, errs
+}
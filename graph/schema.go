@@ -0,0 +1,607 @@
+package graph
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
+
+	"github.com/graphql-go/graphql"
+)
+
+// the GraphQL schema served at `/graphql`.
+var schema = mustSchema()
+
+/*
+A Key Of Salvation together with its metadata (the metadata itself doesn't contain the token ID).
+The default resolver doesn't look into the embedded `KOSMetadata`, so its fields have their own resolvers.
+*/
+type kosToken struct {
+	TokenID int
+	*models.KOSMetadata
+}
+
+/*
+The NFTs owned by `address`. The owned IDs are only read from the chain once, even if several fields need them.
+*/
+type ownership struct {
+	Address string
+	store   Store
+
+	keysOnce sync.Once
+	keyIds   []int
+	keysErr  error
+}
+
+func (o *ownership) ownedKeyIds() ([]int, error) {
+	o.keysOnce.Do(func() {
+		ids, err := o.store.OwnerIDs(o.Address, UtilsNFT.RoleKey)
+		o.keyIds, o.keysErr = toInts(ids, err)
+	})
+
+	return o.keyIds, o.keysErr
+}
+
+func mustSchema() graphql.Schema {
+	s, err := newSchema()
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %v", err))
+	}
+
+	return s
+}
+
+/*
+Builds the GraphQL schema. The object types reference each other (e.g. a subpool's staker and a staker's subpools),
+so their fields are declared as thunks that are only evaluated once every type exists.
+*/
+func newSchema() (graphql.Schema, error) {
+	var stakingPoolType, subpoolType, stakerType, metadataType, ownershipType *graphql.Object
+
+	poolStatusEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "PoolStatus",
+		Description: "filters staking pools by their current phase",
+		Values: graphql.EnumValueConfigMap{
+			"ALL":       {Value: "all", Description: "every staking pool"},
+			"STAKEABLE": {Value: "stakeable", Description: "pools that stakers can currently enter"},
+			"ONGOING":   {Value: "ongoing", Description: "pools that have started but not ended yet"},
+			"CLOSED":    {Value: "closed", Description: "pools that have ended"},
+		},
+	})
+
+	subpoolStatusEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "SubpoolStatus",
+		Description: "filters the subpools of a staking pool",
+		Values: graphql.EnumValueConfigMap{
+			"ALL":    {Value: "all", Description: "active and closed subpools"},
+			"ACTIVE": {Value: "active", Description: "only active subpools"},
+			"CLOSED": {Value: "closed", Description: "only closed subpools (unstaked, banned or ended)"},
+		},
+	})
+
+	rewardType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Reward",
+		Fields: graphql.Fields{
+			"name":   {Type: graphql.NewNonNull(graphql.String)},
+			"amount": {Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	attributeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "KOSAttribute",
+		Fields: graphql.Fields{
+			"traitType": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Attribute).TraitType, nil
+			}},
+			"displayType": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Attribute).DisplayType, nil
+			}},
+			// attribute values are either strings or numbers, so they're always returned as a string.
+			"value": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if value := p.Source.(models.Attribute).Value; value != nil {
+					return fmt.Sprint(value), nil
+				}
+				return nil, nil
+			}},
+		},
+	})
+
+	metadataType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "KOSMetadata",
+		Description: "a Key Of Salvation and its metadata",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"tokenId": {Type: graphql.NewNonNull(graphql.Int)},
+				"name": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*kosToken).Name, nil
+				}},
+				"image": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*kosToken).Image, nil
+				}},
+				"animationUrl": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*kosToken).AnimationUrl, nil
+				}},
+				"attributes": {Type: nonNullList(attributeType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if attributes := p.Source.(*kosToken).Attributes; attributes != nil {
+						return attributes, nil
+					}
+					return []models.Attribute{}, nil
+				}},
+				"houseTrait": {Type: graphql.String, Resolve: simplifiedTrait(func(md *models.KOSSimplifiedMetadata) interface{} {
+					return md.HouseTrait
+				})},
+				"typeTrait": {Type: graphql.String, Resolve: simplifiedTrait(func(md *models.KOSSimplifiedMetadata) interface{} {
+					return md.TypeTrait
+				})},
+				"luckTrait": {Type: graphql.Float, Resolve: simplifiedTrait(func(md *models.KOSSimplifiedMetadata) interface{} {
+					return md.LuckTrait
+				})},
+				"luckBoostTrait": {Type: graphql.Float, Resolve: simplifiedTrait(func(md *models.KOSSimplifiedMetadata) interface{} {
+					return md.LuckBoostTrait
+				})},
+			}
+		}),
+	})
+
+	ownershipType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Ownership",
+		Description: "the Keys Of Salvation, keychains and superior keychains owned by a wallet (read from the chain)",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"address": {Type: graphql.NewNonNull(graphql.String)},
+				"keyIds": {Type: nonNullList(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*ownership).ownedKeyIds()
+				}},
+				"keys": {Type: nonNullList(metadataType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					keyIds, err := p.Source.(*ownership).ownedKeyIds()
+					if err != nil {
+						return nil, err
+					}
+					return loadTokens(p, keyIds), nil
+				}},
+				"keychainIds": {Type: nonNullList(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					o := p.Source.(*ownership)
+					return toInts(o.store.OwnerIDs(o.Address, UtilsNFT.RoleBooster))
+				}},
+				"superiorKeychainIds": {Type: nonNullList(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					o := p.Source.(*ownership)
+					return toInts(o.store.OwnerIDs(o.Address, UtilsNFT.RoleMultiplier))
+				}},
+			}
+		}),
+	})
+
+	stakingPoolType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "StakingPool",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"stakingPoolId":    {Type: graphql.NewNonNull(graphql.Int)},
+				"reward":           {Type: graphql.NewNonNull(rewardType)},
+				"totalYieldPoints": {Type: graphql.NewNonNull(graphql.Float)},
+				"entryAllowance":   {Type: graphql.DateTime, Description: "when stakers are allowed to enter the pool"},
+				"startTime":        {Type: graphql.DateTime, Description: "when staking starts (entry is no longer allowed)"},
+				"endTime":          {Type: graphql.DateTime, Description: "when the pool closes"},
+				"totalSubpoolPoints": {
+					Type:        graphql.NewNonNull(graphql.Float),
+					Description: "the total subpool points across all active and closed subpools",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						var total float64
+						for _, subpool := range poolSubpools(p.Source.(*models.StakingPool), "all") {
+							total += subpool.SubpoolPoints
+						}
+						return total, nil
+					},
+				},
+				"totalTokenReward": {
+					Type:        graphql.Float,
+					Description: "the total token reward of the pool (errors with `NON_TOKEN_REWARD` if the reward isn't a token)",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return storeFrom(p.Context).GetTotalTokenReward(p.Source.(*models.StakingPool).StakingPoolID)
+					},
+				},
+				"subpools": {
+					Type: nonNullList(subpoolType),
+					Args: graphql.FieldConfigArgument{
+						"status": {Type: subpoolStatusEnum, DefaultValue: "all"},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return poolSubpools(p.Source.(*models.StakingPool), p.Args["status"].(string)), nil
+					},
+				},
+				"subpool": {
+					Type: subpoolType,
+					Args: graphql.FieldConfigArgument{
+						"subpoolId": {Type: graphql.NewNonNull(graphql.Int)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return findSubpool(p.Source.(*models.StakingPool), p.Args["subpoolId"].(int)), nil
+					},
+				},
+			}
+		}),
+	})
+
+	subpoolType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "StakingSubpool",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"subpoolId": {Type: graphql.NewNonNull(graphql.Int), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return s.SubpoolID
+				})},
+				"stakingPoolId": {Type: graphql.NewNonNull(graphql.Int), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return s.StakingPoolID
+				})},
+				"stakerWallet": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					s := p.Source.(*models.StakingSubpoolWithID)
+					if s.StakerWallet != "" || s.Staker == nil {
+						return s.StakerWallet, nil
+					}
+
+					// subpools only store the staker's object ID, so the wallet comes from the staker.
+					thunk := loadersFrom(p.Context).stakersByID.Load(*s.Staker)
+					return func() (interface{}, error) {
+						staker, err := thunk()
+						if err != nil || staker.(*models.Staker) == nil {
+							return nil, err
+						}
+						return staker.(*models.Staker).Wallet, nil
+					}, nil
+				}},
+				"staker": {Type: stakerType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					s := p.Source.(*models.StakingSubpoolWithID)
+					if s.Staker == nil {
+						return nil, nil
+					}
+					return loadersFrom(p.Context).stakersByID.Load(*s.Staker), nil
+				}},
+				"enterTime": {Type: graphql.DateTime, Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return nonZeroTime(s.EnterTime)
+				})},
+				"exitTime": {Type: graphql.DateTime, Description: "null while the subpool is active", Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return nonZeroTime(s.ExitTime)
+				})},
+				"stakedKeyIds": {Type: nonNullList(graphql.Int), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return stakedKeyIds(s)
+				})},
+				"stakedKeys": {Type: nonNullList(metadataType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadTokens(p, stakedKeyIds(p.Source.(*models.StakingSubpoolWithID))), nil
+				}},
+				"stakedKeychainIds": {Type: nonNullList(graphql.Int), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					if s.StakedKeychainIDs == nil {
+						return []int{}
+					}
+					return s.StakedKeychainIDs
+				})},
+				"stakedSuperiorKeychainId": {Type: graphql.Int, Description: "null if no superior keychain is staked", Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					if s.StakedSuperiorKeychainID <= 0 {
+						return nil
+					}
+					return s.StakedSuperiorKeychainID
+				})},
+				"subpoolPoints": {Type: graphql.NewNonNull(graphql.Float), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return s.SubpoolPoints
+				})},
+				"tokenShare": {
+					Type:        graphql.Float,
+					Description: "the subpool's share of the pool's token reward",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						s := p.Source.(*models.StakingSubpoolWithID)
+						return storeFrom(p.Context).CalculateSubpoolTokenShare(s.StakingPoolID, s.SubpoolID)
+					},
+				},
+				"rewardClaimable": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return s.RewardClaimable
+				})},
+				"rewardClaimed": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return s.RewardClaimed
+				})},
+				"banned": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: subpoolField(func(s *models.StakingSubpoolWithID) interface{} {
+					return s.Banned
+				})},
+			}
+		}),
+	})
+
+	stakerType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Staker",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"wallet": {Type: graphql.NewNonNull(graphql.String)},
+				"earnedRewards": {Type: nonNullList(rewardType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if rewards := p.Source.(*models.Staker).EarnedRewards; rewards != nil {
+						return rewards, nil
+					}
+					return []*models.Reward{}, nil
+				}},
+				"banned": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					bannedData := p.Source.(*models.Staker).BannedData
+					return bannedData != nil && time.Now().Before(bannedData.CurrentUnbanTime), nil
+				}},
+				"bannedUntil": {Type: graphql.DateTime, Description: "null if the staker has never been banned", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if bannedData := p.Source.(*models.Staker).BannedData; bannedData != nil {
+						return bannedData.CurrentUnbanTime, nil
+					}
+					return nil, nil
+				}},
+				"subpools": {
+					Type:        nonNullList(subpoolType),
+					Description: "the staker's subpools across all staking pools (or only `stakingPoolId` if given)",
+					Args: graphql.FieldConfigArgument{
+						"stakingPoolId": {Type: graphql.Int},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						subpools, err := storeFrom(p.Context).GetStakerSubpools(p.Source.(*models.Staker).Wallet)
+						if err != nil {
+							return nil, err
+						}
+
+						stakingPoolId, filter := p.Args["stakingPoolId"].(int)
+						filtered := []*models.StakingSubpoolWithID{}
+						for _, subpool := range subpools {
+							if !filter || subpool.StakingPoolID == stakingPoolId {
+								filtered = append(filtered, subpool)
+							}
+						}
+						return filtered, nil
+					},
+				},
+				"totalSubpoolPoints": {
+					Type:        graphql.NewNonNull(graphql.Float),
+					Description: "the staker's total subpool points in a staking pool",
+					Args: graphql.FieldConfigArgument{
+						"stakingPoolId": {Type: graphql.NewNonNull(graphql.Int)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return storeFrom(p.Context).CalculateStakerTotalSubpoolPoints(p.Args["stakingPoolId"].(int), p.Source.(*models.Staker).Wallet)
+					},
+				},
+				"tokenShare": {
+					Type:        graphql.Float,
+					Description: "the staker's total share of a staking pool's token reward",
+					Args: graphql.FieldConfigArgument{
+						"stakingPoolId": {Type: graphql.NewNonNull(graphql.Int)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return storeFrom(p.Context).CalcTotalTokenShare(p.Args["stakingPoolId"].(int), p.Source.(*models.Staker).Wallet)
+					},
+				},
+				"ownership": {Type: graphql.NewNonNull(ownershipType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return &ownership{Address: p.Source.(*models.Staker).Wallet, store: storeFrom(p.Context)}, nil
+				}},
+			}
+		}),
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"pools": {
+				Type: nonNullList(stakingPoolType),
+				Args: graphql.FieldConfigArgument{
+					"status": {Type: poolStatusEnum, DefaultValue: "all"},
+				},
+				Resolve: resolvePools,
+			},
+			"pool": {
+				Type: stakingPoolType,
+				Args: graphql.FieldConfigArgument{
+					"stakingPoolId": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return storeFrom(p.Context).GetStakingPoolData(p.Args["stakingPoolId"].(int))
+				},
+			},
+			"subpool": {
+				Type: subpoolType,
+				Args: graphql.FieldConfigArgument{
+					"stakingPoolId": {Type: graphql.NewNonNull(graphql.Int)},
+					"subpoolId":     {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					stakingPool, err := storeFrom(p.Context).GetStakingPoolData(p.Args["stakingPoolId"].(int))
+					if err != nil {
+						return nil, err
+					}
+
+					if subpool := findSubpool(stakingPool, p.Args["subpoolId"].(int)); subpool != nil {
+						return subpool, nil
+					}
+					return nil, UtilsKOS.ErrSubpoolNotFound
+				},
+			},
+			"staker": {
+				Type:        stakerType,
+				Description: "null if the wallet has never staked",
+				Args: graphql.FieldConfigArgument{
+					"wallet": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					wallet, err := walletArg(p, "wallet")
+					if err != nil {
+						return nil, err
+					}
+					return loadersFrom(p.Context).stakers.Load(wallet), nil
+				},
+			},
+			"token": {
+				Type: metadataType,
+				Args: graphql.FieldConfigArgument{
+					"tokenId": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					tokenId := p.Args["tokenId"].(int)
+					if tokenId < 1 || tokenId > UtilsKOS.KOSCollectionSize {
+						return nil, fmt.Errorf("%w: tokenId must be a key ID between 1 and %d", utils.ErrValidationFailed, UtilsKOS.KOSCollectionSize)
+					}
+					return loadToken(p, tokenId), nil
+				},
+			},
+			"ownership": {
+				Type: ownershipType,
+				Args: graphql.FieldConfigArgument{
+					"address": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					address, err := walletArg(p, "address")
+					if err != nil {
+						return nil, err
+					}
+					return &ownership{Address: address, store: storeFrom(p.Context)}, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func resolvePools(p graphql.ResolveParams) (interface{}, error) {
+	status := p.Args["status"].(string)
+	if status == "all" {
+		return storeFrom(p.Context).GetAllStakingPools()
+	}
+
+	allPools, err := storeFrom(p.Context).FetchStakingPoolData()
+	if err != nil {
+		return nil, err
+	}
+
+	pools := map[string][]*models.StakingPool{
+		"stakeable": allPools.StakeablePools,
+		"ongoing":   allPools.OngoingPools,
+		"closed":    allPools.ClosedPools,
+	}[status]
+	if pools == nil {
+		return []*models.StakingPool{}, nil
+	}
+
+	return pools, nil
+}
+
+/*
+Returns the subpools of `stakingPool` with the given `status` ("active", "closed" or "all").
+*/
+func poolSubpools(stakingPool *models.StakingPool, status string) []*models.StakingSubpoolWithID {
+	var subpools []*models.StakingSubpool
+	if status != "closed" {
+		subpools = append(subpools, stakingPool.ActiveSubpools...)
+	}
+	if status != "active" {
+		subpools = append(subpools, stakingPool.ClosedSubpools...)
+	}
+
+	withIds := make([]*models.StakingSubpoolWithID, len(subpools))
+	for i, subpool := range subpools {
+		withIds[i] = &models.StakingSubpoolWithID{StakingPoolID: stakingPool.StakingPoolID, StakingSubpool: subpool}
+	}
+
+	return withIds
+}
+
+/*
+Returns the subpool with ID `subpoolId` of `stakingPool` (nil if it doesn't exist).
+*/
+func findSubpool(stakingPool *models.StakingPool, subpoolId int) *models.StakingSubpoolWithID {
+	for _, subpool := range poolSubpools(stakingPool, "all") {
+		if subpool.SubpoolID == subpoolId {
+			return subpool
+		}
+	}
+
+	return nil
+}
+
+func stakedKeyIds(subpool *models.StakingSubpoolWithID) []int {
+	keyIds := make([]int, len(subpool.StakedKeys))
+	for i, key := range subpool.StakedKeys {
+		keyIds[i] = key.TokenID
+	}
+
+	return keyIds
+}
+
+/*
+Resolves a field of a `StakingSubpoolWithID` (the default resolver doesn't look into the embedded `StakingSubpool`).
+*/
+func subpoolField(field func(s *models.StakingSubpoolWithID) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return field(p.Source.(*models.StakingSubpoolWithID)), nil
+	}
+}
+
+/*
+Resolves a trait of a Key Of Salvation from its simplified metadata, parsed from the metadata the token was loaded with.
+*/
+func simplifiedTrait(trait func(md *models.KOSSimplifiedMetadata) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		token := p.Source.(*kosToken)
+		md, err := UtilsKOS.ParseSimplifiedMetadata(token.TokenID, token.KOSMetadata)
+		if err != nil {
+			return nil, err
+		}
+		return trait(md), nil
+	}
+}
+
+/*
+Loads the metadata of `tokenId` through the request's metadata loader.
+*/
+func loadToken(p graphql.ResolveParams, tokenId int) func() (interface{}, error) {
+	thunk := loadersFrom(p.Context).metadata.Load(tokenId)
+
+	return func() (interface{}, error) {
+		metadata, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return &kosToken{TokenID: tokenId, KOSMetadata: metadata.(*models.KOSMetadata)}, nil
+	}
+}
+
+/*
+Loads the metadata of all `tokenIds` through the request's metadata loader.
+*/
+func loadTokens(p graphql.ResolveParams, tokenIds []int) func() (interface{}, error) {
+	thunk := loadersFrom(p.Context).metadata.LoadMany(tokenIds)
+
+	return func() (interface{}, error) {
+		metadatas, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+
+		tokens := make([]*kosToken, len(tokenIds))
+		for i, metadata := range metadatas.([]*models.KOSMetadata) {
+			tokens[i] = &kosToken{TokenID: tokenIds[i], KOSMetadata: metadata}
+		}
+		return tokens, nil
+	}
+}
+
+/*
+Returns the wallet in argument `name`, normalized the same way as the REST routes.
+*/
+func walletArg(p graphql.ResolveParams, name string) (string, error) {
+	wallet := p.Args[name].(string)
+	if !utils.ValidAddress(wallet) {
+		return "", fmt.Errorf("%w: %s must be a valid wallet address", utils.ErrValidationFailed, name)
+	}
+
+	return utils.NormalizeWallet(wallet), nil
+}
+
+func nonNullList(t graphql.Type) *graphql.NonNull {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+func nonZeroTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
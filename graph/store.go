@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"math/big"
+
+	ApiKOS "nbc-backend-api-v2/api/nfts/kos"
+	"nbc-backend-api-v2/models"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+Where the resolvers read the staking pools, stakers and ownership from.
+*/
+type Store interface {
+	GetAllStakingPools() ([]*models.StakingPool, error)
+	FetchStakingPoolData() (*models.AllStakingPools, error)
+	GetStakingPoolData(stakingPoolId int) (*models.StakingPool, error)
+	GetStakers(wallets []string) ([]*models.Staker, error)
+	GetStakersFromObjIDs(stakerObjIds []primitive.ObjectID) ([]*models.Staker, error)
+	GetStakerSubpools(wallet string) ([]*models.StakingSubpoolWithID, error)
+	GetTotalTokenReward(stakingPoolId int) (float64, error)
+	CalculateSubpoolTokenShare(stakingPoolId, subpoolId int) (float64, error)
+	CalculateStakerTotalSubpoolPoints(stakingPoolId int, wallet string) (float64, error)
+	CalcTotalTokenShare(stakingPoolId int, wallet string) (float64, error)
+	// the token IDs owned by `address` in the collections staked as `role`.
+	OwnerIDs(address string, role UtilsNFT.CollectionRole) ([]*big.Int, error)
+}
+
+/*
+Where the resolvers read the metadata of the Keys Of Salvation from.
+*/
+type MetadataStore interface {
+	// returns the metadata and the error of each token (in the same order). called once per batch of the metadata loader,
+	// so it should bound how many tokens it fetches at the same time.
+	FetchMetadata(tokenIds []int) ([]*models.KOSMetadata, []error)
+}
+
+/*
+Reads everything through `api/nfts/kos` (the database, the chain and IPFS). Used by `Execute`.
+*/
+type apiStore struct{}
+
+func (apiStore) GetAllStakingPools() ([]*models.StakingPool, error) {
+	return ApiKOS.GetAllStakingPools()
+}
+
+func (apiStore) FetchStakingPoolData() (*models.AllStakingPools, error) {
+	return ApiKOS.FetchStakingPoolData()
+}
+
+func (apiStore) GetStakingPoolData(stakingPoolId int) (*models.StakingPool, error) {
+	return ApiKOS.GetStakingPoolData(stakingPoolId)
+}
+
+func (apiStore) GetStakers(wallets []string) ([]*models.Staker, error) {
+	return ApiKOS.GetStakers(wallets)
+}
+
+func (apiStore) GetStakersFromObjIDs(stakerObjIds []primitive.ObjectID) ([]*models.Staker, error) {
+	return ApiKOS.GetStakersFromObjIDs(stakerObjIds)
+}
+
+func (apiStore) GetStakerSubpools(wallet string) ([]*models.StakingSubpoolWithID, error) {
+	return ApiKOS.GetStakerSubpools(wallet)
+}
+
+func (apiStore) GetTotalTokenReward(stakingPoolId int) (float64, error) {
+	return ApiKOS.GetTotalTokenReward(stakingPoolId)
+}
+
+func (apiStore) CalculateSubpoolTokenShare(stakingPoolId, subpoolId int) (float64, error) {
+	return ApiKOS.CalculateSubpoolTokenShare(stakingPoolId, subpoolId)
+}
+
+func (apiStore) CalculateStakerTotalSubpoolPoints(stakingPoolId int, wallet string) (float64, error) {
+	return ApiKOS.CalculateStakerTotalSubpoolPoints(stakingPoolId, wallet)
+}
+
+func (apiStore) CalcTotalTokenShare(stakingPoolId int, wallet string) (float64, error) {
+	return ApiKOS.CalcTotalTokenShare(stakingPoolId, wallet)
+}
+
+func (apiStore) OwnerIDs(address string, role UtilsNFT.CollectionRole) ([]*big.Int, error) {
+	return UtilsNFT.OwnerIDsByRole(address, role)
+}
+
+// fetches at most `UtilsNFT.MaxConcurrentMetadataFetches` tokens at a time.
+func (apiStore) FetchMetadata(tokenIds []int) ([]*models.KOSMetadata, []error) {
+	return ApiKOS.FetchMetadataEach(tokenIds)
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+/*
+What a delegate can do for a vault.
+*/
+const (
+	DelegationScopeStaking  = "staking"  // add subpools and unstake from them
+	DelegationScopeClaiming = "claiming" // claim the rewards of closed subpools
+)
+
+/*
+Defines the `RHDelegations` collection. A vault (e.g. a cold wallet holding the keys) authorizes a delegate (e.g. a hot wallet)
+to stake and/or claim on its behalf by signing the delegation as EIP-712 typed data. Contract wallets (e.g. Safe multisigs)
+sign through EIP-1271, which lets keys held in a multisig be staked by one of its owners.
+
+A vault has at most one delegation per delegate; signing a new one with a higher nonce replaces it, so a delegation
+with neither scope revokes the previous one.
+*/
+type Delegation struct {
+	Vault     string    `bson:"vault" json:"vault" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Delegate  string    `bson:"delegate" json:"delegate" example:"0x1f2e3d4c5b6a79880716253443526170819a0b1c"`
+	Staking   bool      `bson:"staking" json:"staking" example:"true"`
+	Claiming  bool      `bson:"claiming" json:"claiming" example:"true"`
+	Expiry    time.Time `bson:"expiry" json:"expiry"`
+	Nonce     int64     `bson:"nonce" json:"nonce" example:"1"`
+	Signature string    `bson:"signature" json:"signature"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+/*
+The delegations of a wallet: the delegates it authorized (as a vault) and the vaults that authorized it (as a delegate).
+Only delegations that haven't expired and have at least one scope are included.
+*/
+type StakerDelegations struct {
+	Delegates []*Delegation `json:"delegates"`
+	Vaults    []*Delegation `json:"vaults"`
+}
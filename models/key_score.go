@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+/*
+How rare and how useful for staking a Key Of Salvation is, compared to the rest of the collection.
+*/
+type KOSKeyScore struct {
+	TokenID           int     `json:"tokenId" example:"25"`
+	HouseTrait        string  `json:"houseTrait" example:"Tiger"`
+	TypeTrait         string  `json:"typeTrait" example:"Brawler"`
+	LuckTrait         float64 `json:"luckTrait" example:"45"`
+	LuckBoostTrait    float64 `json:"luckBoostTrait" example:"1.05"`
+	Angel             bool    `json:"angel" example:"false"`             // whether the key's luck is `AngelLuck`
+	RarityScore       float64 `json:"rarityScore" example:"86.51"`       // the sum of 1 / (the share of keys with the same value) over the key's traits
+	RarityRank        int     `json:"rarityRank" example:"112"`          // 1 for the rarest key
+	SoloPoints        float64 `json:"soloPoints" example:"129.67"`       // the points of a subpool with only this key and no keychains
+	ComboPotential    float64 `json:"comboPotential" example:"81.24"`    // the key combo bonus per key to expect in a multi-key subpool, given how many keys share its house and type
+	StakingUtility    float64 `json:"stakingUtility" example:"210.91"`   // `SoloPoints` + `ComboPotential`
+	UtilityRank       int     `json:"utilityRank" example:"38"`          // 1 for the most useful key for staking
+	UtilityPercentile float64 `json:"utilityPercentile" example:"99.26"` // the share of keys with a lower `StakingUtility`, in percent
+}
+
+/*
+A page of the Key Of Salvation keys ranked by rarity or staking utility.
+*/
+type KOSKeyRanking struct {
+	By        string         `json:"by" example:"utility"` // `rarity` or `utility`
+	Keys      []*KOSKeyScore `json:"keys"`
+	Total     int            `json:"total" example:"5000"` // the number of ranked keys (across all pages)
+	Page      int            `json:"page" example:"1"`
+	Limit     int            `json:"limit" example:"25"`
+	UpdatedAt time.Time      `json:"updatedAt"` // when the scores were computed
+}
@@ -1,32 +1,87 @@
 package models
 
+import "time"
+
 /*
 Represents the full metadata for a Key Of Salvation (taken from Pinata).
 */
-type KOSMetadata struct {
-	Name         string      `json:"name"`
-	Image        string      `json:"image"`
-	AnimationUrl string      `json:"animation_url"`
-	Attributes   []Attribute `json:"attributes,omitempty"`
-}
+type KOSMetadata = NFTMetadata
 
 /*
 Represents a Key Of Salvation's metadata. A more simplified version compared to the `KOSMetadata` struct.
 */
 type KOSSimplifiedMetadata struct {
-	TokenID        int     `json:"tokenID"`        // the token ID of the Key Of Salvation
-	AnimationUrl   string  `json:"animationUrl"`   // the animation URL of the Key Of Salvation
-	HouseTrait     string  `json:"houseTrait"`     // the house trait of the Key Of Salvation
-	TypeTrait      string  `json:"typeTrait"`      // the type trait of the Key Of Salvation
-	LuckTrait      float64 `json:"luckTrait"`      // the luck trait of the Key Of Salvation
-	LuckBoostTrait float64 `json:"luckBoostTrait"` // the luck boost trait of the Key Of Salvation
+	TokenID        int                    `json:"tokenID" example:"25"`                                              // the token ID of the Key Of Salvation
+	AnimationUrl   string                 `json:"animationUrl" example:"https://ipfs.io/ipfs/QmKeyAnimation/25.mp4"` // the animation URL of the Key Of Salvation
+	HouseTrait     string                 `json:"houseTrait"`                                                        // the house trait of the Key Of Salvation
+	TypeTrait      string                 `json:"typeTrait"`                                                         // the type trait of the Key Of Salvation
+	LuckTrait      float64                `json:"luckTrait" example:"45"`                                            // the luck trait of the Key Of Salvation
+	LuckBoostTrait float64                `json:"luckBoostTrait" example:"1.5"`                                      // the luck boost trait of the Key Of Salvation
+	Traits         map[string]interface{} `json:"traits,omitempty"`                                                  // every trait of the Key Of Salvation, keyed by its `trait_type`
+}
+
+/*
+A Key Of Salvation's metadata as stored in `RHKOSMetadata`.
+*/
+type StoredKOSMetadata = StoredNFTMetadata
+
+/*
+The result of syncing the metadata of an NFT collection from IPFS into its store (e.g. `RHKOSMetadata`).
+*/
+type MetadataSyncResult struct {
+	Total             int   `json:"total"`             // the number of tokens in the collection
+	Skipped           int   `json:"skipped"`           // tokens that were already stored (when not refreshing)
+	Added             int   `json:"added"`             // tokens stored for the first time
+	Changed           int   `json:"changed"`           // stored tokens whose metadata changed (when refreshing)
+	Unchanged         int   `json:"unchanged"`         // stored tokens whose metadata didn't change (when refreshing)
+	FailedTokenIDs    []int `json:"failedTokenIds"`    // tokens that couldn't be fetched or stored (syncing again retries them)
+	MalformedTokenIDs []int `json:"malformedTokenIds"` // tokens that were stored but don't match `KOSMetadataSchema`
+}
+
+// the luck of an angel key.
+const AngelLuck = 100
+
+/*
+The filters of a Key Of Salvation token search. Zero values don't filter, except for the luck ranges.
+*/
+type KOSTokenFilter struct {
+	House         string  // matched case-insensitively
+	Type          string  // matched case-insensitively
+	LuckMin       float64 // inclusive
+	LuckMax       float64 // inclusive
+	LuckBoostMin  float64 // inclusive, in percent (e.g. 5 for a 1.05x boost)
+	LuckBoostMax  float64 // inclusive, in percent
+	Angel         *bool   // whether the key's luck is `AngelLuck`
+	StakingPoolID int     // the staking pool that `Staked` applies to
+	Staked        *bool   // whether the key is in an active subpool of `StakingPoolID`
+}
+
+/*
+A page of Key Of Salvation tokens matching a search, with the rarity of every trait across the collection.
+*/
+type KOSTokenSearch struct {
+	Tokens        []*KOSSimplifiedMetadata `json:"tokens"`
+	Total         int                      `json:"total" example:"312"` // the number of tokens matching the search (across all pages)
+	Page          int                      `json:"page" example:"1"`
+	Limit         int                      `json:"limit" example:"25"`
+	IndexedTokens int                      `json:"indexedTokens" example:"5000"` // the number of stored tokens with valid metadata (what the search and the rarity are based on)
+	TraitStats    []*TraitStats            `json:"traitStats"`                   // the rarity of each trait type, ordered by trait type
+	UpdatedAt     time.Time                `json:"updatedAt"`                    // when the tokens were loaded from the store (they're cached for a short while)
+}
+
+/*
+How often each value of a trait type appears across the Key Of Salvation collection.
+*/
+type TraitStats struct {
+	TraitType string             `json:"traitType" example:"House"`
+	Values    []*TraitValueStats `json:"values"` // the rarest values first
 }
 
 /*
-The `Attribute` struct represents a single attribute for a Key Of Salvation.
+How often a single value of a trait appears across the Key Of Salvation collection.
 */
-type Attribute struct {
-	TraitType   string      `json:"trait_type,omitempty"`
-	DisplayType string      `json:"display_type,omitempty"`
-	Value       interface{} `json:"value,omitempty"`
+type TraitValueStats struct {
+	Value      interface{} `json:"value" example:"Tiger"`
+	Count      int         `json:"count" example:"420"`
+	Percentage float64     `json:"percentage" example:"8.4"` // the share of the indexed tokens that have the value, in percent
 }
@@ -19,58 +19,59 @@ type AllStakingPools struct {
 Defines the `StakingPool` collection which is used to store all staking pool data.
 */
 type StakingPool struct {
-	ID               primitive.ObjectID `bson:"_id,omitempty"`              // the object ID of the staking pool
-	StakingPoolID    int                `bson:"stakingPoolID,omitempty"`    // unique ID for each staking pool (starts at 1 for the first staking pool, increments everytime)
-	Reward           Reward             `bson:"reward,omitempty"`           // the reward for staking in this pool
-	TotalYieldPoints float64            `bson:"totalYieldPoints,omitempty"` // the total yield points generated across ALL stakers' subpools (calculated from `StakingSubpool`)
-	EntryAllowance   time.Time          `bson:"entryAllowance,omitempty"`   // the time when stakers are allowed to enter the pool (also when the staking pool is created)
-	StartTime        time.Time          `bson:"startTime,omitempty"`        // the start time of the staking pool (where entry is no longer allowed and staking has started)
-	EndTime          time.Time          `bson:"endTime,omitempty"`          // when the staking pool ends (when the staking pool is closed)
-	ActiveSubpools   []*StakingSubpool  `bson:"activeSubpools,omitempty"`   // the active subpools for this staking pool (points to a subpool instance from the `StakingSubpool` collection)
-	ClosedSubpools   []*StakingSubpool  `bson:"closedSubpools,omitempty"`   // the closed subpools for this staking pool (either by unstaking, bans or after the pool ends. points to a subpool instance from the `StakingSubpool` collection)
+	ID               primitive.ObjectID `bson:"_id,omitempty"`                                           // the object ID of the staking pool
+	StakingPoolID    int                `bson:"stakingPoolID,omitempty" example:"3"`                     // unique ID for each staking pool (starts at 1 for the first staking pool, increments everytime)
+	Reward           Reward             `bson:"reward,omitempty"`                                        // the reward for staking in this pool
+	TotalYieldPoints float64            `bson:"totalYieldPoints,omitempty" example:"18234.5"`            // the total yield points generated across ALL stakers' subpools (calculated from `StakingSubpool`)
+	EntryAllowance   time.Time          `bson:"entryAllowance,omitempty" example:"2023-08-01T00:00:00Z"` // the time when stakers are allowed to enter the pool (also when the staking pool is created)
+	StartTime        time.Time          `bson:"startTime,omitempty" example:"2023-08-08T00:00:00Z"`      // the start time of the staking pool (where entry is no longer allowed and staking has started)
+	EndTime          time.Time          `bson:"endTime,omitempty" example:"2023-09-07T00:00:00Z"`        // when the staking pool ends (when the staking pool is closed)
+	ActiveSubpools   []*StakingSubpool  `bson:"activeSubpools,omitempty"`                                // the active subpools for this staking pool (points to a subpool instance from the `StakingSubpool` collection)
+	ClosedSubpools   []*StakingSubpool  `bson:"closedSubpools,omitempty"`                                // the closed subpools for this staking pool (either by unstaking, bans or after the pool ends. points to a subpool instance from the `StakingSubpool` collection)
+	PublishedPhases  []string           `bson:"publishedPhases,omitempty" json:"-"`                      // the phase transitions of this staking pool already written to the outbox (see `PublishPoolPhaseTransitions`)
 }
 
 /*
 Defines the `StakingSubpool` collection which is used to store all staking subpool data for each staking pool.
 */
 type StakingSubpool struct {
-	SubpoolID                int                      `bson:"subpoolID,omitempty"`                // unique ID for each staking subpool (starts at 1 for the first staking subpool IN EACH POOL, increments everytime)
-	Staker                   *primitive.ObjectID      `bson:"staker,omitempty"`                   // the staker that owns this subpool (points to a staker instance from the `Staker` collection)
-	StakerWallet             string                   `bson:"stakerWallet,omitempty"`             // the staker's wallet address
-	EnterTime                time.Time                `bson:"enterTime,omitempty"`                // the time when the staker enters the pool with this subpool
-	ExitTime                 time.Time                `bson:"exitTime,omitempty"`                 // the time when the staker exits the pool with this subpool
-	StakedKeys               []*KOSSimplifiedMetadata `bson:"stakedKeys,omitempty"`               // the keys of salvation staked in this subpool
-	StakedKeychainIDs        []int                    `bson:"stakedKeychainIds,omitempty"`        // the keychain staked in this subpool
-	StakedSuperiorKeychainID int                      `bson:"stakedSuperiorKeychainId,omitempty"` // the superior keychain staked in this subpool
-	SubpoolPoints            float64                  `bson:"subpoolPoints,omitempty"`            // the subpool's yield points generated by this subpool based on the NFTs staked
-	RewardClaimable          bool                     `bson:"rewardClaimable,omitempty"`          // whether the reward is claimable or not (if the staker is banned, this is false. only true if subpool is in ClosedSubpools AND `banned` is false)
-	RewardClaimed            bool                     `bson:"rewardClaimed,omitempty"`            // whether the reward has been claimed or not
-	Banned                   bool                     `bson:"banned,omitempty"`                   // whether the staker is banned for this particular subpool. if yes, they cannot claim the reward, even if `RewardClaimed` is false.
+	SubpoolID                int                      `bson:"subpoolID,omitempty" example:"12"`                                            // unique ID for each staking subpool (starts at 1 for the first staking subpool IN EACH POOL, increments everytime)
+	Staker                   *primitive.ObjectID      `bson:"staker,omitempty"`                                                            // the staker that owns this subpool (points to a staker instance from the `Staker` collection)
+	StakerWallet             string                   `bson:"stakerWallet,omitempty" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"` // the staker's wallet address
+	EnterTime                time.Time                `bson:"enterTime,omitempty"`                                                         // the time when the staker enters the pool with this subpool
+	ExitTime                 time.Time                `bson:"exitTime,omitempty"`                                                          // the time when the staker exits the pool with this subpool
+	StakedKeys               []*KOSSimplifiedMetadata `bson:"stakedKeys,omitempty"`                                                        // the keys of salvation staked in this subpool
+	StakedKeychainIDs        []int                    `bson:"stakedKeychainIds,omitempty" example:"[45,900]"`                              // the keychain staked in this subpool
+	StakedSuperiorKeychainID int                      `bson:"stakedSuperiorKeychainId,omitempty" example:"-1"`                             // the superior keychain staked in this subpool
+	SubpoolPoints            float64                  `bson:"subpoolPoints,omitempty" example:"623.86"`                                    // the subpool's yield points generated by this subpool based on the NFTs staked
+	RewardClaimable          bool                     `bson:"rewardClaimable,omitempty"`                                                   // whether the reward is claimable or not (if the staker is banned, this is false. only true if subpool is in ClosedSubpools AND `banned` is false)
+	RewardClaimed            bool                     `bson:"rewardClaimed,omitempty"`                                                     // whether the reward has been claimed or not
+	Banned                   bool                     `bson:"banned,omitempty"`                                                            // whether the staker is banned for this particular subpool. if yes, they cannot claim the reward, even if `RewardClaimed` is false.
 }
 
 /*
 An alternate version of the `StakingSubpool` struct which is used for API requests (with different types).
 */
 type StakingSubpoolAlt struct {
-	SubpoolID              int                 `json:"subpoolID,omitempty"`              // unique ID for each staking subpool (starts at 1 for the first staking subpool IN EACH POOL, increments everytime)
-	Staker                 *primitive.ObjectID `json:"staker,omitempty"`                 // the staker that owns this subpool (points to a staker instance from the `Staker` collection)
-	StakerWallet           string              `json:"stakerWallet,omitempty"`           // the staker's wallet address
-	EnterTime              int64               `json:"enterTime,omitempty"`              // the time when the staker enters the pool with this subpool (in unix time)
-	ExitTime               int64               `json:"exitTime,omitempty"`               // the time when the staker exits the pool with this subpool (in unix time)
-	StakedKeys             []*NFTData          `json:"stakedKeys,omitempty"`             // the keys of salvation staked in this subpool
-	StakedKeychains        []*NFTData          `json:"stakedKeychains,omitempty"`        // the keychain staked in this subpool
-	StakedSuperiorKeychain *NFTData            `json:"stakedSuperiorKeychain,omitempty"` // the superior keychain staked in this subpool
-	SubpoolPoints          float64             `json:"subpoolPoints,omitempty"`          // the subpool's yield points generated by this subpool based on the NFTs staked
-	RewardClaimable        bool                `json:"rewardClaimable,omitempty"`        // whether the reward is claimable or not (if the staker is banned, this is false. only true if subpool is in ClosedSubpools AND `banned` is false)
-	RewardClaimed          bool                `json:"rewardClaimed,omitempty"`          // whether the reward has been claimed or not
-	Banned                 bool                `json:"banned,omitempty"`                 // whether the staker is banned for this particular subpool. if yes, they cannot claim the reward, even if `RewardClaimed` is false.
+	SubpoolID              int                 `json:"subpoolID,omitempty" example:"12"`                                            // unique ID for each staking subpool (starts at 1 for the first staking subpool IN EACH POOL, increments everytime)
+	Staker                 *primitive.ObjectID `json:"staker,omitempty"`                                                            // the staker that owns this subpool (points to a staker instance from the `Staker` collection)
+	StakerWallet           string              `json:"stakerWallet,omitempty" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"` // the staker's wallet address
+	EnterTime              int64               `json:"enterTime,omitempty" example:"1690934400"`                                    // the time when the staker enters the pool with this subpool (in unix time)
+	ExitTime               int64               `json:"exitTime,omitempty" example:"1694044800"`                                     // the time when the staker exits the pool with this subpool (in unix time)
+	StakedKeys             []*NFTData          `json:"stakedKeys,omitempty"`                                                        // the keys of salvation staked in this subpool
+	StakedKeychains        []*NFTData          `json:"stakedKeychains,omitempty"`                                                   // the keychain staked in this subpool
+	StakedSuperiorKeychain *NFTData            `json:"stakedSuperiorKeychain,omitempty"`                                            // the superior keychain staked in this subpool
+	SubpoolPoints          float64             `json:"subpoolPoints,omitempty" example:"623.86"`                                    // the subpool's yield points generated by this subpool based on the NFTs staked
+	RewardClaimable        bool                `json:"rewardClaimable,omitempty" example:"true"`                                    // whether the reward is claimable or not (if the staker is banned, this is false. only true if subpool is in ClosedSubpools AND `banned` is false)
+	RewardClaimed          bool                `json:"rewardClaimed,omitempty" example:"false"`                                     // whether the reward has been claimed or not
+	Banned                 bool                `json:"banned,omitempty" example:"false"`                                            // whether the staker is banned for this particular subpool. if yes, they cannot claim the reward, even if `RewardClaimed` is false.
 }
 
 /*
 Defines a StakingSubpool struct but also takes into account the `StakingPoolID` of the subpool.
 */
 type StakingSubpoolWithID struct {
-	StakingPoolID int `bson:"stakingPoolID,omitempty"`
+	StakingPoolID int `bson:"stakingPoolID,omitempty" example:"3"`
 	*StakingSubpool
 }
 
@@ -99,8 +100,8 @@ type BannedData struct {
 Represents a Reward for a staking pool.
 */
 type Reward struct {
-	Name   string  `bson:"name"`   // example: "REC", "Limited Edition Collection X", etc.
-	Amount float64 `bson:"amount"` // the amount of the rewards. example: if Name is "REC" and Amount is 100, then the reward is 100 REC in total.
+	Name   string  `bson:"name" example:"REC"`      // example: "REC", "Limited Edition Collection X", etc.
+	Amount float64 `bson:"amount" example:"100000"` // the amount of the rewards. example: if Name is "REC" and Amount is 100, then the reward is 100 REC in total.
 }
 
 /*
@@ -116,20 +117,31 @@ type KeyCombo struct {
 Represents a detailed way of calculating the subpool points, breaking down how the points are calculated.
 */
 type DetailedSubpoolPoints struct {
-	LuckAndLuckBoostSum float64 `json:"luckAndLuckBoostSum"` // the sum of the luck and luck boost of all keys
-	KeyCombo            float64 `json:"keyCombo"`            // the key combo multiplier
-	KeychainCombo       float64 `json:"keychainCombo"`       // the keychain combo multiplier
-	ComboSum            float64 `json:"comboSum"`            // the total subpool points that the staker will earn (calculated by the formula)
+	LuckAndLuckBoostSum float64 `json:"luckAndLuckBoostSum" example:"412.5"` // the sum of the luck and luck boost of all keys
+	KeyCombo            float64 `json:"keyCombo" example:"1.25"`             // the key combo multiplier
+	KeychainCombo       float64 `json:"keychainCombo" example:"1.1"`         // the keychain combo multiplier
+	ComboSum            float64 `json:"comboSum" example:"623.86"`           // the total subpool points that the staker will earn (calculated by the formula)
+}
+
+/*
+Represents how an existing subpool's points were calculated (used to backtrack the subpool points of a subpool).
+*/
+type BacktrackedSubpoolPoints struct {
+	LuckAndLuckBoostSum float64 `json:"luckAndLuckBoostSum" example:"412.5"` // the sum of the luck and luck boost of all keys
+	AngelMultiplier     float64 `json:"angelMultiplier" example:"1.1"`       // the angel multiplier of the keys staked
+	KeyCombo            float64 `json:"keyCombo" example:"1.25"`             // the key combo multiplier
+	KeychainCombo       float64 `json:"keychainCombo" example:"1.1"`         // the keychain combo multiplier
+	TotalSubpoolPoints  float64 `json:"totalSubpoolPoints" example:"623.86"` // the total subpool points of the subpool
 }
 
 /*
 Represents all details required before a staker adds a subpool to a specific staking pool with tokens as a reward.
 */
 type DetailedTokenSubpoolPreAddCalc struct {
-	TokenShare         float64 `json:"tokenShare"`         // the token share of the staker (if they were to add the subpool)
-	PoolTotalReward    float64 `json:"poolTotalReward"`    // the total reward of the staking pool
-	PoolRewardName     string  `json:"poolRewardName"`     // the name of the reward
-	NewTotalPoolPoints float64 `json:"newTotalPoolPoints"` // the new total pool points of the staker after adding the subpool
+	TokenShare         float64 `json:"tokenShare" example:"3412.77"`          // the token share of the staker (if they were to add the subpool)
+	PoolTotalReward    float64 `json:"poolTotalReward" example:"100000"`      // the total reward of the staking pool
+	PoolRewardName     string  `json:"poolRewardName" example:"REC"`          // the name of the reward
+	NewTotalPoolPoints float64 `json:"newTotalPoolPoints" example:"18858.36"` // the new total pool points of the staker after adding the subpool
 	*DetailedSubpoolPoints
 }
 
@@ -141,3 +153,77 @@ type KOSStakerInventory struct {
 	KeychainData         []*NFTData `json:"keychainData"`
 	SuperiorKeychainData []*NFTData `json:"superiorKeychainData"`
 }
+
+/*
+The result of claiming all of a staker's claimable rewards at once.
+*/
+type ClaimAllResult struct {
+	Claimed []*Reward      `json:"claimed"` // the total amount claimed of each reward
+	Results []*ClaimResult `json:"results"` // the result of each subpool whose reward was claimable
+}
+
+/*
+The result of claiming the reward of a single subpool in `ClaimAllResult`.
+*/
+type ClaimResult struct {
+	StakingPoolID int     `json:"stakingPoolId" example:"3"`
+	SubpoolID     int     `json:"subpoolId" example:"12"`
+	Claimed       bool    `json:"claimed" example:"true"`
+	RewardName    string  `json:"rewardName" example:"REC Token"`
+	Amount        float64 `json:"amount" example:"3412.77"`                      // the amount claimed (0 if the claim failed)
+	ErrorCode     string  `json:"errorCode,omitempty" example:"ALREADY_CLAIMED"` // why the claim failed
+	Error         string  `json:"error,omitempty" example:"reward has already been claimed"`
+}
+
+/*
+A subpool to create when staking several subpools at once.
+*/
+type SubpoolSpec struct {
+	KeyIDs             []int `json:"keyIds" example:"[25,1402,3310]"`
+	KeychainIDs        []int `json:"keychainIds" example:"[45]"`
+	SuperiorKeychainID int   `json:"superiorKeychainId" example:"-1"`
+}
+
+/*
+The subpools created by staking several subpools at once, in the order they were given.
+*/
+type BatchStakeResult struct {
+	StakingPoolID int              `json:"stakingPoolId" example:"3"`
+	Subpools      []*StakedSubpool `json:"subpools"`
+	TotalPoints   float64          `json:"totalPoints" example:"1843.2"` // the total points of the created subpools
+}
+
+/*
+A subpool created by staking several subpools at once.
+*/
+type StakedSubpool struct {
+	SubpoolID          int     `json:"subpoolId" example:"12"`
+	KeyIDs             []int   `json:"keyIds" example:"[25,1402,3310]"`
+	KeychainIDs        []int   `json:"keychainIds" example:"[45]"`
+	SuperiorKeychainID int     `json:"superiorKeychainId" example:"-1"`
+	SubpoolPoints      float64 `json:"subpoolPoints" example:"623.86"`
+}
+
+/*
+The recommended way for a staker to stake their unstaked keys, keychains and superior keychains in a staking pool to get the most points.
+*/
+type SubpoolRecommendation struct {
+	StakingPoolID             int                   `json:"stakingPoolId" example:"3"`
+	Subpools                  []*RecommendedSubpool `json:"subpools"`
+	TotalPoints               float64               `json:"totalPoints" example:"1843.2"`         // the total points of the recommended subpools
+	ProjectedTokenShare       float64               `json:"projectedTokenShare" example:"8120.5"` // the token share of the recommended subpools if they were added now (0 for non-token rewards)
+	UnusedKeyIDs              []int                 `json:"unusedKeyIds" example:"[77]"`
+	UnusedKeychainIDs         []int                 `json:"unusedKeychainIds" example:"[]"`
+	UnusedSuperiorKeychainIDs []int                 `json:"unusedSuperiorKeychainIds" example:"[]"`
+}
+
+/*
+A subpool in a `SubpoolRecommendation`. The key, keychain and superior keychain IDs can be staked as they are (e.g. as a `SubpoolSpec`).
+*/
+type RecommendedSubpool struct {
+	KeyIDs              []int   `json:"keyIds" example:"[25,1402,3310]"`
+	KeychainIDs         []int   `json:"keychainIds" example:"[45]"`
+	SuperiorKeychainID  int     `json:"superiorKeychainId" example:"-1"`
+	ProjectedTokenShare float64 `json:"projectedTokenShare" example:"2740.16"` // 0 for non-token rewards
+	*DetailedSubpoolPoints
+}
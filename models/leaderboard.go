@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+/*
+The leaderboard of a single staking pool: its stakers ranked by the points of their (unbanned) active and closed subpools.
+*/
+type PoolLeaderboard struct {
+	StakingPoolID int                     `json:"stakingPoolId" example:"3"`
+	RewardName    string                  `json:"rewardName" example:"REC"`
+	RewardAmount  float64                 `json:"rewardAmount" example:"100000"`
+	TotalPoints   float64                 `json:"totalPoints" example:"18234.5"` // the total points of ALL subpools in the pool (what the token shares are calculated from)
+	Entries       []*PoolLeaderboardEntry `json:"entries"`
+	Total         int64                   `json:"total" example:"148"` // the number of stakers on the leaderboard (across all pages)
+	Page          int                     `json:"page" example:"1"`
+	Limit         int                     `json:"limit" example:"25"`
+	UpdatedAt     time.Time               `json:"updatedAt"` // when the leaderboard was computed (it's cached for a short while)
+}
+
+/*
+A staker on a staking pool's leaderboard.
+*/
+type PoolLeaderboardEntry struct {
+	Rank                int     `bson:"-" json:"rank" example:"1"`
+	Wallet              string  `bson:"wallet" json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Points              float64 `bson:"points" json:"points" example:"1843.2"`                          // the sum of the points of the staker's subpools in the pool
+	SubpoolCount        int     `bson:"subpoolCount" json:"subpoolCount" example:"3"`                   // the number of the staker's subpools in the pool
+	ProjectedTokenShare float64 `bson:"projectedTokenShare" json:"projectedTokenShare" example:"10108"` // the staker's share of the reward (ONLY FOR TOKEN REWARDS) if the points don't change
+}
+
+/*
+The all-time leaderboard of a reward: stakers ranked by how much of the reward they've earned across all staking pools.
+*/
+type RewardLeaderboard struct {
+	RewardName string                    `json:"rewardName" example:"REC"`
+	Entries    []*RewardLeaderboardEntry `json:"entries"`
+	Total      int64                     `json:"total" example:"912"` // the number of stakers on the leaderboard (across all pages)
+	Page       int                       `json:"page" example:"1"`
+	Limit      int                       `json:"limit" example:"25"`
+	UpdatedAt  time.Time                 `json:"updatedAt"` // when the leaderboard was computed (it's cached for a short while)
+}
+
+/*
+A staker on a reward's all-time leaderboard.
+*/
+type RewardLeaderboardEntry struct {
+	Rank          int     `bson:"-" json:"rank" example:"1"`
+	Wallet        string  `bson:"wallet" json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	EarnedRewards float64 `bson:"earnedRewards" json:"earnedRewards" example:"52310.75"` // the amount of the reward the staker has earned so far
+}
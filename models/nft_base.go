@@ -2,6 +2,7 @@ package models
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -24,9 +25,41 @@ type OwnershipData struct {
 A base struct that represents the data of any NFT.
 */
 type NFTData struct {
-	Name      string      `json:"name"`
-	ImageUrl  string      `json:"imageUrl"`
-	TokenID   int         `json:"tokenID"` // the token id just in case it doesn't exist in Metadata
+	Name      string      `json:"name" example:"Key Of Salvation #25"`
+	ImageUrl  string      `json:"imageUrl" example:"https://ipfs.io/ipfs/QmKeyAnimation/25.mp4"`
+	TokenID   int         `json:"tokenID" example:"25"` // the token id just in case it doesn't exist in Metadata
 	Metadata  interface{} `json:"metadata"`
-	Stakeable bool        `json:"stakeable"`
+	Stakeable bool        `json:"stakeable" example:"true"`
+	Chain     string      `json:"chain,omitempty" example:"ethereum"` // the chain the token is owned on (only in inventories)
+}
+
+/*
+The metadata of an NFT (as returned by its token URI), following the common ERC-721 metadata format.
+*/
+type NFTMetadata struct {
+	Name         string      `bson:"name" json:"name" example:"Key Of Salvation #25"`
+	Description  string      `bson:"description,omitempty" json:"description,omitempty"`
+	Image        string      `bson:"image" json:"image" example:"https://ipfs.io/ipfs/QmKeyImage/25.png"`
+	AnimationUrl string      `bson:"animationUrl" json:"animation_url" example:"https://ipfs.io/ipfs/QmKeyAnimation/25.mp4"`
+	Attributes   []Attribute `bson:"attributes,omitempty" json:"attributes,omitempty"`
+}
+
+/*
+The `Attribute` struct represents a single attribute (trait) of an NFT.
+*/
+type Attribute struct {
+	TraitType   string      `bson:"traitType,omitempty" json:"trait_type,omitempty"`
+	DisplayType string      `bson:"displayType,omitempty" json:"display_type,omitempty"`
+	Value       interface{} `bson:"value" json:"value,omitempty"`
+}
+
+/*
+An NFT's metadata as stored in its collection's metadata store (e.g. `RHKOSMetadata`).
+*/
+type StoredNFTMetadata struct {
+	TokenID     int          `bson:"tokenID"`
+	Metadata    *NFTMetadata `bson:"metadata"`
+	ContentHash string       `bson:"contentHash"` // the SHA-256 hash of the metadata (used to detect changed metadata when refreshing)
+	FetchedAt   time.Time    `bson:"fetchedAt"`   // when the metadata was last fetched
+	UpdatedAt   time.Time    `bson:"updatedAt"`   // when the metadata last changed
 }
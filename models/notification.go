@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+The channels a notification can be sent to.
+*/
+const (
+	NotificationChannelInApp   = "inApp"   // the in-app inbox (`/v1/stakers/:wallet/notifications`)
+	NotificationChannelEmail   = "email"   // `NotificationPreferences.Email`
+	NotificationChannelDiscord = "discord" // `NotificationPreferences.DiscordWebhookURL`
+)
+
+/*
+The kinds of notifications sent to stakers.
+*/
+const (
+	NotificationEntryClosing   = "entryClosing"   // a staking pool's entry window closes soon (before `StartTime`)
+	NotificationRewardExpiring = "rewardExpiring" // an unclaimed reward can no longer be claimed soon (48 hours after `EndTime`)
+	NotificationSubpoolBanned  = "subpoolBanned"  // one of the staker's subpools was banned
+)
+
+/*
+Defines the `RHNotificationPreferences` collection, which stores how each wallet wants to be notified.
+Wallets without preferences only receive notifications in their in-app inbox.
+*/
+type NotificationPreferences struct {
+	Wallet            string    `bson:"wallet" json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Channels          []string  `bson:"channels" json:"channels" example:"[\"inApp\",\"discord\"]"`                                                    // the channels notifications are sent to
+	Email             string    `bson:"email,omitempty" json:"email,omitempty" example:"staker@example.com"`                                           // required for the `email` channel
+	DiscordWebhookURL string    `bson:"discordWebhookURL,omitempty" json:"discordWebhookUrl,omitempty" example:"https://discord.com/api/webhooks/1/x"` // required for the `discord` channel
+	MutedKinds        []string  `bson:"mutedKinds" json:"mutedKinds" example:"[\"entryClosing\"]"`                                                     // the kinds of notifications that aren't sent at all
+	UpdatedAt         time.Time `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+/*
+Defines the `RHNotifications` collection. Every notification sent to a wallet is stored, whichever channels it was sent to.
+*/
+type Notification struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id" example:"64f1c2a9e1b2c3d4e5f6071b"`
+	Wallet        string             `bson:"wallet" json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Kind          string             `bson:"kind" json:"kind" example:"rewardExpiring"`
+	Title         string             `bson:"title" json:"title" example:"Your reward for subpool #12 expires soon"`
+	Body          string             `bson:"body" json:"body"`
+	StakingPoolID int                `bson:"stakingPoolID,omitempty" json:"stakingPoolId,omitempty" example:"3"`
+	SubpoolID     int                `bson:"subpoolID,omitempty" json:"subpoolId,omitempty" example:"12"`
+	DedupeKey     string             `bson:"dedupeKey" json:"-"` // unique per notification (e.g. `rewardExpiring:3:12`), so that it's only sent once
+	InApp         bool               `bson:"inApp" json:"-"`     // whether the notification is shown in the in-app inbox
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	ReadAt        *time.Time         `bson:"readAt,omitempty" json:"readAt,omitempty"`
+}
+
+/*
+A page of a wallet's in-app inbox.
+*/
+type NotificationInbox struct {
+	Notifications []*Notification `json:"notifications"` // newest first
+	UnreadCount   int64           `json:"unreadCount" example:"2"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+const (
+	SubpoolStatusActive  = "active"  // staked in a pool that hasn't ended yet
+	SubpoolStatusClosed  = "closed"  // the pool ended and the reward can still be claimed
+	SubpoolStatusClaimed = "claimed" // the reward was claimed
+	SubpoolStatusBanned  = "banned"  // banned (e.g. the staker no longer owns the keys), so the reward can't be claimed
+	SubpoolStatusExpired = "expired" // the reward wasn't claimed before the claim deadline
+)
+
+/*
+A staker's portfolio: every staking pool they've staked in, with their subpools, points and rewards, and their ban history.
+*/
+type StakerPortfolio struct {
+	Wallet           string           `json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Pools            []*PortfolioPool `json:"pools"`            // newest staking pool first
+	RealizedRewards  []*Reward        `json:"realizedRewards"`  // the rewards the staker has earned (claimed) so far, across all pools
+	ProjectedRewards []*Reward        `json:"projectedRewards"` // the rewards the staker's active and still claimable subpools would earn (ONLY FOR TOKEN REWARDS)
+	BanHistory       *BanHistory      `json:"banHistory"`
+}
+
+/*
+A staking pool in a staker's portfolio.
+*/
+type PortfolioPool struct {
+	StakingPoolID   int                 `json:"stakingPoolId" example:"3"`
+	Reward          Reward              `json:"reward"`
+	EntryAllowance  time.Time           `json:"entryAllowance"`
+	StartTime       time.Time           `json:"startTime"`
+	EndTime         time.Time           `json:"endTime"`
+	ClaimDeadline   time.Time           `json:"claimDeadline"`                   // rewards of the pool can only be claimed until then
+	TotalPoints     float64             `json:"totalPoints" example:"18234.5"`   // the total points of ALL subpools in the pool
+	Points          float64             `json:"points" example:"1843.2"`         // the total points of the staker's unbanned subpools in the pool
+	RealizedReward  float64             `json:"realizedReward" example:"0"`      // the reward the staker claimed from the pool (ONLY FOR TOKEN REWARDS)
+	ProjectedReward float64             `json:"projectedReward" example:"10108"` // the reward the staker's active and still claimable subpools would earn (ONLY FOR TOKEN REWARDS)
+	Subpools        []*PortfolioSubpool `json:"subpools"`
+}
+
+/*
+A subpool in a staker's portfolio.
+*/
+type PortfolioSubpool struct {
+	SubpoolID          int        `json:"subpoolId" example:"12"`
+	Status             string     `json:"status" example:"active"` // one of `active`, `closed`, `claimed`, `banned` or `expired`
+	EnterTime          time.Time  `json:"enterTime"`
+	ExitTime           *time.Time `json:"exitTime,omitempty"`
+	KeyIDs             []int      `json:"keyIds" example:"[25,1402,3310]"`
+	KeychainIDs        []int      `json:"keychainIds" example:"[45]"`
+	SuperiorKeychainID int        `json:"superiorKeychainId" example:"-1"`
+	Points             float64    `json:"points" example:"623.86"`
+	TokenShare         float64    `json:"tokenShare" example:"3412.77"` // the subpool's share of the reward, claimed or not (ONLY FOR TOKEN REWARDS, 0 if banned)
+	ClaimDeadline      *time.Time `json:"claimDeadline,omitempty"`      // only for closed subpools whose reward can still be claimed
+}
+
+/*
+A staker's ban history.
+*/
+type BanHistory struct {
+	Banned           bool             `json:"banned" example:"false"` // whether the staker is currently banned from staking
+	BannedCount      int              `json:"bannedCount" example:"1"`
+	LastBanTime      *time.Time       `json:"lastBanTime,omitempty"`
+	CurrentUnbanTime *time.Time       `json:"currentUnbanTime,omitempty"`
+	BannedSubpools   []*BannedSubpool `json:"bannedSubpools"` // every subpool of the staker that was banned
+}
+
+/*
+A banned subpool in a staker's ban history.
+*/
+type BannedSubpool struct {
+	StakingPoolID int `json:"stakingPoolId" example:"3"`
+	SubpoolID     int `json:"subpoolId" example:"12"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+/*
+Defines the `RHSignInNonces` collection. A nonce is issued by the server for a wallet that signs in with its own signature
+(e.g. a Safe multisig), and is part of the SignIn message it signs. A signed session token is only valid while its nonce
+hasn't expired and wasn't revoked (signing out), so a leaked token can be revoked and can't be reused afterwards.
+*/
+type SignInNonce struct {
+	Nonce     string     `bson:"nonce" json:"nonce" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Wallet    string     `bson:"wallet" json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	IssuedAt  time.Time  `bson:"issuedAt" json:"issuedAt"`
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+/*
+What a wallet signs to sign in: the EIP-712 SignIn message with a new nonce. The session token is
+`wallet-signature:<wallet>:<nonce>:<signature of typedData>` until `expiresAt`.
+*/
+type SignInChallenge struct {
+	Wallet    string             `json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Nonce     string             `json:"nonce" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+	TypedData apitypes.TypedData `json:"typedData"`
+}
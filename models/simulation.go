@@ -0,0 +1,42 @@
+package models
+
+/*
+The projected outcome of a staking pool if a wallet added and removed the given subpools (nothing is actually changed).
+*/
+type PoolSimulation struct {
+	StakingPoolID         int                     `json:"stakingPoolId" example:"3"`
+	RewardName            string                  `json:"rewardName" example:"REC"`
+	RewardAmount          float64                 `json:"rewardAmount" example:"100000"`     // the reward amount used for the simulation (the pool's, unless it was changed)
+	TotalPoints           float64                 `json:"totalPoints" example:"20077.7"`     // the total points of ALL subpools after the changes, including `ExtraCompetitorPoints`
+	ExtraCompetitorPoints float64                 `json:"extraCompetitorPoints" example:"0"` // points added by other stakers that aren't in the pool yet
+	Wallet                string                  `json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	WalletPoints          float64                 `json:"walletPoints" example:"1843.2"`       // the points of the wallet's (unbanned) subpools after the changes
+	WalletTokenShare      float64                 `json:"walletTokenShare" example:"9180.4"`   // ONLY FOR TOKEN REWARDS: the wallet's share of the reward after the changes
+	WalletRank            int                     `json:"walletRank" example:"4"`              // the wallet's rank in `Distribution` (0 if it has no points)
+	MarginalValuePerKey   float64                 `json:"marginalValuePerKey" example:"612.3"` // ONLY FOR TOKEN REWARDS: how much the added subpools raise the wallet's token share, per added key
+	AddedSubpools         []*SimulatedSubpool     `json:"addedSubpools"`
+	RemovedSubpoolIDs     []int                   `json:"removedSubpoolIds" example:"[4]"`
+	Distribution          []*SimulatedStakerShare `json:"distribution"` // every staker with points after the changes, ranked by points
+}
+
+/*
+A subpool added in a `PoolSimulation`.
+*/
+type SimulatedSubpool struct {
+	KeyIDs              []int   `json:"keyIds" example:"[25,1402,3310]"`
+	KeychainIDs         []int   `json:"keychainIds" example:"[45]"`
+	SuperiorKeychainID  int     `json:"superiorKeychainId" example:"-1"`
+	MarginalTokenShare  float64 `json:"marginalTokenShare" example:"1836.9"` // ONLY FOR TOKEN REWARDS: how much this subpool raises the wallet's token share (compared to not adding it)
+	MarginalValuePerKey float64 `json:"marginalValuePerKey" example:"612.3"` // `MarginalTokenShare` divided by the number of keys
+	*DetailedSubpoolPoints
+}
+
+/*
+A staker's projected points and token share in a `PoolSimulation`.
+*/
+type SimulatedStakerShare struct {
+	Rank       int     `json:"rank" example:"1"`
+	Wallet     string  `json:"wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Points     float64 `json:"points" example:"1843.2"`
+	TokenShare float64 `json:"tokenShare" example:"9180.4"` // ONLY FOR TOKEN REWARDS
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+Defines the `RHWebhooks` collection, which stores the webhook subscriptions added by admins.
+Each webhook receives the domain events (e.g. `subpoolStaked`) in `Events`, signed with `Secret`.
+*/
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id" example:"64f1c2a9e1b2c3d4e5f60718"`
+	URL       string             `bson:"url" json:"url" example:"https://bot.example.com/webhooks/nbc"`        // the URL that the events are POSTed to
+	Events    []string           `bson:"events" json:"events" example:"[\"subpoolStaked\",\"rewardClaimed\"]"` // the event types sent to this webhook (all event types if empty)
+	Secret    string             `bson:"secret" json:"secret,omitempty"`                                       // the HMAC-SHA256 signing secret. only returned when the webhook is added
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+/*
+The status of a `WebhookDelivery`.
+*/
+const (
+	WebhookDeliveryPending   = "pending"   // waiting to be (re)sent
+	WebhookDeliveryDelivered = "delivered" // the webhook responded with a 2xx status code
+	WebhookDeliveryDead      = "dead"      // every attempt failed. stays in the dead-letter list until it's replayed
+)
+
+/*
+Defines the `RHWebhookDeliveries` collection. One delivery is created for every event sent to every matching webhook.
+*/
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id" example:"64f1c2a9e1b2c3d4e5f60719"`
+	WebhookID      primitive.ObjectID `bson:"webhookID" json:"webhookId" example:"64f1c2a9e1b2c3d4e5f60718"`
+	EventID        primitive.ObjectID `bson:"eventID" json:"eventId" example:"64f1c2a9e1b2c3d4e5f6071a"` // the ID of the event in `RHOutbox`
+	EventType      string             `bson:"eventType" json:"eventType" example:"subpoolStaked"`
+	Payload        string             `bson:"payload" json:"payload"`                                                 // the JSON body (a `WebhookPayload`), identical for every attempt
+	Status         string             `bson:"status" json:"status" example:"dead"`                                    // pending, delivered or dead
+	Attempts       int                `bson:"attempts" json:"attempts" example:"8"`                                   // the number of attempts so far
+	NextAttemptAt  time.Time          `bson:"nextAttemptAt" json:"nextAttemptAt"`                                     // when the next attempt is made (if pending)
+	LastStatusCode int                `bson:"lastStatusCode,omitempty" json:"lastStatusCode,omitempty" example:"500"` // the HTTP status code of the last attempt (0 if the request failed)
+	LastError      string             `bson:"lastError,omitempty" json:"lastError,omitempty"`                         // why the last attempt failed
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	DeliveredAt    *time.Time         `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+}
+
+/*
+The JSON body POSTed to a webhook.
+*/
+type WebhookPayload struct {
+	ID         string      `json:"id" example:"64f1c2a9e1b2c3d4e5f6071a"` // the event ID. the same event can be delivered more than once, so receivers should deduplicate by ID
+	Type       string      `json:"type" example:"subpoolStaked"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"` // the event, e.g. `{"stakingPoolId": 3, "subpoolId": 12, "stakerWallet": "0x..."}`
+}
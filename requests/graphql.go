@@ -0,0 +1,10 @@
+package requests
+
+/*
+Request for the `/graphql` route.
+*/
+type GraphQLRequest struct {
+	Query         string                 `json:"query" validate:"required" example:"{ pool(stakingPoolId: 3) { totalSubpoolPoints subpools { subpoolId staker { wallet } } } }"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
@@ -0,0 +1,315 @@
+package requests
+
+// the header the webapp sends the user's session token in.
+const SessionTokenHeader = "session-token"
+
+/*
+Embedded in requests for routes that require the user's session token (sent in the `session-token` header).
+The token itself is checked against the wallet given by the staking functions. It can also be a token signed by the wallet
+with a nonce from `POST /v1/stakers/:wallet/sign-in` (see `UtilsNFT.WalletFromSessionToken`).
+*/
+type SessionRequest struct {
+	SessionToken string `header:"session-token" json:"-"`
+}
+
+/*
+Request for routes that only need a staking pool ID.
+*/
+type PoolRequest struct {
+	StakingPoolID int `param:"stakingPoolId" validate:"min=1"`
+}
+
+/*
+Request for routes that need a staking pool ID and a subpool ID.
+*/
+type SubpoolRequest struct {
+	StakingPoolID int `param:"stakingPoolId" validate:"min=1"`
+	SubpoolID     int `param:"subpoolId" validate:"min=1"`
+}
+
+/*
+Request for routes that only need a wallet (`:wallet` route param).
+*/
+type WalletRequest struct {
+	Wallet string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
+
+/*
+Request for routes that only need a wallet (`:address` route param).
+*/
+type AddressRequest struct {
+	Address string `param:"address" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
+
+/*
+Request for routes that need a staker's wallet and a staking pool ID.
+*/
+type StakerPoolRequest struct {
+	Wallet        string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolID int    `param:"stakingPoolId" validate:"min=1"`
+}
+
+/*
+Request for routes that need a Key Of Salvation token ID.
+*/
+type TokenRequest struct {
+	TokenID int `param:"tokenId" validate:"keyid"`
+}
+
+/*
+Request for `CheckSubpoolComboEligibility`.
+*/
+type ComboEligibilityRequest struct {
+	StakerWallet  string `param:"stakerWallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolID int    `param:"stakingPoolId" validate:"min=1"`
+	KeyCount      int    `param:"keyCount" validate:"oneof=1 2 3 5 15"`
+}
+
+/*
+Request for calculating subpool points for a set of keys, keychains and a superior keychain (-1 if none).
+*/
+type SubpoolPointsRequest struct {
+	KeyIDs             []int `query:"keyIds" validate:"required,min=1,max=15,unique,dive,keyid" example:"[25,1402,3310]"`
+	KeychainIDs        []int `query:"keychainIds" validate:"max=3,dive,min=-1,ne=0"`
+	SuperiorKeychainID int   `query:"superiorKeychainId" default:"-1" validate:"min=-1,ne=0"`
+}
+
+/*
+Request for `FetchTokenPreAddSubpoolData`.
+*/
+type TokenPreAddSubpoolRequest struct {
+	StakingPoolID int `query:"stakingPoolId" validate:"min=1"`
+	SubpoolPointsRequest
+}
+
+/*
+Request for `CheckIfKeysStaked`.
+*/
+type KeysStakedRequest struct {
+	StakingPoolID int   `query:"stakingPoolId" validate:"min=1"`
+	KeyIDs        []int `query:"keyIds" validate:"required,min=1,unique,dive,keyid"`
+}
+
+/*
+Request body for `ClaimReward`.
+*/
+type ClaimRewardRequest struct {
+	SessionRequest
+	Wallet        string `json:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolID int    `json:"stakingPoolId" validate:"min=1"`
+	SubpoolID     int    `json:"subpoolId" validate:"min=1"`
+}
+
+/*
+Request for claiming all of a staker's claimable rewards (`POST /v1/stakers/:wallet/claims`).
+*/
+type ClaimAllRewardsRequest struct {
+	SessionRequest
+	Wallet string `param:"wallet" json:"-" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
+
+/*
+Request body for `AddSubpool`.
+*/
+type AddSubpoolRequest struct {
+	SessionRequest
+	KeyIds             []int  `json:"keyIds" validate:"required,min=1,max=15,unique,dive,keyid" example:"[25,1402,3310]"`
+	StakerWallet       string `json:"stakerWallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolId      int    `json:"stakingPoolId" validate:"min=1"`
+	KeychainIds        []int  `json:"keychainIds" validate:"max=3,unique,dive,min=-1,ne=0" example:"[45]"`
+	SuperiorKeychainId int    `json:"superiorKeychainId" validate:"min=-1,ne=0"`
+}
+
+/*
+Request body for `AddStakingPool`. Protected by `API_PASSWORD`.
+*/
+type AddStakingPoolRequest struct {
+	RewardAmount float64 `json:"rewardAmount" validate:"gt=0" example:"100000"`
+	RewardName   string  `json:"rewardName" validate:"required" example:"REC"`
+	Password     string  `json:"password" validate:"required"`
+}
+
+/*
+Request body for `UnstakeFromSubpool`.
+*/
+type UnstakeFromSubpoolRequest struct {
+	SessionRequest
+	StakingPoolID int    `json:"stakingPoolId" validate:"min=1"`
+	SubpoolID     int    `json:"subpoolId" validate:"min=1"`
+	Wallet        string `json:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
+
+/*
+Request for listing the subpools of a staking pool (`GET /v1/pools/:stakingPoolId/subpools`).
+*/
+type PoolSubpoolsRequest struct {
+	StakingPoolID int    `param:"stakingPoolId" validate:"min=1"`
+	Status        string `query:"status" default:"all" validate:"oneof=all active closed"`
+}
+
+/*
+Embedded in requests for paginated routes (e.g. the leaderboards).
+*/
+type PageRequest struct {
+	Page  int `query:"page" default:"1" validate:"min=1,max=10000"`
+	Limit int `query:"limit" default:"25" validate:"min=1,max=100"`
+}
+
+/*
+Request for a staking pool's leaderboard (`GET /v1/pools/:stakingPoolId/leaderboard`).
+*/
+type PoolLeaderboardRequest struct {
+	StakingPoolID int `param:"stakingPoolId" validate:"min=1"`
+	PageRequest
+}
+
+/*
+Request for the all-time leaderboard of a reward (`GET /v1/leaderboard`).
+*/
+type RewardLeaderboardRequest struct {
+	Reward string `query:"reward" default:"REC" validate:"required,max=64" example:"REC"`
+	PageRequest
+}
+
+/*
+Request for searching the Key Of Salvation tokens by trait (`GET /v1/collections/kos/tokens`).
+*/
+type TokenSearchRequest struct {
+	House         string  `query:"house" validate:"max=64" example:"Tiger"`
+	Type          string  `query:"type" validate:"max=64" example:"Brawler"`
+	LuckMin       float64 `query:"luckMin" default:"0" validate:"min=0,max=100"`
+	LuckMax       float64 `query:"luckMax" default:"100" validate:"min=0,max=100"`
+	LuckBoostMin  float64 `query:"luckBoostMin" default:"0" validate:"min=0,max=100"`   // in percent
+	LuckBoostMax  float64 `query:"luckBoostMax" default:"100" validate:"min=0,max=100"` // in percent
+	Angel         string  `query:"angel" default:"any" validate:"oneof=any true false"` // whether the key's luck is 100
+	Staked        string  `query:"staked" default:"any" validate:"oneof=any true false"`
+	StakingPoolID int     `query:"stakingPoolId" validate:"min=0"` // required to filter by `staked`
+	Sort          string  `query:"sort" default:"tokenId" validate:"oneof=tokenId luck luckBoost house type"`
+	Order         string  `query:"order" default:"asc" validate:"oneof=asc desc"`
+	PageRequest
+}
+
+/*
+Request for the Key Of Salvation keys ranked by rarity or staking utility (`GET /v1/collections/kos/rankings`).
+*/
+type KeyRankingRequest struct {
+	By string `query:"by" default:"utility" validate:"oneof=rarity utility"`
+	PageRequest
+}
+
+/*
+Request for `GET /v1/pools/:stakingPoolId/subpool-preview`.
+*/
+type PoolSubpoolPreviewRequest struct {
+	StakingPoolID int `param:"stakingPoolId" validate:"min=1"`
+	SubpoolPointsRequest
+}
+
+/*
+Request body for simulating a staking pool (`POST /v1/pools/:stakingPoolId/simulations`).
+*/
+type PoolSimulationRequest struct {
+	StakingPoolID         int            `param:"stakingPoolId" json:"-" validate:"min=1"`
+	Wallet                string         `json:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Additions             []*SubpoolSpec `json:"additions" validate:"max=20,dive,required"`
+	Removals              []int          `json:"removals" validate:"max=20,unique,dive,min=1" example:"[4]"`
+	ExtraCompetitorPoints float64        `json:"extraCompetitorPoints" validate:"min=0" example:"2500"`
+	RewardAmount          *float64       `json:"rewardAmount" validate:"omitempty,gt=0" example:"150000"` // leave out to use the pool's reward amount
+}
+
+/*
+Request for `GET /v1/pools/:stakingPoolId/keys-staked`.
+*/
+type PoolKeysStakedRequest struct {
+	StakingPoolID int   `param:"stakingPoolId" validate:"min=1"`
+	KeyIDs        []int `query:"keyIds" validate:"required,min=1,unique,dive,keyid" example:"[25,1402,3310]"`
+}
+
+/*
+Request for `POST /v1/stakers/:wallet/delegations`, where `:wallet` is the vault. No session token is needed since the delegation
+is signed by the vault (EIP-712, or EIP-1271 if the vault is a contract wallet). A delegation with neither `staking` nor `claiming` revokes the current one.
+*/
+type AddDelegationRequest struct {
+	Wallet    string `param:"wallet" json:"-" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Delegate  string `json:"delegate" validate:"required,wallet" example:"0x1f2e3d4c5b6a79880716253443526170819a0b1c"`
+	Staking   bool   `json:"staking" example:"true"`
+	Claiming  bool   `json:"claiming" example:"true"`
+	Expiry    int64  `json:"expiry" validate:"min=0" example:"1767225600"` // unix seconds
+	Nonce     int64  `json:"nonce" validate:"min=0" example:"1"`
+	Signature string `json:"signature" validate:"required"`
+}
+
+/*
+Request for `DELETE /v1/stakers/:wallet/sign-in`, which revokes the signed session token sent in the `session-token` header.
+*/
+type SignOutRequest struct {
+	SessionRequest
+	Wallet string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
+
+/*
+Request for `GET /v1/stakers/:wallet/inventory`.
+*/
+type StakerInventoryRequest struct {
+	Wallet        string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolID int    `query:"stakingPoolId" validate:"min=1"`
+}
+
+/*
+Request for `GET /v1/stakers/:wallet/pools/:stakingPoolId/combo-eligibility`.
+*/
+type StakerComboEligibilityRequest struct {
+	Wallet        string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolID int    `param:"stakingPoolId" validate:"min=1"`
+	KeyCount      int    `query:"keyCount" validate:"oneof=1 2 3 5 15"`
+}
+
+/*
+Request for `GET /v1/stakers/:wallet/pools/:stakingPoolId/recommendation`.
+*/
+type SubpoolRecommendationRequest struct {
+	Wallet        string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	StakingPoolID int    `param:"stakingPoolId" validate:"min=1"`
+}
+
+/*
+Request for `POST /v1/pools/:stakingPoolId/subpools`.
+*/
+type CreateSubpoolRequest struct {
+	SessionRequest
+	StakingPoolID      int    `param:"stakingPoolId" json:"-" validate:"min=1"`
+	KeyIDs             []int  `json:"keyIds" validate:"required,min=1,max=15,unique,dive,keyid" example:"[25,1402,3310]"`
+	StakerWallet       string `json:"stakerWallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	KeychainIDs        []int  `json:"keychainIds" validate:"max=3,unique,dive,min=-1,ne=0" example:"[45]"`
+	SuperiorKeychainID int    `json:"superiorKeychainId" validate:"min=-1,ne=0"`
+}
+
+/*
+A subpool in `CreateSubpoolsRequest`, with the same rules as `CreateSubpoolRequest`.
+*/
+type SubpoolSpec struct {
+	KeyIDs             []int `json:"keyIds" validate:"required,min=1,max=15,unique,dive,keyid" example:"[25,1402,3310]"`
+	KeychainIDs        []int `json:"keychainIds" validate:"max=3,unique,dive,min=-1,ne=0" example:"[45]"`
+	SuperiorKeychainID int   `json:"superiorKeychainId" validate:"min=-1,ne=0"`
+}
+
+/*
+Request body for staking several subpools at once (`POST /v1/pools/:stakingPoolId/subpools/batch`).
+*/
+type CreateSubpoolsRequest struct {
+	SessionRequest
+	StakingPoolID int            `param:"stakingPoolId" json:"-" validate:"min=1"`
+	StakerWallet  string         `json:"stakerWallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Subpools      []*SubpoolSpec `json:"subpools" validate:"required,min=1,max=20,dive,required"`
+}
+
+/*
+Request for the routes that act on a staker's subpool
+(`POST /v1/pools/:stakingPoolId/subpools/:subpoolId/claim` and `DELETE /v1/pools/:stakingPoolId/subpools/:subpoolId`).
+*/
+type SubpoolActionRequest struct {
+	SessionRequest
+	StakingPoolID int    `param:"stakingPoolId" json:"-" validate:"min=1"`
+	SubpoolID     int    `param:"subpoolId" json:"-" validate:"min=1"`
+	Wallet        string `json:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
@@ -0,0 +1,40 @@
+package requests
+
+/*
+Request for `GET /v1/stakers/:wallet/notifications`.
+*/
+type NotificationsRequest struct {
+	SessionRequest
+	Wallet string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Unread bool   `query:"unread"`                                      // only return unread notifications
+	Limit  int    `query:"limit" default:"50" validate:"min=1,max=100"` // the max number of notifications returned (newest first)
+}
+
+/*
+Request for `POST /v1/stakers/:wallet/notifications/:notificationId/read`.
+*/
+type NotificationRequest struct {
+	SessionRequest
+	Wallet         string `param:"wallet" json:"-" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	NotificationID string `param:"notificationId" json:"-" validate:"mongodb" example:"64f1c2a9e1b2c3d4e5f6071b"`
+}
+
+/*
+Request for `GET /v1/stakers/:wallet/notifications/preferences`.
+*/
+type NotificationPreferencesRequest struct {
+	SessionRequest
+	Wallet string `param:"wallet" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+}
+
+/*
+Request body for `PUT /v1/stakers/:wallet/notifications/preferences`. Replaces all of the wallet's preferences.
+*/
+type UpdateNotificationPreferencesRequest struct {
+	SessionRequest
+	Wallet            string   `param:"wallet" json:"-" validate:"required,wallet" example:"0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"`
+	Channels          []string `json:"channels" validate:"unique,dive,oneof=inApp email discord" example:"[\"inApp\",\"discord\"]"`
+	Email             string   `json:"email" validate:"omitempty,email,max=254" example:"staker@example.com"`
+	DiscordWebhookURL string   `json:"discordWebhookUrl" validate:"omitempty,url" example:"https://discord.com/api/webhooks/1/x"`
+	MutedKinds        []string `json:"mutedKinds" validate:"unique,dive,oneof=entryClosing rewardExpiring subpoolBanned" example:"[\"entryClosing\"]"`
+}
@@ -0,0 +1,332 @@
+package requests
+
+import (
+	"fmt"
+	"nbc-backend-api-v2/utils"
+	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+`FieldError` describes why a single request field is invalid.
+*/
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+/*
+`ValidationError` is returned by `Bind` when one or more request fields are invalid.
+It unwraps to `utils.ErrValidationFailed`, so the error handler responds with a 422 and lists every field error.
+*/
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = fmt.Sprintf("%s %s", field.Field, field.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", utils.ErrValidationFailed.Message, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return utils.ErrValidationFailed
+}
+
+/*
+Returns the field errors so that they are included in the `data` of the error response.
+*/
+func (e *ValidationError) ErrorData() *fiber.Map {
+	return &fiber.Map{"errors": e.Fields}
+}
+
+var validate = newValidator()
+
+/*
+Creates the validator used for all request structs, including the custom `wallet` and `keyid` tags.
+*/
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// report field errors with the name the webapp uses (json, param, query or header name) instead of the Go field name.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "param", "query", "header"} {
+			if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	// `wallet` checks that the field is a valid EVM address (lowercase or a valid checksum address).
+	v.RegisterValidation("wallet", func(fl validator.FieldLevel) bool {
+		return utils.ValidAddress(fl.Field().String())
+	})
+
+	// `keyid` checks that the field is within the Key Of Salvation collection's token ID range.
+	v.RegisterValidation("keyid", func(fl validator.FieldLevel) bool {
+		id := fl.Field().Int()
+		return id >= 1 && id <= UtilsKOS.KOSCollectionSize
+	})
+
+	return v
+}
+
+/*
+`ParseIDList` parses a comma-separated list of IDs (e.g. `1,25,300`) into a slice of ints.
+An empty string returns an empty slice. Whitespace around each ID is ignored.
+*/
+func ParseIDList(raw string) ([]int, error) {
+	ids := []int{}
+	if strings.TrimSpace(raw) == "" {
+		return ids, nil
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid ID", part)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+/*
+`OptionalBool` parses the value of an optional boolean filter (`true`, `false` or `any`), returning nil for `any`.
+*/
+func OptionalBool(value string) *bool {
+	if value == "" || value == "any" {
+		return nil
+	}
+
+	b := value == "true"
+	return &b
+}
+
+/*
+`Bind` fills `req` (a pointer to a request struct) from the request and validates it.
+
+	fields tagged with `param:"name"` are read from the route params.
+	fields tagged with `query:"name"` are read from the query string (`default:"x"` is used when the query param is missing).
+	fields tagged with `header:"name"` are read from the request headers.
+	for non-GET requests, the JSON body is parsed into the struct using its `json` tags.
+
+After binding, the struct is validated with its `validate` tags and every field tagged with `wallet` is normalized to lowercase.
+Returns a `ValidationError` listing every invalid field if binding or validation fails.
+*/
+func Bind(c *fiber.Ctx, req interface{}) error {
+	if c.Method() != fiber.MethodGet && len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return fmt.Errorf("%w: unable to successfully parse request body: %v", utils.ErrInvalidRequest, err)
+		}
+	}
+
+	v := reflect.ValueOf(req).Elem()
+
+	fieldErrors := bindFields(c, v)
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Fields: fieldErrors}
+	}
+
+	if err := validate.Struct(req); err != nil {
+		validationErrors, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return fmt.Errorf("%w: %v", utils.ErrInvalidRequest, err)
+		}
+
+		for _, fe := range validationErrors {
+			fieldErrors = append(fieldErrors, FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)})
+		}
+		return &ValidationError{Fields: fieldErrors}
+	}
+
+	// normalize all wallets so that they match how they are stored in `RHStakerData`.
+	normalizeWallets(v)
+
+	return nil
+}
+
+/*
+Returns the path of the field of `fe` within the request (e.g. `subpools[1].keyIds` for a field of a slice of structs).
+*/
+func fieldPath(fe validator.FieldError) string {
+	segments := strings.Split(fe.Namespace(), ".")[1:] // leave out the request struct itself
+
+	var path []string
+	for i, segment := range segments {
+		// embedded structs (e.g. `SessionRequest`) have no tag, so they're the only segments named after their Go type.
+		if i < len(segments)-1 && segment != "" && unicode.IsUpper(rune(segment[0])) {
+			continue
+		}
+		path = append(path, segment)
+	}
+
+	return strings.Join(path, ".")
+}
+
+/*
+Reads every `param`, `query` and `header` tagged field of the struct `v` (including embedded structs) from the request.
+*/
+func bindFields(c *fiber.Ctx, v reflect.Value) []FieldError {
+	var fieldErrors []FieldError
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fieldErrors = append(fieldErrors, bindFields(c, v.Field(i))...)
+			continue
+		}
+
+		var name, raw string
+		if name = field.Tag.Get("param"); name != "" {
+			raw = c.Params(name)
+		} else if name = field.Tag.Get("query"); name != "" {
+			raw = c.Query(name, field.Tag.Get("default"))
+			if raw == "" {
+				continue // leave the zero value and let the `validate` tags decide whether it's required
+			}
+		} else if name = field.Tag.Get("header"); name != "" {
+			raw = c.Get(name)
+			if raw == "" {
+				continue
+			}
+		} else {
+			continue
+		}
+
+		if err := setField(v.Field(i), raw); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Message: err.Error()})
+		}
+	}
+
+	return fieldErrors
+}
+
+/*
+Lowercases every string field of the struct `v` (including embedded structs) that is validated with the `wallet` tag.
+*/
+func normalizeWallets(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			normalizeWallets(v.Field(i))
+			continue
+		}
+
+		if v.Field(i).Kind() == reflect.String && hasTag(field.Tag.Get("validate"), "wallet") {
+			v.Field(i).SetString(utils.NormalizeWallet(v.Field(i).String()))
+		}
+	}
+}
+
+/*
+Sets the string value `raw` onto `field` based on the field's type.
+*/
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Int {
+			return fmt.Errorf("unsupported field type")
+		}
+		ids, err := ParseIDList(raw)
+		if err != nil {
+			return fmt.Errorf("must be a comma-separated list of integers: %v", err)
+		}
+		field.Set(reflect.ValueOf(ids))
+	default:
+		return fmt.Errorf("unsupported field type")
+	}
+
+	return nil
+}
+
+/*
+Returns a human-readable message for a failed validation tag.
+*/
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "wallet":
+		return "must be a valid EVM address (lowercase or checksummed)"
+	case "keyid":
+		return fmt.Sprintf("must be a key ID between 1 and %d", UtilsKOS.KOSCollectionSize)
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at least %s item(s)", fe.Param())
+		}
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at most %s item(s)", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "ne":
+		return fmt.Sprintf("must not be %s", fe.Param())
+	case "unique":
+		return "must not contain duplicates"
+	case "url":
+		return "must be a valid URL"
+	case "startswith":
+		return fmt.Sprintf("must start with %q", fe.Param())
+	case "mongodb":
+		return "must be a valid object ID"
+	case "email":
+		return "must be a valid email address"
+	default:
+		return fmt.Sprintf("failed the %q check", fe.Tag())
+	}
+}
+
+/*
+Checks if the comma-separated `validate` tag contains `name`.
+*/
+func hasTag(tags, name string) bool {
+	for _, tag := range strings.Split(tags, ",") {
+		if tag == name {
+			return true
+		}
+	}
+
+	return false
+}
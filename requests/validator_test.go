@@ -0,0 +1,220 @@
+package requests
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"nbc-backend-api-v2/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseIDList(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []int
+		wantErr bool
+	}{
+		{"empty", "", []int{}, false},
+		{"blank", "  ", []int{}, false},
+		{"single", "25", []int{25}, false},
+		{"several", "1,25,300", []int{1, 25, 300}, false},
+		{"whitespace around IDs", " 1, 25 ,300 ", []int{1, 25, 300}, false},
+		{"not a number", "1,abc", nil, true},
+		{"empty ID", "1,,3", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseIDList(test.raw)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want an error: %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseIDList(%q) = %v, want %v", test.raw, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+Binds a `method` request to `target` (with `headers` and the JSON `body`, if any) into a new `T`, on a route registered at `route`.
+*/
+func bind[T any](t *testing.T, method, route, target string, headers map[string]string, body string) (*T, error) {
+	t.Helper()
+
+	var req T
+	var bindErr error
+	app := fiber.New()
+	app.Add(method, route, func(c *fiber.Ctx) error {
+		bindErr = Bind(c, &req)
+		return nil
+	})
+
+	httpReq := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		httpReq.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for name, value := range headers {
+		httpReq.Header.Set(name, value)
+	}
+	res, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	return &req, bindErr
+}
+
+/*
+Returns the field errors of `err`, failing the test if it isn't a `ValidationError`.
+*/
+func fieldErrors(t *testing.T, err error) []FieldError {
+	t.Helper()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error = %v, want a ValidationError", err)
+	}
+	if !errors.Is(err, utils.ErrValidationFailed) {
+		t.Errorf("error = %v, want it to match ErrValidationFailed", err)
+	}
+	return validationErr.Fields
+}
+
+func TestBindSubpoolPoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		want   SubpoolPointsRequest
+		errors []FieldError
+	}{
+		{"defaults", "?keyIds=25", SubpoolPointsRequest{KeyIDs: []int{25}, SuperiorKeychainID: -1}, nil},
+		{"every field", "?keyIds=25,1402,3310&keychainIds=45&superiorKeychainId=7", SubpoolPointsRequest{KeyIDs: []int{25, 1402, 3310}, KeychainIDs: []int{45}, SuperiorKeychainID: 7}, nil},
+		{"missing key IDs", "", SubpoolPointsRequest{}, []FieldError{{"keyIds", "is required"}}},
+		{"key ID not a number", "?keyIds=25,abc", SubpoolPointsRequest{}, []FieldError{{"keyIds", `must be a comma-separated list of integers: "abc" is not a valid ID`}}},
+		{"key ID out of range", "?keyIds=25,5001", SubpoolPointsRequest{}, []FieldError{{"keyIds[1]", "must be a key ID between 1 and 5000"}}},
+		{"duplicate key IDs", "?keyIds=25,25", SubpoolPointsRequest{}, []FieldError{{"keyIds", "must not contain duplicates"}}},
+		{"too many keychains", "?keyIds=25&keychainIds=1,2,3,4", SubpoolPointsRequest{}, []FieldError{{"keychainIds", "must contain at most 3 item(s)"}}},
+		{
+			"several invalid fields",
+			"?keyIds=0&superiorKeychainId=0",
+			SubpoolPointsRequest{},
+			[]FieldError{{"keyIds[0]", "must be a key ID between 1 and 5000"}, {"superiorKeychainId", "must not be 0"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := bind[SubpoolPointsRequest](t, fiber.MethodGet, "/points", "/points"+test.query, nil, "")
+			if test.errors == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(*got, test.want) {
+					t.Errorf("bound %+v, want %+v", *got, test.want)
+				}
+				return
+			}
+
+			if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, test.errors) {
+				t.Errorf("field errors = %+v, want %+v", fields, test.errors)
+			}
+		})
+	}
+}
+
+func TestBindWallets(t *testing.T) {
+	lowercase := "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"
+	checksummed := common.HexToAddress(lowercase).Hex()
+	// the checksummed address with the case of its first letter flipped.
+	i := strings.IndexAny(checksummed[2:], "abcdefABCDEF") + 2
+	badChecksum := checksummed[:i] + string(checksummed[i]^0x20) + checksummed[i+1:]
+
+	tests := []struct {
+		name   string
+		wallet string
+		want   string
+		errors []FieldError
+	}{
+		{"lowercase", lowercase, lowercase, nil},
+		{"uppercase", "0x" + strings.ToUpper(lowercase[2:]), lowercase, nil},
+		{"checksummed", checksummed, lowercase, nil},
+		{"invalid checksum", badChecksum, "", []FieldError{{"wallet", "must be a valid EVM address (lowercase or checksummed)"}}},
+		{"too short", "0x8d1a", "", []FieldError{{"wallet", "must be a valid EVM address (lowercase or checksummed)"}}},
+		{"without 0x", lowercase[2:], "", []FieldError{{"wallet", "must be a valid EVM address (lowercase or checksummed)"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := bind[StakerPoolRequest](t, fiber.MethodGet, "/v1/stakers/:wallet/pools/:stakingPoolId", "/v1/stakers/"+test.wallet+"/pools/3", nil, "")
+			if test.errors == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				// wallets are stored in lowercase, so that's how every request has them.
+				if got.Wallet != test.want || got.StakingPoolID != 3 {
+					t.Errorf("bound %+v, want wallet %s of staking pool 3", *got, test.want)
+				}
+				return
+			}
+
+			if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, test.errors) {
+				t.Errorf("field errors = %+v, want %+v", fields, test.errors)
+			}
+		})
+	}
+}
+
+func TestBindBody(t *testing.T) {
+	wallet := "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"
+	headers := map[string]string{SessionTokenHeader: "session"}
+
+	t.Run("params, header and body", func(t *testing.T) {
+		body := `{"stakerWallet": "` + common.HexToAddress(wallet).Hex() + `", "subpools": [{"keyIds": [25, 1402], "keychainIds": [45], "superiorKeychainId": -1}]}`
+		got, err := bind[CreateSubpoolsRequest](t, fiber.MethodPost, "/v1/pools/:stakingPoolId/subpools/batch", "/v1/pools/3/subpools/batch", headers, body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := CreateSubpoolsRequest{
+			SessionRequest: SessionRequest{SessionToken: "session"},
+			StakingPoolID:  3,
+			StakerWallet:   wallet,
+			Subpools:       []*SubpoolSpec{{KeyIDs: []int{25, 1402}, KeychainIDs: []int{45}, SuperiorKeychainID: -1}},
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("bound %+v, want %+v", *got, want)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		errors []FieldError
+	}{
+		{"invalid param", "/v1/pools/abc/subpools/batch", `{"stakerWallet": "` + wallet + `", "subpools": [{"keyIds": [25]}]}`, []FieldError{{"stakingPoolId", "must be an integer"}}},
+		{"no subpools", "/v1/pools/3/subpools/batch", `{"stakerWallet": "` + wallet + `", "subpools": []}`, []FieldError{{"subpools", "must contain at least 1 item(s)"}}},
+		// the fields of nested structs are reported with their index.
+		{"invalid subpool", "/v1/pools/3/subpools/batch", `{"stakerWallet": "` + wallet + `", "subpools": [{"keyIds": [25], "superiorKeychainId": -1}, {"keyIds": [25], "keychainIds": [0], "superiorKeychainId": -1}]}`, []FieldError{{"subpools[1].keychainIds[0]", "must not be 0"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := bind[CreateSubpoolsRequest](t, fiber.MethodPost, "/v1/pools/:stakingPoolId/subpools/batch", test.target, headers, test.body)
+			if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, test.errors) {
+				t.Errorf("field errors = %+v, want %+v", fields, test.errors)
+			}
+		})
+	}
+
+	t.Run("malformed body", func(t *testing.T) {
+		_, err := bind[CreateSubpoolsRequest](t, fiber.MethodPost, "/v1/pools/:stakingPoolId/subpools/batch", "/v1/pools/3/subpools/batch", headers, `{"subpools": `)
+		if !errors.Is(err, utils.ErrInvalidRequest) {
+			t.Errorf("error = %v, want ErrInvalidRequest", err)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package requests
+
+// the header that admin routes read the `API_PASSWORD` from (GET routes have no body to send it in).
+const AdminPasswordHeader = "api-password"
+
+/*
+Embedded in the requests of admin routes. Protected by `API_PASSWORD`.
+*/
+type AdminRequest struct {
+	Password string `header:"api-password" json:"-" validate:"required"`
+}
+
+/*
+Request body for `AddWebhook`.
+*/
+type AddWebhookRequest struct {
+	AdminRequest
+	URL    string   `json:"url" validate:"required,url,startswith=http" example:"https://bot.example.com/webhooks/nbc"`
+	Events []string `json:"events" validate:"max=20,unique" example:"[\"subpoolStaked\",\"subpoolBanned\",\"rewardClaimed\"]"` // all event types if empty
+	Secret string   `json:"secret" validate:"omitempty,min=16"`                                                                // a random secret is generated if empty
+}
+
+/*
+Request for routes on a single webhook (`/v1/webhooks/:webhookId`).
+*/
+type WebhookRequest struct {
+	AdminRequest
+	WebhookID string `param:"webhookId" validate:"mongodb" example:"64f1c2a9e1b2c3d4e5f60718"`
+}
+
+/*
+Request for listing the dead-letter list (`GET /v1/webhooks/deliveries/dead`).
+*/
+type DeadDeliveriesRequest struct {
+	AdminRequest
+	WebhookID string `query:"webhookId" validate:"omitempty,mongodb" example:"64f1c2a9e1b2c3d4e5f60718"` // only the deliveries of this webhook if set
+}
+
+/*
+Request for routes on a single webhook delivery (`/v1/webhooks/deliveries/:deliveryId`).
+*/
+type DeliveryRequest struct {
+	AdminRequest
+	DeliveryID string `param:"deliveryId" validate:"mongodb" example:"64f1c2a9e1b2c3d4e5f60719"`
+}
@@ -0,0 +1,114 @@
+package responses
+
+import (
+	"errors"
+	"log"
+	"nbc-backend-api-v2/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Maps each `utils.ErrorKind` to its HTTP status code.
+*/
+var kindStatusCodes = map[utils.ErrorKind]int{
+	utils.KindInternal:      fiber.StatusInternalServerError,
+	utils.KindBadRequest:    fiber.StatusBadRequest,
+	utils.KindUnauthorized:  fiber.StatusUnauthorized,
+	utils.KindForbidden:     fiber.StatusForbidden,
+	utils.KindNotFound:      fiber.StatusNotFound,
+	utils.KindConflict:      fiber.StatusConflict,
+	utils.KindUnprocessable: fiber.StatusUnprocessableEntity,
+	utils.KindUpstream:      fiber.StatusBadGateway,
+}
+
+/*
+Errors implementing `DataError` attach extra data (e.g. the list of invalid fields) to the error response.
+*/
+type DataError interface {
+	error
+	ErrorData() *fiber.Map
+}
+
+/*
+`ErrorHandler` is the central Fiber error handler. Route handlers simply return an error and this function
+converts it into a `Response` with the correct HTTP status code and a machine-readable error code.
+
+	`utils.DomainError` (including wrapped ones) are mapped based on their `Kind`.
+	`*fiber.Error` (e.g. 404 for unknown routes) keep their status code.
+	MongoDB network errors and timeouts are treated as an unavailable upstream (502).
+	anything else is an internal server error (500).
+
+Every server error (5xx) is logged and returned with only its public message (see `publicMessage`),
+so that database, RPC and file details wrapped in it don't leak to clients.
+*/
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status, code := StatusAndCode(err)
+
+	var data *fiber.Map
+	var dataErr DataError
+	if errors.As(err, &dataErr) {
+		data = dataErr.ErrorData()
+	}
+
+	message := err.Error()
+	if status >= fiber.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Method(), c.OriginalURL(), err)
+		message = publicMessage(err, status)
+		data = nil
+	}
+
+	return c.Status(status).JSON(&Response{
+		Status:  status,
+		Code:    code,
+		Message: message,
+		Data:    data,
+	})
+}
+
+/*
+Returns the message of a server error `err` (with status code `status`) that can be sent to clients:
+the message of its `utils.DomainError` (without the details wrapped around it), or a generic one.
+*/
+func publicMessage(err error, status int) string {
+	if domainErr, ok := utils.AsDomainError(err); ok {
+		return domainErr.Message
+	}
+	if status == fiber.StatusBadGateway {
+		return utils.ErrDatabaseUnavailable.Message
+	}
+
+	return "internal server error"
+}
+
+/*
+Returns the HTTP status code and machine-readable error code for `err`.
+*/
+func StatusAndCode(err error) (int, string) {
+	if domainErr, ok := utils.AsDomainError(err); ok {
+		status, ok := kindStatusCodes[domainErr.Kind]
+		if !ok {
+			status = fiber.StatusInternalServerError
+		}
+		return status, domainErr.Code
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		switch fiberErr.Code {
+		case fiber.StatusNotFound:
+			return fiberErr.Code, "ROUTE_NOT_FOUND"
+		case fiber.StatusMethodNotAllowed:
+			return fiberErr.Code, "METHOD_NOT_ALLOWED"
+		default:
+			return fiberErr.Code, "INVALID_REQUEST"
+		}
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return fiber.StatusBadGateway, utils.ErrDatabaseUnavailable.Code
+	}
+
+	return fiber.StatusInternalServerError, "INTERNAL_ERROR"
+}
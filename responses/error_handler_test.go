@@ -0,0 +1,48 @@
+package responses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"nbc-backend-api-v2/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorHandlerRedactsServerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"domain error", fmt.Errorf("%w: no staking pool with ID 3 exists", utils.NewDomainError(utils.KindNotFound, "POOL_NOT_FOUND", "staking pool not found")), fiber.StatusNotFound, "POOL_NOT_FOUND", "staking pool not found: no staking pool with ID 3 exists"},
+		{"database unavailable", fmt.Errorf("%w: connection() error occurred during connection handshake: dial tcp 10.0.0.5:27017", utils.ErrDatabaseUnavailable), fiber.StatusBadGateway, "DATABASE_UNAVAILABLE", utils.ErrDatabaseUnavailable.Message},
+		{"chain unavailable", fmt.Errorf("%w: Post \"https://mainnet.infura.io/v3/secret\": EOF", utils.ErrChainUnavailable), fiber.StatusBadGateway, "CHAIN_UNAVAILABLE", utils.ErrChainUnavailable.Message},
+		{"unknown error", errors.New("open abi/KeyOfSalvation.json: no such file or directory"), fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/", func(c *fiber.Ctx) error { return test.err })
+
+			res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			var body Response
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != test.status || body.Code != test.code || body.Message != test.message {
+				t.Errorf("got %d %s %q, want %d %s %q", res.StatusCode, body.Code, body.Message, test.status, test.code, test.message)
+			}
+		})
+	}
+}
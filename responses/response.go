@@ -4,9 +4,12 @@ import "github.com/gofiber/fiber/v2"
 
 /*
 `Response` is the response struct for all API responses.
+
+`Code` is only set for error responses. It is a stable, machine-readable error code (e.g. `POOL_NOT_FOUND`) that the webapp can switch on.
 */
 type Response struct {
 	Status  int        `json:"status"`
+	Code    string     `json:"code,omitempty"`
 	Message string     `json:"message"`
 	Data    *fiber.Map `json:"data"`
 }
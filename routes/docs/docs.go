@@ -0,0 +1,49 @@
+package routes_docs
+
+import (
+	"nbc-backend-api-v2/docs"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// the Swagger UI page. loads Swagger UI from a CDN and points it to `/openapi.json`.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8" />
+	<title>NBC Webapp API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+	<script>
+		window.onload = () => {
+			window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+		};
+	</script>
+</body>
+</html>`
+
+func DocsRoutes(app *fiber.App) {
+	// OpenAPI specification route
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodGet,
+		Path:    "/openapi.json",
+		Summary: "the OpenAPI 3 specification of this API",
+		Hidden:  true,
+	}, func(c *fiber.Ctx) error {
+		return c.JSON(docs.Spec())
+	})
+
+	// Swagger UI route
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodGet,
+		Path:    "/docs",
+		Summary: "the Swagger UI for the OpenAPI specification",
+		Hidden:  true,
+	}, func(c *fiber.Ctx) error {
+		c.Type("html")
+		return c.SendString(swaggerUI)
+	})
+}
@@ -0,0 +1,29 @@
+package routes_graphql
+
+import (
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/graph"
+	"nbc-backend-api-v2/requests"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GraphQLRoutes(app *fiber.App) {
+	// GraphQL route over pools, subpools, stakers and Keys Of Salvation.
+	// responses follow the GraphQL format (`{data, errors}`) instead of `responses.Response`,
+	// so the route is left out of the OpenAPI specification. the schema itself can be introspected.
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/graphql",
+		Summary: "executes a GraphQL query",
+		Request: requests.GraphQLRequest{},
+		Hidden:  true,
+	}, func(c *fiber.Ctx) error {
+		var req requests.GraphQLRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		return c.JSON(graph.Execute(c.UserContext(), req.Query, req.Variables, req.OperationName))
+	})
+}
@@ -1,897 +1,350 @@
 package routes_nfts
 
 import (
+	"encoding/json"
 	"fmt"
-	ApiKOS "nbc-backend-api-v2/api/nfts/kos"
-	"os"
-	"strconv"
+	"math/big"
+	"net/url"
+	"regexp"
 	"strings"
 
-	"nbc-backend-api-v2/responses"
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/requests"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func KOSRoutes(app *fiber.App) {
-	// FetchStakerInventory route
-	app.Get("/kos/fetch-staker-inventory/:wallet/:stakingPoolId", func(c *fiber.Ctx) error {
-		wallet := c.Params("wallet")
-		stakingPoolId := c.Params("stakingPoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.StakerInventory(wallet, stakingPoolIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch staker inventory for given wallet: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched staker inventory for given wallet.",
-			Data:    &fiber.Map{"inventory": res},
-		})
-	})
-
-	// FetchTokenPreAddSubpoolData route
-	app.Get("/kos/fetch-token-pre-add-subpool-data/", func(c *fiber.Ctx) error {
-		// get the staking pool id, subpool id, key ids, keychain id and superior keychain id from the query params
-		stakingPoolId := c.Query("stakingPoolId")
-		keyIds := c.Query("keyIds")
-		keychainIds := c.Query("keychainIds")
-		superiorKeychainId := c.Query("superiorKeychainId")
-
-		keyIdsStr := strings.Split(keyIds, ",")
-		keyIdsInt := make([]int, len(keyIdsStr))
-		for i, keyId := range keyIdsStr {
-			keyIdInt, err := strconv.Atoi(keyId)
-			if err != nil {
-				return c.JSON(&responses.Response{
-					Status:  fiber.StatusBadRequest,
-					Message: "unable to successfully convert given keyId to int.",
-					Data:    nil,
-				})
-			}
-			keyIdsInt[i] = keyIdInt
-		}
-
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-		keychainIdsStr := strings.Split(keychainIds, ",")
-		keychainIdsInt := make([]int, len(keychainIdsStr))
-		for i, keychainId := range keychainIdsStr {
-			keychainIdInt, err := strconv.Atoi(keychainId)
-			if err != nil {
-				return c.JSON(&responses.Response{
-					Status:  fiber.StatusBadRequest,
-					Message: "unable to successfully convert given keychainId to int.",
-					Data:    nil,
-				})
-			}
-			keychainIdsInt[i] = keychainIdInt
-		}
-		superiorKeychainIdInt, err := strconv.Atoi(superiorKeychainId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given superiorKeychainId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.FetchTokenPreAddSubpoolData(stakingPoolIdInt, keyIdsInt, keychainIdsInt, superiorKeychainIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch token pre add subpool data: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched token pre add subpool data.",
-			Data:    &fiber.Map{"tokenPreAddSubpoolData": res},
-		})
-	})
-
-	app.Get("/kos/backtrack-subpool-points/:stakingPoolId/:subpoolId", func(c *fiber.Ctx) error {
-		stakingPoolId := c.Params("stakingPoolId")
-		subpoolId := c.Params("subpoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-		subpoolIdInt, err := strconv.Atoi(subpoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given subpoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.BacktrackSubpoolPoints(stakingPoolIdInt, subpoolIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully backtrack subpool points: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully backtracked subpool points.",
-			Data:    &fiber.Map{"backtrackSubpoolPoints": res},
-		})
-	})
-
-	// FetchStakerRECBalance route
-	app.Get("/kos/fetch-staker-rec-balance/:wallet", func(c *fiber.Ctx) error {
-		wallet := c.Params("wallet")
-
-		res, err := ApiKOS.GetStakerRECBalance(wallet)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch staker rec balance: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched staker rec balance.",
-			Data:    &fiber.Map{"stakerRecBalance": res},
-		})
-	})
-
-	// FetchSubpoolData route
-	app.Get("/kos/fetch-subpool-data/:stakingPoolId/:subpoolId", func(c *fiber.Ctx) error {
-		stakingPoolId := c.Params("stakingPoolId")
-		subpoolId := c.Params("subpoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-		subpoolIdInt, err := strconv.Atoi(subpoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given subpoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.FetchSubpoolData(stakingPoolIdInt, subpoolIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch subpool data for given stakingPoolId and subpoolId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched subpool data for given stakingPoolId and subpoolId.",
-			Data:    &fiber.Map{"subpoolData": res},
-		})
-	})
-
-	app.Get("/kos/fetch-simplified-metadata/:tokenId", func(c *fiber.Ctx) error {
-		tokenId := c.Params("tokenId")
-		tokenIdInt, err := strconv.Atoi(tokenId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given tokenId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.FetchSimplifiedMetadata(tokenIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch simplified metadata for given tokenId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched simplified metadata for given tokenId.",
-			Data:    &fiber.Map{"simplifiedMetadata": res},
-		})
-	})
-
-	// CalculateStakerTotalSubpoolPoints route
-	app.Get("/kos/staker-total-subpool-points/:wallet/:stakingPoolId", func(c *fiber.Ctx) error {
-		wallet := c.Params("wallet")
-		stakingPoolId := c.Params("stakingPoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.CalculateStakerTotalSubpoolPoints(stakingPoolIdInt, wallet)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully calculate staker total subpool points for given wallet: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully calculated staker total subpool points for given wallet.",
-			Data:    &fiber.Map{"totalSubpoolPoints": res},
-		})
-	})
-
-	// CalculateTotalTokenShare route
-	app.Get("/kos/calculate-total-token-share/:wallet/:stakingPoolId", func(c *fiber.Ctx) error {
-		wallet := c.Params("wallet")
-		stakingPoolId := c.Params("stakingPoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.CalcTotalTokenShare(stakingPoolIdInt, wallet)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully calculate total token share for given wallet: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully calculated total token share for given wallet.",
-			Data:    &fiber.Map{"totalTokenShare": res},
-		})
-	})
-
-	app.Get("/kos/calculate-subpool-token-share/:stakingPoolId/:subpoolId", func(c *fiber.Ctx) error {
-		stakingPoolId := c.Params("stakingPoolId")
-		subpoolId := c.Params("subpoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-		subpoolIdInt, err := strconv.Atoi(subpoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given subpoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.CalculateSubpoolTokenShare(stakingPoolIdInt, subpoolIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully calculate subpool token share for given stakingPoolId and subpoolId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully calculated subpool token share for given stakingPoolId and subpoolId.",
-			Data:    &fiber.Map{"subpoolTokenShare": res},
-		})
-	})
-
-	// CheckSubpoolComboEligibility route
-	app.Get("/kos/check-subpool-combo-eligiblity/:stakerWallet/:stakingPoolId/:keyCount", func(c *fiber.Ctx) error {
-		stakerWallet := c.Params("stakerWallet")
-		stakingPoolId := c.Params("stakingPoolId")
-		keyCount := c.Params("keyCount")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-		keyCountInt, err := strconv.Atoi(keyCount)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given keyCount to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.CheckSubpoolComboEligibility(stakingPoolIdInt, keyCountInt, stakerWallet)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully check subpool combo eligibility for given stakerWallet, stakingPoolId, and keyCount: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully checked subpool combo eligibility for given stakerWallet, stakingPoolId, and keyCount.",
-			Data:    &fiber.Map{"isEligible": res},
-		})
-	})
-
-	// GetStakingPoolData route
-	app.Get("/kos/staking-pool-data/:stakingPoolId", func(c *fiber.Ctx) error {
-		id := c.Params("stakingPoolId")
-		idInt, err := strconv.Atoi(id)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.GetStakingPoolData(idInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch staking pool data for given stakingPoolId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched staking pool data for given stakingPoolId.",
-			Data:    &fiber.Map{"stakingPoolData": res},
-		})
-	})
-
-	// FetchStakingPoolData route
-	app.Get("/kos/fetch-staking-pools", func(c *fiber.Ctx) error {
-		res, err := ApiKOS.FetchStakingPoolData()
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch staking pool data: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched staking pool data.",
-			Data:    &fiber.Map{"stakingPools": res},
-		})
-	})
-
-	// FetchMetadata route
-	app.Get("/kos/fetch-metadata/:tokenId", func(c *fiber.Ctx) error {
-		tokenId := c.Params("tokenId")
-		tokenIdInt, err := strconv.Atoi(tokenId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given tokenId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.FetchMetadata(tokenIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch metadata for given tokenId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched metadata for given tokenId.",
-			Data:    &fiber.Map{"metadata": res},
-		})
-	})
-
-	// OwnerIDs route
-	app.Get("/kos/owner-ids/:address", func(c *fiber.Ctx) error {
-		address := c.Params("address")
-		res, err := ApiKOS.OwnerIDs(address)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch ownerIds for given address: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched ownerIds for given address.",
-			Data:    &fiber.Map{"ownerIds": res},
-		})
-	})
-
-	app.Get("/kos/get-all-staked-key-ids/:stakingPoolId", func(c *fiber.Ctx) error {
-		stakingPoolId := c.Params("stakingPoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-
-		res, err := ApiKOS.GetAllStakedKeyIDs(stakingPoolIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch all staked key ids for given stakingPoolId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched all staked key ids for given stakingPoolId.",
-			Data:    &fiber.Map{"allStakedKeyIds": res},
-		})
-	})
-
-	// TotalTokenReward route
-	app.Get("/kos/total-token-reward/:stakingPoolId", func(c *fiber.Ctx) error {
-		stakingPoolId := c.Params("stakingPoolId")
-		stakingPoolIdInt, err := strconv.Atoi(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "unable to successfully convert given stakingPoolId to int.",
-				Data:    nil,
-			})
-		}
-		res, err := ApiKOS.GetTotalTokenReward(stakingPoolIdInt)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch total token reward for given stakingPoolId: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched total token reward for given stakingPoolId.",
-			Data:    &fiber.Map{"totalTokenReward": res},
-		})
-	})
-
-	// CalculateSubpoolPoints route
-	app.Get("/kos/calculate-subpool-points", func(c *fiber.Ctx) error {
-		// get the keyIds param from the request query params
-		keyIdsParam := c.Query("keyIds")
-		keychainIdsParam := c.Query("keychainIds")
-		// get the superior keychain id param from the request query params
-		superiorKeychainIdParam := c.Query("superiorKeychainId")
-
-		// convert the keyIds param to an array of ints
-		keyIdsStr := strings.Split(keyIdsParam, ",")
-		keyIds := make([]int, len(keyIdsStr))
-		for i, id := range keyIdsStr {
-			idInt, err := strconv.Atoi(id)
-			if err != nil {
-				return c.JSON(&responses.Response{
-					Status:  fiber.StatusBadRequest,
-					Message: fmt.Sprintf("unable to successfully convert given keyId to int: %v", err),
-					Data:    nil,
-				})
-			}
-
-			keyIds[i] = idInt
-		}
+/*
+Registers the legacy `/kos` routes.
 
-		// convert the keychainIDs param to an array of ints
-		keychainIdsStr := strings.Split(keychainIdsParam, ",")
-		keychainIds := make([]int, len(keychainIdsStr))
-		for i, id := range keychainIdsStr {
-			idInt, err := strconv.Atoi(id)
-			if err != nil {
-				return c.JSON(&responses.Response{
-					Status:  fiber.StatusBadRequest,
-					Message: fmt.Sprintf("unable to successfully convert given keychainId to int: %v", err),
-					Data:    nil,
-				})
-			}
-
-			keychainIds[i] = idInt
-		}
-
-		superiorKeychainId, err := strconv.Atoi(superiorKeychainIdParam)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully convert given superiorKeychainId to int: %v", err),
-				Data:    nil,
-			})
-		}
-
-		// call the CalculateSubpoolPoints function
-		points := ApiKOS.CalculateSubpoolPoints(keyIds, keychainIds, superiorKeychainId)
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully calculated subpool points.",
-			Data:    &fiber.Map{"points": points},
-		})
-	})
-
-	app.Get("/kos/get-staker-subpools/:wallet", func(c *fiber.Ctx) error {
-		wallet := c.Params("wallet")
-		res, err := ApiKOS.GetStakerSubpools(wallet)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully fetch staker subpools for given wallet: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully fetched staker subpools for given wallet.",
-			Data:    &fiber.Map{"stakerSubpools": res},
-		})
-	})
+These are deprecated aliases of the `/v1` routes (see `KOSV1Routes`) and will be removed once the webapp has migrated.
+Each legacy route rewrites the request to its `/v1` successor, so both return exactly the same response.
+*/
+func KOSRoutes(app *fiber.App) {
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-staker-inventory/:wallet/:stakingPoolId",
+		Summary:  "fetches the keys, keychains and superior keychains of a staker and whether they can be staked in the staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerPoolRequest{},
+		DataKey:  "inventory",
+		Response: models.KOSStakerInventory{},
+	}, fiber.MethodGet, "/v1/stakers/:wallet/inventory?stakingPoolId=:stakingPoolId")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-token-pre-add-subpool-data/",
+		Summary:  "calculates the points and token share a subpool would get before it is added",
+		Tags:     []string{"Pools"},
+		Request:  requests.TokenPreAddSubpoolRequest{},
+		DataKey:  "tokenPreAddSubpoolData",
+		Response: models.DetailedTokenSubpoolPreAddCalc{},
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/subpool-preview")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/backtrack-subpool-points/:stakingPoolId/:subpoolId",
+		Summary:  "breaks down how the points of an existing subpool were calculated",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolRequest{},
+		DataKey:  "backtrackSubpoolPoints",
+		Response: models.BacktrackedSubpoolPoints{},
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/subpools/:subpoolId/points")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-staker-rec-balance/:wallet",
+		Summary:  "fetches the staker's REC balance",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "stakerRecBalance",
+		Response: float64(0),
+	}, fiber.MethodGet, "/v1/stakers/:wallet/rec-balance")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-subpool-data/:stakingPoolId/:subpoolId",
+		Summary:  "fetches a subpool with the metadata of its staked NFTs",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolRequest{},
+		DataKey:  "subpoolData",
+		Response: models.StakingSubpoolAlt{},
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/subpools/:subpoolId")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-simplified-metadata/:tokenId",
+		Summary:  "fetches the simplified metadata of a Key Of Salvation",
+		Tags:     []string{"Metadata"},
+		Request:  requests.TokenRequest{},
+		DataKey:  "simplifiedMetadata",
+		Response: models.KOSSimplifiedMetadata{},
+	}, fiber.MethodGet, "/v1/collections/kos/tokens/:tokenId/simplified-metadata")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/staker-total-subpool-points/:wallet/:stakingPoolId",
+		Summary:  "calculates the total points of all of a staker's subpools in a staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerPoolRequest{},
+		DataKey:  "totalSubpoolPoints",
+		Response: float64(0),
+	}, fiber.MethodGet, "/v1/stakers/:wallet/pools/:stakingPoolId/points")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/calculate-total-token-share/:wallet/:stakingPoolId",
+		Summary:  "calculates the staker's total token share in a staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerPoolRequest{},
+		DataKey:  "totalTokenShare",
+		Response: float64(0),
+	}, fiber.MethodGet, "/v1/stakers/:wallet/pools/:stakingPoolId/token-share")
+
+	// this route used to be registered twice. only the first registration (responding with `subpoolTokenShare`) was ever served.
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/calculate-subpool-token-share/:stakingPoolId/:subpoolId",
+		Summary:  "calculates a subpool's token share",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolRequest{},
+		DataKey:  "subpoolTokenShare",
+		Response: float64(0),
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/subpools/:subpoolId/token-share")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/check-subpool-combo-eligiblity/:stakerWallet/:stakingPoolId/:keyCount",
+		Summary:  "checks if the staker can stake another subpool with the given number of keys",
+		Tags:     []string{"Stakers"},
+		Request:  requests.ComboEligibilityRequest{},
+		DataKey:  "isEligible",
+		Response: false,
+	}, fiber.MethodGet, "/v1/stakers/:stakerWallet/pools/:stakingPoolId/combo-eligibility?keyCount=:keyCount")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/staking-pool-data/:stakingPoolId",
+		Summary:  "fetches a staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "stakingPoolData",
+		Response: models.StakingPool{},
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-staking-pools",
+		Summary:  "fetches all stakeable, ongoing and closed staking pools",
+		Tags:     []string{"Pools"},
+		DataKey:  "stakingPools",
+		Response: models.AllStakingPools{},
+	}, fiber.MethodGet, "/v1/pools")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/fetch-metadata/:tokenId",
+		Summary:  "fetches the full metadata of a Key Of Salvation",
+		Tags:     []string{"Metadata"},
+		Request:  requests.TokenRequest{},
+		DataKey:  "metadata",
+		Response: models.KOSMetadata{},
+	}, fiber.MethodGet, "/v1/collections/kos/tokens/:tokenId/metadata")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/owner-ids/:address",
+		Summary:  "fetches the Key Of Salvation token IDs owned by an address",
+		Tags:     []string{"Metadata"},
+		Request:  requests.AddressRequest{},
+		DataKey:  "ownerIds",
+		Response: []*big.Int{},
+	}, fiber.MethodGet, "/v1/collections/kos/owners/:address/tokens")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/get-all-staked-key-ids/:stakingPoolId",
+		Summary:  "fetches the IDs of all keys staked in a staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "allStakedKeyIds",
+		Response: []int{},
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/staked-keys")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/total-token-reward/:stakingPoolId",
+		Summary:  "fetches the total token reward of a staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "totalTokenReward",
+		Response: float64(0),
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/total-reward")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/calculate-subpool-points",
+		Summary:  "calculates the points a subpool with the given keys, keychains and superior keychain would get",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolPointsRequest{},
+		DataKey:  "points",
+		Response: float64(0),
+	}, fiber.MethodGet, "/v1/subpool-points")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/get-staker-subpools/:wallet",
+		Summary:  "fetches all of a staker's subpools across all staking pools",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "stakerSubpools",
+		Response: []*models.StakingSubpoolWithID{},
+	}, fiber.MethodGet, "/v1/stakers/:wallet/subpools")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/check-if-staker-banned/:address",
+		Summary:  "checks if a staker is currently banned from staking",
+		Tags:     []string{"Stakers"},
+		Request:  requests.AddressRequest{},
+		DataKey:  "banned",
+		Response: false,
+	}, fiber.MethodGet, "/v1/stakers/:address/ban-status")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/check-pool-time-allowance-exceeded/:stakingPoolId",
+		Summary:  "checks if the staking pool's entry window has closed",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "exceeded",
+		Response: false,
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/entry-window")
+
+	legacyRoute(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/kos/check-if-keys-staked",
+		Summary:  "checks if any of the keys are already staked in the staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.KeysStakedRequest{},
+		DataKey:  "keysStaked",
+		Response: false,
+	}, fiber.MethodGet, "/v1/pools/:stakingPoolId/keys-staked")
+
+	legacyRoute(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/kos/claim-reward",
+		Summary: "claims the reward of a closed subpool",
+		Tags:    []string{"Subpools"},
+		Request: requests.ClaimRewardRequest{},
+	}, fiber.MethodPost, "/v1/pools/:stakingPoolId/subpools/:subpoolId/claim")
+
+	legacyRoute(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/kos/add-subpool",
+		Summary: "stakes keys, keychains and a superior keychain as a new subpool",
+		Tags:    []string{"Subpools"},
+		Request: requests.AddSubpoolRequest{},
+	}, fiber.MethodPost, "/v1/pools/:stakingPoolId/subpools")
+
+	legacyRoute(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/kos/add-staking-pool",
+		Summary: "adds a new staking pool (requires the API password)",
+		Tags:    []string{"Pools"},
+		Request: requests.AddStakingPoolRequest{},
+	}, fiber.MethodPost, "/v1/pools")
+
+	legacyRoute(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/kos/unstake-from-subpool",
+		Summary: "unstakes a subpool before the staking pool starts",
+		Tags:    []string{"Subpools"},
+		Request: requests.UnstakeFromSubpoolRequest{},
+	}, fiber.MethodDelete, "/v1/pools/:stakingPoolId/subpools/:subpoolId")
+}
 
-	app.Get("/kos/calculate-subpool-token-share/:stakingPoolId/:subpoolId", func(c *fiber.Ctx) error {
-		// get the stakingPoolId and subpoolId params from the request query params
-		stakingPoolIdParam := c.Params("stakingPoolId")
-		subpoolIdParam := c.Params("subpoolId")
+// matches the `:name` placeholders in a successor path.
+var successorParam = regexp.MustCompile(`:(\w+)`)
 
-		// convert the stakingPoolId and subpoolId params to ints
-		stakingPoolId, err := strconv.Atoi(stakingPoolIdParam)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully convert given stakingPoolId to int: %v", err),
-				Data:    nil,
-			})
-		}
+/*
+Registers a deprecated legacy route which rewrites the request to its `/v1` successor `successorMethod successorPath`.
 
-		subpoolId, err := strconv.Atoi(subpoolIdParam)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully convert given subpoolId to int: %v", err),
-				Data:    nil,
-			})
-		}
+Every `:name` placeholder in `successorPath` (including its query string) is filled with the legacy request's
+route param `name`, falling back to the query param and then the JSON body field with the same name.
+The original query string is kept. Responses include a `Deprecation` header and a `Link` header pointing to the successor.
+*/
+func legacyRoute(app *fiber.App, route docs.Route, successorMethod, successorPath string) {
+	route.Deprecated = true
+	route.Description = fmt.Sprintf("deprecated: use `%s %s` instead.", successorMethod, successorParam.ReplaceAllString(successorPath, "{$1}"))
 
-		// call the CalculateSubpoolTokenShare function
-		tokenShare, err := ApiKOS.CalculateSubpoolTokenShare(stakingPoolId, subpoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully calculate subpool token share: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully calculated subpool token share.",
-			Data:    &fiber.Map{"tokenShare": tokenShare},
-		})
-	})
+	docs.Register(app, route, func(c *fiber.Ctx) error {
+		c.Set("Deprecation", "true")
 
-	app.Get("/kos/check-if-staker-banned/:address", func(c *fiber.Ctx) error {
-		// get the address param from the request query params
-		addressParam := c.Params("address")
-
-		// call the CheckIfStakerBanned function
-		banned, err := ApiKOS.CheckIfStakerBanned(addressParam)
+		path, query, err := successorURL(c, successorPath)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully check if staker banned: %v", err),
-				Data:    nil,
-			})
+			return err
 		}
 
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully checked if staker banned.",
-			Data:    &fiber.Map{"banned": banned},
-		})
-	})
-
-	app.Get("/kos/check-pool-time-allowance-exceeded/:stakingPoolId", func(c *fiber.Ctx) error {
-		// get the stakingPoolId param from the request query params
-		stakingPoolIdParam := c.Params("stakingPoolId")
-
-		// convert the stakingPoolId param to an int
-		stakingPoolId, err := strconv.Atoi(stakingPoolIdParam)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully convert given stakingPoolId to int: %v", err),
-				Data:    nil,
-			})
+		link := path
+		if query != "" {
+			link += "?" + query
 		}
 
-		// call the CheckPoolTimeAllowanceExceeded function
-		exceeded, err := ApiKOS.CheckPoolTimeAllowanceExceeded(stakingPoolId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully check if pool time allowance exceeded: %v", err),
-				Data:    nil,
-			})
-		}
+		c.Set(fiber.HeaderLink, fmt.Sprintf(`<%s>; rel="successor-version"`, link))
 
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully checked if pool time allowance exceeded.",
-			Data:    &fiber.Map{"exceeded": exceeded},
-		})
+		// rewrite the request and route it to the successor.
+		c.Request().URI().SetQueryString(query)
+		c.Method(successorMethod)
+		c.Path(path)
+		return c.RestartRouting()
 	})
+}
 
-	app.Get("/kos/check-if-keys-staked", func(c *fiber.Ctx) error {
-		// get the keyIds param from the request query params
-		keyIdsParam := c.Query("keyIds")
-
-		// convert the keyIds param to an array of ints
-		keyIdsStr := strings.Split(keyIdsParam, ",")
-		keyIds := make([]int, len(keyIdsStr))
-		for i, id := range keyIdsStr {
-			idInt, err := strconv.Atoi(id)
-			if err != nil {
-				return c.JSON(&responses.Response{
-					Status:  fiber.StatusBadRequest,
-					Message: fmt.Sprintf("unable to successfully convert given keyId to int: %v", err),
-					Data:    nil,
-				})
+/*
+Fills the placeholders of `successorPath` from the legacy request and returns the successor's path and query string.
+*/
+func successorURL(c *fiber.Ctx, successorPath string) (string, string, error) {
+	var body map[string]interface{}
+	if len(c.Body()) > 0 {
+		// an invalid body is reported by the successor when it parses the body itself.
+		_ = json.Unmarshal(c.Body(), &body)
+	}
+
+	var fieldErrors []requests.FieldError
+	fill := func(placeholder string) string {
+		name := placeholder[1:]
+
+		value := c.Params(name)
+		if value == "" {
+			value = c.Query(name)
+		}
+		if value == "" {
+			if field, ok := body[name]; ok && field != nil {
+				value = fmt.Sprint(field)
 			}
-
-			keyIds[i] = idInt
-		}
-
-		// get the stakingPoolId param from the request query params
-		stakingPoolIdParam := c.Query("stakingPoolId")
-
-		// convert the stakingPoolId param to an int
-		stakingPoolId, err := strconv.Atoi(stakingPoolIdParam)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully convert given stakingPoolId to int: %v", err),
-				Data:    nil,
-			})
-		}
-
-		// call the CheckIfKeysStaked function
-		keysStaked, err := ApiKOS.CheckIfKeysStaked(stakingPoolId, keyIds)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully check if keys staked: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully checked if keys staked.",
-			Data:    &fiber.Map{"keysStaked": keysStaked},
-		})
-	})
-
-	// ClaimReward route
-	app.Post("/kos/claim-reward", func(c *fiber.Ctx) error {
-		type ClaimRewardRequest struct {
-			Wallet        string `json:"wallet"`
-			StakingPoolID int    `json:"stakingPoolId"`
-			SubpoolID     int    `json:"subpoolId"`
-		}
-
-		// get the session token from the request header
-		sessionToken := c.Get("session-token")
-
-		// get the request body
-		var claimRewardRequest ClaimRewardRequest
-		if err := c.BodyParser(&claimRewardRequest); err != nil {
-			return c.JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully parse request body: %v", err),
-				Data:    nil,
-			})
-		}
-
-		// call the ClaimReward function
-		err := ApiKOS.ClaimReward(sessionToken, claimRewardRequest.Wallet, claimRewardRequest.StakingPoolID, claimRewardRequest.SubpoolID)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully claim reward: %v", err),
-				Data:    nil,
-			})
 		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully claimed reward.",
-			Data:    nil,
-		})
-	})
-
-	app.Post("/kos/add-subpool", func(c *fiber.Ctx) error {
-		type AddSubpoolRequest struct {
-			KeyIds             []int  `json:"keyIds"`
-			StakerWallet       string `json:"stakerWallet"`
-			StakingPoolId      int    `json:"stakingPoolId"`
-			KeychainIds        []int  `json:"keychainIds"`
-			SuperiorKeychainId int    `json:"superiorKeychainId"`
+		if value == "" {
+			fieldErrors = append(fieldErrors, requests.FieldError{Field: name, Message: "is required"})
 		}
 
-		// get the session token from the request header
-		sessionToken := c.Get("session-token")
+		return url.PathEscape(value)
+	}
 
-		// parse the req body into the AddSubpoolRequest struct
-		var addSubpoolRequest AddSubpoolRequest
-		err := c.BodyParser(&addSubpoolRequest)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully parse request body: %v", err),
-				Data:    nil,
-			})
-		}
+	path, successorQuery, _ := strings.Cut(successorPath, "?")
+	path = successorParam.ReplaceAllStringFunc(path, fill)
+	successorQuery = successorParam.ReplaceAllStringFunc(successorQuery, fill)
+	if len(fieldErrors) > 0 {
+		return "", "", &requests.ValidationError{Fields: fieldErrors}
+	}
 
-		fmt.Printf("addSubpoolRequest: %+v\n", addSubpoolRequest)
+	// keep the original query string and add the successor's query params that were taken from the legacy route params.
+	query := c.Request().URI().QueryArgs()
+	extra, _ := url.ParseQuery(successorQuery)
+	for key, values := range extra {
+		query.Set(key, values[0])
+	}
 
-		// call the AddSubpool fn
-		err = ApiKOS.AddSubpool(addSubpoolRequest.KeyIds, sessionToken, addSubpoolRequest.StakerWallet, addSubpoolRequest.StakingPoolId, addSubpoolRequest.KeychainIds, addSubpoolRequest.SuperiorKeychainId)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully add subpool: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully added subpool.",
-			Data:    nil,
-		})
-	})
-
-	// calls the add staking pool function BUT with a password
-	app.Post("/kos/add-staking-pool", func(c *fiber.Ctx) error {
-		type AddStakingPoolRequest struct {
-			RewardAmount float64 `json:"rewardAmount"`
-			RewardName   string  `json:"rewardName"`
-			Password     string  `json:"password"`
-		}
-
-		// parse the req body into the AddStakingPoolRequest struct
-		var addStakingPoolRequest AddStakingPoolRequest
-		err := c.BodyParser(&addStakingPoolRequest)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully parse request body: %v", err),
-				Data:    nil,
-			})
-		}
-
-		// check if password matches the .env password
-		if addStakingPoolRequest.Password != os.Getenv("API_PASSWORD") {
-			return c.JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: "password does not match.",
-				Data:    nil,
-			})
-		}
-
-		// call the AddStakingPool fn
-		err = ApiKOS.AddStakingPool(addStakingPoolRequest.RewardName, addStakingPoolRequest.RewardAmount)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully add staking pool: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: "successfully added staking pool.",
-			Data:    nil,
-		})
-	})
-
-	// UnstakeFromSubpool route
-	app.Post("/kos/unstake-from-subpool", func(c *fiber.Ctx) error {
-		type UnstakeFromSubpoolRequest struct {
-			StakingPoolID int    `json:"stakingPoolId"`
-			SubpoolID     int    `json:"subpoolId"`
-			Wallet        string `json:"wallet"`
-		}
-
-		// get the session token from the request header
-		sessionToken := c.Get("session-token")
-
-		// parse the req body into the UnstakeFromSubpoolRequest struct
-		var unstakeFromSubpoolRequest UnstakeFromSubpoolRequest
-		err := c.BodyParser(&unstakeFromSubpoolRequest)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully parse request body: %v", err),
-				Data:    nil,
-			})
-		}
-
-		fmt.Printf("unstakeFromSubpoolRequest: %+v\n", unstakeFromSubpoolRequest)
-
-		// call the UnstakeFromSubpool fn
-		err = ApiKOS.UnstakeFromSubpool(sessionToken, unstakeFromSubpoolRequest.Wallet, unstakeFromSubpoolRequest.StakingPoolID, unstakeFromSubpoolRequest.SubpoolID)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(&responses.Response{
-				Status:  fiber.StatusBadRequest,
-				Message: fmt.Sprintf("unable to successfully unstake from subpool: %v", err),
-				Data:    nil,
-			})
-		}
-
-		return c.JSON(&responses.Response{
-			Status:  fiber.StatusOK,
-			Message: fmt.Sprintf("successfully unstaked from subpool %d of staking pool id %d", unstakeFromSubpoolRequest.SubpoolID, unstakeFromSubpoolRequest.StakingPoolID),
-			Data:    nil,
-		})
-	})
+	return path, string(query.QueryString()), nil
 }
@@ -0,0 +1,156 @@
+package routes_nfts
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ApiKOS "nbc-backend-api-v2/api/nfts/kos"
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/requests"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	eventsPingInterval = 30 * time.Second // how often idle connections are pinged so that proxies don't close them
+	eventsRetry        = 5 * time.Second  // how long `EventSource` waits before reconnecting
+)
+
+/*
+Registers the real-time staking pool event routes.
+
+Both routes push a `events.PoolEvent` (as JSON) whenever a subpool is added, unstaked or banned, the pool's total points change
+or the pool enters a new phase. `/ws/pools/:stakingPoolId` is a WebSocket, `/v1/pools/:stakingPoolId/events` is the
+Server-Sent Events fallback for clients that can't use WebSockets.
+
+Events are only pushed while connected, so clients should refetch the pool data after (re)connecting.
+The routes don't return `responses.Response`, so they're left out of the OpenAPI specification.
+*/
+func KOSEventRoutes(app *fiber.App) {
+	poolEventsWebSocket := websocket.New(func(conn *websocket.Conn) {
+		sub := events.SubscribePool(conn.Locals("stakingPoolId").(int))
+		defer sub.Close()
+
+		// clients don't send anything, but reading is needed to notice when they disconnect.
+		disconnected := make(chan struct{})
+		go func() {
+			defer close(disconnected)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(eventsPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case event := <-sub.C:
+				if err := conn.WriteJSON(event); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventsPingInterval)); err != nil {
+					return
+				}
+			case <-disconnected:
+				return
+			}
+		}
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodGet,
+		Path:    "/ws/pools/:stakingPoolId",
+		Summary: "streams the events of a staking pool over a WebSocket",
+		Request: requests.PoolRequest{},
+		Hidden:  true,
+	}, func(c *fiber.Ctx) error {
+		stakingPoolId, err := poolEventsRequest(c)
+		if err != nil {
+			return err
+		}
+
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		c.Locals("stakingPoolId", stakingPoolId)
+		return poolEventsWebSocket(c)
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodGet,
+		Path:    "/v1/pools/:stakingPoolId/events",
+		Summary: "streams the events of a staking pool as Server-Sent Events",
+		Request: requests.PoolRequest{},
+		Hidden:  true,
+	}, func(c *fiber.Ctx) error {
+		stakingPoolId, err := poolEventsRequest(c)
+		if err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no") // stops nginx from buffering the stream
+
+		// subscribe before returning so that no events are missed before the stream starts.
+		sub := events.SubscribePool(stakingPoolId)
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer sub.Close()
+
+			fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			ping := time.NewTicker(eventsPingInterval)
+			defer ping.Stop()
+
+			for {
+				select {
+				case event := <-sub.C:
+					data, err := json.Marshal(event)
+					if err != nil {
+						return
+					}
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				case <-ping.C:
+					fmt.Fprint(w, ": ping\n\n")
+				}
+
+				// flushing fails once the client has disconnected.
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+
+		return nil
+	})
+}
+
+/*
+Validates the `:stakingPoolId` of a pool events route and checks that the staking pool exists.
+*/
+func poolEventsRequest(c *fiber.Ctx) (int, error) {
+	var req requests.PoolRequest
+	if err := requests.Bind(c, &req); err != nil {
+		return 0, err
+	}
+
+	if _, err := ApiKOS.GetStakingPoolData(req.StakingPoolID); err != nil {
+		return 0, fmt.Errorf("unable to subscribe to the events of the staking pool: %w", err)
+	}
+
+	return req.StakingPoolID, nil
+}
@@ -0,0 +1,1154 @@
+package routes_nfts
+
+import (
+	"fmt"
+	"math/big"
+	ApiKOS "nbc-backend-api-v2/api/nfts/kos"
+	"os"
+
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/requests"
+	"nbc-backend-api-v2/responses"
+	"nbc-backend-api-v2/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+Registers the versioned `/v1` KOS staking routes.
+
+Resources:
+
+	/v1/pools                                   staking pools
+	/v1/pools/:stakingPoolId/subpools           subpools of a staking pool
+	/v1/pools/:stakingPoolId/leaderboard        a staking pool's leaderboard (and /v1/leaderboard for the all-time leaderboard)
+	/v1/stakers/:wallet                         stakers and their inventory, subpools and per-pool stats
+	/v1/collections/kos                         Key Of Salvation tokens and owners
+*/
+func KOSV1Routes(app *fiber.App) {
+	/********************
+	POOLS
+	********************/
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools",
+		Summary:  "fetches all stakeable, ongoing and closed staking pools",
+		Tags:     []string{"Pools"},
+		DataKey:  "stakingPools",
+		Response: models.AllStakingPools{},
+	}, func(c *fiber.Ctx) error {
+		res, err := ApiKOS.FetchStakingPoolData()
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch staking pool data: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched staking pool data.",
+			Data:    &fiber.Map{"stakingPools": res},
+		})
+	})
+
+	// calls the add staking pool function BUT with a password
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/v1/pools",
+		Summary: "adds a new staking pool (requires the API password)",
+		Tags:    []string{"Pools"},
+		Request: requests.AddStakingPoolRequest{},
+	}, func(c *fiber.Ctx) error {
+		// parse and validate the req body into the AddStakingPoolRequest struct
+		var addStakingPoolRequest requests.AddStakingPoolRequest
+		if err := requests.Bind(c, &addStakingPoolRequest); err != nil {
+			return err
+		}
+
+		// check if password matches the .env password
+		if addStakingPoolRequest.Password != os.Getenv("API_PASSWORD") {
+			return utils.ErrInvalidPassword
+		}
+
+		// call the AddStakingPool fn
+		err := ApiKOS.AddStakingPool(addStakingPoolRequest.RewardName, addStakingPoolRequest.RewardAmount)
+		if err != nil {
+			return fmt.Errorf("unable to successfully add staking pool: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully added staking pool.",
+			Data:    nil,
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId",
+		Summary:  "fetches a staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "stakingPoolData",
+		Response: models.StakingPool{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetStakingPoolData(req.StakingPoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch staking pool data for given stakingPoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched staking pool data for given stakingPoolId.",
+			Data:    &fiber.Map{"stakingPoolData": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/total-reward",
+		Summary:  "fetches the total token reward of a staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "totalTokenReward",
+		Response: float64(0),
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetTotalTokenReward(req.StakingPoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch total token reward for given stakingPoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched total token reward for given stakingPoolId.",
+			Data:    &fiber.Map{"totalTokenReward": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/entry-window",
+		Summary:  "checks if the staking pool's entry window has closed",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "exceeded",
+		Response: false,
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the CheckPoolTimeAllowanceExceeded function
+		exceeded, err := ApiKOS.CheckPoolTimeAllowanceExceeded(req.StakingPoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully check if pool time allowance exceeded: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully checked if pool time allowance exceeded.",
+			Data:    &fiber.Map{"exceeded": exceeded},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/staked-keys",
+		Summary:  "fetches the IDs of all keys staked in a staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolRequest{},
+		DataKey:  "allStakedKeyIds",
+		Response: []int{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetAllStakedKeyIDs(req.StakingPoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch all staked key ids for given stakingPoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched all staked key ids for given stakingPoolId.",
+			Data:    &fiber.Map{"allStakedKeyIds": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/keys-staked",
+		Summary:  "checks if any of the keys are already staked in the staking pool",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolKeysStakedRequest{},
+		DataKey:  "keysStaked",
+		Response: false,
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolKeysStakedRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the CheckIfKeysStaked function
+		keysStaked, err := ApiKOS.CheckIfKeysStaked(req.StakingPoolID, req.KeyIDs)
+		if err != nil {
+			return fmt.Errorf("unable to successfully check if keys staked: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully checked if keys staked.",
+			Data:    &fiber.Map{"keysStaked": keysStaked},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/subpool-preview",
+		Summary:  "calculates the points and token share a subpool would get before it is added",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolSubpoolPreviewRequest{},
+		DataKey:  "tokenPreAddSubpoolData",
+		Response: models.DetailedTokenSubpoolPreAddCalc{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolSubpoolPreviewRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.FetchTokenPreAddSubpoolData(req.StakingPoolID, req.KeyIDs, req.KeychainIDs, req.SuperiorKeychainID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch token pre add subpool data: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched token pre add subpool data.",
+			Data:    &fiber.Map{"tokenPreAddSubpoolData": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPost,
+		Path:     "/v1/pools/:stakingPoolId/simulations",
+		Summary:  "simulates the token share distribution, the wallet's rank and the value per added key if the wallet added and removed subpools (nothing is changed)",
+		Tags:     []string{"Pools"},
+		Request:  requests.PoolSimulationRequest{},
+		DataKey:  "simulation",
+		Response: models.PoolSimulation{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolSimulationRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		additions := make([]*models.SubpoolSpec, len(req.Additions))
+		for i, spec := range req.Additions {
+			additions[i] = &models.SubpoolSpec{
+				KeyIDs:             spec.KeyIDs,
+				KeychainIDs:        spec.KeychainIDs,
+				SuperiorKeychainID: spec.SuperiorKeychainID,
+			}
+		}
+
+		res, err := ApiKOS.SimulatePool(req.StakingPoolID, req.Wallet, additions, req.Removals, req.ExtraCompetitorPoints, req.RewardAmount)
+		if err != nil {
+			return fmt.Errorf("unable to successfully simulate staking pool: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully simulated staking pool.",
+			Data:    &fiber.Map{"simulation": res},
+		})
+	})
+
+	/********************
+	SUBPOOLS
+	********************/
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/subpools",
+		Summary:  "fetches the active and/or closed subpools of a staking pool",
+		Tags:     []string{"Subpools"},
+		Request:  requests.PoolSubpoolsRequest{},
+		DataKey:  "subpools",
+		Response: []*models.StakingSubpool{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolSubpoolsRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetStakingPoolSubpools(req.StakingPoolID, req.Status)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch subpools for given stakingPoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched subpools for given stakingPoolId.",
+			Data:    &fiber.Map{"subpools": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/v1/pools/:stakingPoolId/subpools",
+		Summary: "stakes keys, keychains and a superior keychain as a new subpool",
+		Tags:    []string{"Subpools"},
+		Request: requests.CreateSubpoolRequest{},
+	}, func(c *fiber.Ctx) error {
+		// parse and validate the req body and session token into the CreateSubpoolRequest struct
+		var req requests.CreateSubpoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the AddSubpool fn
+		err := ApiKOS.AddSubpool(req.KeyIDs, req.SessionToken, req.StakerWallet, req.StakingPoolID, req.KeychainIDs, req.SuperiorKeychainID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully add subpool: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully added subpool.",
+			Data:    nil,
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPost,
+		Path:     "/v1/pools/:stakingPoolId/subpools/batch",
+		Summary:  "stakes several subpools at once (all or nothing), checking ownership once and the combo limits across the whole batch",
+		Tags:     []string{"Subpools"},
+		Request:  requests.CreateSubpoolsRequest{},
+		DataKey:  "batch",
+		Response: models.BatchStakeResult{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.CreateSubpoolsRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		specs := make([]*models.SubpoolSpec, len(req.Subpools))
+		for i, spec := range req.Subpools {
+			specs[i] = &models.SubpoolSpec{
+				KeyIDs:             spec.KeyIDs,
+				KeychainIDs:        spec.KeychainIDs,
+				SuperiorKeychainID: spec.SuperiorKeychainID,
+			}
+		}
+
+		res, err := ApiKOS.AddSubpools(req.SessionToken, req.StakerWallet, req.StakingPoolID, specs)
+		if err != nil {
+			return fmt.Errorf("unable to successfully add subpools: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: fmt.Sprintf("successfully added %d subpools.", len(res.Subpools)),
+			Data:    &fiber.Map{"batch": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/subpools/:subpoolId",
+		Summary:  "fetches a subpool with the metadata of its staked NFTs",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolRequest{},
+		DataKey:  "subpoolData",
+		Response: models.StakingSubpoolAlt{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.SubpoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.FetchSubpoolData(req.StakingPoolID, req.SubpoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch subpool data for given stakingPoolId and subpoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched subpool data for given stakingPoolId and subpoolId.",
+			Data:    &fiber.Map{"subpoolData": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodDelete,
+		Path:    "/v1/pools/:stakingPoolId/subpools/:subpoolId",
+		Summary: "unstakes a subpool before the staking pool starts",
+		Tags:    []string{"Subpools"},
+		Request: requests.SubpoolActionRequest{},
+	}, func(c *fiber.Ctx) error {
+		// parse and validate the req body and session token into the SubpoolActionRequest struct
+		var req requests.SubpoolActionRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the UnstakeFromSubpool fn
+		err := ApiKOS.UnstakeFromSubpool(req.SessionToken, req.Wallet, req.StakingPoolID, req.SubpoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully unstake from subpool: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: fmt.Sprintf("successfully unstaked from subpool %d of staking pool id %d", req.SubpoolID, req.StakingPoolID),
+			Data:    nil,
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/v1/pools/:stakingPoolId/subpools/:subpoolId/claim",
+		Summary: "claims the reward of a closed subpool",
+		Tags:    []string{"Subpools"},
+		Request: requests.SubpoolActionRequest{},
+	}, func(c *fiber.Ctx) error {
+		// parse and validate the request body and session token
+		var req requests.SubpoolActionRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the ClaimReward function
+		err := ApiKOS.ClaimReward(req.SessionToken, req.Wallet, req.StakingPoolID, req.SubpoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully claim reward: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully claimed reward.",
+			Data:    nil,
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/subpools/:subpoolId/points",
+		Summary:  "breaks down how the points of an existing subpool were calculated",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolRequest{},
+		DataKey:  "backtrackSubpoolPoints",
+		Response: models.BacktrackedSubpoolPoints{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.SubpoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.BacktrackSubpoolPoints(req.StakingPoolID, req.SubpoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully backtrack subpool points: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully backtracked subpool points.",
+			Data:    &fiber.Map{"backtrackSubpoolPoints": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/subpools/:subpoolId/token-share",
+		Summary:  "calculates a subpool's token share",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolRequest{},
+		DataKey:  "subpoolTokenShare",
+		Response: float64(0),
+	}, func(c *fiber.Ctx) error {
+		var req requests.SubpoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.CalculateSubpoolTokenShare(req.StakingPoolID, req.SubpoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully calculate subpool token share for given stakingPoolId and subpoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully calculated subpool token share for given stakingPoolId and subpoolId.",
+			Data:    &fiber.Map{"subpoolTokenShare": res},
+		})
+	})
+
+	// CalculateSubpoolPoints route
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/subpool-points",
+		Summary:  "calculates the points a subpool with the given keys, keychains and superior keychain would get",
+		Tags:     []string{"Subpools"},
+		Request:  requests.SubpoolPointsRequest{},
+		DataKey:  "points",
+		Response: float64(0),
+	}, func(c *fiber.Ctx) error {
+		// get the key ids, keychain ids and superior keychain id from the query params
+		var req requests.SubpoolPointsRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the CalculateSubpoolPoints function
+		points, err := ApiKOS.CalculateSubpoolPoints(req.KeyIDs, req.KeychainIDs, req.SuperiorKeychainID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully calculate subpool points: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully calculated subpool points.",
+			Data:    &fiber.Map{"points": points},
+		})
+	})
+
+	/********************
+	LEADERBOARDS
+	********************/
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/pools/:stakingPoolId/leaderboard",
+		Summary:  "fetches a page of a staking pool's leaderboard: its stakers ranked by points, with their projected token share",
+		Tags:     []string{"Leaderboards"},
+		Request:  requests.PoolLeaderboardRequest{},
+		DataKey:  "leaderboard",
+		Response: models.PoolLeaderboard{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.PoolLeaderboardRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetPoolLeaderboard(req.StakingPoolID, req.Page, req.Limit)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch leaderboard for given stakingPoolId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched leaderboard for given stakingPoolId.",
+			Data:    &fiber.Map{"leaderboard": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/leaderboard",
+		Summary:  "fetches a page of the all-time leaderboard: stakers ranked by how much of a reward they've earned across all staking pools",
+		Tags:     []string{"Leaderboards"},
+		Request:  requests.RewardLeaderboardRequest{},
+		DataKey:  "leaderboard",
+		Response: models.RewardLeaderboard{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.RewardLeaderboardRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetRewardLeaderboard(req.Reward, req.Page, req.Limit)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch all-time leaderboard: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched all-time leaderboard.",
+			Data:    &fiber.Map{"leaderboard": res},
+		})
+	})
+
+	/********************
+	STAKERS
+	********************/
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet",
+		Summary:  "fetches a staker",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "staker",
+		Response: models.Staker{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetStaker(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch staker for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched staker for given wallet.",
+			Data:    &fiber.Map{"staker": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/portfolio",
+		Summary:  "fetches every staking pool a staker has staked in, with each subpool's status, points, rewards and claim deadline, and the staker's ban history",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "portfolio",
+		Response: models.StakerPortfolio{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetStakerPortfolio(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch portfolio for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched portfolio for given wallet.",
+			Data:    &fiber.Map{"portfolio": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPost,
+		Path:     "/v1/stakers/:wallet/claims",
+		Summary:  "claims the rewards of all of the staker's claimable, unbanned and unclaimed closed subpools, returning the result of each",
+		Tags:     []string{"Stakers"},
+		Request:  requests.ClaimAllRewardsRequest{},
+		DataKey:  "claims",
+		Response: models.ClaimAllResult{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.ClaimAllRewardsRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.ClaimAllRewards(req.SessionToken, req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully claim rewards: %w", err)
+		}
+
+		// failed claims are returned per subpool, so the request itself still succeeds.
+		claimed := 0
+		for _, result := range res.Results {
+			if result.Claimed {
+				claimed++
+			}
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: fmt.Sprintf("successfully claimed %d of %d rewards.", claimed, len(res.Results)),
+			Data:    &fiber.Map{"claims": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPost,
+		Path:     "/v1/stakers/:wallet/delegations",
+		Summary:  "adds (or replaces) a delegation signed by the vault (EIP-712), allowing the delegate to stake and/or claim on the vault's behalf",
+		Tags:     []string{"Stakers"},
+		Request:  requests.AddDelegationRequest{},
+		DataKey:  "delegation",
+		Response: models.Delegation{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.AddDelegationRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.AddDelegation(req.Wallet, req.Delegate, req.Staking, req.Claiming, req.Expiry, req.Nonce, req.Signature)
+		if err != nil {
+			return fmt.Errorf("unable to successfully add delegation: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully added delegation.",
+			Data:    &fiber.Map{"delegation": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/delegations",
+		Summary:  "fetches the active delegations of a wallet, both to its delegates (as a vault) and from its vaults (as a delegate)",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "delegations",
+		Response: models.StakerDelegations{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetDelegations(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch delegations for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched delegations for given wallet.",
+			Data:    &fiber.Map{"delegations": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPost,
+		Path:     "/v1/stakers/:wallet/sign-in",
+		Summary:  "issues a nonce for a wallet to sign in with its own signature (EIP-712, or EIP-1271 for contract wallets) and returns the SignIn message to sign",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "signIn",
+		Response: models.SignInChallenge{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.IssueSignInNonce(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully issue sign-in nonce: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully issued sign-in nonce.",
+			Data:    &fiber.Map{"signIn": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodDelete,
+		Path:    "/v1/stakers/:wallet/sign-in",
+		Summary: "signs a wallet out by revoking the nonce of its signed session token",
+		Tags:    []string{"Stakers"},
+		Request: requests.SignOutRequest{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.SignOutRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		if err := ApiKOS.SignOut(req.Wallet, req.SessionToken); err != nil {
+			return fmt.Errorf("unable to successfully sign out: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully signed out.",
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/inventory",
+		Summary:  "fetches the keys, keychains and superior keychains of a staker and whether they can be staked in the staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerInventoryRequest{},
+		DataKey:  "inventory",
+		Response: models.KOSStakerInventory{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.StakerInventoryRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.StakerInventory(req.Wallet, req.StakingPoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch staker inventory for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched staker inventory for given wallet.",
+			Data:    &fiber.Map{"inventory": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/pools/:stakingPoolId/recommendation",
+		Summary:  "recommends how a staker should split their unstaked keys, keychains and superior keychains into subpools to get the most points in the staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.SubpoolRecommendationRequest{},
+		DataKey:  "recommendation",
+		Response: models.SubpoolRecommendation{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.SubpoolRecommendationRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.RecommendSubpools(req.Wallet, req.StakingPoolID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully recommend subpools for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully recommended subpools for given wallet.",
+			Data:    &fiber.Map{"recommendation": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/subpools",
+		Summary:  "fetches all of a staker's subpools across all staking pools",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "stakerSubpools",
+		Response: []*models.StakingSubpoolWithID{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetStakerSubpools(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch staker subpools for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched staker subpools for given wallet.",
+			Data:    &fiber.Map{"stakerSubpools": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/rec-balance",
+		Summary:  "fetches the staker's REC balance",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "stakerRecBalance",
+		Response: float64(0),
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetStakerRECBalance(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch staker rec balance: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched staker rec balance.",
+			Data:    &fiber.Map{"stakerRecBalance": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/ban-status",
+		Summary:  "checks if a staker is currently banned from staking",
+		Tags:     []string{"Stakers"},
+		Request:  requests.WalletRequest{},
+		DataKey:  "banned",
+		Response: false,
+	}, func(c *fiber.Ctx) error {
+		var req requests.WalletRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// call the CheckIfStakerBanned function
+		banned, err := ApiKOS.CheckIfStakerBanned(req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully check if staker banned: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully checked if staker banned.",
+			Data:    &fiber.Map{"banned": banned},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/pools/:stakingPoolId/points",
+		Summary:  "calculates the total points of all of a staker's subpools in a staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerPoolRequest{},
+		DataKey:  "totalSubpoolPoints",
+		Response: float64(0),
+	}, func(c *fiber.Ctx) error {
+		var req requests.StakerPoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.CalculateStakerTotalSubpoolPoints(req.StakingPoolID, req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully calculate staker total subpool points for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully calculated staker total subpool points for given wallet.",
+			Data:    &fiber.Map{"totalSubpoolPoints": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/pools/:stakingPoolId/token-share",
+		Summary:  "calculates the staker's total token share in a staking pool",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerPoolRequest{},
+		DataKey:  "totalTokenShare",
+		Response: float64(0),
+	}, func(c *fiber.Ctx) error {
+		var req requests.StakerPoolRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.CalcTotalTokenShare(req.StakingPoolID, req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully calculate total token share for given wallet: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully calculated total token share for given wallet.",
+			Data:    &fiber.Map{"totalTokenShare": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/pools/:stakingPoolId/combo-eligibility",
+		Summary:  "checks if the staker can stake another subpool with the given number of keys",
+		Tags:     []string{"Stakers"},
+		Request:  requests.StakerComboEligibilityRequest{},
+		DataKey:  "isEligible",
+		Response: false,
+	}, func(c *fiber.Ctx) error {
+		var req requests.StakerComboEligibilityRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.CheckSubpoolComboEligibility(req.StakingPoolID, req.KeyCount, req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully check subpool combo eligibility for given wallet, stakingPoolId, and keyCount: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully checked subpool combo eligibility for given wallet, stakingPoolId, and keyCount.",
+			Data:    &fiber.Map{"isEligible": res},
+		})
+	})
+
+	/********************
+	KOS COLLECTION
+	********************/
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/collections/kos/tokens",
+		Summary:  "searches the Key Of Salvation tokens by trait, with the rarity of every trait across the collection",
+		Tags:     []string{"Metadata"},
+		Request:  requests.TokenSearchRequest{},
+		DataKey:  "tokenSearch",
+		Response: models.KOSTokenSearch{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.TokenSearchRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		filter := &models.KOSTokenFilter{
+			House:         req.House,
+			Type:          req.Type,
+			LuckMin:       req.LuckMin,
+			LuckMax:       req.LuckMax,
+			LuckBoostMin:  req.LuckBoostMin,
+			LuckBoostMax:  req.LuckBoostMax,
+			Angel:         requests.OptionalBool(req.Angel),
+			StakingPoolID: req.StakingPoolID,
+			Staked:        requests.OptionalBool(req.Staked),
+		}
+		res, err := ApiKOS.SearchTokens(filter, req.Sort, req.Order == "desc", req.Page, req.Limit)
+		if err != nil {
+			return fmt.Errorf("unable to successfully search tokens: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully searched tokens.",
+			Data:    &fiber.Map{"tokenSearch": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/collections/kos/tokens/:tokenId/score",
+		Summary:  "fetches the rarity and staking utility of a Key Of Salvation, with its ranks across the collection",
+		Tags:     []string{"Metadata"},
+		Request:  requests.TokenRequest{},
+		DataKey:  "score",
+		Response: models.KOSKeyScore{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.TokenRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetKeyScore(req.TokenID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch score for given tokenId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched score for given tokenId.",
+			Data:    &fiber.Map{"score": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/collections/kos/rankings",
+		Summary:  "fetches a page of the Key Of Salvation keys ranked by rarity or staking utility",
+		Tags:     []string{"Metadata"},
+		Request:  requests.KeyRankingRequest{},
+		DataKey:  "ranking",
+		Response: models.KOSKeyRanking{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.KeyRankingRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.GetKeyRanking(req.By, req.Page, req.Limit)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch key ranking: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched key ranking.",
+			Data:    &fiber.Map{"ranking": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/collections/kos/tokens/:tokenId/metadata",
+		Summary:  "fetches the full metadata of a Key Of Salvation",
+		Tags:     []string{"Metadata"},
+		Request:  requests.TokenRequest{},
+		DataKey:  "metadata",
+		Response: models.KOSMetadata{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.TokenRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.FetchMetadata(req.TokenID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch metadata for given tokenId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched metadata for given tokenId.",
+			Data:    &fiber.Map{"metadata": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/collections/kos/tokens/:tokenId/simplified-metadata",
+		Summary:  "fetches the simplified metadata of a Key Of Salvation",
+		Tags:     []string{"Metadata"},
+		Request:  requests.TokenRequest{},
+		DataKey:  "simplifiedMetadata",
+		Response: models.KOSSimplifiedMetadata{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.TokenRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.FetchSimplifiedMetadata(req.TokenID)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch simplified metadata for given tokenId: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched simplified metadata for given tokenId.",
+			Data:    &fiber.Map{"simplifiedMetadata": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/collections/kos/owners/:address/tokens",
+		Summary:  "fetches the Key Of Salvation token IDs owned by an address",
+		Tags:     []string{"Metadata"},
+		Request:  requests.AddressRequest{},
+		DataKey:  "ownerIds",
+		Response: []*big.Int{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.AddressRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiKOS.OwnerIDs(req.Address)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch ownerIds for given address: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched ownerIds for given address.",
+			Data:    &fiber.Map{"ownerIds": res},
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package routes_notifications
+
+import (
+	"fmt"
+	ApiNotifications "nbc-backend-api-v2/api/notifications"
+
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/requests"
+	"nbc-backend-api-v2/responses"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+Registers the routes for a staker's notification inbox and preferences. Every route requires the staker's `session-token` header.
+*/
+func NotificationRoutes(app *fiber.App) {
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/notifications",
+		Summary:  "fetches the newest notifications in the staker's in-app inbox and their unread count",
+		Tags:     []string{"Notifications"},
+		Request:  requests.NotificationsRequest{},
+		DataKey:  "inbox",
+		Response: models.NotificationInbox{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.NotificationsRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiNotifications.GetInbox(req.SessionToken, req.Wallet, req.Unread, req.Limit)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch notifications: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched notifications.",
+			Data:    &fiber.Map{"inbox": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/v1/stakers/:wallet/notifications/:notificationId/read",
+		Summary: "marks a notification in the staker's in-app inbox as read",
+		Tags:    []string{"Notifications"},
+		Request: requests.NotificationRequest{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.NotificationRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		// `Bind` already checked that the ID is a valid object ID.
+		notificationId, _ := primitive.ObjectIDFromHex(req.NotificationID)
+		if err := ApiNotifications.MarkAsRead(req.SessionToken, req.Wallet, notificationId); err != nil {
+			return fmt.Errorf("unable to successfully mark notification as read: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: fmt.Sprintf("successfully marked notification %s as read.", req.NotificationID),
+			Data:    nil,
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/stakers/:wallet/notifications/preferences",
+		Summary:  "fetches the staker's notification preferences (the in-app inbox only if none were set)",
+		Tags:     []string{"Notifications"},
+		Request:  requests.NotificationPreferencesRequest{},
+		DataKey:  "preferences",
+		Response: models.NotificationPreferences{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.NotificationPreferencesRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiNotifications.GetPreferences(req.SessionToken, req.Wallet)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch notification preferences: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched notification preferences.",
+			Data:    &fiber.Map{"preferences": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPut,
+		Path:     "/v1/stakers/:wallet/notifications/preferences",
+		Summary:  "replaces the staker's notification channels (inApp, email, discord) and muted notification kinds",
+		Tags:     []string{"Notifications"},
+		Request:  requests.UpdateNotificationPreferencesRequest{},
+		DataKey:  "preferences",
+		Response: models.NotificationPreferences{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.UpdateNotificationPreferencesRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+
+		res, err := ApiNotifications.UpdatePreferences(req.SessionToken, req.Wallet, &models.NotificationPreferences{
+			Channels:          req.Channels,
+			Email:             req.Email,
+			DiscordWebhookURL: req.DiscordWebhookURL,
+			MutedKinds:        req.MutedKinds,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to successfully update notification preferences: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully updated notification preferences.",
+			Data:    &fiber.Map{"preferences": res},
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package routes_webhooks
+
+import (
+	"crypto/subtle"
+	"fmt"
+	ApiWebhooks "nbc-backend-api-v2/api/webhooks"
+	"os"
+
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/requests"
+	"nbc-backend-api-v2/responses"
+	"nbc-backend-api-v2/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+Registers the admin routes for managing the outgoing webhooks. Every route requires the `api-password` header.
+
+Each delivery is a POST with a `models.WebhookPayload` JSON body, signed in the `X-NBC-Signature` header
+(see `utils/webhooks.Sign`). Failed deliveries are retried with an exponential backoff before they're moved to the dead-letter list.
+*/
+func WebhookRoutes(app *fiber.App) {
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodPost,
+		Path:     "/v1/webhooks",
+		Summary:  "adds a webhook that receives the given staking events (requires the API password)",
+		Tags:     []string{"Webhooks"},
+		Request:  requests.AddWebhookRequest{},
+		DataKey:  "webhook",
+		Response: models.Webhook{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.AddWebhookRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+		if err := authorize(req.AdminRequest); err != nil {
+			return err
+		}
+
+		res, err := ApiWebhooks.AddWebhook(req.URL, req.Events, req.Secret)
+		if err != nil {
+			return fmt.Errorf("unable to successfully add webhook: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully added webhook. the secret is only returned once.",
+			Data:    &fiber.Map{"webhook": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/webhooks",
+		Summary:  "fetches all webhooks, without their secrets (requires the API password)",
+		Tags:     []string{"Webhooks"},
+		Request:  requests.AdminRequest{},
+		DataKey:  "webhooks",
+		Response: []*models.Webhook{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.AdminRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+		if err := authorize(req); err != nil {
+			return err
+		}
+
+		res, err := ApiWebhooks.GetWebhooks()
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch webhooks: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched webhooks.",
+			Data:    &fiber.Map{"webhooks": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodDelete,
+		Path:    "/v1/webhooks/:webhookId",
+		Summary: "removes a webhook (requires the API password)",
+		Tags:    []string{"Webhooks"},
+		Request: requests.WebhookRequest{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.WebhookRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+		if err := authorize(req.AdminRequest); err != nil {
+			return err
+		}
+
+		// `Bind` already checked that the ID is a valid object ID.
+		webhookId, _ := primitive.ObjectIDFromHex(req.WebhookID)
+		if err := ApiWebhooks.RemoveWebhook(webhookId); err != nil {
+			return fmt.Errorf("unable to successfully remove webhook: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: fmt.Sprintf("successfully removed webhook %s.", req.WebhookID),
+			Data:    nil,
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:   fiber.MethodGet,
+		Path:     "/v1/webhooks/deliveries/dead",
+		Summary:  "fetches the dead-letter list: deliveries that failed every attempt (requires the API password)",
+		Tags:     []string{"Webhooks"},
+		Request:  requests.DeadDeliveriesRequest{},
+		DataKey:  "deliveries",
+		Response: []*models.WebhookDelivery{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.DeadDeliveriesRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+		if err := authorize(req.AdminRequest); err != nil {
+			return err
+		}
+
+		var webhookId *primitive.ObjectID
+		if req.WebhookID != "" {
+			id, _ := primitive.ObjectIDFromHex(req.WebhookID)
+			webhookId = &id
+		}
+
+		res, err := ApiWebhooks.GetDeadDeliveries(webhookId)
+		if err != nil {
+			return fmt.Errorf("unable to successfully fetch dead webhook deliveries: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: "successfully fetched dead webhook deliveries.",
+			Data:    &fiber.Map{"deliveries": res},
+		})
+	})
+
+	docs.Register(app, docs.Route{
+		Method:  fiber.MethodPost,
+		Path:    "/v1/webhooks/deliveries/:deliveryId/replay",
+		Summary: "sends a webhook delivery again, e.g. from the dead-letter list (requires the API password)",
+		Tags:    []string{"Webhooks"},
+		Request: requests.DeliveryRequest{},
+	}, func(c *fiber.Ctx) error {
+		var req requests.DeliveryRequest
+		if err := requests.Bind(c, &req); err != nil {
+			return err
+		}
+		if err := authorize(req.AdminRequest); err != nil {
+			return err
+		}
+
+		deliveryId, _ := primitive.ObjectIDFromHex(req.DeliveryID)
+		if err := ApiWebhooks.ReplayDelivery(deliveryId); err != nil {
+			return fmt.Errorf("unable to successfully replay webhook delivery: %w", err)
+		}
+
+		return c.JSON(&responses.Response{
+			Status:  fiber.StatusOK,
+			Message: fmt.Sprintf("successfully queued webhook delivery %s to be sent again.", req.DeliveryID),
+			Data:    nil,
+		})
+	})
+}
+
+/*
+Checks the admin password of `req` against `API_PASSWORD`.
+*/
+func authorize(req requests.AdminRequest) error {
+	if subtle.ConstantTimeCompare([]byte(req.Password), []byte(os.Getenv("API_PASSWORD"))) != 1 {
+		return utils.ErrInvalidPassword
+	}
+
+	return nil
+}
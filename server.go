@@ -1,8 +1,20 @@
 package main
 
 import (
+	"log"
+	ApiKOS "nbc-backend-api-v2/api/nfts/kos"
+	ApiNotifications "nbc-backend-api-v2/api/notifications"
+	ApiWebhooks "nbc-backend-api-v2/api/webhooks"
 	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/docs"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/responses"
+	RoutesDocs "nbc-backend-api-v2/routes/docs"
+	RoutesGraphQL "nbc-backend-api-v2/routes/graphql"
 	RoutesNFTs "nbc-backend-api-v2/routes/nfts"
+	RoutesNotifications "nbc-backend-api-v2/routes/notifications"
+	RoutesWebhooks "nbc-backend-api-v2/routes/webhooks"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	"os"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,17 +31,46 @@ func main() {
 		port = "3000"
 	}
 
-	app := fiber.New()
-
-	// Allow requests from webapp.nbcompany.io
-	app.Use(cors.New(configs.CorsConfig()))
-
 	// runs the ConnectMongo function
 	configs.ConnectMongo()
 
-	RoutesNFTs.KOSRoutes(app)
+	// loads the NFT collection registry, so that an invalid collections config stops the server before it starts serving
+	if _, err := UtilsNFT.Collections(); err != nil {
+		log.Fatal(err)
+	}
+
+	// a vault can only have one delegation per delegate, which `AddDelegation` relies on when two are added at the same time
+	if err := ApiKOS.EnsureDelegationIndexes(); err != nil {
+		log.Fatal(err)
+	}
+
+	// nonces are unique and expire with the signed session tokens that use them
+	if err := ApiKOS.EnsureSignInNonceIndexes(); err != nil {
+		log.Fatal(err)
+	}
+
+	app := newApp()
+
+	// every route should be registered with `docs.Register` so that it shows up in `/openapi.json` (`TestRoutesDocumented` checks it against docs/openapi.json)
+	if missing := docs.MissingRoutes(app); len(missing) > 0 {
+		log.Printf("routes missing from the OpenAPI specification: %v", missing)
+	}
+
+	// dispatches the domain events written to RHOutbox to their subscribers
+	ApiWebhooks.SubscribeToEvents()
+	ApiNotifications.SubscribeToEvents()
+	events.StartOutboxDispatcher(configs.GetCollections(configs.DB, events.OutboxCollection))
+	// pushes the pool events written to RHOutbox to the WebSocket and SSE clients of this instance
+	events.StartPoolStream(configs.GetCollections(configs.DB, events.OutboxCollection))
 
 	// SCHEDULERS
+	// only records which phase transitions were published, so it keeps running while the other schedulers are removed
+	ApiKOS.PublishPoolPhaseTransitionsScheduler().Start()
+	// sends the queued webhook deliveries (and retries the failed ones)
+	ApiWebhooks.SendDueDeliveriesScheduler().Start()
+	// sends the entry closing and reward expiring reminders to the stakers
+	ApiNotifications.SendRemindersScheduler().Start()
+
 	// TEMPORARILY REMOVED SCHEDULERS DUE TO STAKING END (AUG/SEP)
 	// ApiKOS.UpdateTotalYieldPointsScheduler().Start()
 	// ApiKOS.CloseSubpoolsOnStakeEndScheduler().Start()
@@ -39,3 +80,26 @@ func main() {
 
 	app.Listen(":" + port)
 }
+
+/*
+Creates the Fiber app with every route registered. Doesn't connect to the database, so that the routes can be checked without one.
+*/
+func newApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		// maps errors returned by route handlers to the correct HTTP status code and error code
+		ErrorHandler: responses.ErrorHandler,
+	})
+
+	// Allow requests from webapp.nbcompany.io
+	app.Use(cors.New(configs.CorsConfig()))
+
+	RoutesNFTs.KOSV1Routes(app)
+	RoutesNFTs.KOSRoutes(app)
+	RoutesNFTs.KOSEventRoutes(app)
+	RoutesGraphQL.GraphQLRoutes(app)
+	RoutesWebhooks.WebhookRoutes(app)
+	RoutesNotifications.NotificationRoutes(app)
+	RoutesDocs.DocsRoutes(app)
+
+	return app
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"nbc-backend-api-v2/docs"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// the OpenAPI specification served at `/openapi.json`, committed so that changes to the API show up in review.
+const specFile = "docs/openapi.json"
+
+var updateSpec = flag.Bool("update", false, "regenerate "+specFile+" from the registered routes")
+
+func TestRoutesDocumented(t *testing.T) {
+	app := newApp()
+
+	if *updateSpec {
+		spec, err := json.MarshalIndent(docs.Spec(), "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(specFile, append(spec, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := os.ReadFile(specFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec docs.Document
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		t.Fatalf("%s is not a valid OpenAPI specification: %v", specFile, err)
+	}
+
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range *item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	// the docs routes themselves are left out of the specification
+	hidden := map[string]bool{}
+	for _, route := range docs.Routes() {
+		if route.Hidden {
+			hidden[route.Method+" "+docs.OpenAPIPath(route.Path)] = true
+		}
+	}
+
+	// middleware (`app.Use`) isn't returned when filtering, and Fiber adds a `HEAD` route for every `GET` route
+	registered := map[string]bool{}
+	for _, route := range app.GetRoutes(true) {
+		key := route.Method + " " + docs.OpenAPIPath(route.Path)
+		if route.Method == fiber.MethodHead || hidden[key] {
+			continue
+		}
+		registered[key] = true
+	}
+
+	if missing := difference(registered, documented); len(missing) > 0 {
+		t.Errorf("routes missing from %s (register them with `docs.Register` and run `go test -run TestRoutesDocumented -update`): %v", specFile, missing)
+	}
+	if stale := difference(documented, registered); len(stale) > 0 {
+		t.Errorf("routes in %s that aren't registered (run `go test -run TestRoutesDocumented -update`): %v", specFile, stale)
+	}
+}
+
+/*
+Returns the sorted keys of `a` that aren't in `b`.
+*/
+func difference(a, b map[string]bool) []string {
+	var keys []string
+	for key := range a {
+		if !b[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
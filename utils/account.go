@@ -12,10 +12,27 @@ Fetches the wallet from `sessionToken` and checks if it matches `walletToCheck`.
 Returns true if the wallet matches, false otherwise.
 */
 func CheckWalletMatchFromSessionToken(sessionToken, walletToCheck string) (bool, error) {
-	res, err := http.Get(fmt.Sprintf(`https://nbc-webapp-api-ts-production.up.railway.app/backend-account/fetch-wallet-from-session-token/%s`, sessionToken))
+	walletAddress, err := WalletFromSessionToken(sessionToken)
 	if err != nil {
 		return false, err
 	}
+
+	// check if the wallet address matches the staker's wallet address.
+	if walletAddress != strings.ToLower(walletToCheck) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+/*
+Fetches the wallet (in lowercase) that `sessionToken` belongs to from the session service.
+*/
+func WalletFromSessionToken(sessionToken string) (string, error) {
+	res, err := http.Get(fmt.Sprintf(`https://nbc-webapp-api-ts-production.up.railway.app/backend-account/fetch-wallet-from-session-token/%s`, sessionToken))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrSessionServiceUnavailable, err)
+	}
 	defer res.Body.Close()
 
 	var responseBody struct {
@@ -26,18 +43,12 @@ func CheckWalletMatchFromSessionToken(sessionToken, walletToCheck string) (bool,
 		} `json:"data"`
 	}
 	if err := json.NewDecoder(res.Body).Decode(&responseBody); err != nil {
-		return false, err
+		return "", fmt.Errorf("%w: %v", ErrSessionServiceUnavailable, err)
 	}
 
 	if responseBody.Status != 200 {
-		return false, fmt.Errorf("unable to fetch wallet address from session token: %s", responseBody.Message)
+		return "", fmt.Errorf("%w: unable to fetch wallet address from session token: %s", ErrInvalidSession, responseBody.Message)
 	}
 
-	walletAddress := responseBody.Data.WalletAddress
-	// check if the wallet address matches the staker's wallet address.
-	if walletAddress != strings.ToLower(walletToCheck) {
-		return false, nil
-	}
-
-	return true, nil
+	return strings.ToLower(responseBody.Data.WalletAddress), nil
 }
@@ -3,6 +3,7 @@ package utils
 import (
 	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -10,6 +11,11 @@ import (
 ValidChecksum checks whether a given `addr` address is a valid checksum address.
 */
 func ValidChecksum(addr string) bool {
+	// anything shorter than the `0x` prefix can never be a valid address (and would otherwise panic below).
+	if len(addr) < 2 || !strings.HasPrefix(strings.ToLower(addr), "0x") {
+		return false
+	}
+
 	hex := strings.ToLower(addr)[2:]
 
 	d := sha3.NewLegacyKeccak256()
@@ -30,3 +36,27 @@ func ValidChecksum(addr string) bool {
 
 	return addr == ret
 }
+
+/*
+ValidAddress checks whether `addr` is a valid EVM address.
+All lowercase (or all uppercase) addresses are accepted as is, while mixed case addresses must have a valid checksum.
+*/
+func ValidAddress(addr string) bool {
+	if !strings.HasPrefix(addr, "0x") || !common.IsHexAddress(addr) {
+		return false
+	}
+
+	hex := addr[2:]
+	if hex == strings.ToLower(hex) || hex == strings.ToUpper(hex) {
+		return true
+	}
+
+	return ValidChecksum(addr)
+}
+
+/*
+NormalizeWallet returns the lowercase version of `addr`, which is how wallets are stored in `RHStakerData`.
+*/
+func NormalizeWallet(addr string) string {
+	return strings.ToLower(addr)
+}
@@ -46,21 +46,6 @@ func LoadContract(
 		}
 	}
 
-	abiContentBytes, err := os.ReadFile(abiPath)
-	if err != nil {
-		return nil, err
-	}
-
-	// converts the array of bytes obtained from reading the abi to a string
-	abiContent := string(abiContentBytes)
-
-	// contract address on the specific chain
-	addr := common.HexToAddress(os.Getenv(contractAddressEnv))
-	abi, err := abi.JSON(strings.NewReader(abiContent))
-	if err != nil {
-		return nil, err
-	}
-
 	// checks if `caller`, `transactor` or `filterer` are present. if not, default to `client`.
 	if caller == nil {
 		caller = client
@@ -72,6 +57,57 @@ func LoadContract(
 		filterer = client
 	}
 
-	contract := bind.NewBoundContract(addr, abi, caller, transactor, filterer)
+	return BindContract(abiPath, contractAddressEnv, caller, transactor, filterer)
+}
+
+/*
+`BindContract` binds the contract at the address in `contractAddressEnv` with the ABI at `abiPath` to an existing backend
+(e.g. the client of a specific chain), without connecting to a new client.
+*/
+func BindContract(
+	abiPath string,
+	contractAddressEnv string,
+	caller bind.ContractCaller,
+	transactor bind.ContractTransactor,
+	filterer bind.ContractFilterer,
+) (*bind.BoundContract, error) {
+	// contract address on the specific chain
+	addr := common.HexToAddress(os.Getenv(contractAddressEnv))
+
+	return BindContractAt(abiPath, addr, caller, transactor, filterer)
+}
+
+/*
+`BindContractAt` works like `BindContract`, but for a contract at `address` that isn't configured in an env variable
+(e.g. a contract wallet that signed a message).
+*/
+func BindContractAt(
+	abiPath string,
+	address common.Address,
+	caller bind.ContractCaller,
+	transactor bind.ContractTransactor,
+	filterer bind.ContractFilterer,
+) (*bind.BoundContract, error) {
+	abi, err := LoadABI(abiPath)
+	if err != nil {
+		return nil, err
+	}
+
+	contract := bind.NewBoundContract(address, abi, caller, transactor, filterer)
 	return contract, nil
 }
+
+/*
+`LoadABI` reads and parses the ABI at `abiPath`.
+*/
+func LoadABI(abiPath string) (abi.ABI, error) {
+	abiContentBytes, err := os.ReadFile(abiPath)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+
+	// converts the array of bytes obtained from reading the abi to a string
+	abiContent := string(abiContentBytes)
+
+	return abi.JSON(strings.NewReader(abiContent))
+}
@@ -0,0 +1,67 @@
+package utils
+
+import "errors"
+
+/*
+`ErrorKind` classifies a `DomainError` so that the API layer can map it to the correct HTTP status code
+without having to know about every single error that can be returned.
+*/
+type ErrorKind int
+
+const (
+	KindInternal      ErrorKind = iota // unexpected failures (bugs, unknown database errors)
+	KindBadRequest                     // malformed requests (e.g. params that cannot be parsed)
+	KindUnauthorized                   // missing or invalid session tokens
+	KindForbidden                      // the caller is not allowed to perform the action (e.g. banned, not the owner)
+	KindNotFound                       // the requested resource (pool, subpool, staker) does not exist
+	KindConflict                       // the action conflicts with the current state (e.g. already claimed, window closed)
+	KindUnprocessable                  // the request is well-formed but semantically invalid (e.g. invalid key count)
+	KindUpstream                       // an upstream dependency (chain RPC, IPFS, session service) failed
+)
+
+/*
+`DomainError` is an error with a stable, machine-readable `Code` that the webapp can switch on.
+
+Domain errors are declared once as package level variables (e.g. `ErrPoolNotFound`) and can be wrapped with more context
+using `fmt.Errorf("...: %w", err)`. `errors.Is` and `errors.As` will still find the original domain error.
+*/
+type DomainError struct {
+	Kind    ErrorKind // used to determine the HTTP status code
+	Code    string    // stable machine-readable error code, e.g. "POOL_NOT_FOUND"
+	Message string    // human-readable default message
+}
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+/*
+Creates a new `DomainError` instance.
+*/
+func NewDomainError(kind ErrorKind, code, message string) *DomainError {
+	return &DomainError{Kind: kind, Code: code, Message: message}
+}
+
+/*
+Returns the `DomainError` wrapped inside `err` (if any).
+*/
+func AsDomainError(err error) (*DomainError, bool) {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+
+	return nil, false
+}
+
+// generic domain errors that are not specific to a collection.
+var (
+	ErrInvalidRequest            = NewDomainError(KindBadRequest, "INVALID_REQUEST", "invalid request")
+	ErrValidationFailed          = NewDomainError(KindUnprocessable, "VALIDATION_FAILED", "one or more request fields are invalid")
+	ErrInvalidSession            = NewDomainError(KindUnauthorized, "INVALID_SESSION", "invalid or expired session token")
+	ErrSessionMismatch           = NewDomainError(KindForbidden, "SESSION_WALLET_MISMATCH", "wallet from session token does not match wallet given")
+	ErrInvalidPassword           = NewDomainError(KindForbidden, "INVALID_PASSWORD", "password does not match") // the admin password given to a protected route does not match `API_PASSWORD`
+	ErrSessionServiceUnavailable = NewDomainError(KindUpstream, "SESSION_SERVICE_UNAVAILABLE", "unable to reach the session service")
+	ErrChainUnavailable          = NewDomainError(KindUpstream, "CHAIN_UNAVAILABLE", "unable to read data from the chain")
+	ErrDatabaseUnavailable       = NewDomainError(KindUpstream, "DATABASE_UNAVAILABLE", "unable to read from or write to the database")
+)
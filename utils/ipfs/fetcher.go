@@ -0,0 +1,291 @@
+package utils_ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"nbc-backend-api-v2/utils"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the gateways used when `IPFS_GATEWAYS` isn't set, in the order they are tried.
+var DefaultGateways = []string{
+	"https://gateway.pinata.cloud/ipfs/",
+	"https://ipfs.io/ipfs/",
+	"https://cloudflare-ipfs.com/ipfs/",
+}
+
+var (
+	ErrAllGatewaysFailed = errors.New("unable to fetch the content from any IPFS gateway")
+	ErrNotFound          = errors.New("content not found")
+)
+
+/*
+Fetches content from IPFS through an ordered list of gateways, falling back to the next gateway when one fails.
+
+Each request has its own timeout and failed requests are retried (with jittered exponential backoff) before moving on to the next gateway.
+A gateway that keeps failing is skipped for `BreakerCooldown` (circuit breaking), after which it's tried again.
+Content fetched by CID is cached by its CID path, since it can never change.
+*/
+type Fetcher struct {
+	Gateways         []string      // the base URLs of the gateways (ending with `/ipfs/`), in the order they are tried
+	Client           *http.Client  // the HTTP client used for the requests
+	Timeout          time.Duration // the timeout of each request
+	Retries          int           // how many times a failed request is retried on the same gateway
+	BackoffBase      time.Duration // the delay before the first retry (doubled for each retry, plus up to 100% jitter)
+	BreakerThreshold int           // the number of consecutive failures after which a gateway is skipped
+	BreakerCooldown  time.Duration // how long a gateway is skipped for
+
+	mu       sync.Mutex
+	breakers map[string]*gatewayBreaker
+	cache    *utils.LRU[string, []byte] // content by CID path
+}
+
+type gatewayBreaker struct {
+	failures  int       // consecutive failures
+	openUntil time.Time // the gateway is skipped until this time
+}
+
+/*
+Returns a `Fetcher` for `gateways` with the default timeouts, retries and circuit breaking, caching up to `cacheSize` CID paths.
+*/
+func NewFetcher(gateways []string, cacheSize int) *Fetcher {
+	normalized := make([]string, len(gateways))
+	for i, gateway := range gateways {
+		normalized[i] = normalizeGateway(gateway)
+	}
+
+	return &Fetcher{
+		Gateways:         normalized,
+		Client:           &http.Client{},
+		Timeout:          10 * time.Second,
+		Retries:          2,
+		BackoffBase:      200 * time.Millisecond,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+		breakers:         map[string]*gatewayBreaker{},
+		cache:            utils.NewLRU[string, []byte](cacheSize),
+	}
+}
+
+/*
+Returns the gateways in the `IPFS_GATEWAYS` env variable (comma-separated), or `DefaultGateways` if it isn't set.
+*/
+func GatewaysFromEnv() []string {
+	var gateways []string
+	for _, gateway := range strings.Split(os.Getenv("IPFS_GATEWAYS"), ",") {
+		if gateway = strings.TrimSpace(gateway); gateway != "" {
+			gateways = append(gateways, gateway)
+		}
+	}
+	if len(gateways) == 0 {
+		return DefaultGateways
+	}
+	return gateways
+}
+
+/*
+Returns the CID path (e.g. `QmHash/25.json`) of `uri`, which can be an `ipfs://` URI (including `ipfs://ipfs/...`),
+a gateway URL with an `/ipfs/` path, an `/ipfs/...` path or a bare CID path. Returns false if `uri` isn't an IPFS URI.
+
+If `uri` is a gateway URL, its gateway (e.g. `https://gateway.pinata.cloud/ipfs/`) is also returned.
+*/
+func NormalizeURI(uri string) (cidPath, gateway string, ok bool) {
+	uri = strings.TrimSpace(uri)
+
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		cidPath = strings.TrimPrefix(strings.TrimPrefix(uri, "ipfs://"), "ipfs/")
+	case strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://"):
+		index := strings.Index(uri, "/ipfs/")
+		if index == -1 {
+			return "", "", false
+		}
+		gateway = uri[:index+len("/ipfs/")]
+		cidPath = uri[index+len("/ipfs/"):]
+	case strings.HasPrefix(uri, "/ipfs/"):
+		cidPath = strings.TrimPrefix(uri, "/ipfs/")
+	case strings.HasPrefix(uri, "Qm") || strings.HasPrefix(uri, "baf"):
+		cidPath = uri
+	default:
+		return "", "", false
+	}
+
+	cidPath = strings.TrimLeft(cidPath, "/")
+	if cidPath == "" {
+		return "", "", false
+	}
+
+	return cidPath, gateway, true
+}
+
+/*
+Fetches the content at `uri` (see `NormalizeURI`). `validate` (optional) is called with the content of each response,
+so that a gateway returning broken content (e.g. an HTML error page) counts as a failure and the next gateway is tried.
+
+IPFS URIs are fetched from the URI's own gateway (if any) and then from `Gateways`. Other URLs are fetched as they are (with retries).
+*/
+func (f *Fetcher) Fetch(ctx context.Context, uri string, validate func(content []byte) error) ([]byte, error) {
+	cidPath, uriGateway, ok := NormalizeURI(uri)
+	if !ok {
+		content, err := f.fetchWithRetries(ctx, uri, validate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrAllGatewaysFailed, uri, err)
+		}
+		return content, nil
+	}
+
+	if content, ok := f.cache.Get(cidPath); ok {
+		return content, nil
+	}
+
+	gateways := f.Gateways
+	if uriGateway != "" {
+		gateways = append([]string{uriGateway}, without(f.Gateways, uriGateway)...)
+	}
+
+	var failures []string
+	for _, gateway := range gateways {
+		if !f.allow(gateway) {
+			failures = append(failures, fmt.Sprintf("%s: skipped after too many failures", gateway))
+			continue
+		}
+
+		content, err := f.fetchWithRetries(ctx, gateway+cidPath, validate)
+		if err != nil {
+			// a missing file (404) says nothing about the gateway's health.
+			if !errors.Is(err, ErrNotFound) {
+				f.recordFailure(gateway)
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", gateway, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		f.recordSuccess(gateway)
+		f.cache.Add(cidPath, content)
+		return content, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s: %s", ErrAllGatewaysFailed, cidPath, strings.Join(failures, "; "))
+}
+
+/*
+Fetches `url`, retrying up to `Retries` times. Responses with a 404 status aren't retried.
+*/
+func (f *Fetcher) fetchWithRetries(ctx context.Context, url string, validate func(content []byte) error) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt <= f.Retries; attempt++ {
+		if attempt > 0 {
+			// exponential backoff with up to 100% jitter, so that retries of concurrent requests don't all happen at once.
+			backoff := f.BackoffBase << (attempt - 1)
+			if backoff > 0 {
+				backoff += time.Duration(rand.Int63n(int64(backoff)))
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var content []byte
+		content, err = f.fetchOnce(ctx, url)
+		if err == nil && validate != nil {
+			err = validate(content)
+		}
+		if err == nil {
+			return content, nil
+		}
+		if errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+func (f *Fetcher) fetchOnce(ctx context.Context, url string) ([]byte, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", res.StatusCode)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+func (f *Fetcher) allow(gateway string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	breaker, ok := f.breakers[gateway]
+	return !ok || !time.Now().Before(breaker.openUntil)
+}
+
+func (f *Fetcher) recordFailure(gateway string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	breaker, ok := f.breakers[gateway]
+	if !ok {
+		breaker = &gatewayBreaker{}
+		f.breakers[gateway] = breaker
+	}
+	breaker.failures++
+	// after the cooldown, a single failure opens the breaker again.
+	if breaker.failures >= f.BreakerThreshold {
+		breaker.openUntil = time.Now().Add(f.BreakerCooldown)
+	}
+}
+
+func (f *Fetcher) recordSuccess(gateway string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.breakers, gateway)
+}
+
+func normalizeGateway(gateway string) string {
+	gateway = strings.TrimRight(strings.TrimSpace(gateway), "/")
+	if !strings.HasSuffix(gateway, "/ipfs") {
+		gateway += "/ipfs"
+	}
+	return gateway + "/"
+}
+
+func without(gateways []string, gateway string) []string {
+	var rest []string
+	for _, g := range gateways {
+		if g != gateway {
+			rest = append(rest, g)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,199 @@
+package utils_ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testContent = `{"name":"Key Of Salvation #25"}`
+
+/*
+A test gateway that responds with `status` and `body` (which can be changed while it's running), counting its requests.
+*/
+type gateway struct {
+	server   *httptest.Server
+	status   atomic.Int32
+	body     atomic.Value
+	requests atomic.Int32
+}
+
+func newGateway(t *testing.T, status int, body string) *gateway {
+	g := &gateway{}
+	g.respond(status, body)
+	g.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.requests.Add(1)
+		w.WriteHeader(int(g.status.Load()))
+		w.Write([]byte(g.body.Load().(string)))
+	}))
+	t.Cleanup(g.server.Close)
+
+	return g
+}
+
+func (g *gateway) respond(status int, body string) {
+	g.status.Store(int32(status))
+	g.body.Store(body)
+}
+
+func (g *gateway) url() string {
+	return normalizeGateway(g.server.URL)
+}
+
+/*
+Returns a `Fetcher` for `gateways` that doesn't wait between retries.
+*/
+func newTestFetcher(gateways ...*gateway) *Fetcher {
+	urls := make([]string, len(gateways))
+	for i, g := range gateways {
+		urls[i] = g.server.URL
+	}
+
+	f := NewFetcher(urls, 10)
+	f.Timeout = time.Second
+	f.BackoffBase = 0
+	return f
+}
+
+func validateJSON(content []byte) error {
+	if !json.Valid(content) {
+		return errors.New("content is not valid JSON")
+	}
+	return nil
+}
+
+func TestFetchFallsBackToHealthyGateway(t *testing.T) {
+	failing := newGateway(t, http.StatusBadGateway, "bad gateway")
+	healthy := newGateway(t, http.StatusOK, testContent)
+	f := newTestFetcher(failing, healthy)
+
+	content, err := f.Fetch(context.Background(), "ipfs://QmTest/25.json", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != testContent {
+		t.Errorf("content = %q, want %q", content, testContent)
+	}
+	// the failing gateway is retried before falling back.
+	if got, want := failing.requests.Load(), int32(f.Retries+1); got != want {
+		t.Errorf("failing gateway got %d requests, want %d", got, want)
+	}
+	if got := healthy.requests.Load(); got != 1 {
+		t.Errorf("healthy gateway got %d requests, want 1", got)
+	}
+}
+
+func TestFetchNotFoundKeepsBreakerClosed(t *testing.T) {
+	missing := newGateway(t, http.StatusNotFound, "not found")
+	f := newTestFetcher(missing)
+	f.BreakerThreshold = 1
+
+	for i := 0; i < 3; i++ {
+		_, err := f.Fetch(context.Background(), "ipfs://QmMissing/25.json", nil)
+		if !errors.Is(err, ErrAllGatewaysFailed) {
+			t.Fatalf("error = %v, want ErrAllGatewaysFailed", err)
+		}
+	}
+
+	// a 404 is neither retried nor counted as a failure of the gateway.
+	if got := missing.requests.Load(); got != 3 {
+		t.Errorf("gateway got %d requests, want 3", got)
+	}
+	if !f.allow(missing.url()) {
+		t.Error("breaker is open after 404 responses")
+	}
+}
+
+func TestFetchBreaker(t *testing.T) {
+	flaky := newGateway(t, http.StatusInternalServerError, "internal server error")
+	healthy := newGateway(t, http.StatusOK, testContent)
+	f := newTestFetcher(flaky, healthy)
+	f.Retries = 0
+	f.BreakerThreshold = 2
+	f.BreakerCooldown = 100 * time.Millisecond
+
+	// different CIDs, so that nothing is served from the cache.
+	fetch := func(cid string) {
+		t.Helper()
+		if _, err := f.Fetch(context.Background(), "ipfs://"+cid, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	fetch("QmOne")
+	fetch("QmTwo")
+	if got := flaky.requests.Load(); got != 2 {
+		t.Fatalf("flaky gateway got %d requests, want 2", got)
+	}
+
+	// the breaker is open after `BreakerThreshold` failures, so the flaky gateway is skipped.
+	fetch("QmThree")
+	if got := flaky.requests.Load(); got != 2 {
+		t.Errorf("flaky gateway got %d requests while its breaker is open, want 2", got)
+	}
+
+	// after `BreakerCooldown`, the gateway is tried again and a success closes the breaker.
+	time.Sleep(f.BreakerCooldown)
+	flaky.respond(http.StatusOK, testContent)
+	healthyRequests := healthy.requests.Load()
+	fetch("QmFour")
+	if got := flaky.requests.Load(); got != 3 {
+		t.Errorf("flaky gateway got %d requests after the cooldown, want 3", got)
+	}
+	if got := healthy.requests.Load(); got != healthyRequests {
+		t.Errorf("healthy gateway got %d more requests, want none", got-healthyRequests)
+	}
+	if _, ok := f.breakers[flaky.url()]; ok {
+		t.Error("breaker wasn't reset after a success")
+	}
+}
+
+func TestFetchCachesCIDs(t *testing.T) {
+	g := newGateway(t, http.StatusOK, testContent)
+	f := newTestFetcher(g)
+
+	// the same CID path, through different kinds of URIs.
+	for _, uri := range []string{"ipfs://QmTest/25.json", "ipfs://ipfs/QmTest/25.json", "/ipfs/QmTest/25.json", "QmTest/25.json"} {
+		content, err := f.Fetch(context.Background(), uri, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", uri, err)
+		}
+		if string(content) != testContent {
+			t.Errorf("%s: content = %q, want %q", uri, content, testContent)
+		}
+	}
+
+	if got := g.requests.Load(); got != 1 {
+		t.Errorf("gateway got %d requests, want 1", got)
+	}
+}
+
+func TestFetchValidateRejectsHTML(t *testing.T) {
+	broken := newGateway(t, http.StatusOK, "<html><body>504 Gateway Time-out</body></html>")
+	healthy := newGateway(t, http.StatusOK, testContent)
+	f := newTestFetcher(broken, healthy)
+	f.Retries = 0
+
+	content, err := f.Fetch(context.Background(), "ipfs://QmTest/25.json", validateJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != testContent {
+		t.Errorf("content = %q, want the content of the healthy gateway", content)
+	}
+	if got := broken.requests.Load(); got != 1 {
+		t.Errorf("broken gateway got %d requests, want 1", got)
+	}
+
+	// without another gateway, the HTML page is an error rather than the content.
+	f = newTestFetcher(broken)
+	f.Retries = 0
+	if _, err := f.Fetch(context.Background(), "ipfs://QmTest/25.json", validateJSON); !errors.Is(err, ErrAllGatewaysFailed) {
+		t.Errorf("error = %v, want ErrAllGatewaysFailed", err)
+	}
+}
@@ -0,0 +1,193 @@
+package utils_nft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"nbc-backend-api-v2/utils"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+/*
+The client of a chain. Satisfied by `ethclient.Client` and by go-ethereum's simulated backend.
+*/
+type ChainBackend interface {
+	bind.ContractBackend
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+/*
+An RPC endpoint of a chain.
+*/
+type RPC struct {
+	URL       string `json:"url"`                 // the RPC URL, which the API key is appended to if `APIKeyEnv` is set
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"` // the env variable with the RPC's API key
+}
+
+func (r *RPC) url() string {
+	if r.APIKeyEnv == "" {
+		return r.URL
+	}
+	return r.URL + os.Getenv(r.APIKeyEnv)
+}
+
+/*
+The client of an RPC, which can also tell which chain it's on.
+*/
+type rpcClient interface {
+	ChainBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+/*
+Connects to the RPC at `url`. Replaced in tests.
+*/
+var dialRPC = func(ctx context.Context, url string) (rpcClient, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+/*
+A chain the collections are deployed on (e.g. Ethereum, Polygon, Base or Arbitrum).
+*/
+type Chain struct {
+	Name          string `json:"name"`          // how collections refer to the chain
+	ChainID       int64  `json:"chainId"`       // the EIP-155 chain ID, checked against every RPC before it's used
+	RPCs          []*RPC `json:"rpcs"`          // tried in order until one is reachable
+	Confirmations uint64 `json:"confirmations"` // how many blocks behind the head reads are made, so that they aren't undone by a reorg
+
+	mu      sync.Mutex
+	backend ChainBackend
+	current int // the index of the RPC `backend` is connected to
+	next    int // the index of the RPC to try first when connecting
+}
+
+/*
+Returns the client of the chain, connecting to the first of its `RPCs` that is reachable and on the right chain ID.
+The client is kept until a read on it fails (see `Read`), after which the RPCs are tried again starting from the next one.
+*/
+func (c *Chain) Backend(ctx context.Context) (ChainBackend, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.backend != nil {
+		return c.backend, nil
+	}
+
+	for i := range c.RPCs {
+		index := (c.next + i) % len(c.RPCs)
+		rpc := c.RPCs[index]
+
+		client, err := dialRPC(ctx, rpc.url())
+		if err != nil {
+			log.Printf("unable to connect to RPC %s of chain %s: %v", rpc.URL, c.Name, err)
+			continue
+		}
+
+		chainId, err := client.ChainID(ctx)
+		if err != nil {
+			log.Printf("unable to get the chain ID from RPC %s of chain %s: %v", rpc.URL, c.Name, err)
+			closeBackend(client)
+			continue
+		}
+		if chainId.Cmp(big.NewInt(c.ChainID)) != 0 {
+			log.Printf("RPC %s of chain %s is on chain ID %s instead of %d", rpc.URL, c.Name, chainId, c.ChainID)
+			closeBackend(client)
+			continue
+		}
+
+		c.backend, c.current = client, index
+		return client, nil
+	}
+
+	return nil, fmt.Errorf("%w: none of the RPCs of chain %s are reachable", utils.ErrChainUnavailable, c.Name)
+}
+
+/*
+Runs `read` with the client of the chain and the options of a read (see `CallOpts`). If it fails with `utils.ErrChainUnavailable`,
+the client is dropped and `read` is retried on the next RPC, until every RPC was tried once.
+*/
+func (c *Chain) Read(ctx context.Context, read func(backend ChainBackend, opts *bind.CallOpts) error) error {
+	attempts := len(c.RPCs)
+	if attempts == 0 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var backend ChainBackend
+		backend, err = c.Backend(ctx)
+		if err != nil {
+			return err
+		}
+
+		var opts *bind.CallOpts
+		opts, err = c.CallOpts(ctx, backend)
+		if err == nil {
+			err = read(backend, opts)
+		}
+		if err == nil || !errors.Is(err, utils.ErrChainUnavailable) || ctx.Err() != nil {
+			return err
+		}
+
+		log.Printf("read on chain %s failed, trying the next RPC: %v", c.Name, err)
+		c.dropBackend(backend)
+	}
+
+	return err
+}
+
+/*
+Drops `backend` if it's still the client of the chain, so that the next RPC is connected to instead.
+*/
+func (c *Chain) dropBackend(backend ChainBackend) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.backend != backend {
+		return
+	}
+	closeBackend(c.backend)
+	c.backend = nil
+	if len(c.RPCs) > 0 {
+		c.next = (c.current + 1) % len(c.RPCs)
+	}
+}
+
+func closeBackend(backend ChainBackend) {
+	if client, ok := backend.(*ethclient.Client); ok {
+		client.Close()
+	}
+}
+
+/*
+Returns the options of a read on the chain: `Confirmations` blocks behind the head, or at the latest block if `Confirmations` is 0.
+*/
+func (c *Chain) CallOpts(ctx context.Context, backend ChainBackend) (*bind.CallOpts, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	if c.Confirmations == 0 {
+		return opts, nil
+	}
+
+	head, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
+	}
+	if head.Number.Uint64() > c.Confirmations {
+		opts.BlockNumber = new(big.Int).Sub(head.Number, new(big.Int).SetUint64(c.Confirmations))
+	} else {
+		opts.BlockNumber = big.NewInt(0)
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,402 @@
+package utils_nft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// the config file the collections are loaded from if `COLLECTIONS_CONFIG` isn't set.
+const DefaultCollectionsConfig = "configs/collections.json"
+
+/*
+What a collection's tokens are staked as. The staking pools store the staked token IDs by role (keys, keychains and the superior keychain),
+so collections with the same role share their token IDs: they're the same tokens on different chains (e.g. Keychains bridged to an L2).
+*/
+type CollectionRole string
+
+const (
+	RoleKey        CollectionRole = "key"        // the keys staked in a subpool (Key Of Salvation)
+	RoleBooster    CollectionRole = "booster"    // boosts the luck of a subpool's keys (Keychain)
+	RoleMultiplier CollectionRole = "multiplier" // multiplies the points of a subpool (Superior Keychain)
+)
+
+// the roles every collections config must have a collection for.
+var collectionRoles = []CollectionRole{RoleKey, RoleBooster, RoleMultiplier}
+
+func (r CollectionRole) valid() bool {
+	for _, role := range collectionRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrUnknownCollection = utils.NewDomainError(utils.KindNotFound, "UNKNOWN_COLLECTION", "unknown NFT collection")
+	ErrTokenIDOutOfRange = utils.NewDomainError(utils.KindUpstream, "TOKEN_ID_OUT_OF_RANGE", "a token ID read from the chain is out of range")
+)
+
+/*
+A stakeable NFT collection. Everything that reads ownership or metadata of staked tokens goes through the collections of their role.
+*/
+type Collection struct {
+	ID                 string         `json:"id"`
+	Name               string         `json:"name"`
+	ChainName          string         `json:"chain"`              // the `Name` of the chain the contract is deployed on
+	ContractAddressEnv string         `json:"contractAddressEnv"` // the env variable with the contract address
+	ABIPath            string         `json:"abiPath"`
+	Size               int            `json:"size"` // the max supply (token IDs range from 1 to `Size`), or 0 if it isn't fixed
+	Role               CollectionRole `json:"role"`
+	MetadataCollection string         `json:"metadataCollection"`    // the database collection the metadata is stored in
+	TokenURIEnv        string         `json:"tokenUriEnv,omitempty"` // the env variable with the base URI of the metadata
+
+	Chain    *Chain          `json:"-"`
+	Metadata *MetadataSource `json:"-"`
+
+	mu        sync.Mutex
+	parsedABI *abi.ABI            // parsed from `ABIPath` the first time the contract is bound
+	bound     *bind.BoundContract // the contract bound to `boundTo`, rebound if the chain's backend changes
+	boundTo   ChainBackend
+}
+
+/*
+The token IDs owned by a wallet in a collection.
+*/
+type CollectionTokens struct {
+	Collection *Collection
+	TokenIDs   []int
+}
+
+type collectionsConfig struct {
+	Chains      []*Chain      `json:"chains"`
+	Collections []*Collection `json:"collections"`
+}
+
+var (
+	collectionsOnce sync.Once
+	collections     []*Collection
+	collectionsErr  error
+)
+
+/*
+Returns every registered collection, loading them from `COLLECTIONS_CONFIG` (or `DefaultCollectionsConfig`) the first time.
+*/
+func Collections() ([]*Collection, error) {
+	collectionsOnce.Do(func() {
+		path := os.Getenv("COLLECTIONS_CONFIG")
+		if path == "" {
+			path = DefaultCollectionsConfig
+		}
+		collections, collectionsErr = LoadCollections(path)
+	})
+
+	return collections, collectionsErr
+}
+
+/*
+Returns the registered collection with ID `id`.
+*/
+func GetCollection(id string) (*Collection, error) {
+	collections, err := Collections()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, collection := range collections {
+		if collection.ID == id {
+			return collection, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownCollection, id)
+}
+
+/*
+Returns the primary collection staked as `role` (the first one in the config), whose metadata is used for the tokens of the role on every chain.
+*/
+func CollectionByRole(role CollectionRole) (*Collection, error) {
+	collections, err := CollectionsByRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	return collections[0], nil
+}
+
+/*
+Returns every registered collection staked as `role` (one per chain), the primary one first.
+*/
+func CollectionsByRole(role CollectionRole) ([]*Collection, error) {
+	collections, err := Collections()
+	if err != nil {
+		return nil, err
+	}
+
+	var byRole []*Collection
+	for _, collection := range collections {
+		if collection.Role == role {
+			byRole = append(byRole, collection)
+		}
+	}
+	if len(byRole) == 0 {
+		return nil, fmt.Errorf("%w: no collection is staked as %s", ErrUnknownCollection, role)
+	}
+
+	return byRole, nil
+}
+
+/*
+Loads and validates the chains and collections in the config file at `path`, creating the metadata source of each collection.
+*/
+func LoadCollections(path string) ([]*Collection, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read collections config: %v", err)
+	}
+
+	var config collectionsConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid collections config %s: %v", path, err)
+	}
+
+	chains := map[string]*Chain{}
+	for _, chain := range config.Chains {
+		if chain.Name == "" || chain.ChainID < 1 || len(chain.RPCs) == 0 {
+			return nil, fmt.Errorf("invalid collections config %s: every chain needs a name, a chainId and at least one RPC", path)
+		}
+		if chains[chain.Name] != nil {
+			return nil, fmt.Errorf("invalid collections config %s: chain %q is defined twice", path, chain.Name)
+		}
+		chains[chain.Name] = chain
+	}
+
+	ids := map[string]bool{}
+	// the collection of each role on each chain.
+	roles := map[CollectionRole]map[string]string{}
+	for _, collection := range config.Collections {
+		if collection.ID == "" || collection.Name == "" || collection.ContractAddressEnv == "" || collection.ABIPath == "" || collection.MetadataCollection == "" {
+			return nil, fmt.Errorf("invalid collections config %s: collection %q is missing a required field", path, collection.ID)
+		}
+		if ids[collection.ID] {
+			return nil, fmt.Errorf("invalid collections config %s: collection %q is defined twice", path, collection.ID)
+		}
+		ids[collection.ID] = true
+
+		collection.Chain = chains[collection.ChainName]
+		if collection.Chain == nil {
+			return nil, fmt.Errorf("invalid collections config %s: collection %q is on unknown chain %q", path, collection.ID, collection.ChainName)
+		}
+
+		if !collection.Role.valid() {
+			return nil, fmt.Errorf("invalid collections config %s: collection %q has unknown role %q (must be one of %v)", path, collection.ID, collection.Role, collectionRoles)
+		}
+		if roles[collection.Role] == nil {
+			roles[collection.Role] = map[string]string{}
+		}
+		if other, ok := roles[collection.Role][collection.ChainName]; ok {
+			return nil, fmt.Errorf("invalid collections config %s: collections %q and %q are both staked as %s on chain %s", path, other, collection.ID, collection.Role, collection.ChainName)
+		}
+		roles[collection.Role][collection.ChainName] = collection.ID
+
+		if collection.Size < 0 || (collection.Role == RoleKey && collection.Size == 0) {
+			return nil, fmt.Errorf("invalid collections config %s: collection %q has an invalid size", path, collection.ID)
+		}
+
+		collection.Metadata = NewMetadataSource(collection.Name, collection.MetadataCollection, collection.TokenURI)
+	}
+
+	for _, role := range collectionRoles {
+		if len(roles[role]) == 0 {
+			return nil, fmt.Errorf("invalid collections config %s: no collection is staked as %s", path, role)
+		}
+	}
+
+	return config.Collections, nil
+}
+
+/*
+Returns the token IDs owned by `address` in each of `collections` (in the same order), reading every collection (and so every chain) at the same time.
+
+	`address` the EVM address of the owner
+*/
+func GetOwnedTokens(address string, collections []*Collection) ([]*CollectionTokens, error) {
+	owned := make([]*CollectionTokens, len(collections))
+	errs := make([]error, len(collections))
+
+	var wg sync.WaitGroup
+	for i, collection := range collections {
+		wg.Add(1)
+		go func(i int, collection *Collection) {
+			defer wg.Done()
+
+			ownerIds, err := collection.OwnerIDs(address)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s on %s: %w", collection.Name, collection.ChainName, err)
+				return
+			}
+
+			tokenIds, err := TokenIDsToInts(ownerIds)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s on %s: %w", collection.Name, collection.ChainName, err)
+				return
+			}
+			owned[i] = &CollectionTokens{Collection: collection, TokenIDs: tokenIds}
+		}(i, collection)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return owned, nil
+}
+
+/*
+Returns the token IDs owned by `address` in the collections staked as `role`, aggregated across their chains (sorted).
+*/
+func OwnerIDsByRole(address string, role CollectionRole) ([]*big.Int, error) {
+	collections, err := CollectionsByRole(role)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := GetOwnedTokens(address, collections)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenIds []int
+	for _, tokens := range owned {
+		tokenIds = append(tokenIds, tokens.TokenIDs...)
+	}
+	sort.Ints(tokenIds)
+
+	ownerIds := make([]*big.Int, len(tokenIds))
+	for i, tokenId := range tokenIds {
+		ownerIds[i] = big.NewInt(int64(tokenId))
+	}
+
+	return ownerIds, nil
+}
+
+/*
+Converts the token IDs read from a contract into ints, failing with `ErrTokenIDOutOfRange` if one of them doesn't fit.
+*/
+func TokenIDsToInts(ids []*big.Int) ([]int, error) {
+	tokenIds := make([]int, len(ids))
+	for i, id := range ids {
+		// an ID that fits an int64 may still not fit an int (on 32-bit platforms).
+		if !id.IsInt64() || id.Sign() < 0 || int64(int(id.Int64())) != id.Int64() {
+			return nil, fmt.Errorf("%w: %s", ErrTokenIDOutOfRange, id)
+		}
+		tokenIds[i] = int(id.Int64())
+	}
+
+	return tokenIds, nil
+}
+
+/*
+Runs `read` with the collection's contract, bound to the client of its chain, and the options of a read (see `Chain.Read`).
+The ABI is only read once, and the bound contract is kept for as long as the chain's client doesn't change.
+*/
+func (c *Collection) read(ctx context.Context, read func(contract *bind.BoundContract, opts *bind.CallOpts) error) error {
+	return c.Chain.Read(ctx, func(backend ChainBackend, opts *bind.CallOpts) error {
+		contract, err := c.bind(backend)
+		if err != nil {
+			return fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
+		}
+
+		return read(contract, opts)
+	})
+}
+
+func (c *Collection) bind(backend ChainBackend) (*bind.BoundContract, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.bound != nil && c.boundTo == backend {
+		return c.bound, nil
+	}
+
+	if c.parsedABI == nil {
+		parsedABI, err := utils.LoadABI(c.ABIPath)
+		if err != nil {
+			return nil, err
+		}
+		c.parsedABI = &parsedABI
+	}
+
+	address := common.HexToAddress(os.Getenv(c.ContractAddressEnv))
+	c.bound, c.boundTo = bind.NewBoundContract(address, *c.parsedABI, backend, backend, backend), backend
+
+	return c.bound, nil
+}
+
+/*
+Returns the token IDs owned by `address` in the collection.
+
+	`address` the EVM address of the owner
+*/
+func (c *Collection) OwnerIDs(address string) ([]*big.Int, error) {
+	var ownerIds *models.OwnershipData
+	err := c.read(context.Background(), func(contract *bind.BoundContract, opts *bind.CallOpts) (err error) {
+		ownerIds, err = GetOwnerIDs(contract, opts, address)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ownerIds.TokenIDs, nil
+}
+
+/*
+Calls `GetExplicitOwnerships` for every token of the collection. Only works for collections with a fixed `Size`.
+*/
+func (c *Collection) ExplicitOwnerships() ([]models.ExplicitOwnership, error) {
+	if c.Size == 0 {
+		return nil, fmt.Errorf("the size of collection %q isn't fixed", c.ID)
+	}
+
+	var ownerships []models.ExplicitOwnership
+	err := c.read(context.Background(), func(contract *bind.BoundContract, opts *bind.CallOpts) (err error) {
+		ownerships, err = GetExplicitOwnerships(contract, opts, c.Size)
+		return err
+	})
+
+	return ownerships, err
+}
+
+/*
+Returns the URI of a token's metadata: `<base URI><tokenId>.json` if the env variable `TokenURIEnv` (the base URI of the collection) is set,
+otherwise the contract's `tokenURI`. The base URI can be an `ipfs://` URI or a gateway URL.
+*/
+func (c *Collection) TokenURI(tokenId int) (string, error) {
+	if c.TokenURIEnv != "" {
+		if baseURI := os.Getenv(c.TokenURIEnv); baseURI != "" {
+			return baseURI + fmt.Sprint(tokenId) + ".json", nil
+		}
+	}
+
+	var tokenURI string
+	err := c.read(context.Background(), func(contract *bind.BoundContract, opts *bind.CallOpts) (err error) {
+		tokenURI, err = GetTokenURI(contract, opts, tokenId)
+		return err
+	})
+
+	return tokenURI, err
+}
@@ -0,0 +1,273 @@
+package utils_nft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"nbc-backend-api-v2/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTokenIDsToInts(t *testing.T) {
+	tooLarge, _ := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+
+	tests := []struct {
+		name string
+		ids  []*big.Int
+		want []int
+		err  error
+	}{
+		{"empty", []*big.Int{}, []int{}, nil},
+		{"in range", []*big.Int{big.NewInt(1), big.NewInt(25), big.NewInt(5000)}, []int{1, 25, 5000}, nil},
+		{"larger than an int64", []*big.Int{big.NewInt(1), tooLarge}, nil, ErrTokenIDOutOfRange},
+		{"negative", []*big.Int{big.NewInt(-1)}, nil, ErrTokenIDOutOfRange},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := TokenIDsToInts(test.ids)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("TokenIDsToInts() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+/*
+Returns the runtime code of a contract whose `tokensOfOwner` returns `tokenIds` for every owner (at most 5 IDs below 65536).
+*/
+func tokensOfOwnerCode(tokenIds ...uint16) []byte {
+	// the ABI encoding of a uint256[]: the offset of the array, its length and its elements.
+	code := []byte{0x60, 0x20, 0x60, 0x00, 0x52, 0x60, byte(len(tokenIds)), 0x60, 0x20, 0x52}
+	for i, tokenId := range tokenIds {
+		code = append(code, 0x61, byte(tokenId>>8), byte(tokenId), 0x60, byte(0x40+32*i), 0x52)
+	}
+	return append(code, 0x60, byte(0x40+32*len(tokenIds)), 0x60, 0x00, 0xf3)
+}
+
+/*
+Returns a simulated chain with a contract deployed on it, and the contract's address. `code` returns the contract's runtime code given its address.
+*/
+func simulatedChain(t *testing.T, code func(contract common.Address) []byte) (*backends.SimulatedBackend, common.Address) {
+	deployerKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployer := crypto.PubkeyToAddress(deployerKey.PublicKey)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{deployer: {Balance: big.NewInt(1e18)}}, 10_000_000)
+	t.Cleanup(func() { backend.Close() })
+
+	contract := crypto.CreateAddress(deployer, 0)
+	runtimeCode := code(contract)
+
+	// copies the runtime code into memory and returns it.
+	initCode := append([]byte{0x60, byte(len(runtimeCode)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(runtimeCode)), 0x60, 0x00, 0xf3}, runtimeCode...)
+	tx, err := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 1_000_000, big.NewInt(1e9), initCode), types.HomesteadSigner{}, deployerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	return backend, contract
+}
+
+/*
+A chain whose RPC can't be reached.
+*/
+type unreachableBackend struct {
+	*backends.SimulatedBackend
+}
+
+func (unreachableBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+/*
+The RPC of a simulated chain, on chain ID `chainId`.
+*/
+type testRPC struct {
+	ChainBackend
+	chainId int64
+}
+
+func (r testRPC) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(r.chainId), nil
+}
+
+/*
+Makes the chains connect to `rpcs` (by URL) instead of dialing their RPCs, starting again from their first RPC.
+Any other URL can't be reached. Returns how many times an URL was connected to.
+*/
+func useTestRPCs(t *testing.T, rpcs map[string]rpcClient) (dials func(url string) int) {
+	var mu sync.Mutex
+	dialed := map[string]int{}
+
+	previous := dialRPC
+	dialRPC = func(ctx context.Context, url string) (rpcClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		dialed[url]++
+		if rpc, ok := rpcs[url]; ok {
+			return rpc, nil
+		}
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	t.Cleanup(func() { dialRPC = previous })
+
+	collections, err := Collections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, collection := range collections {
+		collection.Chain.mu.Lock()
+		collection.Chain.backend, collection.Chain.next = nil, 0
+		collection.Chain.mu.Unlock()
+	}
+
+	return func(url string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return dialed[url]
+	}
+}
+
+// two chains with a key collection each. the ABIs are in `%[1]s`.
+const testCollectionsConfig = `{
+	"chains": [
+		{"name": "mainnet", "chainId": 1337, "rpcs": [{"url": "http://mainnet-1"}, {"url": "http://mainnet-2"}]},
+		{"name": "l2", "chainId": 1338, "rpcs": [{"url": "http://l2"}]}
+	],
+	"collections": [
+		{"id": "kos", "name": "Key Of Salvation", "chain": "mainnet", "contractAddressEnv": "TEST_KOS_ADDRESS", "abiPath": "%[1]s/KeyOfSalvation.json", "size": 5000, "role": "key", "metadataCollection": "RHKOSMetadata"},
+		{"id": "kos-l2", "name": "Key Of Salvation", "chain": "l2", "contractAddressEnv": "TEST_KOS_L2_ADDRESS", "abiPath": "%[1]s/KeyOfSalvation.json", "size": 5000, "role": "key", "metadataCollection": "RHKOSMetadata"},
+		{"id": "keychain", "name": "Keychain", "chain": "mainnet", "contractAddressEnv": "TEST_KEYCHAIN_ADDRESS", "abiPath": "%[1]s/Keychain.json", "role": "booster", "metadataCollection": "RHKeychainMetadata"},
+		{"id": "superior-keychain", "name": "Superior Keychain", "chain": "mainnet", "contractAddressEnv": "TEST_SUPERIOR_KEYCHAIN_ADDRESS", "abiPath": "%[1]s/SuperiorKeychain.json", "role": "multiplier", "metadataCollection": "RHSuperiorKeychainMetadata"}
+	]
+}`
+
+var loadTestCollectionsOnce sync.Once
+
+/*
+Loads `testCollectionsConfig` as the registered collections (which are only loaded once) and returns its two key collections.
+*/
+func loadTestCollections(t *testing.T) []*Collection {
+	loadTestCollectionsOnce.Do(func() {
+		abiDir, err := filepath.Abs("../../abi")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "collections.json")
+		if err := os.WriteFile(path, []byte(fmt.Sprintf(testCollectionsConfig, abiDir)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		os.Setenv("COLLECTIONS_CONFIG", path)
+	})
+
+	keys, err := CollectionsByRole(RoleKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d key collections, want 2", len(keys))
+	}
+
+	return keys
+}
+
+func TestOwnerIDsByRoleAcrossChains(t *testing.T) {
+	loadTestCollections(t)
+
+	mainnet, mainnetAddress := simulatedChain(t, func(common.Address) []byte { return tokensOfOwnerCode(25, 7, 1402) })
+	l2, l2Address := simulatedChain(t, func(common.Address) []byte { return tokensOfOwnerCode(3310, 12) })
+	t.Setenv("TEST_KOS_ADDRESS", mainnetAddress.Hex())
+	t.Setenv("TEST_KOS_L2_ADDRESS", l2Address.Hex())
+	useTestRPCs(t, map[string]rpcClient{"http://mainnet-1": testRPC{mainnet, 1337}, "http://l2": testRPC{l2, 1338}})
+
+	owner := "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"
+
+	t.Run("merged and sorted", func(t *testing.T) {
+		ids, err := OwnerIDsByRole(owner, RoleKey)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := TokenIDsToInts(ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int{7, 12, 25, 1402, 3310}; !reflect.DeepEqual(got, want) {
+			t.Errorf("OwnerIDsByRole() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("one chain unavailable", func(t *testing.T) {
+		useTestRPCs(t, map[string]rpcClient{"http://mainnet-1": testRPC{mainnet, 1337}, "http://l2": testRPC{unreachableBackend{l2}, 1338}})
+
+		if _, err := OwnerIDsByRole(owner, RoleKey); !errors.Is(err, utils.ErrChainUnavailable) {
+			t.Errorf("error = %v, want ErrChainUnavailable", err)
+		}
+	})
+}
+
+func TestChainFailover(t *testing.T) {
+	keys := loadTestCollections(t)
+
+	mainnet, mainnetAddress := simulatedChain(t, func(common.Address) []byte { return tokensOfOwnerCode(25, 7) })
+	t.Setenv("TEST_KOS_ADDRESS", mainnetAddress.Hex())
+	owner := "0x8d1a1b2c3d4e5f60718293a4b5c6d7e8f9012345"
+
+	reachable := testRPC{mainnet, 1337}
+	unreachable := testRPC{unreachableBackend{mainnet}, 1337}
+
+	tests := []struct {
+		name  string
+		rpcs  map[string]rpcClient
+		err   error
+		dials map[string]int // how many times each RPC is connected to by two reads
+	}{
+		{"first RPC", map[string]rpcClient{"http://mainnet-1": reachable, "http://mainnet-2": reachable}, nil, map[string]int{"http://mainnet-1": 1, "http://mainnet-2": 0}},
+		{"first RPC not reachable", map[string]rpcClient{"http://mainnet-2": reachable}, nil, map[string]int{"http://mainnet-1": 1, "http://mainnet-2": 1}},
+		{"first RPC on another chain", map[string]rpcClient{"http://mainnet-1": testRPC{mainnet, 1}, "http://mainnet-2": reachable}, nil, map[string]int{"http://mainnet-1": 1, "http://mainnet-2": 1}},
+		// the read fails on the client of the first RPC, which is dropped for the second one.
+		{"read fails on the first RPC", map[string]rpcClient{"http://mainnet-1": unreachable, "http://mainnet-2": reachable}, nil, map[string]int{"http://mainnet-1": 1, "http://mainnet-2": 1}},
+		{"read fails on every RPC", map[string]rpcClient{"http://mainnet-1": unreachable, "http://mainnet-2": unreachable}, utils.ErrChainUnavailable, map[string]int{"http://mainnet-1": 2, "http://mainnet-2": 2}},
+		{"no RPC reachable", map[string]rpcClient{}, utils.ErrChainUnavailable, map[string]int{"http://mainnet-1": 2, "http://mainnet-2": 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dials := useTestRPCs(t, test.rpcs)
+
+			for i := 0; i < 2; i++ {
+				ids, err := keys[0].OwnerIDs(owner)
+				if !errors.Is(err, test.err) {
+					t.Fatalf("read %d: error = %v, want %v", i+1, err, test.err)
+				}
+				if err == nil && len(ids) != 2 {
+					t.Errorf("read %d: got %d token IDs, want 2", i+1, len(ids))
+				}
+			}
+			for url, want := range test.dials {
+				if got := dials(url); got != want {
+					t.Errorf("connected to %s %d times, want %d", url, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/models"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Stakes every subpool in `specs` in the staking pool with ID `stakingPoolId` at once. Either all subpools are added or none are.
+
+Also stakes single subpools (see `AddSubpool`). The checks run for the whole batch: the session token and ownership are only checked once (for all keys),
+no key, keychain or superior keychain may be used by more than one spec, and the combo limits count the subpools in the batch
+on top of the staker's existing ones. Errors about a single spec say which one (starting at 1).
+*/
+func AddSubpools(collection *mongo.Collection, sessionToken string, stakingPoolId int, stakerWallet string, specs []*models.SubpoolSpec) (*models.BatchStakeResult, error) {
+	if collection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+
+	// check for session token authorization (the staker itself or one of its delegates for staking).
+	if err := AuthorizeStaker(sessionToken, stakerWallet, models.DelegationScopeStaking); err != nil {
+		return nil, err
+	}
+
+	// check if time is within stake time allowance.
+	timeExceeded, err := CheckPoolTimeAllowanceExceeded(collection, stakingPoolId)
+	if err != nil {
+		return nil, err
+	}
+	if timeExceeded {
+		return nil, ErrEntryWindowClosed
+	}
+
+	// check if the staker is banned.
+	banned, err := CheckIfStakerBanned(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, ErrStakerBanned
+	}
+
+	// check that no NFT is used twice in the batch.
+	var allKeyIds, allKeychainIds, allSuperiorKeychainIds []int
+	usedKeys, usedKeychains, usedSuperiorKeychains := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	for i, spec := range specs {
+		for _, keyId := range spec.KeyIDs {
+			if usedKeys[keyId] {
+				return nil, fmt.Errorf("%w: subpool %d: key %d", ErrDuplicateInBatch, i+1, keyId)
+			}
+			usedKeys[keyId] = true
+			allKeyIds = append(allKeyIds, keyId)
+		}
+		for _, keychainId := range spec.KeychainIDs {
+			if keychainId == -1 {
+				continue
+			}
+			if usedKeychains[keychainId] {
+				return nil, fmt.Errorf("%w: subpool %d: keychain %d", ErrDuplicateInBatch, i+1, keychainId)
+			}
+			usedKeychains[keychainId] = true
+			allKeychainIds = append(allKeychainIds, keychainId)
+		}
+		if spec.SuperiorKeychainID != -1 {
+			if usedSuperiorKeychains[spec.SuperiorKeychainID] {
+				return nil, fmt.Errorf("%w: subpool %d: superior keychain %d", ErrDuplicateInBatch, i+1, spec.SuperiorKeychainID)
+			}
+			usedSuperiorKeychains[spec.SuperiorKeychainID] = true
+			allSuperiorKeychainIds = append(allSuperiorKeychainIds, spec.SuperiorKeychainID)
+		}
+	}
+
+	// fetch the metadata of all keys at once and check the keys and keychains of each spec.
+	metadatas, err := FetchSimplifiedMetadataConcurrent(allKeyIds)
+	if err != nil {
+		return nil, err
+	}
+	metadataByID := make(map[int]*models.KOSSimplifiedMetadata, len(metadatas))
+	for _, metadata := range metadatas {
+		metadataByID[metadata.TokenID] = metadata
+	}
+
+	keysBySpec := make([][]*models.KOSSimplifiedMetadata, len(specs))
+	for i, spec := range specs {
+		for _, keyId := range spec.KeyIDs {
+			keysBySpec[i] = append(keysBySpec[i], metadataByID[keyId])
+		}
+		if err := CheckKeysToStakeEligibility(keysBySpec[i], spec.KeychainIDs, spec.SuperiorKeychainID); err != nil {
+			return nil, fmt.Errorf("subpool %d: %w", i+1, err)
+		}
+	}
+
+	// check the ownership of all keys, keychains and superior keychains with a single call per collection.
+	ownership, err := VerifyStakedOwnership(stakerWallet, map[UtilsNFT.CollectionRole][]int{
+		UtilsNFT.RoleKey:        allKeyIds,
+		UtilsNFT.RoleBooster:    allKeychainIds,
+		UtilsNFT.RoleMultiplier: allSuperiorKeychainIds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ownership {
+		return nil, fmt.Errorf("%w: one or more keys, keychains or superior keychains specified do not belong to the wallet specified", ErrNotOwner)
+	}
+
+	var subpools []*models.StakingSubpool
+	err = events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+		// after all checks, get the staker from `RHStakerData` (creating it if it doesn't exist yet) in the transaction,
+		// so that it's only created if the subpools are added.
+		stakerObjId, err := upsertStaker(ctx, configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
+		if err != nil {
+			return nil, err
+		}
+
+		// the staking pool is read (and written) in the transaction, so the subpool IDs and the checks below
+		// can't be invalidated by a subpool added at the same time.
+		subpools, err = newBatchSubpools(ctx, collection, stakingPoolId, stakerObjId, stakerWallet, specs, keysBySpec)
+		if err != nil {
+			return nil, err
+		}
+
+		filter := bson.M{"stakingPoolID": stakingPoolId}
+		update := bson.M{"$push": bson.M{"activeSubpools": bson.M{"$each": subpools}}}
+		if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+			return nil, err
+		}
+
+		var domainEvents []events.DomainEvent
+		for i, subpool := range subpools {
+			domainEvents = append(domainEvents, events.SubpoolStakedEvent{
+				StakingPoolID:      stakingPoolId,
+				SubpoolID:          subpool.SubpoolID,
+				StakerWallet:       subpool.StakerWallet,
+				KeyIDs:             specs[i].KeyIDs,
+				KeychainIDs:        specs[i].KeychainIDs,
+				SuperiorKeychainID: specs[i].SuperiorKeychainID,
+				SubpoolPoints:      subpool.SubpoolPoints,
+			})
+		}
+		return appendTotalPointsChanged(ctx, collection, stakingPoolId, domainEvents)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.BatchStakeResult{StakingPoolID: stakingPoolId, Subpools: []*models.StakedSubpool{}}
+	for i, subpool := range subpools {
+		log.Printf("Added Subpool ID %d to Staking Pool ID %d", subpool.SubpoolID, stakingPoolId)
+		result.Subpools = append(result.Subpools, &models.StakedSubpool{
+			SubpoolID:          subpool.SubpoolID,
+			KeyIDs:             specs[i].KeyIDs,
+			KeychainIDs:        subpool.StakedKeychainIDs,
+			SuperiorKeychainID: subpool.StakedSuperiorKeychainID,
+			SubpoolPoints:      subpool.SubpoolPoints,
+		})
+		result.TotalPoints += subpool.SubpoolPoints
+	}
+	result.TotalPoints = math.Round(result.TotalPoints*100) / 100
+
+	return result, nil
+}
+
+/*
+Reads the staking pool with ID `stakingPoolId` using `ctx` and returns the subpools to add for `specs` (with the keys of each spec in `keysBySpec`),
+checking that none of their NFTs are already staked in the pool and that the staker's combo limits aren't exceeded.
+*/
+func newBatchSubpools(
+	ctx context.Context,
+	collection *mongo.Collection,
+	stakingPoolId int,
+	stakerObjId *primitive.ObjectID,
+	stakerWallet string,
+	specs []*models.SubpoolSpec,
+	keysBySpec [][]*models.KOSSimplifiedMetadata,
+) ([]*models.StakingSubpool, error) {
+	var stakingPool models.StakingPool
+	if err := collection.FindOne(ctx, bson.M{"stakingPoolID": stakingPoolId}).Decode(&stakingPool); err != nil {
+		return nil, poolLookupError(err, stakingPoolId)
+	}
+
+	// the NFTs already staked and the staker's subpools for each key count, from the active subpools only (see `CheckIfKeyStaked`).
+	stakedKeys, stakedKeychains, stakedSuperiorKeychains := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	comboCounts := map[int]int{}
+	nextSubpoolId := 1
+	for _, subpool := range append(stakingPool.ActiveSubpools, stakingPool.ClosedSubpools...) {
+		if subpool.SubpoolID >= nextSubpoolId {
+			nextSubpoolId = subpool.SubpoolID + 1
+		}
+	}
+	for _, subpool := range stakingPool.ActiveSubpools {
+		for _, key := range subpool.StakedKeys {
+			stakedKeys[key.TokenID] = true
+		}
+		for _, keychainId := range subpool.StakedKeychainIDs {
+			stakedKeychains[keychainId] = true
+		}
+		stakedSuperiorKeychains[subpool.StakedSuperiorKeychainID] = true
+		if subpool.Staker != nil && *subpool.Staker == *stakerObjId {
+			comboCounts[len(subpool.StakedKeys)]++
+		}
+	}
+
+	var subpools []*models.StakingSubpool
+	for i, spec := range specs {
+		for _, keyId := range spec.KeyIDs {
+			if stakedKeys[keyId] {
+				return nil, fmt.Errorf("%w: subpool %d: key %d is already staked", ErrAlreadyStaked, i+1, keyId)
+			}
+		}
+		for _, keychainId := range spec.KeychainIDs {
+			if keychainId != -1 && stakedKeychains[keychainId] {
+				return nil, fmt.Errorf("%w: subpool %d: keychain %d has already been staked in another subpool for this staking pool", ErrAlreadyStaked, i+1, keychainId)
+			}
+		}
+		if spec.SuperiorKeychainID != -1 && stakedSuperiorKeychains[spec.SuperiorKeychainID] {
+			return nil, fmt.Errorf("%w: subpool %d: superior keychain %d has already been staked in another subpool for this staking pool", ErrAlreadyStaked, i+1, spec.SuperiorKeychainID)
+		}
+
+		keyCount := len(spec.KeyIDs)
+		if limit, ok := subpoolComboLimits[keyCount]; ok && comboCounts[keyCount] >= limit {
+			return nil, fmt.Errorf("%w: subpool %d: at most %d subpools of %d keys are allowed", ErrComboLimitReached, i+1, limit, keyCount)
+		}
+		comboCounts[keyCount]++
+
+		subpoolPoints := CalculateSubpoolPoints(keysBySpec[i], spec.KeychainIDs, spec.SuperiorKeychainID)
+		subpools = append(subpools, &models.StakingSubpool{
+			SubpoolID:                nextSubpoolId + i,
+			Staker:                   stakerObjId,
+			StakerWallet:             strings.ToLower(stakerWallet),
+			EnterTime:                time.Now(),
+			StakedKeys:               keysBySpec[i],
+			StakedKeychainIDs:        spec.KeychainIDs,
+			StakedSuperiorKeychainID: spec.SuperiorKeychainID,
+			SubpoolPoints:            math.Round(subpoolPoints*100) / 100, // 2 decimal places
+			RewardClaimable:          false,
+		})
+	}
+
+	return subpools, nil
+}
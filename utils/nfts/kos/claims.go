@@ -0,0 +1,153 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Claims the reward of every closed subpool of `wallet` that is claimable, unbanned and unclaimed, across all staking pools.
+The session token is only checked once.
+
+Each subpool is claimed in its own transaction (crediting the reward and setting `RewardClaimed` together), the same way `ClaimReward` does,
+so one failed claim doesn't undo the others. Subpools that were claimed in the meantime fail with `ALREADY_CLAIMED`
+and calling this again only claims what's left.
+*/
+func ClaimAllRewards(collection *mongo.Collection, sessionToken, wallet string) (*models.ClaimAllResult, error) {
+	if collection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+
+	// check for session token authorization (the wallet itself or one of its delegates for claiming).
+	if err := AuthorizeStaker(sessionToken, wallet, models.DelegationScopeClaiming); err != nil {
+		return nil, err
+	}
+
+	result := &models.ClaimAllResult{Claimed: []*models.Reward{}, Results: []*models.ClaimResult{}}
+
+	stakersCollection := configs.GetCollections(configs.DB, "RHStakerData")
+	staker, err := GetStakerFromWallet(stakersCollection, wallet)
+	if errors.Is(err, ErrStakerNotFound) {
+		return result, nil // stakers that don't exist yet have nothing to claim
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claimable := bson.M{"staker": staker.ID, "rewardClaimable": true, "rewardClaimed": bson.M{"$ne": true}, "banned": bson.M{"$ne": true}}
+	filter := bson.M{"closedSubpools": bson.M{"$elemMatch": claimable}}
+	opts := options.Find().SetSort(bson.M{"stakingPoolID": 1})
+
+	cursor, err := collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	var stakingPools []*models.StakingPool
+	if err := cursor.All(context.Background(), &stakingPools); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	claimed := map[string]*models.Reward{}
+	for _, stakingPool := range stakingPools {
+		for _, subpool := range stakingPool.ClosedSubpools {
+			if subpool.Staker == nil || *subpool.Staker != staker.ID || !subpool.RewardClaimable || subpool.RewardClaimed || subpool.Banned {
+				continue
+			}
+
+			claimResult := claimSubpool(collection, stakersCollection, stakingPool, subpool, staker.Wallet)
+			result.Results = append(result.Results, claimResult)
+			if !claimResult.Claimed {
+				continue
+			}
+
+			reward, ok := claimed[claimResult.RewardName]
+			if !ok {
+				reward = &models.Reward{Name: claimResult.RewardName}
+				claimed[claimResult.RewardName] = reward
+				result.Claimed = append(result.Claimed, reward)
+			}
+			reward.Amount = math.Round((reward.Amount+claimResult.Amount)*100) / 100
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Claims the reward of `subpool` in `stakingPool` for `wallet` in a single transaction, assuming the subpool was claimable when `stakingPool` was read.
+*/
+func claimSubpool(collection, stakersCollection *mongo.Collection, stakingPool *models.StakingPool, subpool *models.StakingSubpool, wallet string) *models.ClaimResult {
+	claimResult := &models.ClaimResult{
+		StakingPoolID: stakingPool.StakingPoolID,
+		SubpoolID:     subpool.SubpoolID,
+		RewardName:    stakingPool.Reward.Name,
+	}
+
+	err := func() error {
+		// NOT IMPLEMENTED YET for non-token rewards (see `ClaimReward`).
+		if !strings.Contains(stakingPool.Reward.Name, "Token") {
+			return fmt.Errorf("%w: non-token rewards are not implemented yet", ErrNonTokenReward)
+		}
+
+		// the same share as `CalcSubpoolTokenShare`, from the staking pool that was already read.
+		totalSubpoolPoints := poolSharePoints(stakingPool)
+		// without any points, the share would be NaN (or infinite), so there's nothing to give.
+		if totalSubpoolPoints <= 0 {
+			return fmt.Errorf("%w: staking pool %d", ErrNoSubpoolPoints, stakingPool.StakingPoolID)
+		}
+		tokensToGive := tokenShare(subpool.SubpoolPoints, totalSubpoolPoints, stakingPool.Reward.Amount)
+
+		err := events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+			// marking the reward as claimed first fails with `ErrAlreadyClaimed` (and aborts) if it was claimed in the meantime.
+			if err := updateRewardClaimedToTrue(ctx, collection, stakingPool.StakingPoolID, subpool.SubpoolID); err != nil {
+				return nil, err
+			}
+			if err := addTokensToStaker(ctx, stakersCollection, stakingPool.Reward.Name, wallet, tokensToGive); err != nil {
+				return nil, err
+			}
+
+			return []events.DomainEvent{events.RewardClaimedEvent{
+				StakingPoolID: stakingPool.StakingPoolID,
+				SubpoolID:     subpool.SubpoolID,
+				StakerWallet:  strings.ToLower(wallet),
+				RewardName:    stakingPool.Reward.Name,
+				Amount:        tokensToGive,
+			}}, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		claimResult.Amount = tokensToGive
+		return nil
+	}()
+
+	if err != nil {
+		log.Printf("unable to claim the reward of subpool %d in staking pool %d for %s: %v", subpool.SubpoolID, stakingPool.StakingPoolID, wallet, err)
+
+		claimResult.Error = err.Error()
+		if domainErr, ok := utils.AsDomainError(err); ok {
+			claimResult.ErrorCode = domainErr.Code
+		} else {
+			claimResult.ErrorCode = "INTERNAL_ERROR"
+		}
+		return claimResult
+	}
+
+	claimResult.Claimed = true
+	return claimResult
+}
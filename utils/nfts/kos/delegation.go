@@ -0,0 +1,287 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the EIP-712 domain of delegations. the chain ID is the one of the key collection's chain.
+const (
+	DelegationDomainName    = "NBC Staking"
+	DelegationDomainVersion = "1"
+)
+
+/*
+The EIP-712 types of a delegation. `expiry` is in unix seconds.
+*/
+var delegationTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+	},
+	"Delegation": {
+		{Name: "vault", Type: "address"},
+		{Name: "delegate", Type: "address"},
+		{Name: "staking", Type: "bool"},
+		{Name: "claiming", Type: "bool"},
+		{Name: "expiry", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+	},
+}
+
+/*
+Returns `delegation` as the EIP-712 typed data the vault signs.
+*/
+func DelegationTypedData(delegation *models.Delegation) (apitypes.TypedData, error) {
+	keys, err := KeyCollection()
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
+
+	return apitypes.TypedData{
+		Types:       delegationTypes,
+		PrimaryType: "Delegation",
+		Domain: apitypes.TypedDataDomain{
+			Name:    DelegationDomainName,
+			Version: DelegationDomainVersion,
+			ChainId: math.NewHexOrDecimal256(keys.Chain.ChainID),
+		},
+		Message: apitypes.TypedDataMessage{
+			"vault":    delegation.Vault,
+			"delegate": delegation.Delegate,
+			"staking":  delegation.Staking,
+			"claiming": delegation.Claiming,
+			"expiry":   fmt.Sprint(delegation.Expiry.Unix()),
+			"nonce":    fmt.Sprint(delegation.Nonce),
+		},
+	}, nil
+}
+
+/*
+Adds `delegation` (signed by its vault) to `RHDelegations`, replacing the vault's current delegation to the same delegate.
+The nonce must be higher than the current delegation's, so that an older (e.g. revoked) delegation can't be added again.
+*/
+func AddDelegation(collection *mongo.Collection, delegation *models.Delegation) (*models.Delegation, error) {
+	if collection.Name() != "RHDelegations" {
+		return nil, errors.New("collection must be RHDelegations")
+	}
+
+	delegation.Vault = strings.ToLower(delegation.Vault)
+	delegation.Delegate = strings.ToLower(delegation.Delegate)
+	if delegation.Vault == delegation.Delegate {
+		return nil, fmt.Errorf("%w: a wallet can't delegate to itself", utils.ErrInvalidRequest)
+	}
+	if (delegation.Staking || delegation.Claiming) && !delegation.Expiry.After(time.Now()) {
+		return nil, fmt.Errorf("%w: the expiry of the delegation must be in the future", utils.ErrInvalidRequest)
+	}
+
+	// the delegation must be signed by the vault.
+	typedData, err := DelegationTypedData(delegation)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := utils.TypedDataHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	if err := UtilsNFT.VerifyWalletSignature(delegation.Vault, hash, delegation.Signature); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"vault": delegation.Vault, "delegate": delegation.Delegate}
+	var current models.Delegation
+	err = collection.FindOne(context.Background(), filter).Decode(&current)
+	exists := err == nil
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	if exists {
+		if delegation.Nonce <= current.Nonce {
+			return nil, fmt.Errorf("%w: the current nonce is %d", ErrDelegationNonceUsed, current.Nonce)
+		}
+		// only replaces the delegation that was checked, in case another one was added in the meantime.
+		filter["nonce"] = current.Nonce
+	}
+
+	delegation.UpdatedAt = time.Now()
+	result, err := collection.ReplaceOne(context.Background(), filter, delegation, options.Replace().SetUpsert(!exists))
+	if mongo.IsDuplicateKeyError(err) {
+		// another delegation to the same delegate was added since it was checked (see `EnsureDelegationIndexes`).
+		return nil, ErrDelegationNonceUsed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	if exists && result.MatchedCount == 0 {
+		return nil, ErrDelegationNonceUsed
+	}
+
+	return delegation, nil
+}
+
+/*
+Creates the unique index on the vault and delegate of `RHDelegations`, so that a vault has at most one delegation per delegate
+even when two are added at the same time. Called when the server starts.
+*/
+func EnsureDelegationIndexes(collection *mongo.Collection) error {
+	if collection.Name() != "RHDelegations" {
+		return errors.New("collection must be RHDelegations")
+	}
+
+	index := mongo.IndexModel{Keys: bson.D{{Key: "vault", Value: 1}, {Key: "delegate", Value: 1}}, Options: options.Index().SetUnique(true)}
+	if _, err := collection.Indexes().CreateOne(context.Background(), index); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return nil
+}
+
+/*
+Gets the active delegations of `wallet`, both as a vault and as a delegate.
+*/
+func GetDelegations(collection *mongo.Collection, wallet string) (*models.StakerDelegations, error) {
+	if collection.Name() != "RHDelegations" {
+		return nil, errors.New("collection must be RHDelegations")
+	}
+
+	wallet = strings.ToLower(wallet)
+	delegates, err := findActiveDelegations(collection, bson.M{"vault": wallet})
+	if err != nil {
+		return nil, err
+	}
+	vaults, err := findActiveDelegations(collection, bson.M{"delegate": wallet})
+	if err != nil {
+		return nil, err
+	}
+
+	delegations := &models.StakerDelegations{Delegates: delegates, Vaults: vaults}
+	return delegations, nil
+}
+
+/*
+Finds the delegations matching `filter` that haven't expired and have at least one scope, the most recently updated first.
+*/
+func findActiveDelegations(collection *mongo.Collection, filter bson.M) ([]*models.Delegation, error) {
+	filter["expiry"] = bson.M{"$gt": time.Now()}
+	filter["$or"] = bson.A{bson.M{"staking": true}, bson.M{"claiming": true}}
+
+	cursor, err := collection.Find(context.Background(), filter, options.Find().SetSort(bson.M{"updatedAt": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	delegations := []*models.Delegation{}
+	if err := cursor.All(context.Background(), &delegations); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return delegations, nil
+}
+
+/*
+Checks if `delegate` may act for `vault` within `scope` (`models.DelegationScopeStaking` or `models.DelegationScopeClaiming`),
+either through an active delegation in `RHDelegations` or, if `DELEGATE_REGISTRY_ADDRESS` is set, through the on-chain delegate registry.
+*/
+func IsDelegate(collection *mongo.Collection, vault, delegate, scope string) (bool, error) {
+	if collection.Name() != "RHDelegations" {
+		return false, errors.New("collection must be RHDelegations")
+	}
+	if scope != models.DelegationScopeStaking && scope != models.DelegationScopeClaiming {
+		return false, fmt.Errorf("unknown delegation scope %q", scope)
+	}
+
+	// the scopes are stored as booleans with the same name.
+	filter := bson.M{
+		"vault":    strings.ToLower(vault),
+		"delegate": strings.ToLower(delegate),
+		scope:      true,
+		"expiry":   bson.M{"$gt": time.Now()},
+	}
+	count, err := collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	return isOnChainDelegate(vault, delegate)
+}
+
+/*
+Checks if `delegate` is delegated all rights of `vault` in the delegate registry at `DELEGATE_REGISTRY_ADDRESS` (delegate.xyz v2),
+on the chain of the key collection. Returns false if `DELEGATE_REGISTRY_ADDRESS` isn't set.
+*/
+func isOnChainDelegate(vault, delegate string) (bool, error) {
+	if os.Getenv("DELEGATE_REGISTRY_ADDRESS") == "" {
+		return false, nil
+	}
+
+	keys, err := KeyCollection()
+	if err != nil {
+		return false, err
+	}
+	var rawResult []interface{}
+	err = keys.Chain.Read(context.Background(), func(backend UtilsNFT.ChainBackend, opts *bind.CallOpts) error {
+		contract, err := utils.BindContract("abi/DelegateRegistry.json", "DELEGATE_REGISTRY_ADDRESS", backend, backend, backend)
+		if err != nil {
+			return fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
+		}
+
+		// empty rights (all zeros) means that every right is delegated.
+		if err := contract.Call(opts, &rawResult, "checkDelegateForAll", common.HexToAddress(delegate), common.HexToAddress(vault), [32]byte{}); err != nil {
+			return fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	valid, ok := rawResult[0].(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: unexpected checkDelegateForAll result %T", utils.ErrChainUnavailable, rawResult[0])
+	}
+
+	return valid, nil
+}
+
+/*
+Checks that `sessionToken` belongs to `staker` or to one of its delegates for `scope`. Staking and claiming functions act for `staker`
+(the vault) either way, so the subpools, ownership checks and bans are always against the vault.
+*/
+func AuthorizeStaker(sessionToken, staker, scope string) error {
+	sessionWallet, err := UtilsNFT.WalletFromSessionToken(sessionToken)
+	if err != nil {
+		return err
+	}
+	if sessionWallet == strings.ToLower(staker) {
+		return nil
+	}
+
+	delegate, err := IsDelegate(configs.GetCollections(configs.DB, "RHDelegations"), staker, sessionWallet, scope)
+	if err != nil {
+		return err
+	}
+	if !delegate {
+		return fmt.Errorf("%w: %s is neither the wallet given nor one of its delegates for %s", utils.ErrSessionMismatch, sessionWallet, scope)
+	}
+
+	return nil
+}
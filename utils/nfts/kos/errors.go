@@ -0,0 +1,51 @@
+package utils_kos
+
+import (
+	"errors"
+	"fmt"
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Domain errors returned by the KOS staking functions.
+Each error has a stable code (e.g. `POOL_NOT_FOUND`) which is returned to the webapp in `responses.Response`.
+Callers can check for a specific error with `errors.Is(err, ErrPoolNotFound)`.
+*/
+var (
+	ErrPoolNotFound         = utils.NewDomainError(utils.KindNotFound, "POOL_NOT_FOUND", "staking pool not found")
+	ErrSubpoolNotFound      = utils.NewDomainError(utils.KindNotFound, "SUBPOOL_NOT_FOUND", "subpool not found")
+	ErrStakerNotFound       = utils.NewDomainError(utils.KindNotFound, "STAKER_NOT_FOUND", "staker not found")
+	ErrStakerBanned         = utils.NewDomainError(utils.KindForbidden, "STAKER_BANNED", "staker is temporarily banned from staking")
+	ErrNotOwner             = utils.NewDomainError(utils.KindForbidden, "NOT_OWNER", "one or more NFTs specified do not belong to the wallet specified")
+	ErrNotSubpoolOwner      = utils.NewDomainError(utils.KindForbidden, "NOT_SUBPOOL_OWNER", "wallet specified is not the owner of the subpool")
+	ErrSubpoolBanned        = utils.NewDomainError(utils.KindForbidden, "SUBPOOL_BANNED", "subpool is banned from claiming rewards")
+	ErrEntryWindowClosed    = utils.NewDomainError(utils.KindConflict, "ENTRY_WINDOW_CLOSED", "time allowance for this staking pool has passed. please wait for the next staking pool to open")
+	ErrUnstakeWindowClosed  = utils.NewDomainError(utils.KindConflict, "UNSTAKE_WINDOW_CLOSED", "cannot unstake from a subpool after the staking pool has started")
+	ErrAlreadyStaked        = utils.NewDomainError(utils.KindConflict, "ALREADY_STAKED", "1 or more NFTs are already staked in this staking pool")
+	ErrComboLimitReached    = utils.NewDomainError(utils.KindConflict, "COMBO_LIMIT_REACHED", "you have already staked this combination of keys more times than allowed for this staking pool")
+	ErrDuplicateInBatch     = utils.NewDomainError(utils.KindUnprocessable, "DUPLICATE_IN_BATCH", "the same NFT is used in more than one subpool of the batch")
+	ErrAlreadyClaimed       = utils.NewDomainError(utils.KindConflict, "ALREADY_CLAIMED", "reward has already been claimed")
+	ErrRewardNotClaimable   = utils.NewDomainError(utils.KindConflict, "REWARD_NOT_CLAIMABLE", "rewards for subpool is not claimable")
+	ErrNoSubpoolPoints      = utils.NewDomainError(utils.KindConflict, "NO_SUBPOOL_POINTS", "the subpools of the staking pool have no points to share the reward by")
+	ErrInvalidKeyCount      = utils.NewDomainError(utils.KindUnprocessable, "INVALID_KEY_COUNT", "must stake 1, 2, 3, 5 or 15 keys")
+	ErrInvalidKeychainCombo = utils.NewDomainError(utils.KindUnprocessable, "INVALID_KEYCHAIN_COMBO", "invalid keychain and/or superior keychain combination")
+	ErrNonTokenReward       = utils.NewDomainError(utils.KindUnprocessable, "NON_TOKEN_REWARD", "reward must be a token")
+	ErrMetadataUnavailable  = UtilsNFT.ErrMetadataUnavailable
+	ErrKeyNotIndexed        = utils.NewDomainError(utils.KindNotFound, "KEY_NOT_INDEXED", "key metadata has not been synced or is malformed")
+	ErrDelegationNonceUsed  = utils.NewDomainError(utils.KindConflict, "DELEGATION_NONCE_USED", "the nonce of the delegation must be higher than the nonce of the current delegation")
+)
+
+/*
+Converts an error returned when looking up the staking pool with ID `stakingPoolId` into a domain error.
+`mongo.ErrNoDocuments` becomes `ErrPoolNotFound`; anything else is treated as the database being unavailable.
+*/
+func poolLookupError(err error, stakingPoolId int) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("%w: no staking pool with ID %d exists", ErrPoolNotFound, stakingPoolId)
+	}
+
+	return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+}
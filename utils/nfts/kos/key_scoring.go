@@ -0,0 +1,217 @@
+package utils_kos
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"nbc-backend-api-v2/models"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the key counts of subpools with a key combo bonus, used to estimate a key's combo potential.
+var comboKeyCounts = []int{2, 3, 5, 15}
+
+/*
+Gets the rarity and staking utility of the Key Of Salvation with ID `tokenId`, compared to every key stored in `RHKOSMetadata`.
+
+The scores are computed whenever the stored metadata is loaded (see `TokenIndexCacheTTL`), so they follow synced metadata
+and the current scoring formulas in `staking_calc.go`.
+*/
+func GetKeyScore(collection *mongo.Collection, tokenId int) (*models.KOSKeyScore, error) {
+	if collection.Name() != "RHKOSMetadata" {
+		return nil, errors.New("collection must be RHKOSMetadata")
+	}
+
+	index, err := loadTokenIndex(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	score, ok := index.scores[tokenId]
+	if !ok {
+		return nil, fmt.Errorf("%w: key %d", ErrKeyNotIndexed, tokenId)
+	}
+
+	return score, nil
+}
+
+/*
+Gets page `page` (of `limit` keys each) of the keys stored in `RHKOSMetadata` ranked by `by` (`rarity` or `utility`).
+Keys with the same score are ordered by token ID.
+*/
+func GetKeyRanking(collection *mongo.Collection, by string, page, limit int) (*models.KOSKeyRanking, error) {
+	if collection.Name() != "RHKOSMetadata" {
+		return nil, errors.New("collection must be RHKOSMetadata")
+	}
+
+	index, err := loadTokenIndex(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranked []*models.KOSKeyScore
+	switch by {
+	case "rarity":
+		ranked = index.byRarity
+	case "utility":
+		ranked = index.byUtility
+	default:
+		return nil, errors.New("ranking must be by rarity or utility")
+	}
+
+	ranking := &models.KOSKeyRanking{
+		By:        by,
+		Keys:      []*models.KOSKeyScore{},
+		Total:     len(ranked),
+		Page:      page,
+		Limit:     limit,
+		UpdatedAt: index.loadedAt,
+	}
+	if start := (page - 1) * limit; start < len(ranked) {
+		ranking.Keys = ranked[start:minInt(start+limit, len(ranked))]
+	}
+
+	return ranking, nil
+}
+
+/*
+Scores every key in `tokens` and ranks them by rarity and by staking utility (both highest first).
+
+The rarity score of a key is the sum of 1 / (the share of keys with the same value) over each of its traits.
+The staking utility is the points of a subpool with only the key (`CalculateSubpoolPoints`, so angels get their multiplier)
+plus the key's combo potential (see `comboPotential`).
+*/
+func scoreKeys(tokens []*models.KOSSimplifiedMetadata) (scores map[int]*models.KOSKeyScore, byRarity, byUtility []*models.KOSKeyScore) {
+	// how many keys have each value of each trait (values are keyed by their string form, like in `traitStats`).
+	valueCounts := map[string]map[string]int{}
+	houseCounts, typeCounts, houseTypeCounts := map[string]int{}, map[string]int{}, map[string]int{}
+	for _, token := range tokens {
+		for traitType, value := range token.Traits {
+			if valueCounts[traitType] == nil {
+				valueCounts[traitType] = map[string]int{}
+			}
+			valueCounts[traitType][fmt.Sprint(value)]++
+		}
+		houseCounts[token.HouseTrait]++
+		typeCounts[token.TypeTrait]++
+		houseTypeCounts[token.HouseTrait+"\x00"+token.TypeTrait]++
+	}
+
+	scores = make(map[int]*models.KOSKeyScore, len(tokens))
+	for _, token := range tokens {
+		rarity := 0.0
+		for traitType, value := range token.Traits {
+			rarity += float64(len(tokens)) / float64(valueCounts[traitType][fmt.Sprint(value)])
+		}
+
+		soloPoints := CalculateSubpoolPoints([]*models.KOSSimplifiedMetadata{token}, []int{-1}, -1)
+		potential := comboPotential(
+			len(tokens),
+			houseCounts[token.HouseTrait],
+			typeCounts[token.TypeTrait],
+			houseTypeCounts[token.HouseTrait+"\x00"+token.TypeTrait],
+		)
+
+		score := &models.KOSKeyScore{
+			TokenID:        token.TokenID,
+			HouseTrait:     token.HouseTrait,
+			TypeTrait:      token.TypeTrait,
+			LuckTrait:      token.LuckTrait,
+			LuckBoostTrait: token.LuckBoostTrait,
+			Angel:          token.LuckTrait == models.AngelLuck,
+			RarityScore:    math.Round(rarity*100) / 100,
+			SoloPoints:     soloPoints,
+			ComboPotential: math.Round(potential*100) / 100,
+			StakingUtility: math.Round((soloPoints+potential)*100) / 100,
+		}
+		scores[token.TokenID] = score
+		byRarity = append(byRarity, score)
+		byUtility = append(byUtility, score)
+	}
+
+	rankScores(byRarity, func(score *models.KOSKeyScore) float64 { return score.RarityScore })
+	for i, score := range byRarity {
+		score.RarityRank = i + 1
+	}
+
+	rankScores(byUtility, func(score *models.KOSKeyScore) float64 { return score.StakingUtility })
+	for i, score := range byUtility {
+		score.UtilityRank = i + 1
+	}
+	// the keys with a lower utility are the ones after the last key with the same utility.
+	lower := len(byUtility)
+	for i := range byUtility {
+		if i == 0 || byUtility[i].StakingUtility != byUtility[i-1].StakingUtility {
+			lower = len(byUtility) - i - countEqualUtility(byUtility[i:])
+		}
+		byUtility[i].UtilityPercentile = math.Round(float64(lower)/float64(len(byUtility))*100*100) / 100
+	}
+
+	return scores, byRarity, byUtility
+}
+
+/*
+Estimates the key combo bonus per key that a key can expect in a multi-key subpool, averaged over `comboKeyCounts`.
+
+The other keys in the subpool are assumed to be drawn from the collection, so a key whose house and type are shared by more keys
+(out of `total`) is more likely to get the same-house and same-type bonuses from `BaseKeyCombo`.
+*/
+func comboPotential(total, houseCount, typeCount, houseTypeCount int) float64 {
+	if total < 2 {
+		return 0
+	}
+
+	// the share of the other keys with the same house and type, with only the same type, and with only the same house.
+	others := float64(total - 1)
+	sameBoth := float64(houseTypeCount-1) / others
+	sameTypeOnly := float64(typeCount-houseTypeCount) / others
+	sameHouseOnly := float64(houseCount-houseTypeCount) / others
+
+	potential := 0.0
+	for _, keyCount := range comboKeyCounts {
+		same := make([]string, keyCount)
+		different := make([]string, keyCount)
+		for i := range different {
+			same[i] = "same"
+			different[i] = fmt.Sprint(i)
+		}
+
+		base := BaseKeyCombo(keyCount, different, different)
+		expected := base +
+			sameBoth*(BaseKeyCombo(keyCount, same, same)-base) +
+			sameTypeOnly*(BaseKeyCombo(keyCount, different, same)-base) +
+			sameHouseOnly*(BaseKeyCombo(keyCount, same, different)-base)
+
+		potential += expected / float64(keyCount)
+	}
+
+	return potential / float64(len(comboKeyCounts))
+}
+
+/*
+Sorts `scores` by `value` (highest first), then by token ID.
+*/
+func rankScores(scores []*models.KOSKeyScore, value func(score *models.KOSKeyScore) float64) {
+	sort.Slice(scores, func(i, j int) bool {
+		if value(scores[i]) != value(scores[j]) {
+			return value(scores[i]) > value(scores[j])
+		}
+		return scores[i].TokenID < scores[j].TokenID
+	})
+}
+
+/*
+Returns how many keys at the start of `scores` have the same utility as the first one.
+*/
+func countEqualUtility(scores []*models.KOSKeyScore) int {
+	count := 0
+	for _, score := range scores {
+		if score.StakingUtility != scores[0].StakingUtility {
+			break
+		}
+		count++
+	}
+	return count
+}
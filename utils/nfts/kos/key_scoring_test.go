@@ -0,0 +1,100 @@
+package utils_kos
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"nbc-backend-api-v2/models"
+)
+
+func TestComboPotential(t *testing.T) {
+	tests := []struct {
+		name                                      string
+		total, houseCount, typeCount, houseTypeCt int
+		want                                      float64
+	}{
+		{"no other keys", 1, 1, 1, 1, 0},
+		// (80/2 + 175/3 + 360/5 + 1250/15) / 4
+		{"no key shares the house or type", 100, 1, 1, 1, 63.42},
+		// (140/2 + 300/3 + 600/5 + 3500/15) / 4
+		{"every key shares the house and type", 10, 10, 10, 10, 130.83},
+		// (110/2 + 240/3 + 485/5 + 2000/15) / 4
+		{"every key shares the type only", 10, 1, 10, 1, 91.33},
+		// (95/2 + 200/3 + 410/5 + 1500/15) / 4
+		{"every key shares the house only", 10, 10, 1, 1, 74.04},
+		{"half of the other keys share the house and type", 3, 2, 2, 2, 97.13},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := math.Round(comboPotential(test.total, test.houseCount, test.typeCount, test.houseTypeCt)*100) / 100; got != test.want {
+				t.Errorf("comboPotential() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func scoredKey(tokenId int, house, typ string, luck float64) *models.KOSSimplifiedMetadata {
+	key := testKey(tokenId, house, typ, luck, 1)
+	key.Traits = map[string]interface{}{"House": house, "Type": typ, "Luck": luck, "Luck Boost": 1.0}
+	return key
+}
+
+type expectedScore struct {
+	tokenId           int
+	rarityScore       float64
+	rarityRank        int
+	utilityRank       int
+	utilityPercentile float64
+}
+
+func TestScoreKeys(t *testing.T) {
+	keys := []*models.KOSSimplifiedMetadata{
+		scoredKey(3, "Glory", "Brawler", 50),
+		scoredKey(1, "Tranquility", "Brawler", models.AngelLuck),
+		scoredKey(4, "Glory", "Brawler", 50),
+		scoredKey(2, "Tranquility", "Hunter", 90),
+	}
+
+	// the rarity score is 4 / (the number of keys with the same value) summed over the house, type, luck and luck boost.
+	want := []expectedScore{
+		{1, 2 + 4.0/3 + 4 + 1, 2, 1, 75},
+		// key 2 shares its house and type with no other key, so its combo potential is the lowest despite its luck.
+		{2, 2 + 4 + 4 + 1, 1, 4, 0},
+		// keys with the same traits have the same scores and percentile, and are ranked by token ID.
+		{3, 2 + 4.0/3 + 2 + 1, 3, 2, 25},
+		{4, 2 + 4.0/3 + 2 + 1, 4, 3, 25},
+	}
+
+	scores, byRarity, byUtility := scoreKeys(keys)
+
+	var got []expectedScore
+	for tokenId := 1; tokenId <= len(keys); tokenId++ {
+		score := scores[tokenId]
+		got = append(got, expectedScore{score.TokenID, score.RarityScore, score.RarityRank, score.UtilityRank, score.UtilityPercentile})
+
+		if score.StakingUtility != math.Round((score.SoloPoints+score.ComboPotential)*100)/100 {
+			t.Errorf("key %d: staking utility %v isn't its solo points %v plus its combo potential %v", tokenId, score.StakingUtility, score.SoloPoints, score.ComboPotential)
+		}
+		if score.Angel != (tokenId == 1) {
+			t.Errorf("key %d: angel = %v", tokenId, score.Angel)
+		}
+	}
+	for i := range want {
+		want[i].rarityScore = math.Round(want[i].rarityScore*100) / 100
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scores = %+v, want %+v", got, want)
+	}
+
+	for i, score := range byRarity {
+		if score.RarityRank != i+1 {
+			t.Errorf("key %d is at position %d of the rarity ranking, but has rank %d", score.TokenID, i+1, score.RarityRank)
+		}
+	}
+	for i, score := range byUtility {
+		if score.UtilityRank != i+1 {
+			t.Errorf("key %d is at position %d of the utility ranking, but has rank %d", score.TokenID, i+1, score.UtilityRank)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// how long a computed leaderboard page is served from the cache before it's computed again.
+const LeaderboardCacheTTL = time.Minute
+
+var (
+	// the cached leaderboard pages, keyed by leaderboard, page and limit (e.g. `pool:3:1:25`).
+	leaderboardCache sync.Map
+)
+
+type cachedLeaderboard struct {
+	leaderboard interface{}
+	expiresAt   time.Time
+}
+
+/*
+Gets page `page` (of `limit` stakers each) of the leaderboard of the staking pool with ID `stakingPoolId`.
+
+Stakers are ranked by the sum of the points of their active and closed subpools in the pool (banned subpools don't count).
+The projected token share is the staker's points divided by the total points of ALL subpools in the pool,
+the same way `CalcSubpoolTokenShare` calculates it. Stakers with the same points are ordered by wallet.
+*/
+func GetPoolLeaderboard(collection *mongo.Collection, stakingPoolId, page, limit int) (*models.PoolLeaderboard, error) {
+	if collection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+
+	now := time.Now()
+	cacheKey := fmt.Sprintf("pool:%d:%d:%d", stakingPoolId, page, limit)
+	if cached, ok := loadLeaderboard(cacheKey, now); ok {
+		return cached.(*models.PoolLeaderboard), nil
+	}
+
+	stakingPool, err := GetStakingPoolData(collection, stakingPoolId)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := subpoolStakerWallets(configs.GetCollections(configs.DB, "RHStakerData"), stakingPool)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderboard := poolLeaderboard(stakingPool, wallets, page, limit)
+	leaderboard.UpdatedAt = now
+	storeLeaderboard(cacheKey, leaderboard, now)
+
+	return leaderboard, nil
+}
+
+/*
+Returns page `page` (of `limit` stakers each) of the leaderboard of `stakingPool` (see `GetPoolLeaderboard`),
+given the wallets of the stakers of its subpools that don't have a `StakerWallet` (see `subpoolStakerWallets`).
+*/
+func poolLeaderboard(stakingPool *models.StakingPool, wallets map[primitive.ObjectID]string, page, limit int) *models.PoolLeaderboard {
+	totalPoints := poolSharePoints(stakingPool)
+	isToken := strings.Contains(stakingPool.Reward.Name, "Token")
+
+	leaderboard := &models.PoolLeaderboard{
+		StakingPoolID: stakingPool.StakingPoolID,
+		RewardName:    stakingPool.Reward.Name,
+		RewardAmount:  stakingPool.Reward.Amount,
+		TotalPoints:   math.Round(totalPoints*100) / 100,
+		Entries:       []*models.PoolLeaderboardEntry{},
+		Page:          page,
+		Limit:         limit,
+	}
+
+	// banned subpools count towards the total points, but not for their staker.
+	var entries []*models.PoolLeaderboardEntry
+	byWallet := map[string]*models.PoolLeaderboardEntry{}
+	addSubpools := func(subpools []*models.StakingSubpool) {
+		for _, subpool := range subpools {
+			wallet := strings.ToLower(subpool.StakerWallet)
+			if wallet == "" && subpool.Staker != nil {
+				wallet = wallets[*subpool.Staker]
+			}
+			if subpool.Banned || wallet == "" {
+				continue
+			}
+
+			entry, ok := byWallet[wallet]
+			if !ok {
+				entry = &models.PoolLeaderboardEntry{Wallet: wallet}
+				byWallet[wallet] = entry
+				entries = append(entries, entry)
+			}
+			entry.Points += subpool.SubpoolPoints
+			entry.SubpoolCount++
+		}
+	}
+	addSubpools(stakingPool.ActiveSubpools)
+	addSubpools(stakingPool.ClosedSubpools)
+
+	for _, entry := range entries {
+		if isToken {
+			entry.ProjectedTokenShare = tokenShare(entry.Points, totalPoints, stakingPool.Reward.Amount)
+		}
+		entry.Points = math.Round(entry.Points*100) / 100
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].Wallet < entries[j].Wallet
+	})
+
+	leaderboard.Total = int64(len(entries))
+	start := (page - 1) * limit
+	for i := start; i < len(entries) && i < start+limit; i++ {
+		entries[i].Rank = leaderboardRank(page, limit, i-start)
+		leaderboard.Entries = append(leaderboard.Entries, entries[i])
+	}
+
+	return leaderboard
+}
+
+/*
+Returns the wallets (in lowercase) of the stakers of the subpools of `stakingPool` that don't have a `StakerWallet` (older subpools),
+keyed by the staker's object ID. `collection` must be RHStakerData.
+*/
+func subpoolStakerWallets(collection *mongo.Collection, stakingPool *models.StakingPool) (map[primitive.ObjectID]string, error) {
+	var stakerObjIds []primitive.ObjectID
+	for _, subpools := range [][]*models.StakingSubpool{stakingPool.ActiveSubpools, stakingPool.ClosedSubpools} {
+		for _, subpool := range subpools {
+			if subpool.StakerWallet == "" && subpool.Staker != nil {
+				stakerObjIds = append(stakerObjIds, *subpool.Staker)
+			}
+		}
+	}
+
+	wallets := make(map[primitive.ObjectID]string)
+	if len(stakerObjIds) == 0 {
+		return wallets, nil
+	}
+
+	cursor, err := collection.Find(context.Background(), bson.M{"_id": bson.M{"$in": stakerObjIds}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var staker models.Staker
+		if err := cursor.Decode(&staker); err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+		}
+		wallets[staker.ID] = strings.ToLower(staker.Wallet)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return wallets, nil
+}
+
+/*
+Gets page `page` (of `limit` stakers each) of the all-time leaderboard of the reward named `rewardName`,
+ranking stakers by the amount of the reward in their `EarnedRewards`. Stakers with the same amount are ordered by wallet.
+*/
+func GetRewardLeaderboard(collection *mongo.Collection, rewardName string, page, limit int) (*models.RewardLeaderboard, error) {
+	if collection.Name() != "RHStakerData" {
+		return nil, errors.New("collection must be RHStakerData")
+	}
+
+	now := time.Now()
+	cacheKey := fmt.Sprintf("reward:%s:%d:%d", rewardName, page, limit)
+	if cached, ok := loadLeaderboard(cacheKey, now); ok {
+		return cached.(*models.RewardLeaderboard), nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"earnedRewards.name": rewardName}}},
+		{{Key: "$unwind", Value: "$earnedRewards"}},
+		{{Key: "$match", Value: bson.M{"earnedRewards.name": rewardName, "earnedRewards.amount": bson.M{"$gt": 0}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"$toLower": "$wallet"},
+			"earnedRewards": bson.M{"$sum": "$earnedRewards.amount"},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "wallet": "$_id", "earnedRewards": bson.M{"$round": bson.A{"$earnedRewards", 2}}}}},
+		{{Key: "$facet", Value: bson.M{
+			"total":   bson.A{bson.M{"$count": "count"}},
+			"entries": leaderboardPage(bson.D{{Key: "earnedRewards", Value: -1}, {Key: "wallet", Value: 1}}, page, limit),
+		}}},
+	}
+
+	var result struct {
+		Total   []struct{ Count int64 }          `bson:"total"`
+		Entries []*models.RewardLeaderboardEntry `bson:"entries"`
+	}
+	if err := aggregateOne(collection, pipeline, &result); err != nil {
+		return nil, err
+	}
+
+	leaderboard := &models.RewardLeaderboard{
+		RewardName: rewardName,
+		Entries:    []*models.RewardLeaderboardEntry{},
+		Page:       page,
+		Limit:      limit,
+		UpdatedAt:  now,
+	}
+	if len(result.Total) > 0 {
+		leaderboard.Total = result.Total[0].Count
+	}
+	for i, entry := range result.Entries {
+		entry.Rank = leaderboardRank(page, limit, i)
+		leaderboard.Entries = append(leaderboard.Entries, entry)
+	}
+
+	storeLeaderboard(cacheKey, leaderboard, now)
+
+	return leaderboard, nil
+}
+
+/*
+Returns the rank of the `i`th entry (from 0) of page `page` of `limit` entries. Ranks start at 1 for the first entry of the first page.
+*/
+func leaderboardRank(page, limit, i int) int {
+	return (page-1)*limit + i + 1
+}
+
+/*
+Returns the `$facet` stages that sort the leaderboard entries by `sort` and return page `page` of `limit` entries.
+*/
+func leaderboardPage(sort bson.D, page, limit int) bson.A {
+	return bson.A{
+		bson.M{"$sort": sort},
+		bson.M{"$skip": (page - 1) * limit},
+		bson.M{"$limit": limit},
+	}
+}
+
+/*
+Runs `pipeline` on `collection` and decodes its only result (the output of a `$facet` stage) into `result`.
+*/
+func aggregateOne(collection *mongo.Collection, pipeline mongo.Pipeline, result interface{}) error {
+	cursor, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	if cursor.Next(context.Background()) {
+		if err := cursor.Decode(result); err != nil {
+			return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return nil
+}
+
+/*
+Returns the leaderboard page cached under `key`, unless it expired before `now`.
+*/
+func loadLeaderboard(key string, now time.Time) (interface{}, bool) {
+	value, ok := leaderboardCache.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	cached := value.(*cachedLeaderboard)
+	if now.After(cached.expiresAt) {
+		return nil, false
+	}
+
+	return cached.leaderboard, true
+}
+
+/*
+Caches `leaderboard` under `key` for `LeaderboardCacheTTL` from `now`.
+*/
+func storeLeaderboard(key string, leaderboard interface{}, now time.Time) {
+	// remove the expired pages first, so that pages nobody requests anymore don't pile up.
+	leaderboardCache.Range(func(key, value interface{}) bool {
+		if now.After(value.(*cachedLeaderboard).expiresAt) {
+			leaderboardCache.Delete(key)
+		}
+		return true
+	})
+
+	leaderboardCache.Store(key, &cachedLeaderboard{leaderboard: leaderboard, expiresAt: now.Add(LeaderboardCacheTTL)})
+}
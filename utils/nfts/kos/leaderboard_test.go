@@ -0,0 +1,129 @@
+package utils_kos
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"nbc-backend-api-v2/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testSubpool(wallet string, points float64, banned bool) *models.StakingSubpool {
+	return &models.StakingSubpool{StakerWallet: wallet, SubpoolPoints: points, Banned: banned}
+}
+
+type expectedEntry struct {
+	rank                int
+	wallet              string
+	points              float64
+	subpoolCount        int
+	projectedTokenShare float64
+}
+
+func TestPoolLeaderboard(t *testing.T) {
+	// an older subpool, without the staker's wallet.
+	olderStaker := primitive.NewObjectID()
+	older := &models.StakingSubpool{Staker: &olderStaker, SubpoolPoints: 100}
+	wallets := map[primitive.ObjectID]string{olderStaker: "0xdddd"}
+
+	stakingPool := &models.StakingPool{
+		StakingPoolID: 3,
+		Reward:        models.Reward{Name: "REC Token", Amount: 1000},
+		ActiveSubpools: []*models.StakingSubpool{
+			testSubpool("0xBBBB", 300, false),
+			testSubpool("0xaaaa", 150, false),
+			older,
+		},
+		ClosedSubpools: []*models.StakingSubpool{
+			testSubpool("0xaaaa", 150, false),
+			// banned subpools count towards the total points (what the token shares are calculated from), but not for their staker.
+			testSubpool("0xcccc", 300, true),
+			testSubpool("0xbbbb", 0, true),
+		},
+	}
+
+	all := []expectedEntry{
+		// the same points are ordered by wallet.
+		{1, "0xaaaa", 300, 2, 300},
+		{2, "0xbbbb", 300, 1, 300},
+		{3, "0xdddd", 100, 1, 100},
+	}
+
+	tests := []struct {
+		name        string
+		page, limit int
+		want        []expectedEntry
+	}{
+		{"every staker", 1, 25, all},
+		{"first page", 1, 2, all[:2]},
+		// ranks continue from the previous pages.
+		{"second page", 2, 2, all[2:]},
+		{"past the last page", 3, 2, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			leaderboard := poolLeaderboard(stakingPool, wallets, test.page, test.limit)
+
+			if leaderboard.TotalPoints != 1000 || leaderboard.Total != 3 {
+				t.Errorf("got %v total points and %d stakers, want 1000 and 3", leaderboard.TotalPoints, leaderboard.Total)
+			}
+
+			var got []expectedEntry
+			for _, entry := range leaderboard.Entries {
+				got = append(got, expectedEntry{entry.Rank, entry.Wallet, entry.Points, entry.SubpoolCount, entry.ProjectedTokenShare})
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got entries %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPoolLeaderboardShareMatchesClaims(t *testing.T) {
+	stakingPool := &models.StakingPool{
+		Reward:         models.Reward{Name: "REC Token", Amount: 100000},
+		ActiveSubpools: []*models.StakingSubpool{testSubpool("0xaaaa", 623.86, false), testSubpool("0xbbbb", 1219.34, false)},
+		ClosedSubpools: []*models.StakingSubpool{testSubpool("0xcccc", 411.5, true)},
+	}
+
+	leaderboard := poolLeaderboard(stakingPool, nil, 1, 25)
+	for _, entry := range leaderboard.Entries {
+		// a staker with a single subpool is projected exactly what claiming it gives (see `claimSubpool`).
+		for _, subpool := range stakingPool.ActiveSubpools {
+			if subpool.StakerWallet != entry.Wallet {
+				continue
+			}
+			if want := tokenShare(subpool.SubpoolPoints, poolSharePoints(stakingPool), stakingPool.Reward.Amount); entry.ProjectedTokenShare != want {
+				t.Errorf("%s: projected token share = %v, want %v", entry.Wallet, entry.ProjectedTokenShare, want)
+			}
+		}
+	}
+
+	// only token rewards are shared.
+	stakingPool.Reward.Name = "Gold Pass"
+	for _, entry := range poolLeaderboard(stakingPool, nil, 1, 25).Entries {
+		if entry.ProjectedTokenShare != 0 {
+			t.Errorf("%s: projected token share = %v for a non-token reward, want 0", entry.Wallet, entry.ProjectedTokenShare)
+		}
+	}
+}
+
+func TestLeaderboardCacheTTL(t *testing.T) {
+	now := time.Now()
+	storeLeaderboard("test:ttl", "page", now)
+
+	if _, ok := loadLeaderboard("test:ttl", now.Add(LeaderboardCacheTTL-time.Second)); !ok {
+		t.Error("the page expired before LeaderboardCacheTTL")
+	}
+	if _, ok := loadLeaderboard("test:ttl", now.Add(LeaderboardCacheTTL+time.Second)); ok {
+		t.Error("the page is still served after LeaderboardCacheTTL")
+	}
+
+	// storing another page removes the expired ones.
+	storeLeaderboard("test:other", "page", now.Add(LeaderboardCacheTTL+time.Second))
+	if _, ok := leaderboardCache.Load("test:ttl"); ok {
+		t.Error("the expired page wasn't removed")
+	}
+}
@@ -1,63 +1,55 @@
 package utils_kos
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"nbc-backend-api-v2/models"
-	"net/http"
-	"os"
-	"sort"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	"sync"
 )
 
-var (
-	client        = &http.Client{}
-	metadataCache sync.Map
-)
-
 /*
-`FetchMetadata` fetches a Key Of Salvation's metadata from Pinata (IPFS) and returns it as a `KOSMetadata` struct instance.
+`FetchMetadata` fetches a Key Of Salvation's metadata and returns it as a `KOSMetadata` struct instance.
+
+The metadata is read from the in-memory cache first, then from `RHKOSMetadata` and only then from IPFS,
+in which case it's added to `RHKOSMetadata` so that it doesn't have to be fetched again after a restart.
 
 	`tokenId` the token ID of the Key
 */
 func FetchMetadata(tokenId int) (*models.KOSMetadata, error) {
-	// check if metadata is in cache
-	if metadata, ok := metadataCache.Load(tokenId); ok {
-		return metadata.(*models.KOSMetadata), nil
-	}
-
-	url := os.Getenv("KOS_URI") + fmt.Sprint(tokenId) + ".json"
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	keys, err := KeyCollection()
 	if err != nil {
 		return nil, err
 	}
 
-	// send the request and get the response
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	defer res.Body.Close()
-
-	// check if response is OK
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-200 status code: %d", res.StatusCode)
-	}
+	return keys.Metadata.Fetch(tokenId)
+}
 
-	// unmarshal the response body into a `KOSMetadata` struct instance
-	var metadata models.KOSMetadata
-	err = json.NewDecoder(res.Body).Decode(&metadata)
+/*
+Returns the metadata and the error of each Key Of Salvation in `tokenIds` (in the same order), fetching at most `UtilsNFT.MaxConcurrentMetadataFetches` at a time.
+*/
+func FetchMetadataEach(tokenIds []int) ([]*models.KOSMetadata, []error) {
+	keys, err := KeyCollection()
 	if err != nil {
-		return nil, err
+		errs := make([]error, len(tokenIds))
+		for i := range errs {
+			errs[i] = err
+		}
+		return make([]*models.KOSMetadata, len(tokenIds)), errs
 	}
 
-	// cache metadata
-	metadataCache.Store(tokenId, &metadata)
+	return keys.Metadata.FetchEach(tokenIds)
+}
 
-	return &metadata, nil
+// the traits every Key Of Salvation's metadata must have. attributes are looked up by `trait_type`, so their order doesn't matter.
+var KOSMetadataSchema = &UtilsNFT.MetadataSchema{
+	Collection: "Key Of Salvation",
+	Traits: []*UtilsNFT.TraitSpec{
+		{TraitType: "Luck", Kind: UtilsNFT.TraitNumber, Required: true, Min: 0, Max: 100},
+		{TraitType: "Luck Boost", Kind: UtilsNFT.TraitNumber, Required: true, Min: 0, Max: 100}, // in percent
+		{TraitType: "House", Kind: UtilsNFT.TraitString, Required: true},
+		{TraitType: "Type", Kind: UtilsNFT.TraitString, Required: true},
+	},
 }
 
 /*
@@ -65,102 +57,87 @@ func FetchMetadata(tokenId int) (*models.KOSMetadata, error) {
 
 	`tokenId` the token ID of the Key
 */
-
 func FetchSimplifiedMetadata(tokenId int) (*models.KOSSimplifiedMetadata, error) {
 	metadata, err := FetchMetadata(tokenId)
 	if err != nil {
 		return nil, err
 	}
 
-	simplifiedMetadata := &models.KOSSimplifiedMetadata{
-		TokenID:        tokenId,
-		AnimationUrl:   metadata.AnimationUrl,
-		HouseTrait:     metadata.Attributes[3].Value.(string),
-		TypeTrait:      metadata.Attributes[7].Value.(string),
-		LuckTrait:      metadata.Attributes[0].Value.(float64),
-		LuckBoostTrait: 1 + (metadata.Attributes[1].Value.(float64) / 100),
-	}
-
-	return simplifiedMetadata, nil
+	return ParseSimplifiedMetadata(tokenId, metadata)
 }
 
-func FetchSimplifiedMetadataConcurrent(tokenIds []int) ([]*models.KOSSimplifiedMetadata, error) {
-	type result struct {
-		index    int
-		metadata *models.KOSSimplifiedMetadata
-		err      error
+/*
+Parses the metadata of the Key Of Salvation with ID `tokenId` with `KOSMetadataSchema`.
+Returns a `UtilsNFT.MetadataError` (matching `UtilsNFT.ErrMalformedMetadata`) if a trait is missing or invalid.
+*/
+func ParseSimplifiedMetadata(tokenId int, metadata *models.KOSMetadata) (*models.KOSSimplifiedMetadata, error) {
+	traits, err := KOSMetadataSchema.Parse(tokenId, metadata.Attributes)
+	if err != nil {
+		return nil, err
 	}
 
-	// use a buffered channel to limit the number of concurrent goroutines
-	ch := make(chan result, len(tokenIds))
+	return &models.KOSSimplifiedMetadata{
+		TokenID:        tokenId,
+		AnimationUrl:   metadata.AnimationUrl,
+		HouseTrait:     traits["House"].(string),
+		TypeTrait:      traits["Type"].(string),
+		LuckTrait:      traits["Luck"].(float64),
+		LuckBoostTrait: 1 + (traits["Luck Boost"].(float64) / 100),
+		Traits:         traits,
+	}, nil
+}
 
-	// create worker goroutines
-	for i, id := range tokenIds {
-		go func(index, tokenId int) {
-			metadata, err := FetchSimplifiedMetadata(tokenId)
-			ch <- result{index, metadata, err}
-		}(i, id)
+/*
+Returns the simplified metadata of each key in `tokenIds` (in the same order), fetching at most `UtilsNFT.MaxConcurrentMetadataFetches` at a time.
+*/
+func FetchSimplifiedMetadataConcurrent(tokenIds []int) ([]*models.KOSSimplifiedMetadata, error) {
+	simplifiedMetadata := make([]*models.KOSSimplifiedMetadata, len(tokenIds))
+	errs := make([]error, len(tokenIds))
+
+	// a bounded pool of workers, each fetching the keys at the indexes it receives.
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < UtilsNFT.MaxConcurrentMetadataFetches && i < len(tokenIds); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				simplifiedMetadata[index], errs[index] = FetchSimplifiedMetadata(tokenIds[index])
+			}
+		}()
 	}
-
-	// collect results
-	results := make([]result, len(tokenIds))
-	for range tokenIds {
-		res := <-ch
-		results[res.index] = res
+	for index := range tokenIds {
+		indexes <- index
 	}
+	close(indexes)
+	wg.Wait()
 
-	// check for errors
-	var errors []error
-	for _, res := range results {
-		if res.err != nil {
-			errors = append(errors, res.err)
+	// check for errors. they are joined so that callers can still match them (e.g. with `errors.Is(err, ErrMetadataUnavailable)`).
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
 		}
 	}
-	if len(errors) > 0 {
-		return nil, fmt.Errorf("encountered %d errors: %v", len(errors), errors)
-	}
-
-	// sort results by original order
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].index < results[j].index
-	})
-
-	// extract simplified metadata from results
-	simplifiedMetadata := make([]*models.KOSSimplifiedMetadata, len(results))
-	for i, res := range results {
-		simplifiedMetadata[i] =
-			res.metadata
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("encountered %d errors: %w", len(failed), errors.Join(failed...))
 	}
 
 	return simplifiedMetadata, nil
 }
 
-// func FetchSimplifiedMetadata(tokenId int) *models.KOSSimplifiedMetadata {
-// 	metadata := FetchMetadata(tokenId)
-
-// 	simplifiedMetadata := &models.KOSSimplifiedMetadata{
-// 		TokenID:        tokenId,
-// 		HouseTrait:     metadata.Attributes[3].Value.(string),
-// 		TypeTrait:      metadata.Attributes[7].Value.(string),
-// 		LuckTrait:      metadata.Attributes[0].Value.(float64),
-// 		LuckBoostTrait: 1 + (metadata.Attributes[1].Value.(float64) / 100),
-// 	}
-
-// 	return simplifiedMetadata
-// }
-
 /*
 Gets the simplified metadata struct instance for each key ID.
 */
-func GetMetadataFromIDs(keyIds []int) []*models.KOSSimplifiedMetadata {
+func GetMetadataFromIDs(keyIds []int) ([]*models.KOSSimplifiedMetadata, error) {
 	var metadatas []*models.KOSSimplifiedMetadata
 	for _, id := range keyIds {
 		metadata, err := FetchSimplifiedMetadata(id)
 		if err != nil {
-			log.Fatal("Error while fetching metadata", err)
+			return nil, err
 		}
 		metadatas = append(metadatas, metadata)
 	}
 
-	return metadatas
+	return metadatas, nil
 }
@@ -0,0 +1,62 @@
+package utils_kos
+
+import (
+	"errors"
+	"nbc-backend-api-v2/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Gets the metadata of the Key Of Salvation with ID `tokenId` from `RHKOSMetadata`. Returns nil if it isn't stored yet.
+*/
+func GetStoredMetadata(collection *mongo.Collection, tokenId int) (*models.StoredKOSMetadata, error) {
+	if collection.Name() != "RHKOSMetadata" {
+		return nil, errors.New("collection must be RHKOSMetadata")
+	}
+
+	keys, err := KeyCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.Metadata.GetStored(collection, tokenId)
+}
+
+/*
+Syncs the metadata of every Key Of Salvation from IPFS into `RHKOSMetadata`, fetching `concurrency` tokens at a time.
+
+Without `refresh`, tokens that are already stored are skipped, so a sync that was interrupted (or had failed tokens) continues where it stopped.
+With `refresh`, every token is fetched again and only the tokens whose content hash changed (e.g. after `setRevealStage`) are updated
+(and removed from the in-memory cache). Tokens that don't match `KOSMetadataSchema` are reported as malformed.
+*/
+func SyncMetadata(collection *mongo.Collection, concurrency int, refresh bool) (*models.MetadataSyncResult, error) {
+	if collection.Name() != "RHKOSMetadata" {
+		return nil, errors.New("collection must be RHKOSMetadata")
+	}
+
+	keys, err := KeyCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenIds := make([]int, keys.Size)
+	for i := range tokenIds {
+		tokenIds[i] = i + 1
+	}
+
+	result, err := keys.Metadata.Sync(collection, tokenIds, concurrency, refresh, func(tokenId int, metadata *models.NFTMetadata) error {
+		_, err := ParseSimplifiedMetadata(tokenId, metadata)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the search index and the key scores are based on the stored metadata.
+	if result.Added > 0 || result.Changed > 0 {
+		invalidateTokenIndex()
+	}
+
+	return result, nil
+}
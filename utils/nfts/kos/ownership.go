@@ -1,95 +1,147 @@
 package utils_kos
 
 import (
-	"fmt"
+	"log"
 	"math/big"
 	"nbc-backend-api-v2/models"
 	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	"sort"
 )
 
+// the total supply of the Key Of Salvation collection. token IDs range from 1 to `KOSCollectionSize`.
+const KOSCollectionSize = 5000
+
+/*
+Returns the collection staked as keys (the Key Of Salvation) from the collection registry.
+*/
+func KeyCollection() (*UtilsNFT.Collection, error) {
+	return UtilsNFT.CollectionByRole(UtilsNFT.RoleKey)
+}
+
 /*
 Calls `GetExplicitOwnerships` for the Key Of Salvation contract.
 */
 func KOSExplicitOwnership() ([]models.ExplicitOwnership, error) {
-	// calls `GetExplicitOwnerships` for the Key Of Salvation with the given address
-	ownerships, err := UtilsNFT.GetExplicitOwnerships(
-		"ALCHEMY_ETH_API_KEY",
-		true,
-		"https://eth-mainnet.g.alchemy.com/v2/",
-		"abi/KeyOfSalvation.json",
-		"KOS_ADDRESS",
-		5000,
-		nil,
-		nil,
-		nil,
-	)
+	keys, err := KeyCollection()
 	if err != nil {
 		return nil, err
 	}
 
-	return ownerships, nil
+	return keys.ExplicitOwnerships()
 }
 
 /*
-`OwnerIDs` returns the owned token IDs for the given `address` for the KOS collection.
+`OwnerIDs` returns the owned token IDs for the given `address` for the KOS collection, on every chain it's on.
 
 	`address` the EVM address of the owner
 */
 func OwnerIDs(address string) ([]*big.Int, error) {
-	// calls `GetOwnerIds` for the Key Of Salvation contract with the given address
-	ownerIds, err := UtilsNFT.GetOwnerIDs(
-		"ALCHEMY_ETH_API_KEY",
-		true,
-		"https://eth-mainnet.g.alchemy.com/v2/",
-		"abi/KeyOfSalvation.json",
-		"KOS_ADDRESS",
-		address,
-		nil,
-		nil,
-		nil,
-	)
+	return UtilsNFT.OwnerIDsByRole(address, UtilsNFT.RoleKey)
+}
+
+/*
+Returns the token IDs owned by `address` in every registered collection, keyed by the collection's staking role.
+The tokens of a role are aggregated across the chains its collections are on.
+*/
+func OwnedTokenIDs(address string) (map[UtilsNFT.CollectionRole][]int, error) {
+	collections, err := UtilsNFT.Collections()
+	if err != nil {
+		return nil, err
+	}
+	owned, err := UtilsNFT.GetOwnedTokens(address, collections)
 	if err != nil {
 		return nil, err
 	}
 
-	return ownerIds.TokenIDs, nil
+	ownedIds := make(map[UtilsNFT.CollectionRole][]int, len(collections))
+	for _, tokens := range owned {
+		ownedIds[tokens.Collection.Role] = append(ownedIds[tokens.Collection.Role], tokens.TokenIDs...)
+	}
+	for _, tokenIds := range ownedIds {
+		sort.Ints(tokenIds)
+	}
+
+	return ownedIds, nil
 }
 
 /*
-`VerifyOwnership` checks if `address` still owns ANY of the mentioned `ids` for the KOS collection.
+`VerifyOwnership` checks if `address` still owns ALL of the mentioned `ids` for the KOS collection (on any of its chains).
 
 If even just one of the ids are no longer owned by `address`, this function returns false.
 
-Called for staking purposes. `ids` should be the ids of the NFTs the user has staked in a PARTICULAR pool at a single time.
-
-For multiple pools, this function should be called multiple times, each for each pool and with different IDs.
-
 	`address` the EVM address of the owner
 	`ids` the token IDs to check
 */
 func VerifyOwnership(address string, ids []int) (bool, error) {
-	currentOwnedIds, err := OwnerIDs(address)
-	fmt.Println("Current owned ids: ", currentOwnedIds)
+	return VerifyStakedOwnership(address, map[UtilsNFT.CollectionRole][]int{UtilsNFT.RoleKey: ids})
+}
+
+/*
+Checks if `address` still owns ALL of the tokens in `stakedIds` (the token IDs of each staking role), going through every registered collection.
+A token counts as owned if `address` owns it on any of the chains of its role.
+
+Called for staking purposes. `stakedIds` should be what the user stakes (or has staked) in a PARTICULAR subpool;
+IDs below 1 (like -1 for no keychain or superior keychain) are skipped.
+
+	`address` the EVM address of the owner
+	`stakedIds` the token IDs to check, keyed by staking role
+*/
+func VerifyStakedOwnership(address string, stakedIds map[UtilsNFT.CollectionRole][]int) (bool, error) {
+	collections, err := UtilsNFT.Collections()
 	if err != nil {
 		return false, err
 	}
 
-	for _, id := range ids {
-		found := false
-		// check if `id` exists in `currentOwnedIds`. the moment one id is not owned, return false
-		for _, currentOwnedId := range currentOwnedIds {
-			if currentOwnedId.Cmp(big.NewInt(int64(id))) == 0 {
-				found = true
-				break
+	// only the collections of the roles with tokens to check are read.
+	toCheck := map[UtilsNFT.CollectionRole][]int{}
+	for role, ids := range stakedIds {
+		for _, id := range ids {
+			if id >= 1 {
+				toCheck[role] = append(toCheck[role], id)
 			}
 		}
+	}
+	var checked []*UtilsNFT.Collection
+	for _, collection := range collections {
+		if len(toCheck[collection.Role]) > 0 {
+			checked = append(checked, collection)
+		}
+	}
+
+	owned, err := UtilsNFT.GetOwnedTokens(address, checked)
+	if err != nil {
+		return false, err
+	}
+	ownedIds := map[UtilsNFT.CollectionRole]map[int]bool{}
+	for _, tokens := range owned {
+		if ownedIds[tokens.Collection.Role] == nil {
+			ownedIds[tokens.Collection.Role] = map[int]bool{}
+		}
+		for _, tokenId := range tokens.TokenIDs {
+			ownedIds[tokens.Collection.Role][tokenId] = true
+		}
+	}
 
-		if !found {
-			fmt.Println("Id not found: ", id)
-			return false, nil
+	// the moment one id is not owned, return false
+	for role, ids := range toCheck {
+		for _, id := range ids {
+			if !ownedIds[role][id] {
+				log.Printf("%s %d is not owned by `address` %s", role, id, address)
+				return false, nil
+			}
 		}
 	}
 
-	fmt.Println("Ownership verified: true")
 	return true, nil
 }
+
+/*
+Returns the token IDs of a subpool's keys, keychains and superior keychain keyed by their staking role, for `VerifyStakedOwnership`.
+*/
+func SubpoolTokenIDs(keyIds, keychainIds []int, superiorKeychainId int) map[UtilsNFT.CollectionRole][]int {
+	return map[UtilsNFT.CollectionRole][]int{
+		UtilsNFT.RoleKey:        keyIds,
+		UtilsNFT.RoleBooster:    keychainIds,
+		UtilsNFT.RoleMultiplier: {superiorKeychainId},
+	}
+}
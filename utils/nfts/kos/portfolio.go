@@ -0,0 +1,197 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Gets the portfolio of the staker with wallet `wallet` at `now`: every staking pool they've staked in (newest first)
+with the status, points and token share of each of their subpools, their realized and projected rewards and their ban history.
+
+Only reads the staker; wallets that haven't staked yet get an empty portfolio.
+*/
+func GetStakerPortfolio(poolsCollection, stakersCollection *mongo.Collection, wallet string, now time.Time) (*models.StakerPortfolio, error) {
+	if poolsCollection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+	if stakersCollection.Name() != "RHStakerData" {
+		return nil, errors.New("collection must be RHStakerData")
+	}
+
+	portfolio := &models.StakerPortfolio{
+		Wallet:           strings.ToLower(wallet),
+		Pools:            []*models.PortfolioPool{},
+		RealizedRewards:  []*models.Reward{},
+		ProjectedRewards: []*models.Reward{},
+		BanHistory:       &models.BanHistory{BannedSubpools: []*models.BannedSubpool{}},
+	}
+
+	staker, err := GetStakerFromWallet(stakersCollection, wallet)
+	if errors.Is(err, ErrStakerNotFound) {
+		return portfolio, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if staker.EarnedRewards != nil {
+		portfolio.RealizedRewards = staker.EarnedRewards
+	}
+	if staker.BannedData != nil {
+		portfolio.BanHistory.Banned = now.Before(staker.BannedData.CurrentUnbanTime)
+		portfolio.BanHistory.BannedCount = staker.BannedData.BannedCount
+		portfolio.BanHistory.LastBanTime = &staker.BannedData.LastBanTime
+		portfolio.BanHistory.CurrentUnbanTime = &staker.BannedData.CurrentUnbanTime
+	}
+
+	// only the staking pools the staker has (or had) a subpool in.
+	filter := bson.M{"$or": bson.A{
+		bson.M{"activeSubpools.staker": staker.ID},
+		bson.M{"closedSubpools.staker": staker.ID},
+	}}
+	opts := options.Find().SetSort(bson.M{"stakingPoolID": -1})
+
+	cursor, err := poolsCollection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	var stakingPools []*models.StakingPool
+	if err := cursor.All(context.Background(), &stakingPools); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	// the projected rewards of each reward, in the order they first appear.
+	projected := map[string]*models.Reward{}
+
+	for _, stakingPool := range stakingPools {
+		pool := portfolioPool(stakingPool, staker, now)
+		portfolio.Pools = append(portfolio.Pools, pool)
+
+		for _, subpool := range pool.Subpools {
+			if subpool.Status == models.SubpoolStatusBanned {
+				portfolio.BanHistory.BannedSubpools = append(portfolio.BanHistory.BannedSubpools, &models.BannedSubpool{
+					StakingPoolID: pool.StakingPoolID,
+					SubpoolID:     subpool.SubpoolID,
+				})
+			}
+		}
+
+		if pool.ProjectedReward > 0 {
+			reward, ok := projected[pool.Reward.Name]
+			if !ok {
+				reward = &models.Reward{Name: pool.Reward.Name}
+				projected[pool.Reward.Name] = reward
+				portfolio.ProjectedRewards = append(portfolio.ProjectedRewards, reward)
+			}
+			reward.Amount = math.Round((reward.Amount+pool.ProjectedReward)*100) / 100
+		}
+	}
+
+	return portfolio, nil
+}
+
+/*
+Returns `stakingPool` as it appears in the portfolio of `staker` at `now`.
+*/
+func portfolioPool(stakingPool *models.StakingPool, staker *models.Staker, now time.Time) *models.PortfolioPool {
+	pool := &models.PortfolioPool{
+		StakingPoolID:  stakingPool.StakingPoolID,
+		Reward:         stakingPool.Reward,
+		EntryAllowance: stakingPool.EntryAllowance,
+		StartTime:      stakingPool.StartTime,
+		EndTime:        stakingPool.EndTime,
+		ClaimDeadline:  stakingPool.EndTime.Add(RewardClaimWindow),
+		Subpools:       []*models.PortfolioSubpool{},
+	}
+
+	// the token share is calculated from the points of ALL subpools, the same way `CalcSubpoolTokenShare` does.
+	pool.TotalPoints = poolSharePoints(stakingPool)
+	isToken := strings.Contains(stakingPool.Reward.Name, "Token")
+
+	add := func(subpool *models.StakingSubpool, status string) {
+		portfolioSubpool := &models.PortfolioSubpool{
+			SubpoolID:          subpool.SubpoolID,
+			Status:             status,
+			EnterTime:          subpool.EnterTime,
+			KeyIDs:             []int{},
+			KeychainIDs:        []int{},
+			SuperiorKeychainID: subpool.StakedSuperiorKeychainID,
+			Points:             subpool.SubpoolPoints,
+		}
+		if !subpool.ExitTime.IsZero() {
+			portfolioSubpool.ExitTime = &subpool.ExitTime
+		}
+		for _, key := range subpool.StakedKeys {
+			portfolioSubpool.KeyIDs = append(portfolioSubpool.KeyIDs, key.TokenID)
+		}
+		if subpool.StakedKeychainIDs != nil {
+			portfolioSubpool.KeychainIDs = subpool.StakedKeychainIDs
+		}
+		if isToken && status != models.SubpoolStatusBanned {
+			portfolioSubpool.TokenShare = tokenShare(subpool.SubpoolPoints, pool.TotalPoints, stakingPool.Reward.Amount)
+		}
+
+		switch status {
+		case models.SubpoolStatusActive:
+			pool.ProjectedReward += portfolioSubpool.TokenShare
+		case models.SubpoolStatusClosed:
+			portfolioSubpool.ClaimDeadline = &pool.ClaimDeadline
+			pool.ProjectedReward += portfolioSubpool.TokenShare
+		case models.SubpoolStatusClaimed:
+			pool.RealizedReward += portfolioSubpool.TokenShare
+		}
+		if status != models.SubpoolStatusBanned {
+			pool.Points += subpool.SubpoolPoints
+		}
+
+		pool.Subpools = append(pool.Subpools, portfolioSubpool)
+	}
+
+	for _, subpool := range stakingPool.ActiveSubpools {
+		if subpool.Staker != nil && *subpool.Staker == staker.ID {
+			add(subpool, models.SubpoolStatusActive)
+		}
+	}
+	for _, subpool := range stakingPool.ClosedSubpools {
+		if subpool.Staker != nil && *subpool.Staker == staker.ID {
+			add(subpool, closedSubpoolStatus(subpool, pool.ClaimDeadline, now))
+		}
+	}
+
+	pool.TotalPoints = math.Round(pool.TotalPoints*100) / 100
+	pool.Points = math.Round(pool.Points*100) / 100
+	pool.RealizedReward = math.Round(pool.RealizedReward*100) / 100
+	pool.ProjectedReward = math.Round(pool.ProjectedReward*100) / 100
+
+	return pool
+}
+
+/*
+Returns the status of a subpool in `ClosedSubpools` at `now`, given the pool's `claimDeadline`.
+*/
+func closedSubpoolStatus(subpool *models.StakingSubpool, claimDeadline, now time.Time) string {
+	switch {
+	case subpool.Banned:
+		return models.SubpoolStatusBanned
+	case subpool.RewardClaimed:
+		return models.SubpoolStatusClaimed
+	// `RemoveExpiredUnclaimableSubpools` only sets `RewardClaimable` to false after the deadline, and it may not have run yet.
+	case !subpool.RewardClaimable || now.After(claimDeadline):
+		return models.SubpoolStatusExpired
+	default:
+		return models.SubpoolStatusClosed
+	}
+}
@@ -0,0 +1,523 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"math"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/models"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// how many of the best greedy allocations are improved by swapping keys.
+	recommendationCandidates = 3
+	// the max number of times all key swaps of an allocation are tried before giving up on improving it further.
+	maxRecommendationPasses = 10
+)
+
+/*
+A way to split keys into subpools, used when searching for the best allocation.
+*/
+type keyAllocation struct {
+	groups [][]*models.KOSSimplifiedMetadata // the keys of each subpool
+	unused []*models.KOSSimplifiedMetadata   // the keys that aren't staked
+	points float64                           // the total points with the keychains and superior keychains assigned by `assignKeychains`
+}
+
+/*
+Recommends how the staker with wallet `stakerWallet` should stake the keys, keychains and superior keychains with IDs `keyIds`, `keychainIds`
+and `superiorKeychainIds` (the ones they own) in the staking pool with ID `stakingPoolId` to get the most points.
+
+NFTs that are already staked in the pool are left out, and the staker's active subpools count towards the combo limits.
+Since a subpool's token share only goes up with its points, the recommendation also has the highest projected token share.
+*/
+func GetSubpoolRecommendation(
+	collection *mongo.Collection,
+	stakingPoolId int,
+	stakerWallet string,
+	keyIds,
+	keychainIds,
+	superiorKeychainIds []int,
+) (*models.SubpoolRecommendation, error) {
+	if collection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+
+	var stakingPool models.StakingPool
+	if err := collection.FindOne(context.Background(), bson.M{"stakingPoolID": stakingPoolId}).Decode(&stakingPool); err != nil {
+		return nil, poolLookupError(err, stakingPoolId)
+	}
+
+	// a staker that doesn't exist yet has no subpools (recommending must not create the staker).
+	stakerObjId, err := GetStakerInstance(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	// the NFTs already staked and the staker's subpools for each key count, from the active subpools only (see `CheckIfKeyStaked`).
+	stakedKeys, stakedKeychains, stakedSuperiorKeychains := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	comboCounts := map[int]int{}
+	for _, subpool := range stakingPool.ActiveSubpools {
+		for _, key := range subpool.StakedKeys {
+			stakedKeys[key.TokenID] = true
+		}
+		for _, keychainId := range subpool.StakedKeychainIDs {
+			stakedKeychains[keychainId] = true
+		}
+		stakedSuperiorKeychains[subpool.StakedSuperiorKeychainID] = true
+		if stakerObjId != nil && subpool.Staker != nil && *subpool.Staker == *stakerObjId {
+			comboCounts[len(subpool.StakedKeys)]++
+		}
+	}
+
+	var unstakedKeyIds, unstakedKeychainIds, unstakedSuperiorKeychainIds []int
+	for _, keyId := range keyIds {
+		if !stakedKeys[keyId] {
+			unstakedKeyIds = append(unstakedKeyIds, keyId)
+		}
+	}
+	for _, keychainId := range keychainIds {
+		if !stakedKeychains[keychainId] {
+			unstakedKeychainIds = append(unstakedKeychainIds, keychainId)
+		}
+	}
+	for _, superiorKeychainId := range superiorKeychainIds {
+		if !stakedSuperiorKeychains[superiorKeychainId] {
+			unstakedSuperiorKeychainIds = append(unstakedSuperiorKeychainIds, superiorKeychainId)
+		}
+	}
+
+	var keys []*models.KOSSimplifiedMetadata
+	if len(unstakedKeyIds) > 0 {
+		keys, err = FetchSimplifiedMetadataConcurrent(unstakedKeyIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recommendation := &models.SubpoolRecommendation{
+		StakingPoolID:             stakingPoolId,
+		Subpools:                  RecommendSubpools(keys, unstakedKeychainIds, unstakedSuperiorKeychainIds, comboCounts),
+		UnusedKeyIDs:              []int{},
+		UnusedKeychainIDs:         []int{},
+		UnusedSuperiorKeychainIDs: []int{},
+	}
+
+	usedKeys, usedKeychains, usedSuperiorKeychains := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	for _, subpool := range recommendation.Subpools {
+		for _, keyId := range subpool.KeyIDs {
+			usedKeys[keyId] = true
+		}
+		for _, keychainId := range subpool.KeychainIDs {
+			usedKeychains[keychainId] = true
+		}
+		usedSuperiorKeychains[subpool.SuperiorKeychainID] = true
+		recommendation.TotalPoints += subpool.ComboSum
+	}
+	recommendation.TotalPoints = math.Round(recommendation.TotalPoints*100) / 100
+
+	for _, keyId := range sortedIDs(unstakedKeyIds) {
+		if !usedKeys[keyId] {
+			recommendation.UnusedKeyIDs = append(recommendation.UnusedKeyIDs, keyId)
+		}
+	}
+	for _, keychainId := range sortedIDs(unstakedKeychainIds) {
+		if !usedKeychains[keychainId] {
+			recommendation.UnusedKeychainIDs = append(recommendation.UnusedKeychainIDs, keychainId)
+		}
+	}
+	for _, superiorKeychainId := range sortedIDs(unstakedSuperiorKeychainIds) {
+		if !usedSuperiorKeychains[superiorKeychainId] {
+			recommendation.UnusedSuperiorKeychainIDs = append(recommendation.UnusedSuperiorKeychainIDs, superiorKeychainId)
+		}
+	}
+
+	// the token share is calculated from the points of ALL subpools (including the recommended ones), the same way `CalcSubpoolTokenShare` does.
+	if strings.Contains(stakingPool.Reward.Name, "Token") && recommendation.TotalPoints > 0 {
+		totalPoints := recommendation.TotalPoints
+		for _, subpool := range append(stakingPool.ActiveSubpools, stakingPool.ClosedSubpools...) {
+			totalPoints += subpool.SubpoolPoints
+		}
+		for _, subpool := range recommendation.Subpools {
+			subpool.ProjectedTokenShare = math.Round(subpool.ComboSum/totalPoints*stakingPool.Reward.Amount*100) / 100
+			recommendation.ProjectedTokenShare += subpool.ProjectedTokenShare
+		}
+		recommendation.ProjectedTokenShare = math.Round(recommendation.ProjectedTokenShare*100) / 100
+	}
+
+	return recommendation, nil
+}
+
+/*
+Splits `keys` into the subpools that give the most points in total, and assigns the keychains with IDs `keychainIds`
+and the superior keychains with IDs `superiorKeychainIds` to them. `comboCounts` is the number of active subpools the staker already has
+for each key count, which count towards the combo limits. The subpools are ordered by their points (highest first).
+
+Every combination of single, duo, trio and pentuple subpools allowed by the combo limits is tried, with the rest of the keys in flushes.
+The keys of each combination are first grouped greedily (preferring keys of the same house and type), and the best combinations
+are then improved by swapping keys between subpools (and the unused keys) until no swap gives more points.
+Ties are always broken the same way, so the same inventory always gets the same recommendation.
+*/
+func RecommendSubpools(keys []*models.KOSSimplifiedMetadata, keychainIds, superiorKeychainIds []int, comboCounts map[int]int) []*models.RecommendedSubpool {
+	keys = append([]*models.KOSSimplifiedMetadata{}, keys...)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].TokenID < keys[j].TokenID })
+	keychainIds, superiorKeychainIds = sortedIDs(keychainIds), sortedIDs(superiorKeychainIds)
+
+	// the number of subpools of each key count the staker can still create.
+	remaining := map[int]int{}
+	for keyCount, limit := range subpoolComboLimits {
+		if limit > comboCounts[keyCount] {
+			remaining[keyCount] = limit - comboCounts[keyCount]
+		}
+	}
+
+	var candidates []*keyAllocation
+	for pentuples := remaining[5]; pentuples >= 0; pentuples-- {
+		for trios := remaining[3]; trios >= 0; trios-- {
+			for duos := remaining[2]; duos >= 0; duos-- {
+				for singles := remaining[1]; singles >= 0; singles-- {
+					keyCount := 5*pentuples + 3*trios + 2*duos + singles
+					if keyCount > len(keys) {
+						continue
+					}
+
+					// flushes are unlimited, so the rest of the keys go in as many flushes as possible.
+					var sizes []int
+					for i := 0; i < (len(keys)-keyCount)/15; i++ {
+						sizes = append(sizes, 15)
+					}
+					sizes = appendSizes(sizes, 5, pentuples)
+					sizes = appendSizes(sizes, 3, trios)
+					sizes = appendSizes(sizes, 2, duos)
+					sizes = appendSizes(sizes, 1, singles)
+
+					allocation := greedyAllocation(keys, sizes)
+					allocation.points = allocationPoints(allocation.groups, len(keychainIds), len(superiorKeychainIds))
+					candidates = append(candidates, allocation)
+				}
+			}
+		}
+	}
+
+	// the allocations with the same points keep the order they were tried in.
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].points > candidates[j].points })
+	if len(candidates) > recommendationCandidates {
+		candidates = candidates[:recommendationCandidates]
+	}
+
+	var best *keyAllocation
+	for _, allocation := range candidates {
+		improveAllocation(allocation, len(keychainIds), len(superiorKeychainIds))
+		if best == nil || allocation.points > best.points+1e-9 {
+			best = allocation
+		}
+	}
+
+	subpools := []*models.RecommendedSubpool{}
+	if best == nil {
+		return subpools
+	}
+
+	points := make([]float64, len(best.groups))
+	for i, group := range best.groups {
+		points[i] = CalculateSubpoolPoints(group, nil, -1)
+	}
+	superior, keychain := assignKeychains(points, best.groups, len(keychainIds), len(superiorKeychainIds))
+
+	// the keychains and superior keychains with the lowest IDs go to the subpools with the most points.
+	nextKeychain, nextSuperiorKeychain := 0, 0
+	for _, i := range rankGroups(points) {
+		group := best.groups[i]
+		sort.SliceStable(group, func(a, b int) bool { return group[a].TokenID < group[b].TokenID })
+
+		subpool := &models.RecommendedSubpool{KeyIDs: []int{}, KeychainIDs: []int{}, SuperiorKeychainID: -1}
+		for _, key := range group {
+			subpool.KeyIDs = append(subpool.KeyIDs, key.TokenID)
+		}
+		if superior[i] {
+			subpool.SuperiorKeychainID = superiorKeychainIds[nextSuperiorKeychain]
+			nextSuperiorKeychain++
+		}
+		if keychain[i] {
+			keychainCount := 1
+			if len(group) == 15 {
+				keychainCount = 3
+			}
+			subpool.KeychainIDs = keychainIds[nextKeychain : nextKeychain+keychainCount]
+			nextKeychain += keychainCount
+		}
+
+		// the same breakdown as `GetTokenPreAddSubpoolData`.
+		luckAndLuckBoostSum := 0.0
+		for _, key := range group {
+			luckAndLuckBoostSum += (key.LuckTrait * key.LuckBoostTrait)
+		}
+		subpool.DetailedSubpoolPoints = &models.DetailedSubpoolPoints{
+			LuckAndLuckBoostSum: luckAndLuckBoostSum,
+			KeyCombo:            CalculateKeyCombo(group),
+			KeychainCombo:       CalculateKeychainCombo(subpool.KeychainIDs, subpool.SuperiorKeychainID),
+			ComboSum:            CalculateSubpoolPoints(group, subpool.KeychainIDs, subpool.SuperiorKeychainID),
+		}
+
+		subpools = append(subpools, subpool)
+	}
+
+	sort.SliceStable(subpools, func(i, j int) bool { return subpools[i].ComboSum > subpools[j].ComboSum })
+
+	return subpools
+}
+
+/*
+Splits `keys` into subpools with `sizes` keys each (in order), picking the keys of each subpool with `pickKeys`.
+*/
+func greedyAllocation(keys []*models.KOSSimplifiedMetadata, sizes []int) *keyAllocation {
+	// the keys with the highest luck (and luck boost) are picked first.
+	remaining := append([]*models.KOSSimplifiedMetadata{}, keys...)
+	sort.SliceStable(remaining, func(i, j int) bool { return keyLuck(remaining[i]) > keyLuck(remaining[j]) })
+
+	allocation := &keyAllocation{}
+	for _, size := range sizes {
+		group := pickKeys(remaining, size)
+		allocation.groups = append(allocation.groups, group)
+
+		picked := map[*models.KOSSimplifiedMetadata]bool{}
+		for _, key := range group {
+			picked[key] = true
+		}
+		var rest []*models.KOSSimplifiedMetadata
+		for _, key := range remaining {
+			if !picked[key] {
+				rest = append(rest, key)
+			}
+		}
+		remaining = rest
+	}
+	allocation.unused = remaining
+
+	return allocation
+}
+
+/*
+Picks `size` keys from `keys` (sorted by luck, highest first) for a subpool, preferring keys of the same house and type,
+then the same type and then the same house (the order of the key combo bonuses in `BaseKeyCombo`).
+Of the keys that match, the ones with the highest luck are picked.
+*/
+func pickKeys(keys []*models.KOSSimplifiedMetadata, size int) []*models.KOSSimplifiedMetadata {
+	matchers := []func(key *models.KOSSimplifiedMetadata) string{
+		func(key *models.KOSSimplifiedMetadata) string { return key.HouseTrait + "|" + key.TypeTrait },
+		func(key *models.KOSSimplifiedMetadata) string { return key.TypeTrait },
+		func(key *models.KOSSimplifiedMetadata) string { return key.HouseTrait },
+	}
+
+	for _, matcher := range matchers {
+		buckets := map[string][]*models.KOSSimplifiedMetadata{}
+		var names []string
+		for _, key := range keys {
+			name := matcher(key)
+			if _, ok := buckets[name]; !ok {
+				names = append(names, name)
+			}
+			buckets[name] = append(buckets[name], key)
+		}
+		sort.Strings(names)
+
+		var best []*models.KOSSimplifiedMetadata
+		bestLuck := 0.0
+		for _, name := range names {
+			if len(buckets[name]) < size {
+				continue
+			}
+			luck := 0.0
+			for _, key := range buckets[name][:size] {
+				luck += keyLuck(key)
+			}
+			if best == nil || luck > bestLuck {
+				best, bestLuck = buckets[name][:size], luck
+			}
+		}
+		if best != nil {
+			return append([]*models.KOSSimplifiedMetadata{}, best...)
+		}
+	}
+
+	return append([]*models.KOSSimplifiedMetadata{}, keys[:size]...)
+}
+
+/*
+Improves `allocation` by swapping keys between its subpools (and its unused keys) as long as a swap gives more points.
+*/
+func improveAllocation(allocation *keyAllocation, keychainCount, superiorKeychainCount int) {
+	// the unused keys are the last group and don't earn any points.
+	groups := append(append([][]*models.KOSSimplifiedMetadata{}, allocation.groups...), allocation.unused)
+	unused := len(groups) - 1
+
+	points := make([]float64, len(groups))
+	for i := 0; i < unused; i++ {
+		points[i] = CalculateSubpoolPoints(groups[i], nil, -1)
+	}
+
+	score := func() float64 {
+		return scoreAllocation(points[:unused], groups[:unused], keychainCount, superiorKeychainCount)
+	}
+	best := score()
+
+	for pass := 0; pass < maxRecommendationPasses; pass++ {
+		improved := false
+		for g := 0; g < unused; g++ {
+			for h := g + 1; h < len(groups); h++ {
+				for i := range groups[g] {
+					for j := range groups[h] {
+						if sameKeyTraits(groups[g][i], groups[h][j]) {
+							continue // swapping identical keys changes nothing.
+						}
+
+						previousG, previousH := points[g], points[h]
+						groups[g][i], groups[h][j] = groups[h][j], groups[g][i]
+						points[g] = CalculateSubpoolPoints(groups[g], nil, -1)
+						if h != unused {
+							points[h] = CalculateSubpoolPoints(groups[h], nil, -1)
+						}
+
+						if total := score(); total > best+1e-9 {
+							best = total
+							improved = true
+							continue
+						}
+
+						groups[g][i], groups[h][j] = groups[h][j], groups[g][i]
+						points[g], points[h] = previousG, previousH
+					}
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	allocation.groups, allocation.unused, allocation.points = groups[:unused], groups[unused], best
+}
+
+/*
+Returns the total points of the subpools with `groups` keys, with the keychains and superior keychains assigned by `assignKeychains`.
+*/
+func allocationPoints(groups [][]*models.KOSSimplifiedMetadata, keychainCount, superiorKeychainCount int) float64 {
+	points := make([]float64, len(groups))
+	for i, group := range groups {
+		points[i] = CalculateSubpoolPoints(group, nil, -1)
+	}
+
+	return scoreAllocation(points, groups, keychainCount, superiorKeychainCount)
+}
+
+func scoreAllocation(points []float64, groups [][]*models.KOSSimplifiedMetadata, keychainCount, superiorKeychainCount int) float64 {
+	superior, keychain := assignKeychains(points, groups, keychainCount, superiorKeychainCount)
+
+	total := 0.0
+	for i := range points {
+		switch {
+		case superior[i]:
+			total += points[i] * 1.5
+		case keychain[i]:
+			total += points[i] * 1.1
+		default:
+			total += points[i]
+		}
+	}
+
+	return total
+}
+
+/*
+Decides which of the subpools with `groups` keys and `points` points (without any keychain bonus) get a superior keychain
+and which get keychains, given `keychainCount` keychains and `superiorKeychainCount` superior keychains.
+
+A subpool gets either a superior keychain (1.5x) or keychains (1.1x), see `CheckKeysToStakeEligibility` and `CalculateKeychainCombo`.
+Flushes need 3 keychains and the other subpools need 1. The superior keychains go to the subpools with the most points,
+and the keychains to whichever of the remaining flushes and other subpools give the most points for the keychains available.
+*/
+func assignKeychains(points []float64, groups [][]*models.KOSSimplifiedMetadata, keychainCount, superiorKeychainCount int) (superior, keychain []bool) {
+	superior, keychain = make([]bool, len(points)), make([]bool, len(points))
+
+	var flushes, others []int
+	for _, i := range rankGroups(points) {
+		if superiorKeychainCount > 0 {
+			superior[i] = true
+			superiorKeychainCount--
+		} else if len(groups[i]) == 15 {
+			flushes = append(flushes, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+
+	// try giving keychains to the first 0, 1, 2, ... flushes and the rest to the other subpools.
+	bestFlushes, bestGain := 0, -1.0
+	for flushCount := 0; flushCount <= len(flushes) && 3*flushCount <= keychainCount; flushCount++ {
+		gain := 0.0
+		for _, i := range flushes[:flushCount] {
+			gain += points[i]
+		}
+		for _, i := range others[:minInt(keychainCount-3*flushCount, len(others))] {
+			gain += points[i]
+		}
+		if gain > bestGain+1e-9 {
+			bestFlushes, bestGain = flushCount, gain
+		}
+	}
+
+	for _, i := range flushes[:bestFlushes] {
+		keychain[i] = true
+	}
+	for _, i := range others[:minInt(keychainCount-3*bestFlushes, len(others))] {
+		keychain[i] = true
+	}
+
+	return superior, keychain
+}
+
+/*
+Returns the indexes of `points` from the most to the least points (the lower index first if they are equal).
+*/
+func rankGroups(points []float64) []int {
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return points[order[a]] > points[order[b]] })
+
+	return order
+}
+
+func appendSizes(sizes []int, size, count int) []int {
+	for i := 0; i < count; i++ {
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+func keyLuck(key *models.KOSSimplifiedMetadata) float64 {
+	return key.LuckTrait * key.LuckBoostTrait
+}
+
+func sameKeyTraits(a, b *models.KOSSimplifiedMetadata) bool {
+	return a.HouseTrait == b.HouseTrait && a.TypeTrait == b.TypeTrait && a.LuckTrait == b.LuckTrait && a.LuckBoostTrait == b.LuckBoostTrait
+}
+
+func sortedIDs(ids []int) []int {
+	sorted := append([]int{}, ids...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
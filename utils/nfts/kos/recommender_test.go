@@ -0,0 +1,125 @@
+package utils_kos
+
+import (
+	"reflect"
+	"testing"
+
+	"nbc-backend-api-v2/models"
+)
+
+func testKey(tokenId int, house, typ string, luck, luckBoost float64) *models.KOSSimplifiedMetadata {
+	return &models.KOSSimplifiedMetadata{TokenID: tokenId, HouseTrait: house, TypeTrait: typ, LuckTrait: luck, LuckBoostTrait: luckBoost}
+}
+
+/*
+A fixed inventory of keys of different houses, types and luck.
+*/
+func testInventory() []*models.KOSSimplifiedMetadata {
+	return []*models.KOSSimplifiedMetadata{
+		testKey(1, "Glory", "Brawler", 90, 1.2),
+		testKey(2, "Glory", "Brawler", 20, 1),
+		testKey(3, "Tranquility", "Brawler", 60, 1),
+		testKey(4, "Glory", "Hunter", 80, 1.1),
+		testKey(5, "Tranquility", "Hunter", 30, 1),
+		testKey(6, "Glory", "Hunter", 100, 1.5),
+		testKey(7, "Tranquility", "Brawler", 45, 1),
+	}
+}
+
+type expectedSubpool struct {
+	keyIds             []int
+	keychainIds        []int
+	superiorKeychainId int
+	comboSum           float64
+}
+
+func TestRecommendSubpools(t *testing.T) {
+	// four keys with the same traits, so every allocation of them ties with others.
+	identical := []*models.KOSSimplifiedMetadata{
+		testKey(40, "Glory", "Brawler", 50, 1),
+		testKey(12, "Glory", "Brawler", 50, 1),
+		testKey(33, "Glory", "Brawler", 50, 1),
+		testKey(7, "Glory", "Brawler", 50, 1),
+	}
+	reversed := []*models.KOSSimplifiedMetadata{identical[3], identical[2], identical[1], identical[0]}
+	// ties are broken by the token IDs of the keys, keychains and superior keychains, not by the order they're given in.
+	tieBreak := []expectedSubpool{
+		{keyIds: []int{7, 12, 33}, keychainIds: []int{}, superiorKeychainId: 71, comboSum: 706.11},
+		{keyIds: []int{40}, keychainIds: []int{4}, superiorKeychainId: -1, comboSum: 140.59},
+	}
+
+	tests := []struct {
+		name                string
+		keys                []*models.KOSSimplifiedMetadata
+		keychainIds         []int
+		superiorKeychainIds []int
+		comboCounts         map[int]int
+		want                []expectedSubpool
+	}{
+		{"tie break", identical, []int{9, 4}, []int{71}, nil, tieBreak},
+		{"tie break (reversed)", reversed, []int{4, 9}, []int{71}, nil, tieBreak},
+		{
+			name:        "mixed inventory",
+			keys:        testInventory(),
+			keychainIds: []int{20},
+			want: []expectedSubpool{
+				{keyIds: []int{4, 5, 6}, keychainIds: []int{20}, superiorKeychainId: -1, comboSum: 562.48},
+				{keyIds: []int{1, 2}, keychainIds: []int{}, superiorKeychainId: -1, comboSum: 301.82},
+				{keyIds: []int{3, 7}, keychainIds: []int{}, superiorKeychainId: -1, comboSum: 292.24},
+			},
+		},
+		{
+			// only a trio and pentuples are left, and the pentuple beats a trio and 2 unused keys.
+			name:        "singles and duos used up",
+			keys:        testInventory()[:5],
+			comboCounts: map[int]int{1: 2, 2: 2, 3: 1},
+			want: []expectedSubpool{
+				{keyIds: []int{1, 2, 3, 4, 5}, keychainIds: []int{}, superiorKeychainId: -1, comboSum: 589.68},
+			},
+		},
+		{
+			// only a single trio is left, so 4 keys stay unused.
+			name:        "only a trio left",
+			keys:        testInventory(),
+			comboCounts: map[int]int{1: 2, 2: 2, 3: 1, 5: 2},
+			want: []expectedSubpool{
+				{keyIds: []int{1, 4, 6}, keychainIds: []int{}, superiorKeychainId: -1, comboSum: 516.75},
+			},
+		},
+		{
+			name:        "every limit used up",
+			keys:        testInventory(),
+			comboCounts: map[int]int{1: 2, 2: 2, 3: 2, 5: 2},
+			want:        []expectedSubpool{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subpools := RecommendSubpools(test.keys, test.keychainIds, test.superiorKeychainIds, test.comboCounts)
+
+			got := make([]expectedSubpool, len(subpools))
+			for i, subpool := range subpools {
+				got[i] = expectedSubpool{subpool.KeyIDs, subpool.KeychainIDs, subpool.SuperiorKeychainID, subpool.ComboSum}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("RecommendSubpools() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecommendSubpoolsBeatsNaiveGrouping(t *testing.T) {
+	keys := testInventory()
+
+	// staking the keys in order of their token IDs, filling the biggest subpools first.
+	naive := CalculateSubpoolPoints(keys[:5], nil, -1) + CalculateSubpoolPoints(keys[5:6], nil, -1) + CalculateSubpoolPoints(keys[6:], nil, -1)
+
+	var total float64
+	for _, subpool := range RecommendSubpools(keys, nil, nil, nil) {
+		total += subpool.ComboSum
+	}
+	if total <= naive {
+		t.Errorf("recommended subpools have %.2f points, want more than the %.2f points of the naive grouping", total, naive)
+	}
+}
@@ -0,0 +1,353 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/models"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Simulates the staking pool with ID `stakingPoolId` if `wallet` added the subpools in `additions` and removed its active subpools
+with IDs `removals`, other stakers added `extraCompetitorPoints` points and (if not nil) the reward amount was `rewardAmount`.
+Nothing is written to the database.
+
+The added subpools go through the same checks as `AddSubpools` (except for ownership, so keys the wallet doesn't own yet can be simulated)
+and their points are calculated with `CalculateSubpoolPoints`. The token shares are calculated from the points of ALL subpools,
+the same way `CalcSubpoolTokenShare` does, and stakers are ranked the same way as in `GetPoolLeaderboard`.
+*/
+func SimulatePool(
+	collection *mongo.Collection,
+	stakingPoolId int,
+	wallet string,
+	additions []*models.SubpoolSpec,
+	removals []int,
+	extraCompetitorPoints float64,
+	rewardAmount *float64,
+) (*models.PoolSimulation, error) {
+	if collection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+
+	var stakingPool models.StakingPool
+	if err := collection.FindOne(context.Background(), bson.M{"stakingPoolID": stakingPoolId}).Decode(&stakingPool); err != nil {
+		return nil, poolLookupError(err, stakingPoolId)
+	}
+
+	// a wallet that hasn't staked yet has no subpools to remove (simulating must not create the staker).
+	stakersCollection := configs.GetCollections(configs.DB, "RHStakerData")
+	stakerObjId, err := GetStakerInstance(stakersCollection, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := simulatedRemovals(&stakingPool, stakerObjId, removals)
+	if err != nil {
+		return nil, err
+	}
+	keyIds, err := checkSimulatedAdditions(stakingPool.ActiveSubpools, removed, stakerObjId, additions)
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch the metadata of all keys at once.
+	metadataByID := make(map[int]*models.KOSSimplifiedMetadata, len(keyIds))
+	if len(keyIds) > 0 {
+		metadatas, err := FetchSimplifiedMetadataConcurrent(keyIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, metadata := range metadatas {
+			metadataByID[metadata.TokenID] = metadata
+		}
+	}
+	addedSubpools, err := simulatedSubpools(additions, metadataByID)
+	if err != nil {
+		return nil, err
+	}
+
+	// older subpools don't have the staker's wallet, so it's read from `RHStakerData`.
+	wallets, err := subpoolStakerWallets(stakersCollection, &stakingPool)
+	if err != nil {
+		return nil, err
+	}
+
+	return simulatePool(&stakingPool, wallet, stakerObjId, wallets, removed, addedSubpools, extraCompetitorPoints, rewardAmount), nil
+}
+
+/*
+Returns whether `subpool` belongs to the staker with object ID `stakerObjId` (nil if the wallet hasn't staked yet).
+*/
+func isStakers(subpool *models.StakingSubpool, stakerObjId *primitive.ObjectID) bool {
+	return stakerObjId != nil && subpool.Staker != nil && *subpool.Staker == *stakerObjId
+}
+
+/*
+Checks that every subpool ID in `removals` is an active subpool of `stakingPool` that belongs to the staker with object ID `stakerObjId`
+(only those can be removed, see `UnstakeFromSubpool`), returning them as a set.
+*/
+func simulatedRemovals(stakingPool *models.StakingPool, stakerObjId *primitive.ObjectID, removals []int) (map[int]bool, error) {
+	removed := map[int]bool{}
+	for _, subpoolId := range removals {
+		found := false
+		for _, subpool := range stakingPool.ActiveSubpools {
+			if subpool.SubpoolID != subpoolId {
+				continue
+			}
+			if !isStakers(subpool, stakerObjId) {
+				return nil, fmt.Errorf("%w: subpool %d", ErrNotSubpoolOwner, subpoolId)
+			}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: no active subpool with ID %d exists in staking pool %d", ErrSubpoolNotFound, subpoolId, stakingPool.StakingPoolID)
+		}
+		removed[subpoolId] = true
+	}
+
+	return removed, nil
+}
+
+/*
+Checks the subpools in `additions` the same way `AddSubpools` does, against the `activeSubpools` of the pool that aren't `removed`
+(the subpools of the staker with object ID `stakerObjId` count towards its combo limits). Returns the IDs of every added key.
+*/
+func checkSimulatedAdditions(
+	activeSubpools []*models.StakingSubpool,
+	removed map[int]bool,
+	stakerObjId *primitive.ObjectID,
+	additions []*models.SubpoolSpec,
+) ([]int, error) {
+	// the NFTs already staked and the wallet's subpools for each key count, without the removed subpools.
+	stakedKeys, stakedKeychains, stakedSuperiorKeychains := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	comboCounts := map[int]int{}
+	for _, subpool := range activeSubpools {
+		if removed[subpool.SubpoolID] && isStakers(subpool, stakerObjId) {
+			continue
+		}
+		for _, key := range subpool.StakedKeys {
+			stakedKeys[key.TokenID] = true
+		}
+		for _, keychainId := range subpool.StakedKeychainIDs {
+			stakedKeychains[keychainId] = true
+		}
+		stakedSuperiorKeychains[subpool.StakedSuperiorKeychainID] = true
+		if isStakers(subpool, stakerObjId) {
+			comboCounts[len(subpool.StakedKeys)]++
+		}
+	}
+
+	allKeyIds := []int{}
+	usedKeys, usedKeychains, usedSuperiorKeychains := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	for i, spec := range additions {
+		for _, keyId := range spec.KeyIDs {
+			if usedKeys[keyId] {
+				return nil, fmt.Errorf("%w: subpool %d: key %d", ErrDuplicateInBatch, i+1, keyId)
+			}
+			if stakedKeys[keyId] {
+				return nil, fmt.Errorf("%w: subpool %d: key %d is already staked", ErrAlreadyStaked, i+1, keyId)
+			}
+			usedKeys[keyId] = true
+			allKeyIds = append(allKeyIds, keyId)
+		}
+		for _, keychainId := range spec.KeychainIDs {
+			if keychainId == -1 {
+				continue
+			}
+			if usedKeychains[keychainId] {
+				return nil, fmt.Errorf("%w: subpool %d: keychain %d", ErrDuplicateInBatch, i+1, keychainId)
+			}
+			if stakedKeychains[keychainId] {
+				return nil, fmt.Errorf("%w: subpool %d: keychain %d has already been staked in another subpool for this staking pool", ErrAlreadyStaked, i+1, keychainId)
+			}
+			usedKeychains[keychainId] = true
+		}
+		if spec.SuperiorKeychainID != -1 {
+			if usedSuperiorKeychains[spec.SuperiorKeychainID] {
+				return nil, fmt.Errorf("%w: subpool %d: superior keychain %d", ErrDuplicateInBatch, i+1, spec.SuperiorKeychainID)
+			}
+			if stakedSuperiorKeychains[spec.SuperiorKeychainID] {
+				return nil, fmt.Errorf("%w: subpool %d: superior keychain %d has already been staked in another subpool for this staking pool", ErrAlreadyStaked, i+1, spec.SuperiorKeychainID)
+			}
+			usedSuperiorKeychains[spec.SuperiorKeychainID] = true
+		}
+
+		keyCount := len(spec.KeyIDs)
+		if limit, ok := subpoolComboLimits[keyCount]; ok && comboCounts[keyCount] >= limit {
+			return nil, fmt.Errorf("%w: subpool %d: at most %d subpools of %d keys are allowed", ErrComboLimitReached, i+1, limit, keyCount)
+		}
+		comboCounts[keyCount]++
+	}
+
+	return allKeyIds, nil
+}
+
+/*
+Checks the keys of the subpools in `additions` (with their metadata in `metadataByID`) and calculates the points of the subpools.
+*/
+func simulatedSubpools(additions []*models.SubpoolSpec, metadataByID map[int]*models.KOSSimplifiedMetadata) ([]*models.SimulatedSubpool, error) {
+	simulated := []*models.SimulatedSubpool{}
+	for i, spec := range additions {
+		var keys []*models.KOSSimplifiedMetadata
+		for _, keyId := range spec.KeyIDs {
+			keys = append(keys, metadataByID[keyId])
+		}
+		if err := CheckKeysToStakeEligibility(keys, spec.KeychainIDs, spec.SuperiorKeychainID); err != nil {
+			return nil, fmt.Errorf("subpool %d: %w", i+1, err)
+		}
+
+		keychainIds := spec.KeychainIDs
+		if keychainIds == nil {
+			keychainIds = []int{}
+		}
+
+		// the same breakdown as `GetTokenPreAddSubpoolData`.
+		luckAndLuckBoostSum := 0.0
+		for _, key := range keys {
+			luckAndLuckBoostSum += (key.LuckTrait * key.LuckBoostTrait)
+		}
+		simulated = append(simulated, &models.SimulatedSubpool{
+			KeyIDs:             spec.KeyIDs,
+			KeychainIDs:        keychainIds,
+			SuperiorKeychainID: spec.SuperiorKeychainID,
+			DetailedSubpoolPoints: &models.DetailedSubpoolPoints{
+				LuckAndLuckBoostSum: luckAndLuckBoostSum,
+				KeyCombo:            CalculateKeyCombo(keys),
+				KeychainCombo:       CalculateKeychainCombo(spec.KeychainIDs, spec.SuperiorKeychainID),
+				ComboSum:            CalculateSubpoolPoints(keys, spec.KeychainIDs, spec.SuperiorKeychainID),
+			},
+		})
+	}
+
+	return simulated, nil
+}
+
+/*
+Projects `stakingPool` after the staker with object ID `stakerObjId` (and wallet `wallet`) removed its `removed` subpools and added `addedSubpools`,
+other stakers added `extraCompetitorPoints` points and (if not nil) the reward amount was `rewardAmount`.
+`wallets` has the wallets of the stakers whose subpools don't have them (see `subpoolStakerWallets`).
+*/
+func simulatePool(
+	stakingPool *models.StakingPool,
+	wallet string,
+	stakerObjId *primitive.ObjectID,
+	wallets map[primitive.ObjectID]string,
+	removed map[int]bool,
+	addedSubpools []*models.SimulatedSubpool,
+	extraCompetitorPoints float64,
+	rewardAmount *float64,
+) *models.PoolSimulation {
+	simulation := &models.PoolSimulation{
+		StakingPoolID:         stakingPool.StakingPoolID,
+		RewardName:            stakingPool.Reward.Name,
+		RewardAmount:          stakingPool.Reward.Amount,
+		ExtraCompetitorPoints: extraCompetitorPoints,
+		Wallet:                strings.ToLower(wallet),
+		AddedSubpools:         addedSubpools,
+		RemovedSubpoolIDs:     []int{},
+		Distribution:          []*models.SimulatedStakerShare{},
+	}
+	if rewardAmount != nil {
+		simulation.RewardAmount = *rewardAmount
+	}
+	for subpoolId := range removed {
+		simulation.RemovedSubpoolIDs = append(simulation.RemovedSubpoolIDs, subpoolId)
+	}
+	sort.Ints(simulation.RemovedSubpoolIDs)
+
+	// the points of each staker (by object ID) from their unbanned subpools that aren't removed. banned subpools still count towards the total.
+	points := map[primitive.ObjectID]float64{}
+	stakerWallets := map[primitive.ObjectID]string{}
+	simulation.TotalPoints = extraCompetitorPoints
+	for _, subpool := range append(append([]*models.StakingSubpool{}, stakingPool.ActiveSubpools...), stakingPool.ClosedSubpools...) {
+		if removed[subpool.SubpoolID] && isStakers(subpool, stakerObjId) {
+			continue
+		}
+		simulation.TotalPoints += subpool.SubpoolPoints
+		if subpool.Banned || subpool.Staker == nil {
+			continue
+		}
+
+		points[*subpool.Staker] += subpool.SubpoolPoints
+		if subpool.StakerWallet != "" {
+			stakerWallets[*subpool.Staker] = strings.ToLower(subpool.StakerWallet)
+		} else if _, ok := stakerWallets[*subpool.Staker]; !ok {
+			stakerWallets[*subpool.Staker] = wallets[*subpool.Staker]
+		}
+	}
+
+	// the wallet's points after the changes.
+	if stakerObjId != nil {
+		simulation.WalletPoints = points[*stakerObjId]
+		delete(points, *stakerObjId)
+	}
+	addedPoints := 0.0
+	for _, subpool := range addedSubpools {
+		addedPoints += subpool.ComboSum
+	}
+	simulation.WalletPoints += addedPoints
+	simulation.TotalPoints += addedPoints
+
+	for id, stakerPoints := range points {
+		if stakerPoints > 0 {
+			simulation.Distribution = append(simulation.Distribution, &models.SimulatedStakerShare{Wallet: stakerWallets[id], Points: stakerPoints})
+		}
+	}
+	if simulation.WalletPoints > 0 {
+		simulation.Distribution = append(simulation.Distribution, &models.SimulatedStakerShare{Wallet: simulation.Wallet, Points: simulation.WalletPoints})
+	}
+
+	// stakers with the same points are ordered by wallet (see `GetPoolLeaderboard`).
+	sort.SliceStable(simulation.Distribution, func(i, j int) bool {
+		a, b := simulation.Distribution[i], simulation.Distribution[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Wallet < b.Wallet
+	})
+
+	isToken := strings.Contains(stakingPool.Reward.Name, "Token")
+	share := func(points, totalPoints float64) float64 {
+		if !isToken || totalPoints <= 0 {
+			return 0
+		}
+		return points / totalPoints * simulation.RewardAmount
+	}
+
+	for i, entry := range simulation.Distribution {
+		entry.Rank = i + 1
+		entry.TokenShare = math.Round(share(entry.Points, simulation.TotalPoints)*100) / 100
+		entry.Points = math.Round(entry.Points*100) / 100
+		if entry.Wallet == simulation.Wallet {
+			simulation.WalletRank = entry.Rank
+			simulation.WalletTokenShare = entry.TokenShare
+		}
+	}
+
+	// the marginal value of a subpool is the wallet's token share with it minus the wallet's token share without it.
+	walletShare := share(simulation.WalletPoints, simulation.TotalPoints)
+	addedKeys := 0
+	for _, subpool := range addedSubpools {
+		marginal := walletShare - share(simulation.WalletPoints-subpool.ComboSum, simulation.TotalPoints-subpool.ComboSum)
+		subpool.MarginalTokenShare = math.Round(marginal*100) / 100
+		subpool.MarginalValuePerKey = math.Round(marginal/float64(len(subpool.KeyIDs))*100) / 100
+		addedKeys += len(subpool.KeyIDs)
+	}
+	if addedKeys > 0 {
+		marginal := walletShare - share(simulation.WalletPoints-addedPoints, simulation.TotalPoints-addedPoints)
+		simulation.MarginalValuePerKey = math.Round(marginal/float64(addedKeys)*100) / 100
+	}
+
+	simulation.TotalPoints = math.Round(simulation.TotalPoints*100) / 100
+	simulation.WalletPoints = math.Round(simulation.WalletPoints*100) / 100
+
+	return simulation
+}
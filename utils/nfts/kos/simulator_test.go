@@ -0,0 +1,235 @@
+package utils_kos
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"nbc-backend-api-v2/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+A staking pool with 1000 points in total: two single-key subpools of the wallet (100 points each), one of another staker (300 points),
+one of an older staker without its wallet (200 points) and a banned closed subpool (300 points).
+*/
+func testSimulationPool() (stakingPool *models.StakingPool, staker, other, older primitive.ObjectID) {
+	staker, other, older = primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	stakingPool = &models.StakingPool{
+		StakingPoolID: 3,
+		Reward:        models.Reward{Name: "REC Token", Amount: 1000},
+		ActiveSubpools: []*models.StakingSubpool{
+			{SubpoolID: 1, Staker: &staker, StakerWallet: "0xAAAA", StakedKeys: []*models.KOSSimplifiedMetadata{{TokenID: 25}}, StakedKeychainIDs: []int{45}, StakedSuperiorKeychainID: -1, SubpoolPoints: 100},
+			{SubpoolID: 2, Staker: &staker, StakerWallet: "0xaaaa", StakedKeys: []*models.KOSSimplifiedMetadata{{TokenID: 26}}, StakedSuperiorKeychainID: -1, SubpoolPoints: 100},
+			{SubpoolID: 3, Staker: &other, StakerWallet: "0xbbbb", StakedKeys: []*models.KOSSimplifiedMetadata{{TokenID: 27}}, StakedSuperiorKeychainID: 7, SubpoolPoints: 300},
+			{SubpoolID: 4, Staker: &older, StakedKeys: []*models.KOSSimplifiedMetadata{{TokenID: 28}}, StakedSuperiorKeychainID: -1, SubpoolPoints: 200},
+		},
+		ClosedSubpools: []*models.StakingSubpool{
+			{SubpoolID: 5, Staker: &other, StakerWallet: "0xbbbb", StakedSuperiorKeychainID: -1, SubpoolPoints: 300, Banned: true},
+		},
+	}
+	return stakingPool, staker, other, older
+}
+
+type expectedShare struct {
+	rank       int
+	wallet     string
+	points     float64
+	tokenShare float64
+}
+
+func TestSimulatePool(t *testing.T) {
+	stakingPool, staker, _, older := testSimulationPool()
+	wallets := map[primitive.ObjectID]string{older: "0xcccc"}
+	newStaker := primitive.NewObjectID()
+	doubled := 2000.0
+
+	tests := []struct {
+		name                  string
+		wallet                string
+		stakerObjId           *primitive.ObjectID
+		removed               map[int]bool
+		added                 []float64 // the points of each added subpool of 3 keys
+		extraCompetitorPoints float64
+		rewardAmount          *float64
+		rewardName            string
+		totalPoints           float64
+		walletPoints          float64
+		walletRank            int
+		walletTokenShare      float64
+		marginalValuePerKey   float64
+		distribution          []expectedShare
+	}{
+		{
+			name: "no changes", wallet: "0xaaaa", stakerObjId: &staker,
+			totalPoints: 1000, walletPoints: 200, walletRank: 2, walletTokenShare: 200,
+			// the same points are ordered by wallet.
+			distribution: []expectedShare{{1, "0xbbbb", 300, 300}, {2, "0xaaaa", 200, 200}, {3, "0xcccc", 200, 200}},
+		},
+		{
+			name: "removed subpool", wallet: "0xaaaa", stakerObjId: &staker, removed: map[int]bool{2: true},
+			totalPoints: 900, walletPoints: 100, walletRank: 3, walletTokenShare: 111.11,
+			distribution: []expectedShare{{1, "0xbbbb", 300, 333.33}, {2, "0xcccc", 200, 222.22}, {3, "0xaaaa", 100, 111.11}},
+		},
+		{
+			name: "added subpool", wallet: "0xaaaa", stakerObjId: &staker, added: []float64{150},
+			totalPoints: 1150, walletPoints: 350, walletRank: 1, walletTokenShare: 304.35, marginalValuePerKey: 34.78,
+			distribution: []expectedShare{{1, "0xaaaa", 350, 304.35}, {2, "0xbbbb", 300, 260.87}, {3, "0xcccc", 200, 173.91}},
+		},
+		{
+			name: "extra competitor points and reward amount", wallet: "0xaaaa", stakerObjId: &staker, extraCompetitorPoints: 1000, rewardAmount: &doubled,
+			totalPoints: 2000, walletPoints: 200, walletRank: 2, walletTokenShare: 200,
+			distribution: []expectedShare{{1, "0xbbbb", 300, 300}, {2, "0xaaaa", 200, 200}, {3, "0xcccc", 200, 200}},
+		},
+		{
+			name: "wallet that hasn't staked", wallet: "0xDDDD", added: []float64{500},
+			totalPoints: 1500, walletPoints: 500, walletRank: 1, walletTokenShare: 333.33, marginalValuePerKey: 111.11,
+			distribution: []expectedShare{{1, "0xdddd", 500, 333.33}, {2, "0xbbbb", 300, 200}, {3, "0xaaaa", 200, 133.33}, {4, "0xcccc", 200, 133.33}},
+		},
+		{
+			name: "another staker's subpool isn't removed", wallet: "0xdddd", stakerObjId: &newStaker, removed: map[int]bool{3: true},
+			totalPoints: 1000, walletPoints: 0, walletRank: 0, walletTokenShare: 0,
+			distribution: []expectedShare{{1, "0xbbbb", 300, 300}, {2, "0xaaaa", 200, 200}, {3, "0xcccc", 200, 200}},
+		},
+		{
+			name: "non-token reward", wallet: "0xaaaa", stakerObjId: &staker, added: []float64{150}, rewardName: "Gold Pass",
+			totalPoints: 1150, walletPoints: 350, walletRank: 1,
+			distribution: []expectedShare{{1, "0xaaaa", 350, 0}, {2, "0xbbbb", 300, 0}, {3, "0xcccc", 200, 0}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := *stakingPool
+			if test.rewardName != "" {
+				pool.Reward.Name = test.rewardName
+			}
+			var added []*models.SimulatedSubpool
+			for _, points := range test.added {
+				added = append(added, &models.SimulatedSubpool{KeyIDs: []int{1, 2, 3}, DetailedSubpoolPoints: &models.DetailedSubpoolPoints{ComboSum: points}})
+			}
+
+			simulation := simulatePool(&pool, test.wallet, test.stakerObjId, wallets, test.removed, added, test.extraCompetitorPoints, test.rewardAmount)
+
+			got := []float64{simulation.TotalPoints, simulation.WalletPoints, float64(simulation.WalletRank), simulation.WalletTokenShare, simulation.MarginalValuePerKey}
+			want := []float64{test.totalPoints, test.walletPoints, float64(test.walletRank), test.walletTokenShare, test.marginalValuePerKey}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("total points, wallet points, rank, token share and marginal value per key = %v, want %v", got, want)
+			}
+
+			var distribution []expectedShare
+			for _, entry := range simulation.Distribution {
+				distribution = append(distribution, expectedShare{entry.Rank, entry.Wallet, entry.Points, entry.TokenShare})
+			}
+			if !reflect.DeepEqual(distribution, test.distribution) {
+				t.Errorf("distribution = %+v, want %+v", distribution, test.distribution)
+			}
+		})
+	}
+}
+
+func TestSimulatedRemovals(t *testing.T) {
+	stakingPool, staker, _, _ := testSimulationPool()
+	newStaker := primitive.NewObjectID()
+
+	tests := []struct {
+		name        string
+		stakerObjId *primitive.ObjectID
+		removals    []int
+		want        map[int]bool
+		err         error
+	}{
+		{"none", &staker, nil, map[int]bool{}, nil},
+		{"the wallet's subpools", &staker, []int{1, 2}, map[int]bool{1: true, 2: true}, nil},
+		{"another staker's subpool", &staker, []int{1, 3}, nil, ErrNotSubpoolOwner},
+		{"closed subpool", &staker, []int{5}, nil, ErrSubpoolNotFound},
+		{"unknown subpool", &staker, []int{99}, nil, ErrSubpoolNotFound},
+		{"wallet that hasn't staked", nil, []int{1}, nil, ErrNotSubpoolOwner},
+		{"staker without subpools", &newStaker, []int{1}, nil, ErrNotSubpoolOwner},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := simulatedRemovals(stakingPool, test.stakerObjId, test.removals)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("simulatedRemovals() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckSimulatedAdditions(t *testing.T) {
+	stakingPool, staker, _, _ := testSimulationPool()
+	subpool := func(superiorKeychainId int, keychainIds []int, keyIds ...int) *models.SubpoolSpec {
+		return &models.SubpoolSpec{KeyIDs: keyIds, KeychainIDs: keychainIds, SuperiorKeychainID: superiorKeychainId}
+	}
+
+	tests := []struct {
+		name      string
+		removed   map[int]bool
+		additions []*models.SubpoolSpec
+		want      []int
+		err       error
+	}{
+		{"none", nil, nil, []int{}, nil},
+		{"new keys", nil, []*models.SubpoolSpec{subpool(-1, []int{-1}, 1, 2), subpool(9, []int{46}, 3, 4, 5)}, []int{1, 2, 3, 4, 5}, nil},
+		{"staked key", nil, []*models.SubpoolSpec{subpool(-1, nil, 1, 27)}, nil, ErrAlreadyStaked},
+		{"key of a removed subpool", map[int]bool{2: true}, []*models.SubpoolSpec{subpool(-1, nil, 26, 29)}, []int{26, 29}, nil},
+		{"key of another staker's subpool", map[int]bool{3: true}, []*models.SubpoolSpec{subpool(-1, nil, 27, 29)}, nil, ErrAlreadyStaked},
+		{"same key twice", nil, []*models.SubpoolSpec{subpool(-1, nil, 1, 2), subpool(-1, nil, 2, 3)}, nil, ErrDuplicateInBatch},
+		{"staked keychain", nil, []*models.SubpoolSpec{subpool(-1, []int{45}, 1, 2)}, nil, ErrAlreadyStaked},
+		{"same keychain twice", nil, []*models.SubpoolSpec{subpool(-1, []int{46}, 1, 2), subpool(-1, []int{46}, 3, 4)}, nil, ErrDuplicateInBatch},
+		{"staked superior keychain", nil, []*models.SubpoolSpec{subpool(7, nil, 1, 2)}, nil, ErrAlreadyStaked},
+		{"same superior keychain twice", nil, []*models.SubpoolSpec{subpool(9, nil, 1, 2), subpool(9, nil, 3, 4)}, nil, ErrDuplicateInBatch},
+		// the wallet already has two subpools of a single key.
+		{"combo limit", nil, []*models.SubpoolSpec{subpool(-1, nil, 1)}, nil, ErrComboLimitReached},
+		{"combo limit after a removal", map[int]bool{1: true}, []*models.SubpoolSpec{subpool(-1, nil, 1)}, []int{1}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := checkSimulatedAdditions(stakingPool.ActiveSubpools, test.removed, &staker, test.additions)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("checkSimulatedAdditions() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSimulatedSubpools(t *testing.T) {
+	metadataByID := map[int]*models.KOSSimplifiedMetadata{}
+	for _, key := range testInventory() {
+		metadataByID[key.TokenID] = key
+	}
+
+	additions := []*models.SubpoolSpec{
+		{KeyIDs: []int{1, 4, 6}, SuperiorKeychainID: -1},
+		{KeyIDs: []int{3}, KeychainIDs: []int{45}, SuperiorKeychainID: -1},
+	}
+	simulated, err := simulatedSubpools(additions, metadataByID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, subpool := range simulated {
+		var keys []*models.KOSSimplifiedMetadata
+		for _, keyId := range additions[i].KeyIDs {
+			keys = append(keys, metadataByID[keyId])
+		}
+		// the same points as staking the subpool.
+		if want := CalculateSubpoolPoints(keys, additions[i].KeychainIDs, additions[i].SuperiorKeychainID); subpool.ComboSum != want {
+			t.Errorf("subpool %d: points = %v, want %v", i+1, subpool.ComboSum, want)
+		}
+		if subpool.KeychainIDs == nil {
+			t.Errorf("subpool %d: keychain IDs are nil instead of empty", i+1)
+		}
+	}
+
+	// four keys can't be staked together.
+	if _, err := simulatedSubpools([]*models.SubpoolSpec{{KeyIDs: []int{1, 2, 3, 4}, SuperiorKeychainID: -1}}, metadataByID); !errors.Is(err, ErrInvalidKeyCount) {
+		t.Errorf("error = %v, want %v", err, ErrInvalidKeyCount)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"sync"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -121,13 +120,7 @@ func GetTokenPreAddSubpoolData(
 /*
 Gets the detailed calculation for how the subpool's points were calculated.
 */
-func BacktrackSubpoolPoints(collection *mongo.Collection, stakingPoolId, subpoolId int) (*struct {
-	LuckAndLuckBoostSum float64 `json:"luckAndLuckBoostSum"`
-	AngelMultiplier     float64 `json:"angelMultiplier"`
-	KeyCombo            float64 `json:"keyCombo"`
-	KeychainCombo       float64 `json:"keychainCombo"`
-	TotalSubpoolPoints  float64 `json:"totalSubpoolPoints"`
-}, error) {
+func BacktrackSubpoolPoints(collection *mongo.Collection, stakingPoolId, subpoolId int) (*models.BacktrackedSubpoolPoints, error) {
 	if collection.Name() != "RHStakingPool" {
 		return nil, errors.New("collection must be RHStakingPool")
 	}
@@ -159,13 +152,7 @@ func BacktrackSubpoolPoints(collection *mongo.Collection, stakingPoolId, subpool
 		return nil, errors.New("subpool points do not match")
 	}
 
-	data := &struct {
-		LuckAndLuckBoostSum float64 `json:"luckAndLuckBoostSum"`
-		AngelMultiplier     float64 `json:"angelMultiplier"`
-		KeyCombo            float64 `json:"keyCombo"`
-		KeychainCombo       float64 `json:"keychainCombo"`
-		TotalSubpoolPoints  float64 `json:"totalSubpoolPoints"`
-	}{
+	data := &models.BacktrackedSubpoolPoints{
 		LuckAndLuckBoostSum: math.Round(luckAndLuckBoostSum*100) / 100,
 		AngelMultiplier:     angelMultiplier,
 		KeyCombo:            keyCombo,
@@ -176,6 +163,34 @@ func BacktrackSubpoolPoints(collection *mongo.Collection, stakingPoolId, subpool
 	return data, nil
 }
 
+/*
+Returns the points that the reward of `stakingPool` is shared between: the points of ALL its active and closed subpools (banned ones included).
+Every token share (claims, `CalcSubpoolTokenShare`, portfolios and leaderboards) is calculated from it, see `tokenShare`.
+*/
+func poolSharePoints(stakingPool *models.StakingPool) float64 {
+	var totalPoints float64
+	for _, subpool := range stakingPool.ActiveSubpools {
+		totalPoints += subpool.SubpoolPoints
+	}
+	for _, subpool := range stakingPool.ClosedSubpools {
+		totalPoints += subpool.SubpoolPoints
+	}
+
+	return totalPoints
+}
+
+/*
+Returns the share of a reward of `rewardAmount` for `points` out of `totalPoints` (see `poolSharePoints`), rounded to 2 decimal places.
+Returns 0 if there are no points to share the reward between.
+*/
+func tokenShare(points, totalPoints, rewardAmount float64) float64 {
+	if totalPoints <= 0 {
+		return 0
+	}
+
+	return math.Round(points/totalPoints*rewardAmount*100) / 100
+}
+
 /*
 ONLY FOR TOKEN REWARDS: calculate the reward share for a specific subpool of ID `subpoolId` for a staking pool with ID `stakingPoolId`.
 */
@@ -196,6 +211,10 @@ func CalcSubpoolTokenShare(collection *mongo.Collection, stakingPoolId, subpoolI
 		return 0, err
 	}
 
+	if totalSubpoolPoints <= 0 {
+		return 0, fmt.Errorf("%w: staking pool %d", ErrNoSubpoolPoints, stakingPoolId)
+	}
+
 	// fetch the total token reward for the staking pool
 	totalTokenReward, err := GetTotalTokenReward(collection, stakingPoolId)
 	if err != nil {
@@ -203,7 +222,7 @@ func CalcSubpoolTokenShare(collection *mongo.Collection, stakingPoolId, subpoolI
 	}
 
 	// calculate the reward share for a specific subpool of ID `subpoolId` for a specific staking pool with ID `stakingPoolId`
-	rewardShare := math.Round(accSubpoolPoints/totalSubpoolPoints*totalTokenReward*100) / 100
+	rewardShare := tokenShare(accSubpoolPoints, totalSubpoolPoints, totalTokenReward)
 
 	log.Printf("Reward share for subpool %d of staking pool %d: %f\n", subpoolId, stakingPoolId, rewardShare)
 
@@ -221,7 +240,7 @@ func CalcTotalTokenShare(collection *mongo.Collection, stakingPoolId int, staker
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	var stakingPool *models.StakingPool
 	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return 0, err
+		return 0, poolLookupError(err, stakingPoolId)
 	}
 
 	// get the staker's object ID
@@ -229,19 +248,9 @@ func CalcTotalTokenShare(collection *mongo.Collection, stakingPoolId int, staker
 	if err != nil {
 		return 0, err
 	}
+	// a staker that doesn't exist yet has no token share (calculating it must not create the staker).
 	if stakerObjectId == nil {
-		newStaker := &models.Staker{
-			Wallet: stakerWallet,
-		}
-
-		addStaker, err := configs.GetCollections(configs.DB, "RHStakerData").InsertOne(context.Background(), newStaker)
-		if err != nil {
-			return 0, err
-		}
-
-		stakerObjId_ := addStaker.InsertedID.(primitive.ObjectID)
-		stakerObjectId = &stakerObjId_
-		fmt.Println("staker not found calculating token share. adding new staker: ", newStaker)
+		return 0, nil
 	}
 
 	// find all active subpools belonging to the staker
@@ -320,17 +329,18 @@ func CalculateStakerTotalSubpoolPoints(collection *mongo.Collection, stakingPool
 		if err == mongo.ErrNoDocuments {
 			return 0, nil // staking pool not found
 		} else if err != nil {
-			return 0, err
+			return 0, poolLookupError(err, stakingPoolId)
 		}
 	}
 
 	// get the staker's object ID
 	stakerObjectId, err := GetStakerInstance(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
-	if err == mongo.ErrNoDocuments {
-		return 0, nil // staker not found
-	} else if err != nil {
+	if err != nil {
 		return 0, err
 	}
+	if stakerObjectId == nil {
+		return 0, nil // staker not found
+	}
 
 	// find all subpools belonging to the staker
 	var subpools []*models.StakingSubpool
@@ -426,12 +436,12 @@ func GetTotalTokenReward(collection *mongo.Collection, stakingPoolId int) (float
 
 	var stakingPool models.StakingPool
 	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return 0, err
+		return 0, poolLookupError(err, stakingPoolId)
 	}
 
 	reward := stakingPool.Reward
 	if !strings.Contains(reward.Name, "Token") {
-		return 0, errors.New("reward must be a token") // reward must be a token, or else there is no total token reward
+		return 0, ErrNonTokenReward // reward must be a token, or else there is no total token reward
 	}
 
 	return float64(reward.Amount), nil
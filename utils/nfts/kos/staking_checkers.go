@@ -7,19 +7,20 @@ import (
 	"log"
 	"nbc-backend-api-v2/configs"
 	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-
-	UtilsKeychain "nbc-backend-api-v2/utils/nfts/keychain"
-	UtilsSuperiorKeychain "nbc-backend-api-v2/utils/nfts/superior_keychain"
 )
 
 /*
 For ALL staking pools, this function will get the staker from each active subpool and check whether the keys, keychain and/or superior keychain that they staked in each subpool are still owned by them.
 if not, the subpool will automatically be removed from `ActiveSubpools` and moved to `ClosedSubpools`, change Banned to true and impose a BannedData instance on the staker.
+
+The staker of a subpool is always the vault, even if a delegate staked it, so ownership is checked on and bans are imposed against the vault.
 */
 func VerifyStakerOwnership(collection *mongo.Collection) error {
 	if collection.Name() != "RHStakingPool" {
@@ -40,88 +41,33 @@ func VerifyStakerOwnership(collection *mongo.Collection) error {
 			return err
 		}
 
-		// check whether the staker still owns the keys, keychain and/or superior keychain.
+		// check whether the staker still owns the keys, keychain and/or superior keychain (in every registered collection).
 		// if not, remove the subpool from `ActiveSubpools` and move it to `ClosedSubpools`, change Banned to true and impose a BannedData instance on the staker.
 		// if yes, do nothing.
-		if subpool.StakedKeys != nil {
-			var stakedKeyIds []int
-			// get the token IDs of the staked keys
-			for _, key := range subpool.StakedKeys {
-				stakedKeyIds = append(stakedKeyIds, key.TokenID)
-			}
-
-			// check whether the staker still owns the staked keys
-			stillOwned, err := VerifyOwnership(stakerData.Wallet, stakedKeyIds)
-			if err != nil {
-				return err
-			}
-			if !stillOwned {
-				// first, impose a BannedData instance on the staker.
-				err = UpdateStakerBannedData(configs.GetCollections(configs.DB, "RHStakerData"), subpool.Staker)
-				if err != nil {
-					return err
-				}
-				// then, ban the subpool.
-				err := BanSubpool(collection, subpool.StakingPoolID, subpool.SubpoolID)
-				if err != nil {
-					return err
-				}
-
-				log.Printf("verifying complete. staker does NOT own at least one of the staked keys anymore. ban imposed.")
-				return nil
-			}
+		var stakedKeyIds []int
+		// get the token IDs of the staked keys
+		for _, key := range subpool.StakedKeys {
+			stakedKeyIds = append(stakedKeyIds, key.TokenID)
 		}
 
-		// check whether the staker still owns the keychain
-		// if a keychain is staked, the keychain id is not -1 or 0.
-		if len(subpool.StakedKeychainIDs) > 0 {
-			stillOwned, err := UtilsKeychain.VerifyOwnership(stakerData.Wallet, subpool.StakedKeychainIDs)
+		stillOwned, err := VerifyStakedOwnership(stakerData.Wallet, SubpoolTokenIDs(stakedKeyIds, subpool.StakedKeychainIDs, subpool.StakedSuperiorKeychainID))
+		if err != nil {
+			return err
+		}
+		if !stillOwned {
+			// first, impose a BannedData instance on the staker.
+			err = UpdateStakerBannedData(configs.GetCollections(configs.DB, "RHStakerData"), subpool.Staker)
 			if err != nil {
 				return err
 			}
-
-			// if the staker does not own the keychain anymore, ban the subpool and impose a BannedData instance on the staker.
-			if !stillOwned {
-				// first, impose a BannedData instance on the staker.
-				err = UpdateStakerBannedData(configs.GetCollections(configs.DB, "RHStakerData"), subpool.Staker)
-				if err != nil {
-					return err
-				}
-				// then, ban the subpool.
-				err := BanSubpool(collection, subpool.StakingPoolID, subpool.SubpoolID)
-				if err != nil {
-					return err
-				}
-
-				log.Printf("verifying complete. staker does NOT own at least one of the staked keys anymore. ban imposed.")
-				return nil
-			}
-		}
-
-		// check whether the staker still owns the superior keychain
-		// if a superior keychain is staked, the superior keychain id is not -1 or 0.
-		if subpool.StakedSuperiorKeychainID != -1 && subpool.StakedSuperiorKeychainID != 0 {
-			stillOwned, err := UtilsSuperiorKeychain.VerifyOwnership(stakerData.Wallet, []int{subpool.StakedSuperiorKeychainID})
+			// then, ban the subpool.
+			err := BanSubpool(collection, subpool.StakingPoolID, subpool.SubpoolID)
 			if err != nil {
 				return err
 			}
 
-			// if the staker does not own the superior keychain anymore, ban the subpool and impose a BannedData instance on the staker.
-			if !stillOwned {
-				// first, impose a BannedData instance on the staker.
-				err = UpdateStakerBannedData(configs.GetCollections(configs.DB, "RHStakerData"), subpool.Staker)
-				if err != nil {
-					return err
-				}
-				// then, ban the subpool.
-				err := BanSubpool(collection, subpool.StakingPoolID, subpool.SubpoolID)
-				if err != nil {
-					return err
-				}
-
-				log.Printf("verifying complete. staker does NOT own at least one of the staked keys anymore. ban imposed.")
-				return nil
-			}
+			log.Printf("verifying complete. staker does NOT own at least one of the staked items anymore. ban imposed.")
+			return nil
 		}
 
 		log.Printf("verifying complete. staker still owns all staked items for subpool %d of staking pool %d", subpool.SubpoolID, subpool.StakingPoolID)
@@ -189,8 +135,10 @@ func CheckIfStakerBanned(collection *mongo.Collection, wallet string) (bool, err
 	var staker models.Staker
 	err := collection.FindOne(context.Background(), filter).Decode(&staker)
 
-	if err != nil {
-		return true, err //defaults to true if an error occurs
+	if err == mongo.ErrNoDocuments {
+		return false, nil // stakers that don't exist yet have never been banned
+	} else if err != nil {
+		return true, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err) //defaults to true if an error occurs
 	}
 
 	if staker.BannedData == nil {
@@ -217,7 +165,7 @@ func CheckPoolTimeAllowanceExceeded(collection *mongo.Collection, stakingPoolId
 	var stakingPool models.StakingPool
 	err := collection.FindOne(context.Background(), filter).Decode(&stakingPool)
 	if err != nil {
-		return true, err
+		return true, poolLookupError(err, stakingPoolId)
 	}
 
 	// check if the time now has passed the `StartTime` for the staking pool
@@ -239,12 +187,12 @@ Multiple checks to ensure the eligibility of a user to add a subpool with regard
 func CheckKeysToStakeEligibility(keys []*models.KOSSimplifiedMetadata, keychainIds []int, superiorKeychainId int) error {
 	// ensures that there is at least 1 key to stake.
 	if len(keys) == 0 || keys == nil {
-		return errors.New("must stake at least 1 key")
+		return fmt.Errorf("%w: must stake at least 1 key", ErrInvalidKeyCount)
 	}
 
 	// checks if there are 1, 2, 3, 5 or 15 keys to stake. no other amount is allowed.
 	if len(keys) != 1 && len(keys) != 2 && len(keys) != 3 && len(keys) != 5 && len(keys) != 15 {
-		return errors.New("must stake 1, 2, 3, 5 or 15 keys")
+		return ErrInvalidKeyCount
 	}
 
 	// if a user stakes 1, 2, 3 or 5 keys, they are only allowed to use EITHER 1 keychain or 1 superior keychain.
@@ -254,11 +202,11 @@ func CheckKeysToStakeEligibility(keys []*models.KOSSimplifiedMetadata, keychainI
 	if len(keys) == 15 {
 		// if there isn't either 0 or 3 keychain IDs, then there is an error.
 		if len(keychainIds) != 0 && len(keychainIds) != 3 {
-			return errors.New("invalid number of keychain IDs")
+			return fmt.Errorf("%w: invalid number of keychain IDs", ErrInvalidKeychainCombo)
 		}
 		for _, keychainId := range keychainIds {
 			if keychainId == 0 {
-				return errors.New("invalid keychain ID")
+				return fmt.Errorf("%w: invalid keychain ID", ErrInvalidKeychainCombo)
 			}
 		}
 		// if keychainId != -1 {
@@ -266,127 +214,39 @@ func CheckKeysToStakeEligibility(keys []*models.KOSSimplifiedMetadata, keychainI
 		// }
 
 		if superiorKeychainId == 0 {
-			return errors.New("invalid superior keychain ID")
+			return fmt.Errorf("%w: invalid superior keychain ID", ErrInvalidKeychainCombo)
 		}
 	} else {
 		if len(keychainIds) > 1 {
-			return errors.New("cannot stake more than 1 keychain in non-flush combo subpools.")
+			return fmt.Errorf("%w: cannot stake more than 1 keychain in non-flush combo subpools", ErrInvalidKeychainCombo)
 		}
 		if len(keychainIds) != 0 && superiorKeychainId != -1 {
-			return errors.New("cannot stake both keychain and superior keychain in one subpool. please use either a keychain or a superior keychain")
+			return fmt.Errorf("%w: cannot stake both keychain and superior keychain in one subpool. please use either a keychain or a superior keychain", ErrInvalidKeychainCombo)
 		}
 		for _, keychainId := range keychainIds {
 			if keychainId == 0 {
-				return errors.New("invalid keychain ID")
+				return fmt.Errorf("%w: invalid keychain ID", ErrInvalidKeychainCombo)
 			}
 		}
 		if superiorKeychainId == 0 {
-			return errors.New("invalid superior keychain ID")
+			return fmt.Errorf("%w: invalid superior keychain ID", ErrInvalidKeychainCombo)
 		}
 	}
 
 	return nil
 }
 
+// the max number of active subpools a staker can have in a staking pool for each key count.
+// flush combos (15 keys) are unlimited. used by every check of the combo limits (single, batch, recommendations and simulations).
+var subpoolComboLimits = map[int]int{1: 2, 2: 2, 3: 2, 5: 2}
+
 /*
-A user is allowed to (at the moment) create a limited amount of subpools per staking pool.
+A user is allowed to (at the moment) create a limited amount of subpools per staking pool (see `subpoolComboLimits`).
 For Flush combos (15 keys), they are allowed to create an unlimited amount of subpools.
-For Pentuple (5), they are only allowed to create 5.
-For Single, Duo and Trio combos (1, 2, 3), they are only allowed to create 3 each.
+For Pentuple, Single, Duo and Trio combos (5, 1, 2, 3), they are only allowed to create 2 each.
 */
 func CheckSubpoolComboEligibility(collection *mongo.Collection, stakingPoolId int, stakerWallet string, keys []*models.KOSSimplifiedMetadata) (bool, error) {
-	if collection.Name() != "RHStakingPool" {
-		return false, errors.New("invalid collection name") // defaults to false if an error occurs
-	}
-
-	// fetch the staker's object ID
-	stakerObjId, err := GetStakerInstance(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
-	if err != nil {
-		return false, err
-	}
-	// if staker object doesn't exist, we create a new staker instance.
-	if stakerObjId == nil {
-		newStaker := &models.Staker{
-			Wallet: stakerWallet,
-		}
-
-		addStaker, err := configs.GetCollections(configs.DB, "RHStakerData").InsertOne(context.Background(), newStaker)
-		if err != nil {
-			return false, err
-		}
-
-		stakerObjId_ := addStaker.InsertedID.(primitive.ObjectID)
-		stakerObjId = &stakerObjId_
-		fmt.Println("staker not found when checking combo. created new staker instance: ", newStaker)
-	}
-
-	filter := bson.M{"stakingPoolID": stakingPoolId}
-	var stakingPool models.StakingPool
-	err = collection.FindOne(context.Background(), filter).Decode(&stakingPool)
-	if err != nil {
-		return false, err
-	}
-
-	var stakersSubpools []*models.StakingSubpool
-
-	// fetch the active subpools only (we don't check for closed subpools here since subpool creations are automatically only allowed during the `EntryAllowance` period)
-	// in this case, any closed subpools are treated as if they don't exist at the first place.
-	for _, subpool := range stakingPool.ActiveSubpools {
-		// find all subpools that the staker has created
-		if subpool.Staker.Hex() == stakerObjId.Hex() {
-			stakersSubpools = append(stakersSubpools, subpool)
-		}
-	}
-
-	// the amount of each of these subpools that the staker has created for `stakingPoolId`.
-	// note that flush doesn't count here since it is unlimited.
-	var singleCombo, duoCombo, trioCombo, pentupleCombo int
-
-	for _, subpool := range stakersSubpools {
-		switch len(subpool.StakedKeys) {
-		case 1:
-			singleCombo++
-		case 2:
-			duoCombo++
-		case 3:
-			trioCombo++
-		case 5:
-			pentupleCombo++
-		}
-	}
-
-	// get the length of the `keys`
-	keysLength := len(keys)
-
-	if keysLength == 15 {
-		return true, nil // return true
-	} else if keysLength == 5 {
-		if pentupleCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else if keysLength == 3 {
-		if trioCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else if keysLength == 2 {
-		if duoCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else if keysLength == 1 {
-		if singleCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else {
-		return false, errors.New("invalid keys length")
-	}
+	return CheckSubpoolComboEligibilityAlt(collection, stakingPoolId, stakerWallet, len(keys))
 }
 
 /*
@@ -403,28 +263,13 @@ func CheckSubpoolComboEligibilityAlt(collection *mongo.Collection, stakingPoolId
 	if err != nil {
 		return false, err
 	}
-
-	// if staker object doesn't exist, we create a new staker instance.
-	if stakerObjId == nil {
-		newStaker := &models.Staker{
-			Wallet: stakerWallet,
-		}
-
-		addStaker, err := configs.GetCollections(configs.DB, "RHStakerData").InsertOne(context.Background(), newStaker)
-		if err != nil {
-			return false, err
-		}
-
-		stakerObjId_ := addStaker.InsertedID.(primitive.ObjectID)
-		stakerObjId = &stakerObjId_
-		fmt.Println("staker not found when checking combo. created new staker instance: ", newStaker)
-	}
+	// a staker that doesn't exist yet hasn't created any subpools (checking eligibility must not create the staker).
 
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	var stakingPool models.StakingPool
 	err = collection.FindOne(context.Background(), filter).Decode(&stakingPool)
 	if err != nil {
-		return false, err
+		return false, poolLookupError(err, stakingPoolId)
 	}
 
 	var stakersSubpools []*models.StakingSubpool
@@ -433,57 +278,29 @@ func CheckSubpoolComboEligibilityAlt(collection *mongo.Collection, stakingPoolId
 	// in this case, any closed subpools are treated as if they don't exist at the first place.
 	for _, subpool := range stakingPool.ActiveSubpools {
 		// find all subpools that the staker has created
-		if subpool.Staker.Hex() == stakerObjId.Hex() {
+		if stakerObjId != nil && subpool.Staker != nil && *subpool.Staker == *stakerObjId {
 			stakersSubpools = append(stakersSubpools, subpool)
 		}
 	}
 
-	// the amount of each of these subpools that the staker has created for `stakingPoolId`.
-	// note that flush doesn't count here since it is unlimited.
-	var singleCombo, duoCombo, trioCombo, pentupleCombo int
+	// flush combos are unlimited.
+	if keyCount == 15 {
+		return true, nil
+	}
+	limit, ok := subpoolComboLimits[keyCount]
+	if !ok {
+		return false, ErrInvalidKeyCount
+	}
 
+	// the amount of subpools with `keyCount` keys that the staker has created for `stakingPoolId`.
+	var comboCount int
 	for _, subpool := range stakersSubpools {
-		switch len(subpool.StakedKeys) {
-		case 1:
-			singleCombo++
-		case 2:
-			duoCombo++
-		case 3:
-			trioCombo++
-		case 5:
-			pentupleCombo++
+		if len(subpool.StakedKeys) == keyCount {
+			comboCount++
 		}
 	}
 
-	if keyCount == 15 {
-		return true, nil // return true
-	} else if keyCount == 5 {
-		if pentupleCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else if keyCount == 3 {
-		if trioCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else if keyCount == 2 {
-		if duoCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else if keyCount == 1 {
-		if singleCombo >= 2 {
-			return false, nil // return false
-		} else {
-			return true, nil // return true
-		}
-	} else {
-		return false, errors.New("invalid keys length")
-	}
+	return comboCount < limit, nil
 }
 
 /*
@@ -571,3 +388,19 @@ func CheckIfSuperiorKeychainStaked(collection *mongo.Collection, stakingPoolId,
 
 	return false, nil
 }
+
+/*
+Checks if the token with ID `tokenId` of the collection staked as `role` has already been staked in a specific staking pool.
+*/
+func CheckIfTokenStaked(collection *mongo.Collection, stakingPoolId int, role UtilsNFT.CollectionRole, tokenId int) (bool, error) {
+	switch role {
+	case UtilsNFT.RoleKey:
+		return CheckIfKeyStaked(collection, stakingPoolId, &models.KOSSimplifiedMetadata{TokenID: tokenId})
+	case UtilsNFT.RoleBooster:
+		return CheckIfKeychainStaked(collection, stakingPoolId, tokenId)
+	case UtilsNFT.RoleMultiplier:
+		return CheckIfSuperiorKeychainStaked(collection, stakingPoolId, tokenId)
+	default:
+		return true, fmt.Errorf("unknown staking role %q", role)
+	}
+}
@@ -7,14 +7,17 @@ import (
 	"log"
 	"math"
 	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/events"
 	"nbc-backend-api-v2/models"
 	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
 	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 /*
@@ -35,10 +38,10 @@ func AddStaker(collection *mongo.Collection, wallet string) (*primitive.ObjectID
 		return nil, errors.New("staker with the given wallet already exists")
 	}
 
-	// checks whether `wallet` has a valid checksum address
-	isValidChecksum := utils.ValidChecksum(wallet)
-	if !isValidChecksum {
-		return nil, errors.New("invalid checksum address")
+	// checks whether `wallet` is a valid address (either all lowercase or with a valid checksum)
+	isValidAddress := utils.ValidAddress(wallet)
+	if !isValidAddress {
+		return nil, fmt.Errorf("%w: invalid checksum address", utils.ErrInvalidRequest)
 	}
 
 	// create a new staker instance and add it to `RHStakerData`
@@ -53,7 +56,7 @@ func AddStaker(collection *mongo.Collection, wallet string) (*primitive.ObjectID
 
 	fmt.Println("Added staker with Object ID: ", result.InsertedID)
 
-	var stakerID primitive.ObjectID
+	stakerID := result.InsertedID.(primitive.ObjectID)
 
 	return &stakerID, nil
 }
@@ -66,20 +69,16 @@ func ClaimReward(collection *mongo.Collection, sessionToken, wallet string, stak
 		return errors.New("collection must be RHStakingPool")
 	}
 
-	// check for session token authorization
-	authorized, err := utils.CheckWalletMatchFromSessionToken(sessionToken, wallet)
-	if err != nil {
+	// check for session token authorization (the wallet itself or one of its delegates for claiming).
+	if err := AuthorizeStaker(sessionToken, wallet, models.DelegationScopeClaiming); err != nil {
 		return err
 	}
-	if !authorized {
-		return errors.New("wallet from session token does not match wallet given")
-	}
 
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 
 	var stakingPool models.StakingPool
 	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return err
+		return poolLookupError(err, stakingPoolId)
 	}
 
 	// reward claims only work if the subpool has been moved to `ClosedSubpools` (i.e. when the staking ends).
@@ -94,7 +93,7 @@ func ClaimReward(collection *mongo.Collection, sessionToken, wallet string, stak
 
 	// if `subpool` is nil, then the subpool with ID `subpoolId` does not exist in `ClosedSubpools`.
 	if subpool == nil {
-		return errors.New("subpool with given ID does not exist in ClosedSubpools")
+		return fmt.Errorf("%w: subpool with given ID does not exist in ClosedSubpools", ErrSubpoolNotFound)
 	}
 
 	// returns the Staker's Object ID for `wallet`. if it matches with the `Staker`'s Object ID in `RHStakingPool`, then the staker is valid.
@@ -102,27 +101,30 @@ func ClaimReward(collection *mongo.Collection, sessionToken, wallet string, stak
 	if err != nil {
 		return err
 	}
+	if stakerId == nil {
+		return ErrStakerNotFound
+	}
 
 	// convert the object IDs to hex strings since they are of type `primitive.ObjectID` struct.
 	if stakerId.Hex() != subpool.Staker.Hex() {
 		fmt.Println("Staker ID: ", stakerId)
 		fmt.Println("Subpool Staker ID: ", subpool.Staker)
-		return errors.New("staker for this subpool does not match wallet given")
+		return fmt.Errorf("%w: staker for this subpool does not match wallet given", ErrNotSubpoolOwner)
 	}
 
 	// checks if Subppol is banned
 	if subpool.Banned {
-		return errors.New("subpool is banned from claiming rewards")
+		return ErrSubpoolBanned
 	}
 
 	// checks if reward is claimable.
 	if !subpool.RewardClaimable {
-		return errors.New("rewards for subpool is not claimable")
+		return ErrRewardNotClaimable
 	}
 
 	// checks if reward has been claimed.
 	if subpool.RewardClaimed {
-		return errors.New("reward has already been claimed")
+		return ErrAlreadyClaimed
 	}
 
 	// get the staker's wallet
@@ -140,40 +142,62 @@ func ClaimReward(collection *mongo.Collection, sessionToken, wallet string, stak
 		if err != nil {
 			return err
 		}
-		// we now add the tokens to the staker's wallet.
-		err = AddTokensToStaker(configs.GetCollections(configs.DB, "RHStakerData"), stakingPool.Reward.Name, staker.Wallet, tokensToGive)
-		if err != nil {
-			return err
-		}
+		// we now add the tokens to the staker's wallet and update the `RewardClaimed` field to true.
+		// both are done in the same transaction so that a reward can't be given without being marked as claimed (or vice versa).
+		return events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+			err := addTokensToStaker(ctx, configs.GetCollections(configs.DB, "RHStakerData"), stakingPool.Reward.Name, staker.Wallet, tokensToGive)
+			if err != nil {
+				return nil, err
+			}
 
-		// we now update the `RewardClaimed` field to true.
-		err = UpdateRewardClaimedToTrue(collection, stakingPoolId, subpoolId)
-		if err != nil {
-			return err
-		}
+			err = updateRewardClaimedToTrue(ctx, collection, stakingPoolId, subpoolId)
+			if err != nil {
+				return nil, err
+			}
 
-		return nil
+			return []events.DomainEvent{events.RewardClaimedEvent{
+				StakingPoolID: stakingPoolId,
+				SubpoolID:     subpoolId,
+				StakerWallet:  strings.ToLower(staker.Wallet),
+				RewardName:    stakingPool.Reward.Name,
+				Amount:        tokensToGive,
+			}}, nil
+		})
 	} else {
 		// NOT IMPLEMENTED YET!
 		// this needs to be updated once non-token rewards are out.
-		return errors.New("non-token rewards are not implemented yet")
+		return fmt.Errorf("%w: non-token rewards are not implemented yet", ErrNonTokenReward)
 	}
 }
 
 /*
 Updates a specific subpool with ID `subpoolId` in Staking Pool `stakingPoolId`'s `RewardClaimed` field to true.
-This function does NOT check if `rewardClaimed` has been set to true already. this must be checked beforehand.
+Returns `ErrAlreadyClaimed` if it was already true, so that a reward credited in the same transaction is only credited once
+(even if two claims of the same subpool run concurrently).
 */
 func UpdateRewardClaimedToTrue(collection *mongo.Collection, stakingPoolId, subpoolId int) error {
-	filter := bson.M{"stakingPoolID": stakingPoolId, "closedSubpools.subpoolID": subpoolId}
+	return updateRewardClaimedToTrue(context.Background(), collection, stakingPoolId, subpoolId)
+}
+
+/*
+`UpdateRewardClaimedToTrue` using `ctx` for the update (e.g. to run it in a transaction).
+*/
+func updateRewardClaimedToTrue(ctx context.Context, collection *mongo.Collection, stakingPoolId, subpoolId int) error {
+	filter := bson.M{
+		"stakingPoolID":  stakingPoolId,
+		"closedSubpools": bson.M{"$elemMatch": bson.M{"subpoolID": subpoolId, "rewardClaimed": bson.M{"$ne": true}}},
+	}
 
 	update := bson.M{"$set": bson.M{"closedSubpools.$.rewardClaimed": true}}
 
 	// update the `RewardClaimed` field to true.
-	_, err := collection.UpdateOne(context.Background(), filter, update)
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return ErrAlreadyClaimed
+	}
 
 	log.Printf("Updated subpool ID %d's (from staking pool ID %d) rewardClaimed field to true", subpoolId, stakingPoolId)
 	return nil
@@ -206,10 +230,12 @@ func CloseSubpoolsOnStakeEnd(collection *mongo.Collection) error {
 		}
 
 		// update all subpools in `ActiveSubpools` and move them over to `ClosedSubpools`.
+		var closedSubpoolIds []int
 		for _, subpool := range stakingPool.ActiveSubpools {
 			subpool.ExitTime = now
 			subpool.RewardClaimable = true
 			stakingPool.ClosedSubpools = append(stakingPool.ClosedSubpools, subpool)
+			closedSubpoolIds = append(closedSubpoolIds, subpool.SubpoolID)
 
 			log.Printf("moved subpool %v from staking pool %v to closed subpools \n", subpool.SubpoolID, stakingPool.StakingPoolID)
 		}
@@ -218,7 +244,24 @@ func CloseSubpoolsOnStakeEnd(collection *mongo.Collection) error {
 		stakingPool.ActiveSubpools = nil
 
 		// update the StakingPool document.
-		result, err := collection.ReplaceOne(context.Background(), bson.M{"stakingPoolID": stakingPool.StakingPoolID}, stakingPool)
+		// this runs for every ended staking pool, so `PoolClosed` is only emitted the first time (when there were active subpools to close).
+		var result *mongo.UpdateResult
+		err := events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+			var err error
+			result, err = collection.ReplaceOne(ctx, bson.M{"stakingPoolID": stakingPool.StakingPoolID}, stakingPool)
+			if err != nil {
+				return nil, err
+			}
+
+			var domainEvents []events.DomainEvent
+			if len(closedSubpoolIds) > 0 {
+				domainEvents = append(domainEvents, events.PoolClosedEvent{
+					StakingPoolID:    stakingPool.StakingPoolID,
+					ClosedSubpoolIDs: closedSubpoolIds,
+				})
+			}
+			return appendTotalPointsChanged(ctx, collection, stakingPool.StakingPoolID, domainEvents)
+		})
 		if err != nil {
 			return err
 		}
@@ -237,12 +280,19 @@ func CloseSubpoolsOnStakeEnd(collection *mongo.Collection) error {
 AddTokensToStaker is a helper function that adds `tokensToGive` to the staker's wallet assuming all checks have passed beforehand.
 */
 func AddTokensToStaker(collection *mongo.Collection, rewardName, wallet string, tokensToGive float64) error {
+	return addTokensToStaker(context.Background(), collection, rewardName, wallet, tokensToGive)
+}
+
+/*
+`AddTokensToStaker` using `ctx` for the updates (e.g. to run them in a transaction).
+*/
+func addTokensToStaker(ctx context.Context, collection *mongo.Collection, rewardName, wallet string, tokensToGive float64) error {
 	if collection.Name() != "RHStakerData" {
 		return errors.New("collection must be RHStakerData")
 	}
 
-	// checks if `wallet` exists in RHStakerData. if it exists, return an error.
-	exists, err := CheckStakerExists(collection, wallet)
+	// gets the staker, creating it if it doesn't exist yet (with `ctx`, so that it's only created if the tokens are added).
+	stakerObjId, err := upsertStaker(ctx, collection, wallet)
 	if err != nil {
 		return err
 	}
@@ -251,99 +301,37 @@ func AddTokensToStaker(collection *mongo.Collection, rewardName, wallet string,
 
 	log.Printf("reward to give to staker: %v \n", reward)
 
-	if !exists {
-		log.Printf("staker with wallet %v does not exist. creating new staker... \n", wallet)
-
-		// add a new staker with the given wallet and add `tokensToGive` to the staker's wallet.
-		stakerObjId, err := AddStaker(collection, wallet)
-		if err != nil {
-			return err
-		}
-
-		// add the tokens to the staker's wallet.
-		filter := bson.M{"_id": stakerObjId}
-
-		// since the new staker doesn't have the EarnedRewards field, we need to update it.
-		update := bson.M{
-			"$set": bson.M{
-				"earnedRewards": []*models.Reward{reward},
-			},
-		}
-
-		_, err = collection.UpdateOne(context.Background(), filter, update)
-		if err != nil {
-			return err
-		}
-
-		return nil
-	} else { // if the staker already exists, we first do multiple checks.
-		log.Printf("staker with wallet %v already exists. updating staker... \n", wallet)
+	filter := bson.M{"_id": stakerObjId}
 
-		filter := bson.M{"wallet": wallet}
+	// check if the staker already has an existing `earnedRewards` field.
+	var staker models.Staker
+	if err := collection.FindOne(ctx, filter).Decode(&staker); err != nil {
+		return err
+	}
 
-		// check if the staker already has an existing `earnedRewards` field.
-		var staker models.Staker
-		err := collection.FindOne(context.Background(), filter).Decode(&staker)
-		if err != nil {
-			return err
+	// if the staker already has an existing `earnedRewards` field, we need to check if the reward with the same name already exists.
+	// if it does, we just add the amount to the existing reward.
+	// if it doesn't (after checking every reward), we just append the new reward to the existing `earnedRewards` field.
+	found := false
+	for _, earnedReward := range staker.EarnedRewards {
+		if earnedReward.Name == rewardName {
+			earnedReward.Amount += tokensToGive
+			found = true
+			break
 		}
+	}
+	if !found {
+		staker.EarnedRewards = append(staker.EarnedRewards, reward)
+	}
 
-		// if the staker already has an existing `earnedRewards` field, we just append the new reward to the existing `earnedRewards` field.
-		// otherwise, we need to update the `earnedRewards` field.
-		if staker.EarnedRewards == nil {
-			update := bson.M{
-				"$set": bson.M{
-					"earnedRewards": []*models.Reward{reward},
-				},
-			}
-
-			_, err = collection.UpdateOne(context.Background(), filter, update)
-			if err != nil {
-				return err
-			}
-
-			return nil
-		} else {
-			log.Printf("staker with wallet %v already has an existing `earnedRewards` field. checking if reward with name %v already exists... \n", wallet, rewardName)
-
-			// if the staker already has an existing `earnedRewards` field, we need to check if the reward with the same name already exists.
-			// if it does, we just add the amount to the existing reward.
-			// if it doesn't, we just append the new reward to the existing `earnedRewards` field.
-			for _, earnedReward := range staker.EarnedRewards {
-				if earnedReward.Name == rewardName {
-					earnedReward.Amount += tokensToGive
-
-					update := bson.M{
-						"$set": bson.M{
-							"earnedRewards": staker.EarnedRewards,
-						},
-					}
-
-					_, err = collection.UpdateOne(context.Background(), filter, update)
-					if err != nil {
-						return err
-					}
-
-					return nil
-				} else {
-					staker.EarnedRewards = append(staker.EarnedRewards, reward)
-
-					update := bson.M{
-						"$set": bson.M{
-							"earnedRewards": staker.EarnedRewards,
-						},
-					}
-
-					_, err = collection.UpdateOne(context.Background(), filter, update)
-					if err != nil {
-						return err
-					}
-
-					return nil
-				}
-			}
-		}
+	update := bson.M{
+		"$set": bson.M{
+			"earnedRewards": staker.EarnedRewards,
+		},
+	}
 
+	if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+		return err
 	}
 
 	log.Printf("Successfully added %f tokens to %s's wallet.\n", tokensToGive, wallet)
@@ -377,6 +365,32 @@ func GetStakerRECBalance(collection *mongo.Collection, wallet string) (float64,
 	return 0, nil
 }
 
+/*
+Returns the object ID of the staker with `wallet`, adding it to RHStakerData first if it doesn't exist yet.
+The staker is read and added with `ctx`, so that in a transaction it's only added if the transaction is committed.
+*/
+func upsertStaker(ctx context.Context, collection *mongo.Collection, wallet string) (*primitive.ObjectID, error) {
+	if collection.Name() != "RHStakerData" {
+		return nil, errors.New("collection must be RHStakerData")
+	}
+
+	// checks whether `wallet` is a valid address (either all lowercase or with a valid checksum)
+	if !utils.ValidAddress(wallet) {
+		return nil, fmt.Errorf("%w: invalid checksum address", utils.ErrInvalidRequest)
+	}
+
+	wallet = strings.ToLower(wallet)
+	update := bson.M{"$setOnInsert": bson.M{"wallet": wallet}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var staker models.Staker
+	if err := collection.FindOneAndUpdate(ctx, bson.M{"wallet": wallet}, update, opts).Decode(&staker); err != nil {
+		return nil, err
+	}
+
+	return &staker.ID, nil
+}
+
 /*
 Checks if a Staker instance with `wallet` exists in RHStakerData.
 */
@@ -494,14 +508,10 @@ func UnstakeFromSubpool(collection *mongo.Collection, sessionToken string, walle
 		return errors.New("collection must be RHStakingPool")
 	}
 
-	// check session token authorization
-	authorized, err := utils.CheckWalletMatchFromSessionToken(sessionToken, wallet)
-	if err != nil {
+	// check session token authorization (the wallet itself or one of its delegates for staking).
+	if err := AuthorizeStaker(sessionToken, wallet, models.DelegationScopeStaking); err != nil {
 		return err
 	}
-	if !authorized {
-		return errors.New("session token does not match wallet given")
-	}
 
 	/// check if `wallet` is the owner of the subpool.
 	// although anyone can input any `wallet`, the session token authorization will check if, firstly, the wallet matches the session token's wallet.
@@ -516,8 +526,11 @@ func UnstakeFromSubpool(collection *mongo.Collection, sessionToken string, walle
 	if err != nil {
 		return err
 	}
+	if stakerObjId == nil {
+		return ErrNotSubpoolOwner
+	}
 	if subpoolData.Staker.Hex() != stakerObjId.Hex() {
-		return errors.New("wallet specified is not the owner of the subpool")
+		return ErrNotSubpoolOwner
 	}
 
 	// check if now is past the startTime of the staking pool
@@ -526,7 +539,7 @@ func UnstakeFromSubpool(collection *mongo.Collection, sessionToken string, walle
 		return err
 	}
 	if time.Now().After(startTime) {
-		return errors.New("cannot unstake from a subpool after the staking pool has started")
+		return ErrUnstakeWindowClosed
 	}
 
 	filter := bson.M{"stakingPoolID": stakingPoolId}
@@ -536,15 +549,26 @@ func UnstakeFromSubpool(collection *mongo.Collection, sessionToken string, walle
 		},
 	}
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+	err = events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.ModifiedCount == 0 {
+			return nil, fmt.Errorf("%w: no subpool with ID %d exists in the ActiveSubpools of staking pool %d", ErrSubpoolNotFound, subpoolId, stakingPoolId)
+		}
+
+		return appendTotalPointsChanged(ctx, collection, stakingPoolId, []events.DomainEvent{events.SubpoolUnstakedEvent{
+			StakingPoolID: stakingPoolId,
+			SubpoolID:     subpoolId,
+			StakerWallet:  strings.ToLower(wallet),
+		}})
+	})
 	if err != nil {
 		return err
 	}
 
-	if result.ModifiedCount == 0 {
-		return fmt.Errorf("no subpool with ID %d exists in the ActiveSubpools of staking pool %d", subpoolId, stakingPoolId)
-	}
-
 	log.Printf("unstaked subpool %d from staking pool %d", subpoolId, stakingPoolId)
 	return nil
 }
@@ -566,7 +590,7 @@ func UnstakeFromStakingPool(collection *mongo.Collection, stakingPoolId int, sta
 		return err
 	}
 	if time.Now().After(startTime) {
-		return errors.New("cannot unstake from a subpool after the staking pool has started")
+		return ErrUnstakeWindowClosed
 	}
 
 	// get the object ID based on the wallet
@@ -582,15 +606,39 @@ func UnstakeFromStakingPool(collection *mongo.Collection, stakingPoolId int, sta
 		},
 	}
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+	err = events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+		// read the subpools in the transaction so that the events match exactly the subpools that are pulled.
+		var stakingPool models.StakingPool
+		if err := collection.FindOne(ctx, filter).Decode(&stakingPool); err != nil {
+			return nil, poolLookupError(err, stakingPoolId)
+		}
+
+		var domainEvents []events.DomainEvent
+		for _, subpool := range stakingPool.ActiveSubpools {
+			if stakerObjId != nil && subpool.Staker != nil && *subpool.Staker == *stakerObjId {
+				domainEvents = append(domainEvents, events.SubpoolUnstakedEvent{
+					StakingPoolID: stakingPoolId,
+					SubpoolID:     subpool.SubpoolID,
+					StakerWallet:  strings.ToLower(stakerWallet),
+				})
+			}
+		}
+
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.ModifiedCount == 0 {
+			return nil, fmt.Errorf("%w: no subpool exists for staker with wallet %s in staking pool %d", ErrSubpoolNotFound, stakerWallet, stakingPoolId)
+		}
+
+		return appendTotalPointsChanged(ctx, collection, stakingPoolId, domainEvents)
+	})
 	if err != nil {
 		return err
 	}
 
-	if result.ModifiedCount == 0 {
-		return fmt.Errorf("no subpool exists for staker with wallet %s in staking pool %d", stakerWallet, stakingPoolId)
-	}
-
 	log.Printf("unstaked all subpools for staker %s from staking pool %d", stakerWallet, stakingPoolId)
 	return nil
 }
@@ -607,7 +655,7 @@ func GetStakingPoolData(collection *mongo.Collection, stakingPoolId int) (*model
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	err := collection.FindOne(context.Background(), filter).Decode(&stakingPool)
 	if err != nil {
-		return nil, err
+		return nil, poolLookupError(err, stakingPoolId)
 	}
 	// if no document is found, then return nil but no error
 	if err == mongo.ErrNoDocuments {
@@ -629,7 +677,7 @@ func GetSubpoolData(collection *mongo.Collection, stakingPoolId, subpoolId int)
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	err := collection.FindOne(context.Background(), filter).Decode(&stakingPool)
 	if err != nil {
-		return nil, err
+		return nil, poolLookupError(err, stakingPoolId)
 	}
 
 	for _, sp := range stakingPool.ActiveSubpools {
@@ -643,7 +691,7 @@ func GetSubpoolData(collection *mongo.Collection, stakingPoolId, subpoolId int)
 		}
 	}
 
-	return nil, fmt.Errorf("no subpool with ID %d exists in staking pool %d", subpoolId, stakingPoolId)
+	return nil, fmt.Errorf("%w: no subpool with ID %d exists in staking pool %d", ErrSubpoolNotFound, subpoolId, stakingPoolId)
 }
 
 /*
@@ -676,21 +724,41 @@ func GetSubpoolDataAPI(collection *mongo.Collection, stakingPoolId, subpoolId in
 		nftData = append(nftData, modified)
 	}
 
+	keychains, err := UtilsNFT.CollectionByRole(UtilsNFT.RoleBooster)
+	if err != nil {
+		return nil, err
+	}
+	superiorKeychains, err := UtilsNFT.CollectionByRole(UtilsNFT.RoleMultiplier)
+	if err != nil {
+		return nil, err
+	}
+
+	// -1 means that no keychain/superior keychain is staked, so there's no metadata to fetch.
 	var keychainData []*models.NFTData
 	for _, keychainId := range subpoolData.StakedKeychainIDs {
 		data := &models.NFTData{
-			Name:     fmt.Sprint("Keychain #", keychainId),
-			ImageUrl: "https://realmhunter-kos.fra1.digitaloceanspaces.com/keychains/keychain.mp4",
-			TokenID:  keychainId,
+			Name:    fmt.Sprint(keychains.Name, " #", keychainId),
+			TokenID: keychainId,
+		}
+		if keychainId != -1 {
+			data, err = keychains.Metadata.FetchNFTData(keychainId)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		keychainData = append(keychainData, data)
 	}
 
 	superiorKeychainData := &models.NFTData{
-		Name:     fmt.Sprint("Superior Keychain #", subpoolData.StakedSuperiorKeychainID),
-		ImageUrl: "https://realmhunter-kos.fra1.digitaloceanspaces.com/keychains/superiorKeychain.mp4",
-		TokenID:  subpoolData.StakedSuperiorKeychainID,
+		Name:    fmt.Sprint(superiorKeychains.Name, " #", subpoolData.StakedSuperiorKeychainID),
+		TokenID: subpoolData.StakedSuperiorKeychainID,
+	}
+	if subpoolData.StakedSuperiorKeychainID != -1 {
+		superiorKeychainData, err = superiorKeychains.Metadata.FetchNFTData(subpoolData.StakedSuperiorKeychainID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &models.StakingSubpoolAlt{
@@ -720,7 +788,7 @@ func GetStartTimeOfStakingPool(collection *mongo.Collection, stakingPoolId int)
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	err := collection.FindOne(context.Background(), filter).Decode(&stakingPool)
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, poolLookupError(err, stakingPoolId)
 	}
 
 	return stakingPool.StartTime, nil
@@ -831,7 +899,7 @@ func BanSubpool(collection *mongo.Collection, stakingPoolId, subpoolId int) erro
 	var stakingPool models.StakingPool
 	err := collection.FindOne(context.Background(), filter).Decode(&stakingPool)
 	if err != nil {
-		return err
+		return poolLookupError(err, stakingPoolId)
 	}
 
 	for i, subpool := range stakingPool.ActiveSubpools {
@@ -845,8 +913,23 @@ func BanSubpool(collection *mongo.Collection, stakingPoolId, subpoolId int) erro
 			// add the subpool to the `ClosedSubpools` slice
 			stakingPool.ClosedSubpools = append(stakingPool.ClosedSubpools, subpool)
 
+			stakerWallet, err := subpoolStakerWallet(subpool)
+			if err != nil {
+				return err
+			}
+
 			// update the stakingpool in the database
-			_, err := collection.ReplaceOne(context.Background(), bson.M{"_id": stakingPool.ID}, stakingPool)
+			err = events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+				if _, err := collection.ReplaceOne(ctx, bson.M{"_id": stakingPool.ID}, stakingPool); err != nil {
+					return nil, err
+				}
+
+				return appendTotalPointsChanged(ctx, collection, stakingPoolId, []events.DomainEvent{events.SubpoolBannedEvent{
+					StakingPoolID: stakingPoolId,
+					SubpoolID:     subpoolId,
+					StakerWallet:  stakerWallet,
+				}})
+			})
 			if err != nil {
 				return err
 			}
@@ -856,7 +939,81 @@ func BanSubpool(collection *mongo.Collection, stakingPoolId, subpoolId int) erro
 		}
 	}
 
-	return errors.New("subpool not found in active subpools")
+	return fmt.Errorf("%w: subpool not found in active subpools", ErrSubpoolNotFound)
+}
+
+/*
+Publishes the phase transitions (entry opened, started, ended) of every staking pool that happened after `since` and up to `until`.
+Called periodically, since phase transitions only depend on the time and aren't caused by any write.
+
+Each transition is written to the outbox once, by the first instance that records it in the pool's `PublishedPhases`,
+so that the clients of every instance receive it (see `events.StartPoolStream`) exactly once.
+*/
+func PublishPoolPhaseTransitions(collection *mongo.Collection, since, until time.Time) error {
+	if collection.Name() != "RHStakingPool" {
+		return errors.New("collection must be RHStakingPool")
+	}
+
+	window := bson.M{"$gt": since, "$lte": until}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"entryAllowance": window},
+		bson.M{"startTime": window},
+		bson.M{"endTime": window},
+	}}
+
+	cursor, err := collection.Find(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var stakingPool models.StakingPool
+		if err := cursor.Decode(&stakingPool); err != nil {
+			return err
+		}
+
+		// publish in chronological order, in case more than one transition happened in the same window.
+		transitions := []struct {
+			phase events.PoolEventType
+			time  time.Time
+		}{
+			{events.PoolEntryOpened, stakingPool.EntryAllowance},
+			{events.PoolStarted, stakingPool.StartTime},
+			{events.PoolEnded, stakingPool.EndTime},
+		}
+		for _, transition := range transitions {
+			if !transition.time.After(since) || transition.time.After(until) {
+				continue
+			}
+
+			err := events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+				result, err := collection.UpdateOne(
+					ctx,
+					bson.M{"_id": stakingPool.ID, "publishedPhases": bson.M{"$ne": transition.phase}},
+					bson.M{"$addToSet": bson.M{"publishedPhases": transition.phase}},
+				)
+				if err != nil {
+					return nil, err
+				}
+
+				// another instance already published it.
+				if result.ModifiedCount == 0 {
+					return nil, nil
+				}
+				return []events.DomainEvent{events.PoolPhaseChangedEvent{
+					StakingPoolID: stakingPool.StakingPoolID,
+					Phase:         transition.phase,
+					Time:          transition.time,
+				}}, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return cursor.Err()
 }
 
 /*
@@ -878,7 +1035,7 @@ func GetAccSubpoolPoints(collection *mongo.Collection, stakingPoolId, subpoolId
 
 	var stakingPool models.StakingPool
 	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return 0, err
+		return 0, poolLookupError(err, stakingPoolId)
 	}
 
 	var subpoolPoints float64
@@ -917,8 +1074,7 @@ func GetTotalSubpoolPoints(collection *mongo.Collection, stakingPoolId int) (flo
 		}},
 	}
 
-	var activeSubpools []*models.StakingSubpool
-	var closedSubpools []*models.StakingSubpool
+	var totalSubpoolPoints float64
 
 	cursor, err := collection.Find(context.Background(), filter)
 	if err != nil {
@@ -931,18 +1087,12 @@ func GetTotalSubpoolPoints(collection *mongo.Collection, stakingPoolId int) (flo
 		if err := cursor.Decode(&stakingPool); err != nil {
 			return 0, err
 		}
-		activeSubpools = append(activeSubpools, stakingPool.ActiveSubpools...)
-		closedSubpools = append(closedSubpools, stakingPool.ClosedSubpools...)
+		totalSubpoolPoints += poolSharePoints(&stakingPool)
 	}
 	if err := cursor.Err(); err != nil {
 		return 0, err
 	}
 
-	var totalSubpoolPoints float64
-	for _, subpool := range append(activeSubpools, closedSubpools...) {
-		totalSubpoolPoints += subpool.SubpoolPoints
-	}
-
 	return totalSubpoolPoints, nil
 }
 
@@ -976,8 +1126,11 @@ func GetAllStakingPools(collection *mongo.Collection) ([]*models.StakingPool, er
 	return stakingPools, nil
 }
 
+// how long after a staking pool's `EndTime` the rewards of its subpools can be claimed.
+const RewardClaimWindow = 48 * time.Hour
+
 /*
-Removes expired unclaimable subpools (48 hours after the staking pool of the subpool's end time).
+Removes expired unclaimable subpools (`RewardClaimWindow` after the staking pool of the subpool's end time).
 */
 func RemoveExpiredUnclaimableSubpools(collection *mongo.Collection) error {
 	if collection.Name() != "RHStakingPool" {
@@ -986,7 +1139,7 @@ func RemoveExpiredUnclaimableSubpools(collection *mongo.Collection) error {
 
 	currentTime := time.Now()
 	// find all staking pools that are 2 days or older (for the end time)
-	filter := bson.M{"endTime": bson.M{"$lte": currentTime.Add(-2 * 24 * time.Hour)}}
+	filter := bson.M{"endTime": bson.M{"$lte": currentTime.Add(-RewardClaimWindow)}}
 	cursor, err := collection.Find(context.Background(), filter)
 	if err != nil {
 		return err
@@ -1003,11 +1156,31 @@ func RemoveExpiredUnclaimableSubpools(collection *mongo.Collection) error {
 		for _, subpool := range stakingPool.ClosedSubpools {
 			// change rewardclaimable to false for each subpool
 			if subpool.RewardClaimable {
-				_, err := collection.UpdateOne(
-					context.Background(),
-					bson.M{"_id": stakingPool.ID, "closedSubpools.subpoolID": subpool.SubpoolID},
-					bson.M{"$set": bson.M{"closedSubpools.$.rewardClaimable": false}},
-				)
+				stakerWallet, err := subpoolStakerWallet(subpool)
+				if err != nil {
+					return err
+				}
+
+				err = events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+					_, err := collection.UpdateOne(
+						ctx,
+						bson.M{"_id": stakingPool.ID, "closedSubpools.subpoolID": subpool.SubpoolID},
+						bson.M{"$set": bson.M{"closedSubpools.$.rewardClaimable": false}},
+					)
+					if err != nil {
+						return nil, err
+					}
+
+					// claimed rewards stay claimable (see `ClaimReward`), so only unclaimed rewards actually expire.
+					if subpool.RewardClaimed || subpool.Banned {
+						return nil, nil
+					}
+					return []events.DomainEvent{events.RewardExpiredEvent{
+						StakingPoolID: stakingPool.StakingPoolID,
+						SubpoolID:     subpool.SubpoolID,
+						StakerWallet:  stakerWallet,
+					}}, nil
+				})
 				if err != nil {
 					return err
 				}
@@ -1025,6 +1198,7 @@ func RemoveExpiredUnclaimableSubpools(collection *mongo.Collection) error {
 
 /*
 Updates the `TotalYieldPoints` field across all staking pools.
+The writes that change the subpools of a pool already update it (see `appendTotalPointsChanged`), so this only fixes totals that drifted.
 */
 func UpdateTotalYieldPoints(collection *mongo.Collection) error {
 	// gets all staking pools
@@ -1050,16 +1224,10 @@ func UpdateTotalYieldPoints(collection *mongo.Collection) error {
 	}
 
 	for _, stakingPool := range stakingPools {
-		var totalYieldPoints float64
-		for _, subpool := range stakingPool.ActiveSubpools {
-			totalYieldPoints += subpool.SubpoolPoints
-		}
-		for _, subpool := range stakingPool.ClosedSubpools {
-			totalYieldPoints += subpool.SubpoolPoints
-		}
-
-		// update the total yield points for this staking pool
-		_, err := collection.UpdateOne(context.Background(), bson.D{{"stakingPoolID", stakingPool.StakingPoolID}}, bson.D{{"$set", bson.D{{"totalYieldPoints", math.Round(totalYieldPoints*100) / 100}}}})
+		stakingPoolId := stakingPool.StakingPoolID
+		err := events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+			return appendTotalPointsChanged(ctx, collection, stakingPoolId, nil)
+		})
 		if err != nil {
 			return err
 		}
@@ -1069,6 +1237,36 @@ func UpdateTotalYieldPoints(collection *mongo.Collection) error {
 	return nil
 }
 
+/*
+Returns the total yield points of `stakingPool` (the points its reward is shared between, see `poolSharePoints`), rounded to 2 decimal places.
+*/
+func totalYieldPoints(stakingPool *models.StakingPool) float64 {
+	return math.Round(poolSharePoints(stakingPool)*100) / 100
+}
+
+/*
+Updates the `TotalYieldPoints` of the staking pool with ID `stakingPoolId` in the transaction of `ctx` (after its subpools were written),
+and appends the `TotalPointsChanged` event to `domainEvents` if they changed. Every write that changes the subpools of a pool returns its events through here.
+*/
+func appendTotalPointsChanged(ctx mongo.SessionContext, collection *mongo.Collection, stakingPoolId int, domainEvents []events.DomainEvent) ([]events.DomainEvent, error) {
+	filter := bson.M{"stakingPoolID": stakingPoolId}
+
+	var stakingPool models.StakingPool
+	if err := collection.FindOne(ctx, filter).Decode(&stakingPool); err != nil {
+		return nil, poolLookupError(err, stakingPoolId)
+	}
+
+	total := totalYieldPoints(&stakingPool)
+	if total == stakingPool.TotalYieldPoints {
+		return domainEvents, nil
+	}
+	if _, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totalYieldPoints": total}}); err != nil {
+		return nil, err
+	}
+
+	return append(domainEvents, events.TotalPointsChangedEvent{StakingPoolID: stakingPoolId, TotalYieldPoints: total}), nil
+}
+
 /*
 Gets all active subpools from each staking pool in `RHStakingPool` and returns them as a slice of `StakingSubpool` instances.
 */
@@ -1175,13 +1373,105 @@ func GetStakerFromObjID(collection *mongo.Collection, stakerObjId *primitive.Obj
 
 	var staker models.Staker
 	err := collection.FindOne(context.Background(), bson.M{"_id": stakerObjId}).Decode(&staker)
-	if err != nil {
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrStakerNotFound
+	} else if err != nil {
 		return nil, err
 	}
 
 	return &staker, nil
 }
 
+/*
+Returns the (lowercase) wallet of the staker that owns `subpool`.
+Subpools added before the wallet was stored on the subpool only have the staker's object ID, so the staker is looked up instead.
+*/
+func subpoolStakerWallet(subpool *models.StakingSubpool) (string, error) {
+	if subpool.StakerWallet != "" {
+		return strings.ToLower(subpool.StakerWallet), nil
+	}
+	if subpool.Staker == nil {
+		return "", nil
+	}
+
+	staker, err := GetStakerFromObjID(configs.GetCollections(configs.DB, "RHStakerData"), subpool.Staker)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(staker.Wallet), nil
+}
+
+/*
+Gets Staker Data from `RHStakerData` collection using the staker's wallet.
+*/
+func GetStakerFromWallet(collection *mongo.Collection, wallet string) (*models.Staker, error) {
+	if collection.Name() != "RHStakerData" {
+		return nil, errors.New("Collection must be RHStakerData")
+	}
+
+	var staker models.Staker
+	err := collection.FindOne(context.Background(), bson.M{"wallet": strings.ToLower(wallet)}).Decode(&staker)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrStakerNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return &staker, nil
+}
+
+/*
+Gets the Staker Data of each wallet in `wallets` from `RHStakerData` in a single query.
+Wallets that don't belong to a staker are simply left out of the result.
+*/
+func GetStakersFromWallets(collection *mongo.Collection, wallets []string) ([]*models.Staker, error) {
+	if collection.Name() != "RHStakerData" {
+		return nil, errors.New("Collection must be RHStakerData")
+	}
+
+	lowercased := make([]string, len(wallets))
+	for i, wallet := range wallets {
+		lowercased[i] = strings.ToLower(wallet)
+	}
+
+	cursor, err := collection.Find(context.Background(), bson.M{"wallet": bson.M{"$in": lowercased}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	var stakers []*models.Staker
+	if err := cursor.All(context.Background(), &stakers); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return stakers, nil
+}
+
+/*
+Gets the Staker Data of each object ID in `stakerObjIds` from `RHStakerData` in a single query.
+Object IDs that don't belong to a staker are simply left out of the result.
+*/
+func GetStakersFromObjIDs(collection *mongo.Collection, stakerObjIds []primitive.ObjectID) ([]*models.Staker, error) {
+	if collection.Name() != "RHStakerData" {
+		return nil, errors.New("Collection must be RHStakerData")
+	}
+
+	cursor, err := collection.Find(context.Background(), bson.M{"_id": bson.M{"$in": stakerObjIds}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	var stakers []*models.Staker
+	if err := cursor.All(context.Background(), &stakers); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return stakers, nil
+}
+
 /*
 Gets all stakers from all active subpools in `RHStakingPool` and returns them as a slice of `Staker` instances.
 */
@@ -1234,7 +1524,7 @@ func GetAllStakedKeyIDs(collection *mongo.Collection, stakingPoolId int) ([]int,
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	var stakingPool *models.StakingPool
 	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return nil, err
+		return nil, poolLookupError(err, stakingPoolId)
 	}
 
 	var stakedKeyIDs []int
@@ -1259,7 +1549,7 @@ func GetAllStakedKeychainIDs(collection *mongo.Collection, stakingPoolId int) ([
 	filter := bson.M{"stakingPoolID": stakingPoolId}
 	var stakingPool *models.StakingPool
 	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return nil, err
+		return nil, poolLookupError(err, stakingPoolId)
 	}
 
 	var stakedKeychainIDs []int
@@ -1344,7 +1634,23 @@ func AddStakingPool(collection *mongo.Collection, rewardName string, rewardAmoun
 	}
 
 	// insert the new staking pool into the database
-	result, err := collection.InsertOne(context.Background(), pool)
+	var result *mongo.InsertOneResult
+	err = events.WithOutbox(collection.Database(), func(ctx mongo.SessionContext) ([]events.DomainEvent, error) {
+		var err error
+		result, err = collection.InsertOne(ctx, pool)
+		if err != nil {
+			return nil, err
+		}
+
+		return []events.DomainEvent{events.StakingPoolCreatedEvent{
+			StakingPoolID:  pool.StakingPoolID,
+			RewardName:     pool.Reward.Name,
+			RewardAmount:   pool.Reward.Amount,
+			EntryAllowance: pool.EntryAllowance,
+			StartTime:      pool.StartTime,
+			EndTime:        pool.EndTime,
+		}}, nil
+	})
 	if err != nil {
 		return err
 	}
@@ -1358,11 +1664,13 @@ func AddStakingPool(collection *mongo.Collection, rewardName string, rewardAmoun
 Adds a subpool to a staking pool. Called when a user stakes their keys (and keychains/superior keychains if applicable).
 Every time a user stakes, it counts as a new subpool. If a user has 10 keys and stakes 5 and 5, then there are 2 subpools, each with 5 keys staked.
 
+It's staked as a batch of one subpool (see `AddSubpools`), so that the checks, the subpool ID and the write happen in the same transaction.
+
 	`collection` the collection to add the subpool to (must be RHStakingPool)
 	`sessionToken` the session token of the user staking, to confirm authorization of adding the subpool.
 	`stakingPoolId` the main staking pool ID (to add the subpool instance into)
 	`stakerWallet` the staker's wallet to check against `RHStakerData`
-	`keys` the key IDs staked
+	`keyIds` the key IDs staked
 	`keychainIds` the keychain IDs staked (if applicable, otherwise nil)`
 	`superiorKeychainId` the superior keychain ID staked
 */
@@ -1371,166 +1679,13 @@ func AddSubpool(
 	sessionToken string,
 	stakingPoolId int,
 	stakerWallet string,
-	keys []*models.KOSSimplifiedMetadata,
+	keyIds []int,
 	keychainIds []int,
 	superiorKeychainId int,
 ) error {
-	// collection must be RHStakingPool.
-	if collection.Name() != "RHStakingPool" {
-		return errors.New("collection must be RHStakingPool")
-	}
-
-	// check for session token authorization.
-	authorized, err := utils.CheckWalletMatchFromSessionToken(sessionToken, stakerWallet)
-	if err != nil {
-		return err
-	}
-	if !authorized {
-		return errors.New("session token does not match staker wallet")
-	}
-
-	// check if time is within stake time allowance.
-	timeExceeded, err := CheckPoolTimeAllowanceExceeded(collection, stakingPoolId)
-	if err != nil {
-		return err
-	}
-	if timeExceeded {
-		return errors.New("time allowance for this staking pool has passed. please wait for the next staking pool to open")
-	}
-
-	// check if the staker is banned.
-	banned, err := CheckIfStakerBanned(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
-	if err != nil {
-		return err
-	}
-	if banned {
-		return errors.New("staker is temporarily banned from staking")
-	}
-
-	// check if the key(s) are owned by the `stakerWallet`. technically, we're supposed to check against the wallet owned by `sessionToken`.
-	// however, since it first checks if the wallet matches the `stakerWallet`, it's safe to assume that the `sessionToken` is owned by the `stakerWallet` at this point of the code.
-	var keyIds []int
-	for _, key := range keys {
-		keyIds = append(keyIds, key.TokenID)
-	}
-	ownership, err := VerifyOwnership(stakerWallet, keyIds)
-	if err != nil {
-		return err
-	}
-	if !ownership {
-		return errors.New("one or more keys specified do not belong to the wallet specified")
-	}
-
-	// check if any of the keys in `keys` are already staked.
-	// if even just one of them are, return an error.
-	checkKeysStaked, err := CheckIfKeysStaked(collection, stakingPoolId, keys)
-	if err != nil {
-		return err
-	}
-	if checkKeysStaked {
-		return errors.New("1 or more keys are already staked. please stake a set of keys that are not yet staked")
-	}
-
-	// calls `CheckKeysToStakeEligibility` to check for amount of keys to stake, keychain, and superior keychain eligibility.
-	// if any of the checks fail, return an error.
-	err = CheckKeysToStakeEligibility(keys, keychainIds, superiorKeychainId)
-	if err != nil {
-		return err
-	}
-
-	// calls `CheckSubpoolComboEligibility` to check how many times a user has staked X amount of keys
-	subpoolComboEligiblity, err := CheckSubpoolComboEligibility(collection, stakingPoolId, stakerWallet, keys)
-	if err != nil {
-		return err
-	}
-	if !subpoolComboEligiblity {
-		return errors.New("you have already staked this combination of keys more times than allowed for this staking pool")
-	}
-
-	// checks if keychains are already staked in this staking pool (assuming id is not -1 or 0)
-	if len(keychainIds) > 0 {
-		for _, keychainId := range keychainIds {
-			if keychainId != 1 && keychainId != 0 {
-				staked, err := CheckIfKeychainStaked(collection, stakingPoolId, keychainId)
-				if err != nil {
-					return err
-				}
-				if staked {
-					return errors.New("keychain has already been staked in another subpool for this staking pool")
-				}
-			}
-		}
-	}
-
-	// checks if superior keychain is already staked in this staking pool (assuming id is not -1 or 0)
-	if superiorKeychainId != -1 && superiorKeychainId != 0 {
-		staked, err := CheckIfSuperiorKeychainStaked(collection, stakingPoolId, superiorKeychainId)
-		if err != nil {
-			return err
-		}
-		if staked {
-			return errors.New("superior keychain has already been staked in another subpool for this staking pool")
-		}
-	}
-
-	var stakerObjId *primitive.ObjectID
-
-	// after all checks, check if the staker exists in `RHStakerData`. if not, create a new staker instance.
-	exists, err := CheckStakerExists(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		log.Printf("staker with address %v does not exist. creating a new staker instance...", stakerWallet)
-		stakerObjId, err = AddStaker(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet) // create a new staker instance and get the object ID.
-		if err != nil {
-			return err
-		}
-	} else {
-		stakerObjId, err = GetStakerInstance(configs.GetCollections(configs.DB, "RHStakerData"), stakerWallet) // get the staker instance and get the object ID.
-		if err != nil {
-			return err
-		}
-	}
-
-	// filter for the staking pool
-	filter := bson.M{"stakingPoolID": stakingPoolId}
-
-	var stakingPool models.StakingPool
-
-	if err := collection.FindOne(context.Background(), filter).Decode(&stakingPool); err != nil {
-		return err
-	}
-
-	// get the next subpool ID
-	nextSubpoolId, err := GetNextSubpoolID(collection, stakingPoolId)
-	if err != nil {
-		return err
-	}
-
-	// call `CalculateSubpoolPoints`
-	subpoolPoints := CalculateSubpoolPoints(keys, keychainIds, superiorKeychainId)
-
-	subpool := &models.StakingSubpool{
-		SubpoolID:                nextSubpoolId,
-		Staker:                   stakerObjId,
-		EnterTime:                time.Now(),
-		StakedKeys:               keys,
-		StakedKeychainIDs:        keychainIds,
-		StakedSuperiorKeychainID: superiorKeychainId,
-		SubpoolPoints:            math.Round(subpoolPoints*100) / 100, // 2 decimal places
-		RewardClaimable:          false,
-	}
-
-	updatePool := bson.M{"$push": bson.M{"activeSubpools": subpool}}
-	update, err := collection.UpdateOne(context.Background(), filter, updatePool)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("Added Subpool ID %d to Staking Pool ID %d. Updated %d document(s)", nextSubpoolId, stakingPoolId, update.ModifiedCount)
-
-	return nil
+	spec := &models.SubpoolSpec{KeyIDs: keyIds, KeychainIDs: keychainIds, SuperiorKeychainID: superiorKeychainId}
+	_, err := AddSubpools(collection, sessionToken, stakingPoolId, stakerWallet, []*models.SubpoolSpec{spec})
+	return err
 }
 
 /*
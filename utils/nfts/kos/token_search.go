@@ -0,0 +1,281 @@
+package utils_kos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// how long the tokens loaded from `RHKOSMetadata` are searched before they're loaded again.
+const TokenIndexCacheTTL = 5 * time.Minute
+
+/*
+The sort orders of a Key Of Salvation token search. Tokens that are equal are ordered by token ID.
+*/
+var tokenSearchSorts = map[string]func(a, b *models.KOSSimplifiedMetadata) int{
+	"tokenId":   func(a, b *models.KOSSimplifiedMetadata) int { return a.TokenID - b.TokenID },
+	"luck":      func(a, b *models.KOSSimplifiedMetadata) int { return compareFloats(a.LuckTrait, b.LuckTrait) },
+	"luckBoost": func(a, b *models.KOSSimplifiedMetadata) int { return compareFloats(a.LuckBoostTrait, b.LuckBoostTrait) },
+	"house":     func(a, b *models.KOSSimplifiedMetadata) int { return strings.Compare(a.HouseTrait, b.HouseTrait) },
+	"type":      func(a, b *models.KOSSimplifiedMetadata) int { return strings.Compare(a.TypeTrait, b.TypeTrait) },
+}
+
+/*
+The parsed metadata of every stored Key Of Salvation, with the rarity of every trait and the score of every key.
+*/
+type tokenIndex struct {
+	tokens     []*models.KOSSimplifiedMetadata // ordered by token ID
+	traitStats []*models.TraitStats
+	scores     map[int]*models.KOSKeyScore // keyed by token ID
+	byRarity   []*models.KOSKeyScore       // ranked by rarity
+	byUtility  []*models.KOSKeyScore       // ranked by staking utility
+	loadedAt   time.Time
+}
+
+var (
+	tokenIndexMu     sync.Mutex
+	cachedTokenIndex *tokenIndex
+)
+
+/*
+Searches the Key Of Salvation tokens stored in `RHKOSMetadata` (`metadataCollection`) with `filter`, returning page `page` (of `limit` tokens each)
+sorted by `sortBy` (see `tokenSearchSorts`), descending if `descending` is true. The rarity of the traits is always for the whole collection.
+
+Tokens whose stored metadata doesn't match `KOSMetadataSchema` are left out. Filtering by staked status reads `RHStakingPool` (`stakingPoolCollection`).
+*/
+func SearchTokens(metadataCollection, stakingPoolCollection *mongo.Collection, filter *models.KOSTokenFilter, sortBy string, descending bool, page, limit int) (*models.KOSTokenSearch, error) {
+	if metadataCollection.Name() != "RHKOSMetadata" {
+		return nil, errors.New("collection must be RHKOSMetadata")
+	}
+	if stakingPoolCollection.Name() != "RHStakingPool" {
+		return nil, errors.New("collection must be RHStakingPool")
+	}
+
+	compare, ok := tokenSearchSorts[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %q", utils.ErrInvalidRequest, sortBy)
+	}
+	if filter.LuckMin > filter.LuckMax || filter.LuckBoostMin > filter.LuckBoostMax {
+		return nil, fmt.Errorf("%w: the min of a range must not be greater than its max", utils.ErrInvalidRequest)
+	}
+
+	// the keys in active subpools of the pool (only needed to filter by staked status).
+	var staked map[int]bool
+	if filter.Staked != nil {
+		if filter.StakingPoolID < 1 {
+			return nil, fmt.Errorf("%w: stakingPoolId is required to filter by staked status", utils.ErrInvalidRequest)
+		}
+		stakedKeyIds, err := GetAllStakedKeyIDs(stakingPoolCollection, filter.StakingPoolID)
+		if err != nil {
+			return nil, err
+		}
+		staked = make(map[int]bool, len(stakedKeyIds))
+		for _, keyId := range stakedKeyIds {
+			staked[keyId] = true
+		}
+	}
+
+	index, err := loadTokenIndex(metadataCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*models.KOSSimplifiedMetadata
+	for _, token := range index.tokens {
+		if tokenMatches(filter, token) && (staked == nil || staked[token.TokenID] == *filter.Staked) {
+			matches = append(matches, token)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		order := compare(matches[i], matches[j])
+		if descending {
+			order = -order
+		}
+		if order != 0 {
+			return order < 0
+		}
+		return matches[i].TokenID < matches[j].TokenID
+	})
+
+	result := &models.KOSTokenSearch{
+		Tokens:        []*models.KOSSimplifiedMetadata{},
+		Total:         len(matches),
+		Page:          page,
+		Limit:         limit,
+		IndexedTokens: len(index.tokens),
+		TraitStats:    index.traitStats,
+		UpdatedAt:     index.loadedAt,
+	}
+	if start := (page - 1) * limit; start < len(matches) {
+		result.Tokens = matches[start:minInt(start+limit, len(matches))]
+	}
+
+	return result, nil
+}
+
+/*
+Checks if `token` matches `filter` (except for `Staked`, which needs the staking pool).
+*/
+func tokenMatches(filter *models.KOSTokenFilter, token *models.KOSSimplifiedMetadata) bool {
+	if filter.House != "" && !strings.EqualFold(token.HouseTrait, filter.House) {
+		return false
+	}
+	if filter.Type != "" && !strings.EqualFold(token.TypeTrait, filter.Type) {
+		return false
+	}
+	if token.LuckTrait < filter.LuckMin || token.LuckTrait > filter.LuckMax {
+		return false
+	}
+	// `LuckBoostTrait` is a multiplier (e.g. 1.05), while the filter is in percent.
+	luckBoost := math.Round((token.LuckBoostTrait-1)*100*100) / 100
+	if luckBoost < filter.LuckBoostMin || luckBoost > filter.LuckBoostMax {
+		return false
+	}
+	if filter.Angel != nil && (token.LuckTrait == models.AngelLuck) != *filter.Angel {
+		return false
+	}
+
+	return true
+}
+
+/*
+Returns the index of the tokens in `RHKOSMetadata`, loading it again if it's older than `TokenIndexCacheTTL`.
+*/
+func loadTokenIndex(collection *mongo.Collection) (*tokenIndex, error) {
+	tokenIndexMu.Lock()
+	defer tokenIndexMu.Unlock()
+
+	if cachedTokenIndex != nil && time.Since(cachedTokenIndex.loadedAt) < TokenIndexCacheTTL {
+		return cachedTokenIndex, nil
+	}
+
+	cursor, err := collection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	var stored []*models.StoredKOSMetadata
+	if err := cursor.All(context.Background(), &stored); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	index := &tokenIndex{tokens: []*models.KOSSimplifiedMetadata{}, loadedAt: time.Now()}
+	malformed := 0
+	for _, token := range stored {
+		if token.Metadata == nil {
+			continue
+		}
+		simplified, err := ParseSimplifiedMetadata(token.TokenID, token.Metadata)
+		if err != nil {
+			malformed++
+			continue
+		}
+		index.tokens = append(index.tokens, simplified)
+	}
+	if malformed > 0 {
+		log.Printf("%d stored keys have malformed metadata and can't be searched", malformed)
+	}
+
+	sort.Slice(index.tokens, func(i, j int) bool {
+		return index.tokens[i].TokenID < index.tokens[j].TokenID
+	})
+	index.traitStats = traitStats(index.tokens)
+	index.scores, index.byRarity, index.byUtility = scoreKeys(index.tokens)
+
+	cachedTokenIndex = index
+
+	return index, nil
+}
+
+/*
+Makes the next search load the tokens from `RHKOSMetadata` again (e.g. after syncing changed metadata).
+*/
+func invalidateTokenIndex() {
+	tokenIndexMu.Lock()
+	defer tokenIndexMu.Unlock()
+
+	cachedTokenIndex = nil
+}
+
+/*
+Counts how often each value of each trait type appears in `tokens`.
+*/
+func traitStats(tokens []*models.KOSSimplifiedMetadata) []*models.TraitStats {
+	// values are keyed by their string form, since numbers (float64) and strings can't share a map key otherwise.
+	type valueCount struct {
+		value interface{}
+		count int
+	}
+	counts := map[string]map[string]*valueCount{}
+	for _, token := range tokens {
+		for traitType, value := range token.Traits {
+			if counts[traitType] == nil {
+				counts[traitType] = map[string]*valueCount{}
+			}
+			key := fmt.Sprint(value)
+			if counts[traitType][key] == nil {
+				counts[traitType][key] = &valueCount{value: value}
+			}
+			counts[traitType][key].count++
+		}
+	}
+
+	stats := []*models.TraitStats{}
+	for traitType, values := range counts {
+		traitStats := &models.TraitStats{TraitType: traitType, Values: []*models.TraitValueStats{}}
+		for _, value := range values {
+			traitStats.Values = append(traitStats.Values, &models.TraitValueStats{
+				Value:      value.value,
+				Count:      value.count,
+				Percentage: math.Round(float64(value.count)/float64(len(tokens))*100*100) / 100,
+			})
+		}
+		// the rarest values first.
+		sort.Slice(traitStats.Values, func(i, j int) bool {
+			if traitStats.Values[i].Count != traitStats.Values[j].Count {
+				return traitStats.Values[i].Count < traitStats.Values[j].Count
+			}
+			return lessTraitValue(traitStats.Values[i].Value, traitStats.Values[j].Value)
+		})
+		stats = append(stats, traitStats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].TraitType < stats[j].TraitType
+	})
+
+	return stats
+}
+
+/*
+Orders trait values numerically if both are numbers (as parsed by `KOSMetadataSchema`), otherwise by their string form.
+*/
+func lessTraitValue(a, b interface{}) bool {
+	aNumber, aOk := a.(float64)
+	bNumber, bOk := b.(float64)
+	if aOk && bOk {
+		return aNumber < bNumber
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,411 @@
+package utils_nft
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	UtilsIPFS "nbc-backend-api-v2/utils/ipfs"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// the max number of tokens per collection whose metadata is kept in memory (the least recently used are evicted first).
+	MetadataCacheSize = 2000
+	// the max number of tokens whose metadata is fetched at the same time by `FetchConcurrent`.
+	MaxConcurrentMetadataFetches = 16
+	// the default number of tokens fetched at the same time when syncing.
+	DefaultMetadataSyncConcurrency = 16
+)
+
+var ErrMetadataUnavailable = utils.NewDomainError(utils.KindUpstream, "METADATA_UNAVAILABLE", "unable to fetch NFT metadata")
+
+// fetches the metadata of every collection from the gateways in `IPFS_GATEWAYS` (after the gateway in the token URI, if any).
+var metadataFetcher = UtilsIPFS.NewFetcher(UtilsIPFS.GatewaysFromEnv(), MetadataCacheSize)
+
+// what happened to a token's metadata when it was stored.
+const (
+	MetadataAdded     = "added"
+	MetadataChanged   = "changed"
+	MetadataUnchanged = "unchanged"
+)
+
+/*
+Fetches the metadata of an NFT collection, keeping it in an in-memory cache and in a store (`StoreCollection`)
+so that it doesn't have to be fetched again after a restart.
+*/
+type MetadataSource struct {
+	Collection      string                            // the name of the collection (used in errors and logs)
+	StoreCollection string                            // the database collection the metadata is stored in (e.g. `RHKOSMetadata`)
+	TokenURI        func(tokenId int) (string, error) // returns the URI of a token's metadata (`ipfs://`, a gateway URL or any other URL)
+
+	cache *utils.LRU[int, *models.NFTMetadata]
+}
+
+func NewMetadataSource(collection, storeCollection string, tokenURI func(tokenId int) (string, error)) *MetadataSource {
+	return &MetadataSource{
+		Collection:      collection,
+		StoreCollection: storeCollection,
+		TokenURI:        tokenURI,
+		cache:           utils.NewLRU[int, *models.NFTMetadata](MetadataCacheSize),
+	}
+}
+
+/*
+Returns the metadata of token `tokenId`, reading it from the in-memory cache first, then from the store
+and only then from its token URI, in which case it's added to the store.
+*/
+func (s *MetadataSource) Fetch(tokenId int) (*models.NFTMetadata, error) {
+	// check if metadata is in cache
+	if metadata, ok := s.cache.Get(tokenId); ok {
+		return metadata, nil
+	}
+
+	// if the store can't be read, the metadata is still fetched from the token URI.
+	store := configs.GetCollections(configs.DB, s.StoreCollection)
+	stored, err := s.GetStored(store, tokenId)
+	if err != nil {
+		log.Printf("unable to read the metadata of %s #%d from the store: %v", s.Collection, tokenId, err)
+	}
+	if stored != nil {
+		s.cache.Add(tokenId, stored.Metadata)
+		return stored.Metadata, nil
+	}
+
+	metadata, err := s.FetchRemote(tokenId)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Store(store, tokenId, metadata, time.Now()); err != nil {
+		log.Printf("unable to store the metadata of %s #%d: %v", s.Collection, tokenId, err)
+	}
+	s.cache.Add(tokenId, metadata)
+
+	return metadata, nil
+}
+
+/*
+Returns the metadata of each token in `tokenIds` (in the same order), fetching at most `MaxConcurrentMetadataFetches` at a time.
+*/
+func (s *MetadataSource) FetchConcurrent(tokenIds []int) ([]*models.NFTMetadata, error) {
+	metadatas, errs := s.FetchEach(tokenIds)
+
+	// errors are joined so that callers can still match them (e.g. with `errors.Is(err, ErrMetadataUnavailable)`).
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("encountered %d errors: %w", len(failed), errors.Join(failed...))
+	}
+
+	return metadatas, nil
+}
+
+/*
+Returns the metadata and the error of each token in `tokenIds` (in the same order), fetching at most `MaxConcurrentMetadataFetches` at a time.
+Unlike `FetchConcurrent`, a token that can't be fetched doesn't fail the others.
+*/
+func (s *MetadataSource) FetchEach(tokenIds []int) ([]*models.NFTMetadata, []error) {
+	metadatas := make([]*models.NFTMetadata, len(tokenIds))
+	errs := make([]error, len(tokenIds))
+
+	// a bounded pool of workers, each fetching the tokens at the indexes it receives.
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < MaxConcurrentMetadataFetches && i < len(tokenIds); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				metadatas[index], errs[index] = s.Fetch(tokenIds[index])
+			}
+		}()
+	}
+	for index := range tokenIds {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return metadatas, errs
+}
+
+/*
+Fetches the metadata of token `tokenId` from its token URI, skipping the cache and the store.
+Gateways that fail, time out or return invalid JSON are retried and then skipped for the next gateway (see `UtilsIPFS.Fetcher`).
+*/
+func (s *MetadataSource) FetchRemote(tokenId int) (*models.NFTMetadata, error) {
+	uri, err := s.TokenURI(tokenId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get the token URI of %s #%d: %v", ErrMetadataUnavailable, s.Collection, tokenId, err)
+	}
+
+	content, err := metadataFetcher.Fetch(context.Background(), uri, validateJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMetadataUnavailable, err)
+	}
+
+	// unmarshal the content into a `NFTMetadata` struct instance
+	var metadata models.NFTMetadata
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, &MetadataError{Collection: s.Collection, TokenID: tokenId, Reason: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+
+	return &metadata, nil
+}
+
+// rejects content that isn't JSON (e.g. a gateway's HTML error page), so that the next gateway is tried.
+func validateJSON(content []byte) error {
+	if !json.Valid(content) {
+		return errors.New("content is not valid JSON")
+	}
+	return nil
+}
+
+/*
+Gets the metadata of token `tokenId` from the store. Returns nil if it isn't stored yet.
+*/
+func (s *MetadataSource) GetStored(collection *mongo.Collection, tokenId int) (*models.StoredNFTMetadata, error) {
+	if collection.Name() != s.StoreCollection {
+		return nil, fmt.Errorf("collection must be %s", s.StoreCollection)
+	}
+
+	var stored models.StoredNFTMetadata
+	if err := collection.FindOne(context.Background(), bson.M{"tokenID": tokenId}).Decode(&stored); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return &stored, nil
+}
+
+/*
+Stores `metadata` (fetched at `now`) as the metadata of token `tokenId`,
+returning whether it was added, changed or unchanged (compared by content hash).
+*/
+func (s *MetadataSource) Store(collection *mongo.Collection, tokenId int, metadata *models.NFTMetadata, now time.Time) (string, error) {
+	if collection.Name() != s.StoreCollection {
+		return "", fmt.Errorf("collection must be %s", s.StoreCollection)
+	}
+
+	contentHash, err := MetadataContentHash(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := s.GetStored(collection, tokenId)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.ContentHash == contentHash {
+		_, err := collection.UpdateOne(context.Background(), bson.M{"tokenID": tokenId}, bson.M{"$set": bson.M{"fetchedAt": now}})
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+		}
+		return MetadataUnchanged, nil
+	}
+
+	update := bson.M{"$set": bson.M{
+		"metadata":    metadata,
+		"contentHash": contentHash,
+		"fetchedAt":   now,
+		"updatedAt":   now,
+	}}
+	if _, err := collection.UpdateOne(context.Background(), bson.M{"tokenID": tokenId}, update, options.Update().SetUpsert(true)); err != nil {
+		return "", fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	if existing == nil {
+		return MetadataAdded, nil
+	}
+	return MetadataChanged, nil
+}
+
+/*
+Syncs the metadata of every token in `tokenIds` from its token URI into the store (`collection`), fetching `concurrency` tokens at a time.
+
+Without `refresh`, tokens that are already stored are skipped, so a sync that was interrupted (or had failed tokens) continues where it stopped.
+With `refresh`, every token is fetched again and only the tokens whose content hash changed are updated (and removed from the in-memory cache).
+`validate` (optional) is called with the metadata of every synced token; the tokens it returns an error for are reported as malformed.
+*/
+func (s *MetadataSource) Sync(collection *mongo.Collection, tokenIds []int, concurrency int, refresh bool, validate func(tokenId int, metadata *models.NFTMetadata) error) (*models.MetadataSyncResult, error) {
+	if collection.Name() != s.StoreCollection {
+		return nil, fmt.Errorf("collection must be %s", s.StoreCollection)
+	}
+	if concurrency < 1 {
+		concurrency = DefaultMetadataSyncConcurrency
+	}
+
+	index := mongo.IndexModel{Keys: bson.M{"tokenID": 1}, Options: options.Index().SetUnique(true)}
+	if _, err := collection.Indexes().CreateOne(context.Background(), index); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	// the tokens that are already stored (only skipped when not refreshing).
+	stored := map[int]bool{}
+	if !refresh {
+		cursor, err := collection.Find(context.Background(), bson.M{}, options.Find().SetProjection(bson.M{"tokenID": 1}))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+		}
+		var storedTokens []*models.StoredNFTMetadata
+		if err := cursor.All(context.Background(), &storedTokens); err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+		}
+		for _, token := range storedTokens {
+			stored[token.TokenID] = true
+		}
+	}
+
+	result := &models.MetadataSyncResult{Total: len(tokenIds), FailedTokenIDs: []int{}, MalformedTokenIDs: []int{}}
+	var mu sync.Mutex
+
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tokenId := range pending {
+				status, metadata, err := s.syncToken(collection, tokenId)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					log.Printf("unable to sync the metadata of %s #%d: %v", s.Collection, tokenId, err)
+					result.FailedTokenIDs = append(result.FailedTokenIDs, tokenId)
+				case status == MetadataAdded:
+					result.Added++
+				case status == MetadataChanged:
+					result.Changed++
+				default:
+					result.Unchanged++
+				}
+				if err == nil && validate != nil {
+					if err := validate(tokenId, metadata); err != nil {
+						result.MalformedTokenIDs = append(result.MalformedTokenIDs, tokenId)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, tokenId := range tokenIds {
+		if stored[tokenId] {
+			result.Skipped++
+			continue
+		}
+		pending <- tokenId
+	}
+	close(pending)
+	wg.Wait()
+
+	sort.Ints(result.FailedTokenIDs)
+	sort.Ints(result.MalformedTokenIDs)
+
+	return result, nil
+}
+
+/*
+Fetches the metadata of token `tokenId` from its token URI and stores it in `collection`.
+*/
+func (s *MetadataSource) syncToken(collection *mongo.Collection, tokenId int) (string, *models.NFTMetadata, error) {
+	metadata, err := s.FetchRemote(tokenId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	status, err := s.Store(collection, tokenId, metadata, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	if status == MetadataChanged {
+		s.cache.Remove(tokenId)
+	}
+
+	return status, metadata, nil
+}
+
+/*
+Returns the hex-encoded SHA-256 hash of `metadata` as JSON.
+*/
+func MetadataContentHash(metadata *models.NFTMetadata) (string, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+/*
+Returns the `NFTData` of token `tokenId` of the collection, with `metadata` as its metadata.
+The image is the metadata's animation URL (like the keys' animations), or its image if it has none.
+*/
+func (s *MetadataSource) NFTData(tokenId int, metadata *models.NFTMetadata) *models.NFTData {
+	data := &models.NFTData{
+		Name:     fmt.Sprintf("%s #%d", s.Collection, tokenId),
+		TokenID:  tokenId,
+		Metadata: metadata,
+	}
+	if metadata != nil {
+		if metadata.Name != "" {
+			data.Name = metadata.Name
+		}
+		data.ImageUrl = metadata.AnimationUrl
+		if data.ImageUrl == "" {
+			data.ImageUrl = metadata.Image
+		}
+	}
+
+	return data
+}
+
+/*
+Fetches the metadata of token `tokenId` and returns it as `NFTData`.
+*/
+func (s *MetadataSource) FetchNFTData(tokenId int) (*models.NFTData, error) {
+	metadata, err := s.Fetch(tokenId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.NFTData(tokenId, metadata), nil
+}
+
+/*
+Fetches the metadata of each token in `tokenIds` and returns them as `NFTData` (in the same order).
+*/
+func (s *MetadataSource) FetchNFTDataConcurrent(tokenIds []int) ([]*models.NFTData, error) {
+	metadatas, err := s.FetchConcurrent(tokenIds)
+	if err != nil {
+		return nil, err
+	}
+
+	nftData := make([]*models.NFTData, len(tokenIds))
+	for i, tokenId := range tokenIds {
+		nftData[i] = s.NFTData(tokenId, metadatas[i])
+	}
+
+	return nftData, nil
+}
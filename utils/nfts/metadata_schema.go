@@ -0,0 +1,152 @@
+package utils_nft
+
+import (
+	"errors"
+	"fmt"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"strings"
+)
+
+// returned (wrapped in a `MetadataError`) when an NFT's metadata doesn't match its collection's `MetadataSchema`.
+var ErrMalformedMetadata = utils.NewDomainError(utils.KindUpstream, "MALFORMED_METADATA", "NFT metadata is malformed")
+
+/*
+The type of a trait's value.
+*/
+type TraitKind int
+
+const (
+	TraitString TraitKind = iota // a non-empty string
+	TraitNumber                  // a number within `Min` and `Max`
+)
+
+/*
+Describes a trait that a collection's metadata is expected to have in its `attributes`.
+*/
+type TraitSpec struct {
+	TraitType string    // the `trait_type` of the attribute (matched case-insensitively)
+	Kind      TraitKind // the type of the attribute's value
+	Required  bool      // whether every token must have the trait
+	Min, Max  float64   // the range of the value (only for `TraitNumber`)
+}
+
+/*
+The traits a collection's metadata is expected to have. Attributes are looked up by their `trait_type`, so their order doesn't matter.
+*/
+type MetadataSchema struct {
+	Collection string       // the name of the collection (used in errors)
+	Traits     []*TraitSpec // the traits to validate. other traits are returned as they are.
+}
+
+/*
+Returned when token `TokenID` of `Collection` has malformed metadata. `TraitType` is empty if the problem isn't with a single trait.
+Matches `ErrMalformedMetadata` with `errors.Is`.
+*/
+type MetadataError struct {
+	Collection string
+	TokenID    int
+	TraitType  string
+	Reason     string
+}
+
+func (e *MetadataError) Error() string {
+	if e.TraitType == "" {
+		return fmt.Sprintf("%v: %s #%d: %s", ErrMalformedMetadata, e.Collection, e.TokenID, e.Reason)
+	}
+	return fmt.Sprintf("%v: %s #%d: trait %q %s", ErrMalformedMetadata, e.Collection, e.TokenID, e.TraitType, e.Reason)
+}
+
+func (e *MetadataError) Unwrap() error {
+	return ErrMalformedMetadata
+}
+
+/*
+Returns every trait in `attributes` (the metadata of token `tokenId`) keyed by its `trait_type`, after checking the traits in the schema.
+The traits in the schema are keyed by the schema's `TraitType` (even if the case differs) and numbers are returned as float64.
+
+Returns a `MetadataError` if a required trait is missing, a trait appears more than once, or a value has the wrong type or is out of range.
+*/
+func (s *MetadataSchema) Parse(tokenId int, attributes []models.Attribute) (map[string]interface{}, error) {
+	traits := make(map[string]interface{}, len(attributes))
+
+	for _, attribute := range attributes {
+		if attribute.TraitType == "" {
+			continue // attributes without a trait type can't be looked up.
+		}
+
+		traitType := attribute.TraitType
+		spec := s.trait(traitType)
+		if spec != nil {
+			traitType = spec.TraitType
+		}
+		if _, ok := traits[traitType]; ok {
+			return nil, &MetadataError{Collection: s.Collection, TokenID: tokenId, TraitType: traitType, Reason: "appears more than once"}
+		}
+
+		if spec == nil {
+			traits[traitType] = attribute.Value
+			continue
+		}
+
+		value, err := spec.parse(attribute.Value)
+		if err != nil {
+			return nil, &MetadataError{Collection: s.Collection, TokenID: tokenId, TraitType: traitType, Reason: err.Error()}
+		}
+		traits[traitType] = value
+	}
+
+	for _, spec := range s.Traits {
+		if _, ok := traits[spec.TraitType]; !ok && spec.Required {
+			return nil, &MetadataError{Collection: s.Collection, TokenID: tokenId, TraitType: spec.TraitType, Reason: "is missing"}
+		}
+	}
+
+	return traits, nil
+}
+
+func (s *MetadataSchema) trait(traitType string) *TraitSpec {
+	for _, spec := range s.Traits {
+		if strings.EqualFold(spec.TraitType, traitType) {
+			return spec
+		}
+	}
+	return nil
+}
+
+/*
+Checks `value` against the spec, returning it (numbers as float64).
+*/
+func (spec *TraitSpec) parse(value interface{}) (interface{}, error) {
+	switch spec.Kind {
+	case TraitString:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string, got %T", value)
+		}
+		if strings.TrimSpace(str) == "" {
+			return nil, errors.New("must not be empty")
+		}
+		return str, nil
+	case TraitNumber:
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case int:
+			number = float64(v)
+		case int32:
+			number = float64(v)
+		case int64:
+			number = float64(v)
+		default:
+			return nil, fmt.Errorf("must be a number, got %T", value)
+		}
+		if number < spec.Min || number > spec.Max {
+			return nil, fmt.Errorf("must be between %v and %v, got %v", spec.Min, spec.Max, number)
+		}
+		return number, nil
+	default:
+		return nil, errors.New("has an unknown trait kind")
+	}
+}
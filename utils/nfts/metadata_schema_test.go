@@ -0,0 +1,139 @@
+package utils_nft
+
+import (
+	"errors"
+	"nbc-backend-api-v2/models"
+	"reflect"
+	"testing"
+)
+
+// the traits of a Key Of Salvation.
+var testMetadataSchema = &MetadataSchema{
+	Collection: "Key Of Salvation",
+	Traits: []*TraitSpec{
+		{TraitType: "Luck", Kind: TraitNumber, Required: true, Min: 0, Max: 100},
+		{TraitType: "House", Kind: TraitString, Required: true},
+		{TraitType: "Type", Kind: TraitString},
+	},
+}
+
+func TestMetadataSchemaParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes []models.Attribute
+		want       map[string]interface{}
+		err        *MetadataError
+	}{
+		{
+			"valid",
+			[]models.Attribute{{TraitType: "Luck", Value: 42.5}, {TraitType: "House", Value: "Tyr"}, {TraitType: "Type", Value: "Silver"}},
+			map[string]interface{}{"Luck": 42.5, "House": "Tyr", "Type": "Silver"},
+			nil,
+		},
+		{
+			"reordered attributes",
+			[]models.Attribute{{TraitType: "House", Value: "Tyr"}, {TraitType: "Type", Value: "Silver"}, {TraitType: "Luck", Value: 42.5}},
+			map[string]interface{}{"Luck": 42.5, "House": "Tyr", "Type": "Silver"},
+			nil,
+		},
+		{
+			"case-insensitive trait type",
+			[]models.Attribute{{TraitType: "luck", Value: 7}, {TraitType: "HOUSE", Value: "Tyr"}},
+			map[string]interface{}{"Luck": 7.0, "House": "Tyr"},
+			nil,
+		},
+		{
+			"other traits are kept as they are",
+			[]models.Attribute{{TraitType: "Luck", Value: 42.5}, {TraitType: "House", Value: "Tyr"}, {TraitType: "Background", Value: 3}, {Value: "no trait type"}},
+			map[string]interface{}{"Luck": 42.5, "House": "Tyr", "Background": 3},
+			nil,
+		},
+		{
+			"missing required trait",
+			[]models.Attribute{{TraitType: "Luck", Value: 42.5}, {TraitType: "Type", Value: "Silver"}},
+			nil,
+			&MetadataError{Collection: "Key Of Salvation", TokenID: 25, TraitType: "House", Reason: "is missing"},
+		},
+		{
+			"duplicate trait",
+			[]models.Attribute{{TraitType: "Luck", Value: 42.5}, {TraitType: "House", Value: "Tyr"}, {TraitType: "house", Value: "Odin"}},
+			nil,
+			&MetadataError{Collection: "Key Of Salvation", TokenID: 25, TraitType: "House", Reason: "appears more than once"},
+		},
+		{
+			"wrong type",
+			[]models.Attribute{{TraitType: "Luck", Value: "42.5"}, {TraitType: "House", Value: "Tyr"}},
+			nil,
+			&MetadataError{Collection: "Key Of Salvation", TokenID: 25, TraitType: "Luck", Reason: "must be a number, got string"},
+		},
+		{
+			"luck out of range",
+			[]models.Attribute{{TraitType: "Luck", Value: 100.5}, {TraitType: "House", Value: "Tyr"}},
+			nil,
+			&MetadataError{Collection: "Key Of Salvation", TokenID: 25, TraitType: "Luck", Reason: "must be between 0 and 100, got 100.5"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testMetadataSchema.Parse(25, test.attributes)
+			if test.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("Parse() = %v, want %v", got, test.want)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrMalformedMetadata) {
+				t.Fatalf("error = %v, want ErrMalformedMetadata", err)
+			}
+			var metadataErr *MetadataError
+			if !errors.As(err, &metadataErr) {
+				t.Fatalf("error = %T, want a *MetadataError", err)
+			}
+			if *metadataErr != *test.err {
+				t.Errorf("error = %+v, want %+v", *metadataErr, *test.err)
+			}
+		})
+	}
+}
+
+func TestTraitSpecParse(t *testing.T) {
+	luck := &TraitSpec{TraitType: "Luck", Kind: TraitNumber, Min: 0, Max: 100}
+	house := &TraitSpec{TraitType: "House", Kind: TraitString}
+
+	tests := []struct {
+		name    string
+		spec    *TraitSpec
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"float", luck, 42.5, 42.5, false},
+		{"int", luck, 42, 42.0, false},
+		{"int64", luck, int64(42), 42.0, false},
+		{"minimum", luck, 0.0, 0.0, false},
+		{"maximum", luck, 100.0, 100.0, false},
+		{"below the minimum", luck, -0.5, nil, true},
+		{"above the maximum", luck, 101, nil, true},
+		{"number as a string", luck, "42", nil, true},
+		{"missing number", luck, nil, nil, true},
+		{"string", house, "Tyr", "Tyr", false},
+		{"empty string", house, "  ", nil, true},
+		{"string as a number", house, 3, nil, true},
+		{"unknown kind", &TraitSpec{TraitType: "Luck", Kind: TraitKind(9)}, 42.5, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.spec.parse(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want an error: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("parse() = %v (%T), want %v (%T)", got, got, test.want, test.want)
+			}
+		})
+	}
+}
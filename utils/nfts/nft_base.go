@@ -2,6 +2,7 @@ package utils_nft
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"nbc-backend-api-v2/models"
 	"nbc-backend-api-v2/utils"
@@ -12,33 +13,10 @@ import (
 
 /*
 `GetExplicitOwnerships` calls the `explicitOwnershipsOf` method of a specific NFT contract, returning an array of `ExplicitOwnership` structs.
-*/
-func GetExplicitOwnerships(
-	apiKey string,
-	concat bool,
-	rawClientUrl string,
-	abiPath string,
-	contractAddress string,
-	collectionSize int,
-	caller bind.ContractCaller,
-	transactor bind.ContractTransactor,
-	filterer bind.ContractFilterer,
-) ([]models.ExplicitOwnership, error) {
-	// loads the contract
-	contract, err := utils.LoadContract(
-		apiKey,
-		concat,
-		rawClientUrl,
-		abiPath,
-		contractAddress,
-		caller,
-		transactor,
-		filterer,
-	)
-	if err != nil {
-		return nil, err
-	}
 
+	`opts` the options of the call (e.g. the block to read at). nil reads at the latest block.
+*/
+func GetExplicitOwnerships(contract *bind.BoundContract, opts *bind.CallOpts, collectionSize int) ([]models.ExplicitOwnership, error) {
 	// create an array of all ids of the NFT collection (the collection size)
 	allIds := make([]*big.Int, collectionSize)
 	for i := 1; i <= collectionSize; i++ {
@@ -48,9 +26,9 @@ func GetExplicitOwnerships(
 	var rawResult []interface{}
 
 	// calls the `explicitOwnershipsOf` method of the contract
-	err = contract.Call(nil, &rawResult, "explicitOwnershipsOf", allIds)
+	err := contract.Call(opts, &rawResult, "explicitOwnershipsOf", allIds)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
 	}
 
 	// the result returned in `rawResult` is an array of unknown interfaces.
@@ -86,39 +64,16 @@ func GetExplicitOwnerships(
 
 /*
 `GetOwnerIDs` gets ALL token IDs owned by `ownerAddress` from a specific NFT collection.
-*/
-func GetOwnerIDs(
-	apiKey string,
-	concat bool,
-	rawClientUrl string,
-	abiPath string,
-	contractAddress string,
-	ownerAddress string,
-	caller bind.ContractCaller,
-	transactor bind.ContractTransactor,
-	filterer bind.ContractFilterer,
-) (*models.OwnershipData, error) {
-	// loads the contract
-	contract, err := utils.LoadContract(
-		apiKey,
-		concat,
-		rawClientUrl,
-		abiPath,
-		contractAddress,
-		caller,
-		transactor,
-		filterer,
-	)
-	if err != nil {
-		return nil, err
-	}
 
+	`opts` the options of the call (e.g. the block to read at). nil reads at the latest block.
+*/
+func GetOwnerIDs(contract *bind.BoundContract, opts *bind.CallOpts, ownerAddress string) (*models.OwnershipData, error) {
 	var rawResult []interface{}
 
 	// calls the `tokensOfOwner` method of the contract
-	err = contract.Call(nil, &rawResult, "tokensOfOwner", common.HexToAddress(ownerAddress))
+	err := contract.Call(opts, &rawResult, "tokensOfOwner", common.HexToAddress(ownerAddress))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
 	}
 
 	// the result returned in `rawResult` is an array of unknown interfaces.
@@ -141,3 +96,25 @@ func GetOwnerIDs(
 
 	return &ownershipData, nil
 }
+
+/*
+`GetTokenURI` calls the `tokenURI` method of a specific NFT contract, returning the URI of the metadata of token `tokenId`.
+
+	`opts` the options of the call (e.g. the block to read at). nil reads at the latest block.
+*/
+func GetTokenURI(contract *bind.BoundContract, opts *bind.CallOpts, tokenId int) (string, error) {
+	var rawResult []interface{}
+
+	// calls the `tokenURI` method of the contract
+	err := contract.Call(opts, &rawResult, "tokenURI", big.NewInt(int64(tokenId)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", utils.ErrChainUnavailable, err)
+	}
+
+	uri, ok := rawResult[0].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: unexpected tokenURI result %T", utils.ErrChainUnavailable, rawResult[0])
+	}
+
+	return uri, nil
+}
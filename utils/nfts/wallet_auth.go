@@ -0,0 +1,288 @@
+package utils_nft
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"nbc-backend-api-v2/configs"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// the prefix of a session token signed by the wallet itself: `wallet-signature:<wallet>:<nonce>:<signature>`, see `WalletFromSessionToken`.
+	SignedSessionPrefix = "wallet-signature:"
+	// how long a signed session token is valid for after its nonce is issued.
+	SignedSessionDuration = 24 * time.Hour
+	// the collection the nonces of signed session tokens are stored in.
+	SignInNonceCollection = "RHSignInNonces"
+)
+
+/*
+The EIP-712 types of a signed session token. `nonce` is issued by `IssueSignInNonce` and `expiry` is when it expires, in unix seconds.
+*/
+var signInTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+	},
+	"SignIn": {
+		{Name: "wallet", Type: "address"},
+		{Name: "nonce", Type: "string"},
+		{Name: "expiry", Type: "uint256"},
+	},
+}
+
+/*
+Where the nonces of signed session tokens are stored. Replaced in tests.
+*/
+type SignInNonceStore interface {
+	Insert(nonce *models.SignInNonce) error
+	// returns nil if there's no such nonce.
+	Find(nonce string) (*models.SignInNonce, error)
+	Revoke(nonce string, at time.Time) error
+}
+
+var signInNonces SignInNonceStore = mongoSignInNonces{}
+
+/*
+Stores the nonces in `RHSignInNonces` (see `EnsureSignInNonceIndexes`).
+*/
+type mongoSignInNonces struct{}
+
+func (mongoSignInNonces) collection() *mongo.Collection {
+	return configs.GetCollections(configs.DB, SignInNonceCollection)
+}
+
+func (s mongoSignInNonces) Insert(nonce *models.SignInNonce) error {
+	if _, err := s.collection().InsertOne(context.Background(), nonce); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	return nil
+}
+
+func (s mongoSignInNonces) Find(nonce string) (*models.SignInNonce, error) {
+	var signInNonce models.SignInNonce
+	err := s.collection().FindOne(context.Background(), bson.M{"nonce": nonce}).Decode(&signInNonce)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	return &signInNonce, nil
+}
+
+func (s mongoSignInNonces) Revoke(nonce string, at time.Time) error {
+	filter := bson.M{"nonce": nonce, "revokedAt": bson.M{"$exists": false}}
+	if _, err := s.collection().UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"revokedAt": at}}); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	return nil
+}
+
+/*
+Creates the indexes of `RHSignInNonces`: nonces are unique, and are removed by MongoDB once they expire
+(their tokens are invalid by then anyway). Called when the server starts.
+*/
+func EnsureSignInNonceIndexes(collection *mongo.Collection) error {
+	if collection.Name() != SignInNonceCollection {
+		return fmt.Errorf("collection must be %s", SignInNonceCollection)
+	}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "nonce", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := collection.Indexes().CreateMany(context.Background(), indexes); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return nil
+}
+
+/*
+Returns the EIP-712 typed data `signInNonce.Wallet` signs for a session token with `signInNonce`,
+on the chain of the key collection (the same one `VerifyWalletSignature` checks contract wallets on).
+*/
+func SignInTypedData(signInNonce *models.SignInNonce) (apitypes.TypedData, error) {
+	keys, err := CollectionByRole(RoleKey)
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
+
+	return apitypes.TypedData{
+		Types:       signInTypes,
+		PrimaryType: "SignIn",
+		Domain: apitypes.TypedDataDomain{
+			Name:    "NBC Staking",
+			Version: "1",
+			ChainId: math.NewHexOrDecimal256(keys.Chain.ChainID),
+		},
+		Message: apitypes.TypedDataMessage{
+			"wallet": strings.ToLower(signInNonce.Wallet),
+			"nonce":  signInNonce.Nonce,
+			"expiry": fmt.Sprint(signInNonce.ExpiresAt.Unix()),
+		},
+	}, nil
+}
+
+/*
+Issues a new nonce for `wallet` to sign in with, valid for `SignedSessionDuration`, and returns the SignIn message to sign.
+*/
+func IssueSignInNonce(wallet string) (*models.SignInChallenge, error) {
+	if !common.IsHexAddress(wallet) {
+		return nil, fmt.Errorf("%w: invalid wallet %q", utils.ErrInvalidRequest, wallet)
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("unable to generate a nonce: %w", err)
+	}
+	// stored in seconds, like the expiry that's signed.
+	now := time.Now().Truncate(time.Second)
+	signInNonce := &models.SignInNonce{
+		Nonce:     hex.EncodeToString(random),
+		Wallet:    strings.ToLower(wallet),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(SignedSessionDuration),
+	}
+
+	typedData, err := SignInTypedData(signInNonce)
+	if err != nil {
+		return nil, err
+	}
+	if err := signInNonces.Insert(signInNonce); err != nil {
+		return nil, err
+	}
+
+	return &models.SignInChallenge{Wallet: signInNonce.Wallet, Nonce: signInNonce.Nonce, ExpiresAt: signInNonce.ExpiresAt, TypedData: typedData}, nil
+}
+
+/*
+Returns the wallet (in lowercase) that `sessionToken` belongs to. Everything that authenticates a wallet goes through here.
+
+Session tokens of the webapp are checked with the session service (see `utils.WalletFromSessionToken`).
+Wallets that can't sign in to the webapp (e.g. Safe multisigs) can instead send a token they signed themselves:
+`wallet-signature:<wallet>:<nonce>:<signature of SignInTypedData>`, where the nonce is issued by `IssueSignInNonce`.
+The signature is checked with `VerifyWalletSignature`, and the token is valid until its nonce expires or is revoked by `SignOut`.
+*/
+func WalletFromSessionToken(sessionToken string) (string, error) {
+	if !strings.HasPrefix(sessionToken, SignedSessionPrefix) {
+		return utils.WalletFromSessionToken(sessionToken)
+	}
+
+	signInNonce, err := verifySignedSessionToken(sessionToken)
+	if err != nil {
+		return "", err
+	}
+
+	return signInNonce.Wallet, nil
+}
+
+/*
+Revokes the nonce of the signed session token `sessionToken` of `wallet`, so that it can't be used anymore.
+*/
+func SignOut(wallet, sessionToken string) error {
+	if !strings.HasPrefix(sessionToken, SignedSessionPrefix) {
+		return fmt.Errorf("%w: only signed session tokens (%s...) can be revoked", utils.ErrInvalidRequest, SignedSessionPrefix)
+	}
+
+	signInNonce, err := verifySignedSessionToken(sessionToken)
+	if err != nil {
+		return err
+	}
+
+	if signInNonce.Wallet != strings.ToLower(wallet) {
+		return fmt.Errorf("%w: the session belongs to %s", utils.ErrSessionMismatch, signInNonce.Wallet)
+	}
+
+	return signInNonces.Revoke(signInNonce.Nonce, time.Now())
+}
+
+/*
+Checks the signed session token `sessionToken` and returns its nonce.
+*/
+func verifySignedSessionToken(sessionToken string) (*models.SignInNonce, error) {
+	parts := strings.Split(strings.TrimPrefix(sessionToken, SignedSessionPrefix), ":")
+	if len(parts) != 3 || !common.IsHexAddress(parts[0]) {
+		return nil, fmt.Errorf("%w: signed session tokens must be %s<wallet>:<nonce>:<signature>", utils.ErrInvalidSession, SignedSessionPrefix)
+	}
+	wallet := strings.ToLower(parts[0])
+
+	signInNonce, err := signInNonces.Find(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if signInNonce == nil || signInNonce.Wallet != wallet {
+		return nil, fmt.Errorf("%w: the nonce wasn't issued to %s", utils.ErrInvalidSession, wallet)
+	}
+	if signInNonce.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: the session was signed out", utils.ErrInvalidSession)
+	}
+	if !signInNonce.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: the session expired", utils.ErrInvalidSession)
+	}
+
+	typedData, err := SignInTypedData(signInNonce)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := utils.TypedDataHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyWalletSignature(wallet, hash, parts[2]); err != nil {
+		return nil, err
+	}
+
+	return signInNonce, nil
+}
+
+/*
+Checks that `signature` of `hash` was made by `wallet`: an ECDSA signature if it's a regular wallet or, if it's a contract wallet
+(e.g. a Safe multisig), a signature its `isValidSignature` (EIP-1271) accepts on the chain of the key collection.
+*/
+func VerifyWalletSignature(wallet string, hash []byte, signature string) error {
+	wallet = strings.ToLower(wallet)
+
+	// regular wallets are checked first, so that they don't need the chain.
+	signer, recoverErr := utils.RecoverSigner(hash, signature)
+	if recoverErr == nil && strings.ToLower(signer.Hex()) == wallet {
+		return nil
+	}
+
+	keys, err := CollectionByRole(RoleKey)
+	if err != nil {
+		return err
+	}
+	var valid bool
+	err = keys.Chain.Read(context.Background(), func(backend ChainBackend, opts *bind.CallOpts) (err error) {
+		valid, err = utils.IsValidContractSignature(backend, opts, common.HexToAddress(wallet), hash, signature)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if valid {
+		return nil
+	}
+
+	if recoverErr != nil {
+		return recoverErr
+	}
+	return fmt.Errorf("%w: signed by %s", utils.ErrInvalidSignature, signer.Hex())
+}
@@ -0,0 +1,268 @@
+package utils_nft
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+Returns the runtime code of a minimal EIP-1271 contract wallet: `isValidSignature` returns the magic value (0x1626ba7e)
+for `hash` (whatever the signature) and reverts for any other hash.
+*/
+func eip1271Code(hash []byte) []byte {
+	code := append([]byte{0x7f}, hash...)
+	// compares the first argument with `hash`, reverting if it differs, and returns the magic value otherwise.
+	return append(code, hexutil.MustDecode("0x60043514602d5760006000fd5b631626ba7e60e01b60005260206000f3")...)
+}
+
+/*
+Runs the rest of the test from the root of the module, since `utils.IsValidContractSignature` reads its ABI from `abi/`.
+*/
+func chdirRoot(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+/*
+Nonces stored in memory instead of `RHSignInNonces`.
+*/
+type testSignInNonces map[string]*models.SignInNonce
+
+func (n testSignInNonces) Insert(nonce *models.SignInNonce) error {
+	n[nonce.Nonce] = nonce
+	return nil
+}
+
+func (n testSignInNonces) Find(nonce string) (*models.SignInNonce, error) {
+	return n[nonce], nil
+}
+
+func (n testSignInNonces) Revoke(nonce string, at time.Time) error {
+	if signInNonce, ok := n[nonce]; ok && signInNonce.RevokedAt == nil {
+		signInNonce.RevokedAt = &at
+	}
+	return nil
+}
+
+func useTestSignInNonces(t *testing.T) testSignInNonces {
+	nonces := testSignInNonces{}
+	previous := signInNonces
+	signInNonces = nonces
+	t.Cleanup(func() { signInNonces = previous })
+	return nonces
+}
+
+func issueSignInNonce(t *testing.T, wallet string) *models.SignInChallenge {
+	t.Helper()
+	challenge, err := IssueSignInNonce(wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
+
+func signedSessionToken(t *testing.T, challenge *models.SignInChallenge, sign func(hash []byte) string) string {
+	t.Helper()
+	hash, err := utils.TypedDataHash(challenge.TypedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fmt.Sprintf("%s%s:%s:%s", SignedSessionPrefix, challenge.Wallet, challenge.Nonce, sign(hash))
+}
+
+/*
+Returns the other valid signature of the same hash, with `s` in the upper half of the curve order.
+*/
+func highS(signature []byte) []byte {
+	malleable := append([]byte{}, signature...)
+	s := new(big.Int).Sub(crypto.S256().Params().N, new(big.Int).SetBytes(signature[32:64]))
+	s.FillBytes(malleable[32:64])
+	malleable[crypto.RecoveryIDOffset] ^= 1
+	return malleable
+}
+
+func TestVerifyWalletSignature(t *testing.T) {
+	loadTestCollections(t)
+	chdirRoot(t)
+
+	signed := crypto.Keccak256([]byte("stake"))
+	other := crypto.Keccak256([]byte("unstake"))
+
+	backend, contractWallet := simulatedChain(t, func(common.Address) []byte { return eip1271Code(signed) })
+	useTestRPCs(t, map[string]rpcClient{"http://mainnet-1": testRPC{backend, 1337}})
+
+	walletKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(walletKey.PublicKey)
+	signature, err := crypto.Sign(signed, walletKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		wallet    common.Address
+		hash      []byte
+		signature string
+		err       error
+	}{
+		{"regular wallet", wallet, signed, hexutil.Encode(signature), nil},
+		{"regular wallet, other hash", wallet, other, hexutil.Encode(signature), utils.ErrInvalidSignature},
+		{"regular wallet, high s", wallet, signed, hexutil.Encode(highS(signature)), utils.ErrInvalidSignature},
+		{"contract wallet", contractWallet, signed, "0x", nil},
+		{"contract wallet, rejected", contractWallet, other, "0x", utils.ErrInvalidSignature},
+		{"contract wallet, signed by another wallet", contractWallet, other, hexutil.Encode(signature), utils.ErrInvalidSignature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyWalletSignature(test.wallet.Hex(), test.hash, test.signature)
+			if !errors.Is(err, test.err) {
+				t.Errorf("VerifyWalletSignature() = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestWalletFromSignedSessionToken(t *testing.T) {
+	loadTestCollections(t)
+	chdirRoot(t)
+	nonces := useTestSignInNonces(t)
+
+	// the contract wallet accepts the sign-in with the first nonce issued to it.
+	var contractChallenge *models.SignInChallenge
+	backend, contractWallet := simulatedChain(t, func(contract common.Address) []byte {
+		contractChallenge = issueSignInNonce(t, contract.Hex())
+		hash, err := utils.TypedDataHash(contractChallenge.TypedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return eip1271Code(hash)
+	})
+	useTestRPCs(t, map[string]rpcClient{"http://mainnet-1": testRPC{backend, 1337}})
+
+	walletKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+	signWith := func(hash []byte) string {
+		signature, err := crypto.Sign(hash, walletKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hexutil.Encode(signature)
+	}
+	signWithHighS := func(hash []byte) string {
+		signature, err := crypto.Sign(hash, walletKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hexutil.Encode(highS(signature))
+	}
+	noSignature := func([]byte) string { return "0x" }
+
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherWallet := crypto.PubkeyToAddress(otherKey.PublicKey).Hex()
+
+	challenge := issueSignInNonce(t, wallet)
+	otherChallenge := issueSignInNonce(t, otherWallet)
+	// a token signed for the nonce of another wallet.
+	stolenChallenge := *otherChallenge
+	stolenChallenge.Wallet = strings.ToLower(wallet)
+	unknownChallenge := *challenge
+	unknownChallenge.Nonce = "0123456789abcdef0123456789abcdef"
+	expiredChallenge := issueSignInNonce(t, wallet)
+	nonces[expiredChallenge.Nonce].ExpiresAt = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		sessionToken string
+		want         string
+		err          error
+	}{
+		{"regular wallet", signedSessionToken(t, challenge, signWith), strings.ToLower(wallet), nil},
+		{"regular wallet, high s", signedSessionToken(t, challenge, signWithHighS), "", utils.ErrInvalidSignature},
+		{"contract wallet", signedSessionToken(t, contractChallenge, noSignature), strings.ToLower(contractWallet.Hex()), nil},
+		{"contract wallet, other nonce", signedSessionToken(t, issueSignInNonce(t, contractWallet.Hex()), noSignature), "", utils.ErrInvalidSignature},
+		{"signed by another wallet", signedSessionToken(t, otherChallenge, signWith), "", utils.ErrInvalidSignature},
+		{"nonce of another wallet", signedSessionToken(t, &stolenChallenge, signWith), "", utils.ErrInvalidSession},
+		{"unknown nonce", signedSessionToken(t, &unknownChallenge, signWith), "", utils.ErrInvalidSession},
+		{"expired", signedSessionToken(t, expiredChallenge, signWith), "", utils.ErrInvalidSession},
+		{"malformed", SignedSessionPrefix + wallet, "", utils.ErrInvalidSession},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := WalletFromSessionToken(test.sessionToken)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("WalletFromSessionToken() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	loadTestCollections(t)
+	useTestSignInNonces(t)
+
+	walletKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+	sessionToken := signedSessionToken(t, issueSignInNonce(t, wallet), func(hash []byte) string {
+		signature, err := crypto.Sign(hash, walletKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hexutil.Encode(signature)
+	})
+
+	if _, err := WalletFromSessionToken(sessionToken); err != nil {
+		t.Fatalf("unexpected error before signing out: %v", err)
+	}
+	// only the wallet of the session can sign it out.
+	if err := SignOut("0x1f2e3d4c5b6a79880716253443526170819a0b1c", sessionToken); !errors.Is(err, utils.ErrSessionMismatch) {
+		t.Errorf("SignOut() of another wallet = %v, want %v", err, utils.ErrSessionMismatch)
+	}
+	if err := SignOut(wallet, sessionToken); err != nil {
+		t.Fatalf("SignOut() = %v", err)
+	}
+	// the token can't be used (or signed out) again once its nonce is revoked.
+	if _, err := WalletFromSessionToken(sessionToken); !errors.Is(err, utils.ErrInvalidSession) {
+		t.Errorf("error after signing out = %v, want %v", err, utils.ErrInvalidSession)
+	}
+	if err := SignOut(wallet, sessionToken); !errors.Is(err, utils.ErrInvalidSession) {
+		t.Errorf("SignOut() again = %v, want %v", err, utils.ErrInvalidSession)
+	}
+
+	// the sessions of the webapp are signed out there.
+	if err := SignOut(wallet, "webapp-session"); !errors.Is(err, utils.ErrInvalidRequest) {
+		t.Errorf("SignOut() of a webapp session = %v, want %v", err, utils.ErrInvalidRequest)
+	}
+}
@@ -0,0 +1,88 @@
+package utils_notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// returned when a notification should be emailed but SMTP isn't configured.
+var errEmailNotConfigured = errors.New("SMTP_HOST and NOTIFICATIONS_EMAIL_FROM must be set to send emails")
+
+/*
+Emails a notification to `to` through the SMTP server in `SMTP_HOST` (and `SMTP_PORT`, `SMTP_USERNAME`, `SMTP_PASSWORD`),
+sent from `NOTIFICATIONS_EMAIL_FROM`.
+*/
+func sendEmail(to, subject, body string) error {
+	host, from := os.Getenv("SMTP_HOST"), os.Getenv("NOTIFICATIONS_EMAIL_FROM")
+	if host == "" || from == "" {
+		return errEmailNotConfigured
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{to}, []byte(message))
+}
+
+/*
+Posts a notification to a Discord webhook.
+*/
+func sendDiscord(webhookURL, title, body string) error {
+	payload, err := json.Marshal(map[string]string{
+		"username": "NBC Staking",
+		"content":  fmt.Sprintf("**%s**\n%s", title, body),
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := httpClient.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("discord responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+/*
+Checks whether `url` is a Discord webhook URL, so that stakers can't make the API send requests anywhere else.
+*/
+func validDiscordWebhookURL(url string) bool {
+	for _, prefix := range []string{"https://discord.com/api/webhooks/", "https://discordapp.com/api/webhooks/"} {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,10 @@
+package utils_notifications
+
+import "nbc-backend-api-v2/utils"
+
+/*
+Domain errors returned by the notification functions.
+*/
+var (
+	ErrNotificationNotFound = utils.NewDomainError(utils.KindNotFound, "NOTIFICATION_NOT_FOUND", "notification not found")
+)
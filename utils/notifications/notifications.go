@@ -0,0 +1,292 @@
+package utils_notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	UtilsNFT "nbc-backend-api-v2/utils/nfts"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Returns the preferences of wallets that haven't set any: in-app inbox only, nothing muted.
+*/
+func defaultPreferences(wallet string) *models.NotificationPreferences {
+	return &models.NotificationPreferences{
+		Wallet:     wallet,
+		Channels:   []string{models.NotificationChannelInApp},
+		MutedKinds: []string{},
+	}
+}
+
+/*
+Gets the notification preferences of `wallet` (or the default preferences if it hasn't set any), without checking the session token.
+*/
+func getPreferences(collection *mongo.Collection, wallet string) (*models.NotificationPreferences, error) {
+	wallet = strings.ToLower(wallet)
+
+	var preferences models.NotificationPreferences
+	err := collection.FindOne(context.Background(), bson.M{"wallet": wallet}).Decode(&preferences)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return defaultPreferences(wallet), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return &preferences, nil
+}
+
+/*
+Gets the notification preferences of `wallet`. `sessionToken` must belong to `wallet`.
+*/
+func GetPreferences(collection *mongo.Collection, sessionToken, wallet string) (*models.NotificationPreferences, error) {
+	if collection.Name() != "RHNotificationPreferences" {
+		return nil, errors.New("collection must be RHNotificationPreferences")
+	}
+
+	if err := checkSession(sessionToken, wallet); err != nil {
+		return nil, err
+	}
+
+	return getPreferences(collection, wallet)
+}
+
+/*
+Replaces the notification preferences of `wallet`. `sessionToken` must belong to `wallet`.
+The `email` and `discord` channels require `Email` and `DiscordWebhookURL` respectively.
+*/
+func UpdatePreferences(collection *mongo.Collection, sessionToken, wallet string, preferences *models.NotificationPreferences) (*models.NotificationPreferences, error) {
+	if collection.Name() != "RHNotificationPreferences" {
+		return nil, errors.New("collection must be RHNotificationPreferences")
+	}
+
+	if err := checkSession(sessionToken, wallet); err != nil {
+		return nil, err
+	}
+
+	for _, channel := range preferences.Channels {
+		switch channel {
+		case models.NotificationChannelEmail:
+			if preferences.Email == "" {
+				return nil, fmt.Errorf("%w: an email is required for the email channel", utils.ErrValidationFailed)
+			}
+		case models.NotificationChannelDiscord:
+			if !validDiscordWebhookURL(preferences.DiscordWebhookURL) {
+				return nil, fmt.Errorf("%w: a Discord webhook URL (https://discord.com/api/webhooks/...) is required for the discord channel", utils.ErrValidationFailed)
+			}
+		}
+	}
+	if preferences.DiscordWebhookURL != "" && !validDiscordWebhookURL(preferences.DiscordWebhookURL) {
+		return nil, fmt.Errorf("%w: discordWebhookUrl must be a Discord webhook URL (https://discord.com/api/webhooks/...)", utils.ErrValidationFailed)
+	}
+
+	preferences.Wallet = strings.ToLower(wallet)
+	preferences.UpdatedAt = time.Now()
+	if preferences.Channels == nil {
+		preferences.Channels = []string{}
+	}
+	if preferences.MutedKinds == nil {
+		preferences.MutedKinds = []string{}
+	}
+
+	opts := options.Replace().SetUpsert(true)
+	if _, err := collection.ReplaceOne(context.Background(), bson.M{"wallet": preferences.Wallet}, preferences, opts); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return preferences, nil
+}
+
+/*
+Gets the newest `limit` notifications in the in-app inbox of `wallet` (only the unread ones if `unreadOnly` is true)
+and how many are unread in total. `sessionToken` must belong to `wallet`.
+*/
+func GetInbox(collection *mongo.Collection, sessionToken, wallet string, unreadOnly bool, limit int) (*models.NotificationInbox, error) {
+	if collection.Name() != "RHNotifications" {
+		return nil, errors.New("collection must be RHNotifications")
+	}
+
+	if err := checkSession(sessionToken, wallet); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"wallet": strings.ToLower(wallet), "inApp": true}
+	unreadFilter := bson.M{"wallet": strings.ToLower(wallet), "inApp": true, "readAt": nil}
+	if unreadOnly {
+		filter = unreadFilter
+	}
+
+	opts := options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(int64(limit))
+	cursor, err := collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	inbox := &models.NotificationInbox{Notifications: []*models.Notification{}}
+	if err := cursor.All(context.Background(), &inbox.Notifications); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	inbox.UnreadCount, err = collection.CountDocuments(context.Background(), unreadFilter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return inbox, nil
+}
+
+/*
+Marks the notification with ID `notificationId` in the inbox of `wallet` as read. `sessionToken` must belong to `wallet`.
+*/
+func MarkAsRead(collection *mongo.Collection, sessionToken, wallet string, notificationId primitive.ObjectID) error {
+	if collection.Name() != "RHNotifications" {
+		return errors.New("collection must be RHNotifications")
+	}
+
+	if err := checkSession(sessionToken, wallet); err != nil {
+		return err
+	}
+
+	// only set `readAt` the first time, so that it stays the time the notification was first read.
+	filter := bson.M{"_id": notificationId, "wallet": strings.ToLower(wallet), "inApp": true}
+	update := bson.A{bson.M{"$set": bson.M{"readAt": bson.M{"$ifNull": bson.A{"$readAt", time.Now()}}}}}
+
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotificationNotFound
+	}
+
+	return nil
+}
+
+/*
+Sends a `kind` notification (rendered from its template with `data`) to `data.Wallet`, unless the wallet muted `kind`.
+
+`dedupeKey` identifies the notification (e.g. `rewardExpiring:3:12`): a notification is only sent once per key,
+so reminders can be computed again on every run. The notification is always stored in `RHNotifications` (and shown in the
+in-app inbox if that channel is enabled); sending it by email or Discord is best effort and only logged if it fails.
+*/
+func Notify(preferencesCollection, notificationsCollection *mongo.Collection, kind, dedupeKey string, data *TemplateData) error {
+	if preferencesCollection.Name() != "RHNotificationPreferences" {
+		return errors.New("collection must be RHNotificationPreferences")
+	}
+	if notificationsCollection.Name() != "RHNotifications" {
+		return errors.New("collection must be RHNotifications")
+	}
+
+	preferences, err := getPreferences(preferencesCollection, data.Wallet)
+	if err != nil {
+		return err
+	}
+	if contains(preferences.MutedKinds, kind) {
+		return nil
+	}
+
+	title, body, err := render(kind, data)
+	if err != nil {
+		return err
+	}
+
+	notification := &models.Notification{
+		ID:            primitive.NewObjectID(),
+		Wallet:        preferences.Wallet,
+		Kind:          kind,
+		Title:         title,
+		Body:          body,
+		StakingPoolID: data.StakingPoolID,
+		SubpoolID:     data.SubpoolID,
+		DedupeKey:     dedupeKey,
+		InApp:         contains(preferences.Channels, models.NotificationChannelInApp),
+		CreatedAt:     time.Now(),
+	}
+
+	// the notification is only inserted (and sent) if no notification with the same key exists yet.
+	result, err := notificationsCollection.UpdateOne(
+		context.Background(),
+		bson.M{"dedupeKey": dedupeKey},
+		bson.M{"$setOnInsert": notification},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	if result.UpsertedCount == 0 {
+		return nil
+	}
+
+	if contains(preferences.Channels, models.NotificationChannelEmail) && preferences.Email != "" {
+		if err := sendEmail(preferences.Email, title, body); err != nil {
+			log.Printf("unable to email %s notification %s to %s: %v", kind, dedupeKey, preferences.Wallet, err)
+		}
+	}
+	if contains(preferences.Channels, models.NotificationChannelDiscord) && preferences.DiscordWebhookURL != "" {
+		if err := sendDiscord(preferences.DiscordWebhookURL, title, body); err != nil {
+			log.Printf("unable to send %s notification %s to the Discord webhook of %s: %v", kind, dedupeKey, preferences.Wallet, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+Sends the notifications for a domain event from the outbox. Only `subpoolBanned` events notify anyone for now.
+*/
+func NotifyEvent(preferencesCollection, notificationsCollection *mongo.Collection, event *events.OutboxEvent) error {
+	domainEvent, err := event.Decode()
+	if err != nil {
+		return err
+	}
+
+	switch e := domainEvent.(type) {
+	case *events.SubpoolBannedEvent:
+		if e.StakerWallet == "" {
+			return nil
+		}
+		return Notify(preferencesCollection, notificationsCollection, models.NotificationSubpoolBanned,
+			fmt.Sprintf("%s:%d:%d", models.NotificationSubpoolBanned, e.StakingPoolID, e.SubpoolID),
+			&TemplateData{Wallet: e.StakerWallet, StakingPoolID: e.StakingPoolID, SubpoolID: e.SubpoolID},
+		)
+	}
+
+	return nil
+}
+
+/*
+Checks that `sessionToken` belongs to `wallet`.
+*/
+func checkSession(sessionToken, wallet string) error {
+	sessionWallet, err := UtilsNFT.WalletFromSessionToken(sessionToken)
+	if err != nil {
+		return err
+	}
+	if sessionWallet != strings.ToLower(wallet) {
+		return utils.ErrSessionMismatch
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
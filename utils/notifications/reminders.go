@@ -0,0 +1,230 @@
+package utils_notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nbc-backend-api-v2/models"
+	UtilsKOS "nbc-backend-api-v2/utils/nfts/kos"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	EntryClosingReminderLead   = 24 * time.Hour // how long before a staking pool's `StartTime` (when entry closes) stakers are reminded
+	RewardExpiringReminderLead = 24 * time.Hour // how long before an unclaimed reward expires its staker is reminded
+)
+
+/*
+Sends the reminders that are due at `now`:
+
+ 1. `entryClosing` to every staker (and every wallet with notification preferences) once a staking pool's entry window
+    has opened (`EntryAllowance`) and closes (`StartTime`) within `EntryClosingReminderLead`.
+ 2. `rewardExpiring` to the staker of every closed subpool with an unclaimed reward that expires
+    (`EndTime` + `UtilsKOS.RewardClaimWindow`) within `RewardExpiringReminderLead`.
+
+Each reminder is only sent once (see `Notify`), so this can run as often as needed.
+*/
+func SendReminders(poolsCollection, stakersCollection, preferencesCollection, notificationsCollection *mongo.Collection, now time.Time) error {
+	if poolsCollection.Name() != "RHStakingPool" {
+		return errors.New("collection must be RHStakingPool")
+	}
+	if stakersCollection.Name() != "RHStakerData" {
+		return errors.New("collection must be RHStakerData")
+	}
+
+	if err := sendEntryClosingReminders(poolsCollection, stakersCollection, preferencesCollection, notificationsCollection, now); err != nil {
+		return err
+	}
+
+	return sendRewardExpiringReminders(poolsCollection, stakersCollection, preferencesCollection, notificationsCollection, now)
+}
+
+func sendEntryClosingReminders(poolsCollection, stakersCollection, preferencesCollection, notificationsCollection *mongo.Collection, now time.Time) error {
+	filter := bson.M{
+		"entryAllowance": bson.M{"$lte": now},
+		"startTime":      bson.M{"$gt": now, "$lte": now.Add(EntryClosingReminderLead)},
+	}
+
+	stakingPools, err := findStakingPools(poolsCollection, filter)
+	if err != nil || len(stakingPools) == 0 {
+		return err
+	}
+
+	wallets, err := reminderWallets(stakersCollection, preferencesCollection)
+	if err != nil {
+		return err
+	}
+
+	for _, stakingPool := range stakingPools {
+		// skip the wallets that were already reminded in a previous run, since this can be a lot of wallets.
+		reminded, err := notificationsCollection.Distinct(context.Background(), "wallet", bson.M{
+			"kind":          models.NotificationEntryClosing,
+			"stakingPoolID": stakingPool.StakingPoolID,
+		})
+		if err != nil {
+			return err
+		}
+		skip := make(map[string]bool, len(reminded))
+		for _, wallet := range reminded {
+			if wallet, ok := wallet.(string); ok {
+				skip[wallet] = true
+			}
+		}
+
+		for _, wallet := range wallets {
+			if skip[wallet] {
+				continue
+			}
+
+			err := Notify(preferencesCollection, notificationsCollection, models.NotificationEntryClosing,
+				fmt.Sprintf("%s:%d:%s", models.NotificationEntryClosing, stakingPool.StakingPoolID, wallet),
+				&TemplateData{
+					Wallet:        wallet,
+					StakingPoolID: stakingPool.StakingPoolID,
+					RewardName:    stakingPool.Reward.Name,
+					RewardAmount:  stakingPool.Reward.Amount,
+					Deadline:      stakingPool.StartTime,
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func sendRewardExpiringReminders(poolsCollection, stakersCollection, preferencesCollection, notificationsCollection *mongo.Collection, now time.Time) error {
+	// rewards expire `RewardClaimWindow` after `EndTime`, so these are the pools whose rewards expire within the lead time.
+	filter := bson.M{"endTime": bson.M{
+		"$gt":  now.Add(-UtilsKOS.RewardClaimWindow),
+		"$lte": now.Add(-UtilsKOS.RewardClaimWindow + RewardExpiringReminderLead),
+	}}
+
+	stakingPools, err := findStakingPools(poolsCollection, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, stakingPool := range stakingPools {
+		var subpools []*models.StakingSubpool
+		for _, subpool := range stakingPool.ClosedSubpools {
+			if subpool.RewardClaimable && !subpool.RewardClaimed && !subpool.Banned {
+				subpools = append(subpools, subpool)
+			}
+		}
+
+		wallets, err := subpoolWallets(stakersCollection, subpools)
+		if err != nil {
+			return err
+		}
+
+		for _, subpool := range subpools {
+			wallet := wallets[subpool.SubpoolID]
+			if wallet == "" {
+				continue
+			}
+
+			err := Notify(preferencesCollection, notificationsCollection, models.NotificationRewardExpiring,
+				fmt.Sprintf("%s:%d:%d", models.NotificationRewardExpiring, stakingPool.StakingPoolID, subpool.SubpoolID),
+				&TemplateData{
+					Wallet:        wallet,
+					StakingPoolID: stakingPool.StakingPoolID,
+					SubpoolID:     subpool.SubpoolID,
+					RewardName:    stakingPool.Reward.Name,
+					Deadline:      stakingPool.EndTime.Add(UtilsKOS.RewardClaimWindow),
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func findStakingPools(collection *mongo.Collection, filter bson.M) ([]*models.StakingPool, error) {
+	cursor, err := collection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var stakingPools []*models.StakingPool
+	if err := cursor.All(context.Background(), &stakingPools); err != nil {
+		return nil, err
+	}
+
+	return stakingPools, nil
+}
+
+/*
+Returns the (lowercase) wallets of every staker and every wallet with notification preferences.
+*/
+func reminderWallets(stakersCollection, preferencesCollection *mongo.Collection) ([]string, error) {
+	seen := map[string]bool{}
+	var wallets []string
+
+	for _, collection := range []*mongo.Collection{stakersCollection, preferencesCollection} {
+		values, err := collection.Distinct(context.Background(), "wallet", bson.M{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range values {
+			wallet, _ := value.(string)
+			wallet = strings.ToLower(wallet)
+			if wallet == "" || seen[wallet] {
+				continue
+			}
+			seen[wallet] = true
+			wallets = append(wallets, wallet)
+		}
+	}
+
+	return wallets, nil
+}
+
+/*
+Returns the (lowercase) wallet of the staker of each of `subpools`, by subpool ID.
+Subpools added before the wallet was stored on the subpool are looked up by the staker's object ID.
+*/
+func subpoolWallets(stakersCollection *mongo.Collection, subpools []*models.StakingSubpool) (map[int]string, error) {
+	wallets := make(map[int]string, len(subpools))
+
+	var stakerObjIds []primitive.ObjectID
+	for _, subpool := range subpools {
+		if subpool.StakerWallet != "" {
+			wallets[subpool.SubpoolID] = strings.ToLower(subpool.StakerWallet)
+		} else if subpool.Staker != nil {
+			stakerObjIds = append(stakerObjIds, *subpool.Staker)
+		}
+	}
+	if len(stakerObjIds) == 0 {
+		return wallets, nil
+	}
+
+	stakers, err := UtilsKOS.GetStakersFromObjIDs(stakersCollection, stakerObjIds)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]string, len(stakers))
+	for _, staker := range stakers {
+		byID[staker.ID] = strings.ToLower(staker.Wallet)
+	}
+
+	for _, subpool := range subpools {
+		if _, ok := wallets[subpool.SubpoolID]; !ok && subpool.Staker != nil {
+			wallets[subpool.SubpoolID] = byID[*subpool.Staker]
+		}
+	}
+
+	return wallets, nil
+}
@@ -0,0 +1,94 @@
+package utils_notifications
+
+import (
+	"bytes"
+	"fmt"
+	"nbc-backend-api-v2/models"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+/*
+The data available to the notification templates. Only the fields relevant to the notification's kind are set.
+*/
+type TemplateData struct {
+	Wallet        string
+	StakingPoolID int
+	SubpoolID     int
+	RewardName    string
+	RewardAmount  float64
+	Deadline      time.Time // when the entry window closes or the reward expires
+}
+
+type messageTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	// formats a time in UTC, e.g. "Sep 7, 2023 00:00 UTC"
+	"formatTime": func(t time.Time) string {
+		return t.UTC().Format("Jan 2, 2006 15:04 UTC")
+	},
+	// formats an amount without an exponent, e.g. "100000" instead of "1e+05"
+	"amount": func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	},
+	// formats the time left until `t`, rounded to the hour, e.g. "23 hours"
+	"timeLeft": func(t time.Time) string {
+		hours := int(time.Until(t).Round(time.Hour).Hours())
+		if hours <= 1 {
+			return "less than an hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	},
+}
+
+/*
+The title and body templates of each kind of notification.
+*/
+var templates = map[string]*messageTemplate{
+	models.NotificationEntryClosing: newMessageTemplate(
+		"Staking pool #{{.StakingPoolID}} closes entry soon",
+		"Entry to staking pool #{{.StakingPoolID}} closes on {{formatTime .Deadline}} ({{timeLeft .Deadline}} from now). "+
+			"Stake your keys before then to earn a share of {{amount .RewardAmount}} {{.RewardName}}.",
+	),
+	models.NotificationRewardExpiring: newMessageTemplate(
+		"Your reward for subpool #{{.SubpoolID}} expires soon",
+		"The {{.RewardName}} reward of your subpool #{{.SubpoolID}} in staking pool #{{.StakingPoolID}} can be claimed until "+
+			"{{formatTime .Deadline}} ({{timeLeft .Deadline}} from now). Unclaimed rewards can't be claimed after that.",
+	),
+	models.NotificationSubpoolBanned: newMessageTemplate(
+		"Subpool #{{.SubpoolID}} was banned",
+		"Your subpool #{{.SubpoolID}} in staking pool #{{.StakingPoolID}} was banned because one or more of its NFTs are no longer in your wallet. "+
+			"Its reward can't be claimed.",
+	),
+}
+
+func newMessageTemplate(title, body string) *messageTemplate {
+	return &messageTemplate{
+		title: template.Must(template.New("title").Funcs(templateFuncs).Parse(title)),
+		body:  template.Must(template.New("body").Funcs(templateFuncs).Parse(body)),
+	}
+}
+
+/*
+Renders the title and body of a `kind` notification with `data`.
+*/
+func render(kind string, data *TemplateData) (title, body string, err error) {
+	tmpl, ok := templates[kind]
+	if !ok {
+		return "", "", fmt.Errorf("no template for notification kind %q", kind)
+	}
+
+	var titleBuf, bodyBuf bytes.Buffer
+	if err := tmpl.title.Execute(&titleBuf, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.body.Execute(&bodyBuf, data); err != nil {
+		return "", "", err
+	}
+
+	return titleBuf.String(), bodyBuf.String(), nil
+}
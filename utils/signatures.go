@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+var ErrInvalidSignature = NewDomainError(KindForbidden, "INVALID_SIGNATURE", "signature is invalid or was not signed by the wallet given")
+
+// what `isValidSignature` returns for a valid signature (its own selector), as defined by EIP-1271.
+var eip1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+/*
+Returns the EIP-712 hash of `typedData` (what a wallet signs with `eth_signTypedData_v4`).
+*/
+func TypedDataHash(typedData apitypes.TypedData) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	return hash, nil
+}
+
+/*
+Recovers the address that signed `hash` with the 65 byte (r, s, v) `signature` in hex. `v` can be 0/1 or 27/28.
+Signatures with a high `s` (above secp256k1n/2) are rejected, since flipping `s` gives a second valid signature of the same hash (malleability).
+*/
+func RecoverSigner(hash []byte, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: signature must be 65 bytes in hex", ErrInvalidSignature)
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if !crypto.ValidateSignatureValues(sig[crypto.RecoveryIDOffset], r, s, true) {
+		return common.Address{}, fmt.Errorf("%w: r, s or v is out of range (s must be in the lower half of the curve order)", ErrInvalidSignature)
+	}
+
+	publicKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+/*
+Checks if the contract wallet at `signer` (e.g. a Safe multisig) accepts `signature` for `hash` through its `isValidSignature` (EIP-1271).
+Contract wallets can't sign with a key, so this is how they sign messages; the signature's format is up to the contract.
+Returns false if `signer` has no code (i.e. it's a regular wallet) or the contract rejects the signature.
+
+	`backend` the client of the chain the contract wallet is deployed on
+	`opts` the options of the read (e.g. from `Chain.CallOpts`)
+*/
+func IsValidContractSignature(backend bind.ContractCaller, opts *bind.CallOpts, signer common.Address, hash []byte, signature string) (bool, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return false, fmt.Errorf("%w: signature must be in hex", ErrInvalidSignature)
+	}
+
+	code, err := backend.CodeAt(opts.Context, signer, opts.BlockNumber)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrChainUnavailable, err)
+	}
+	if len(code) == 0 {
+		return false, nil
+	}
+
+	contract, err := BindContractAt("abi/EIP1271.json", signer, backend, nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrChainUnavailable, err)
+	}
+
+	var rawResult []interface{}
+	err = contract.Call(opts, &rawResult, "isValidSignature", common.BytesToHash(hash), sig)
+	if err != nil {
+		// most contract wallets revert on an invalid signature instead of returning something other than the magic value.
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) || strings.Contains(err.Error(), "execution reverted") {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: %v", ErrChainUnavailable, err)
+	}
+
+	magicValue, ok := rawResult[0].([4]byte)
+	if !ok {
+		return false, fmt.Errorf("%w: unexpected isValidSignature result %T", ErrChainUnavailable, rawResult[0])
+	}
+
+	return magicValue == eip1271MagicValue, nil
+}
@@ -0,0 +1,11 @@
+package utils_webhooks
+
+import "nbc-backend-api-v2/utils"
+
+/*
+Domain errors returned by the webhook functions.
+*/
+var (
+	ErrWebhookNotFound  = utils.NewDomainError(utils.KindNotFound, "WEBHOOK_NOT_FOUND", "webhook not found")
+	ErrDeliveryNotFound = utils.NewDomainError(utils.KindNotFound, "WEBHOOK_DELIVERY_NOT_FOUND", "webhook delivery not found")
+)
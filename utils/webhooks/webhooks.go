@@ -0,0 +1,397 @@
+package utils_webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"nbc-backend-api-v2/events"
+	"nbc-backend-api-v2/models"
+	"nbc-backend-api-v2/utils"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MaxDeliveryAttempts = 8                // a delivery is moved to the dead-letter list after this many failed attempts
+	deliveryTimeout     = 10 * time.Second // how long a webhook may take to respond
+	deliveryLease       = time.Minute      // how long a claimed delivery is hidden from other senders
+	initialRetryDelay   = 10 * time.Second // doubles after every failed attempt (10s, 20s, 40s, ... up to `maxRetryDelay`)
+	maxRetryDelay       = time.Hour
+	maxDeliveriesPerRun = 100 // the max number of deliveries sent per `SendDueDeliveries` call; the rest are sent in the next run
+)
+
+var httpClient = &http.Client{Timeout: deliveryTimeout}
+
+/*
+Adds a webhook that receives the events in `eventTypes` (or every event if empty).
+If `secret` is empty, a random secret is generated. The returned webhook is the only place the secret is returned.
+*/
+func AddWebhook(collection *mongo.Collection, url string, eventTypes []string, secret string) (*models.Webhook, error) {
+	if collection.Name() != "RHWebhooks" {
+		return nil, errors.New("collection must be RHWebhooks")
+	}
+
+	for _, eventType := range eventTypes {
+		if !events.KnownEventType(events.EventType(eventType)) {
+			return nil, fmt.Errorf("%w: unknown event type %q (must be one of %v)", utils.ErrValidationFailed, eventType, events.EventTypes())
+		}
+	}
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	if secret == "" {
+		var err error
+		secret, err = newSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	webhook := &models.Webhook{
+		ID:        primitive.NewObjectID(),
+		URL:       url,
+		Events:    eventTypes,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := collection.InsertOne(context.Background(), webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	log.Printf("added webhook %s for %s", webhook.ID.Hex(), url)
+	return webhook, nil
+}
+
+/*
+Gets all webhooks. Their secrets are left out.
+*/
+func GetWebhooks(collection *mongo.Collection) ([]*models.Webhook, error) {
+	if collection.Name() != "RHWebhooks" {
+		return nil, errors.New("collection must be RHWebhooks")
+	}
+
+	cursor, err := collection.Find(context.Background(), bson.M{}, options.Find().SetProjection(bson.M{"secret": 0}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	webhooks := []*models.Webhook{}
+	if err := cursor.All(context.Background(), &webhooks); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return webhooks, nil
+}
+
+/*
+Removes the webhook with ID `webhookId`. Its pending deliveries are moved to the dead-letter list the next time they're sent.
+*/
+func RemoveWebhook(collection *mongo.Collection, webhookId primitive.ObjectID) error {
+	if collection.Name() != "RHWebhooks" {
+		return errors.New("collection must be RHWebhooks")
+	}
+
+	result, err := collection.DeleteOne(context.Background(), bson.M{"_id": webhookId})
+	if err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+
+	log.Printf("removed webhook %s", webhookId.Hex())
+	return nil
+}
+
+/*
+Queues a delivery of `event` for every webhook subscribed to its type.
+Called by the outbox dispatcher, which may call it more than once for the same event; each webhook still gets one delivery.
+*/
+func QueueDeliveries(webhooksCollection, deliveriesCollection *mongo.Collection, event *events.OutboxEvent) error {
+	if webhooksCollection.Name() != "RHWebhooks" {
+		return errors.New("collection must be RHWebhooks")
+	}
+	if deliveriesCollection.Name() != "RHWebhookDeliveries" {
+		return errors.New("collection must be RHWebhookDeliveries")
+	}
+
+	// webhooks without any event types receive every event.
+	filter := bson.M{"$or": bson.A{
+		bson.M{"events": event.Type},
+		bson.M{"events": bson.M{"$size": 0}},
+	}}
+
+	cursor, err := webhooksCollection.Find(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	var webhooks []*models.Webhook
+	if err := cursor.All(context.Background(), &webhooks); err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	domainEvent, err := event.Decode()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(&models.WebhookPayload{
+		ID:         event.ID.Hex(),
+		Type:       string(event.Type),
+		OccurredAt: event.OccurredAt,
+		Data:       domainEvent,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		// upsert on the webhook and event IDs so that redelivered events don't create duplicate deliveries.
+		filter := bson.M{"webhookID": webhook.ID, "eventID": event.ID}
+		update := bson.M{"$setOnInsert": &models.WebhookDelivery{
+			ID:            primitive.NewObjectID(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}}
+
+		_, err := deliveriesCollection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Sends every due delivery to its webhook.
+A failed delivery is retried with an exponential backoff and moved to the dead-letter list after `MaxDeliveryAttempts` attempts.
+*/
+func SendDueDeliveries(webhooksCollection, deliveriesCollection *mongo.Collection) error {
+	if webhooksCollection.Name() != "RHWebhooks" {
+		return errors.New("collection must be RHWebhooks")
+	}
+	if deliveriesCollection.Name() != "RHWebhookDeliveries" {
+		return errors.New("collection must be RHWebhookDeliveries")
+	}
+
+	for i := 0; i < maxDeliveriesPerRun; i++ {
+		delivery, err := claimDelivery(deliveriesCollection)
+		if err != nil {
+			return err
+		}
+		if delivery == nil {
+			return nil
+		}
+
+		if err := sendDelivery(webhooksCollection, deliveriesCollection, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Claims the oldest due delivery by pushing its `NextAttemptAt` back by `deliveryLease`. Returns nil if no delivery is due.
+*/
+func claimDelivery(collection *mongo.Collection) (*models.WebhookDelivery, error) {
+	now := time.Now()
+
+	filter := bson.M{"status": models.WebhookDeliveryPending, "nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"nextAttemptAt": now.Add(deliveryLease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"nextAttemptAt": 1}).SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	if err := collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&delivery); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+/*
+Makes a single attempt at sending `delivery` and records the result.
+Only returns an error if the result can't be recorded.
+*/
+func sendDelivery(webhooksCollection, deliveriesCollection *mongo.Collection, delivery *models.WebhookDelivery) error {
+	var webhook models.Webhook
+	err := webhooksCollection.FindOne(context.Background(), bson.M{"_id": delivery.WebhookID}).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the webhook was removed, so there is nothing to retry.
+		return recordAttempt(deliveriesCollection, delivery, 0, errors.New("webhook was removed"), true)
+	}
+	if err != nil {
+		return err
+	}
+
+	statusCode, err := post(&webhook, delivery)
+	return recordAttempt(deliveriesCollection, delivery, statusCode, err, false)
+}
+
+/*
+POSTs the payload of `delivery` to `webhook`. Returns the response's status code and an error unless it's a 2xx.
+*/
+func post(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NBC-Webhooks/1.0")
+	req.Header.Set("X-NBC-Event", delivery.EventType)
+	req.Header.Set("X-NBC-Delivery", delivery.ID.Hex())
+	req.Header.Set(SignatureHeader, SignatureHeaderValue(webhook.Secret, time.Now(), body))
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// drain (part of) the body so that the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+/*
+Records an attempt at sending `delivery`. `sendErr` is nil if the attempt succeeded.
+The delivery is moved to the dead-letter list if `dead` is true or it has run out of attempts.
+*/
+func recordAttempt(collection *mongo.Collection, delivery *models.WebhookDelivery, statusCode int, sendErr error, dead bool) error {
+	applyAttempt(delivery, statusCode, sendErr, dead, time.Now())
+
+	set := bson.M{
+		"attempts":       delivery.Attempts,
+		"lastStatusCode": delivery.LastStatusCode,
+		"status":         delivery.Status,
+		"nextAttemptAt":  delivery.NextAttemptAt,
+		"lastError":      delivery.LastError,
+	}
+	if delivery.DeliveredAt != nil {
+		set["deliveredAt"] = delivery.DeliveredAt
+	}
+
+	_, err := collection.UpdateOne(context.Background(), bson.M{"_id": delivery.ID}, bson.M{"$set": set})
+	return err
+}
+
+/*
+Updates `delivery` with the result of an attempt made at `now`: delivered, dead (if `dead` is true or it has run out of attempts)
+or pending with its next attempt after the backoff.
+*/
+func applyAttempt(delivery *models.WebhookDelivery, statusCode int, sendErr error, dead bool, now time.Time) {
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case dead || delivery.Attempts >= MaxDeliveryAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.LastError = sendErr.Error()
+		log.Printf("webhook delivery %s (%s event) failed %d times and was moved to the dead-letter list: %v", delivery.ID.Hex(), delivery.EventType, delivery.Attempts, sendErr)
+	default:
+		delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+		delivery.LastError = sendErr.Error()
+	}
+}
+
+/*
+Returns how long to wait before the next attempt at a delivery that has already failed `attempts` times.
+*/
+func retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+/*
+Gets the deliveries in the dead-letter list (newest first), optionally only those of the webhook with ID `webhookId`.
+*/
+func GetDeadDeliveries(collection *mongo.Collection, webhookId *primitive.ObjectID) ([]*models.WebhookDelivery, error) {
+	if collection.Name() != "RHWebhookDeliveries" {
+		return nil, errors.New("collection must be RHWebhookDeliveries")
+	}
+
+	filter := bson.M{"status": models.WebhookDeliveryDead}
+	if webhookId != nil {
+		filter["webhookID"] = *webhookId
+	}
+
+	cursor, err := collection.Find(context.Background(), filter, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	defer cursor.Close(context.Background())
+
+	deliveries := []*models.WebhookDelivery{}
+	if err := cursor.All(context.Background(), &deliveries); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+
+	return deliveries, nil
+}
+
+/*
+Replays the delivery with ID `deliveryId`: it's sent again (with the same payload) as soon as possible,
+with a fresh set of `MaxDeliveryAttempts` attempts. Works for dead and already delivered deliveries alike.
+*/
+func ReplayDelivery(collection *mongo.Collection, deliveryId primitive.ObjectID) error {
+	if collection.Name() != "RHWebhookDeliveries" {
+		return errors.New("collection must be RHWebhookDeliveries")
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"status": models.WebhookDeliveryPending, "attempts": 0, "nextAttemptAt": time.Now()},
+		"$unset": bson.M{"deliveredAt": ""},
+	}
+
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": deliveryId}, update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrDatabaseUnavailable, err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrDeliveryNotFound
+	}
+
+	log.Printf("replaying webhook delivery %s", deliveryId.Hex())
+	return nil
+}
@@ -0,0 +1,148 @@
+package utils_webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nbc-backend-api-v2/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+A webhook receiver that responds with the next status code in `statuses` (repeating the last one) and checks the signature of every delivery.
+*/
+type receiver struct {
+	t        *testing.T
+	secret   string
+	statuses []int
+	requests int32
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Errorf("unable to read body: %v", err)
+	}
+	if !VerifySignature(r.secret, req.Header.Get(SignatureHeader), body, time.Minute) {
+		r.t.Errorf("%s header %q doesn't verify against the body", SignatureHeader, req.Header.Get(SignatureHeader))
+	}
+	if VerifySignature("whsec_wrong", req.Header.Get(SignatureHeader), body, time.Minute) {
+		r.t.Errorf("%s header verifies with the wrong secret", SignatureHeader)
+	}
+
+	i := int(atomic.AddInt32(&r.requests, 1)) - 1
+	if i >= len(r.statuses) {
+		i = len(r.statuses) - 1
+	}
+	w.WriteHeader(r.statuses[i])
+}
+
+func newDelivery() *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:        primitive.NewObjectID(),
+		EventType: "subpoolStaked",
+		Payload:   `{"id":"64f1c2a9e1b2c3d4e5f6071a","type":"subpoolStaked","data":{"stakingPoolId":3,"subpoolId":12}}`,
+		Status:    models.WebhookDeliveryPending,
+	}
+}
+
+/*
+Sends `delivery` to `webhook` like `SendDueDeliveries` does, until it's no longer pending. Returns the time of each attempt.
+*/
+func sendUntilDone(t *testing.T, webhook *models.Webhook, delivery *models.WebhookDelivery) []time.Time {
+	now := time.Now()
+	var attempts []time.Time
+	for delivery.Status == models.WebhookDeliveryPending {
+		if len(attempts) > MaxDeliveryAttempts {
+			t.Fatalf("delivery is still pending after %d attempts", len(attempts))
+		}
+		if len(attempts) > 0 {
+			// the next attempt is made once it's due.
+			now = delivery.NextAttemptAt
+		}
+		attempts = append(attempts, now)
+
+		statusCode, err := post(webhook, delivery)
+		applyAttempt(delivery, statusCode, err, false, now)
+	}
+
+	return attempts
+}
+
+func TestDeliverySigned(t *testing.T) {
+	r := &receiver{t: t, secret: "whsec_test", statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	webhook := &models.Webhook{URL: server.URL, Secret: r.secret}
+	delivery := newDelivery()
+	sendUntilDone(t, webhook, delivery)
+
+	if delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Errorf("got status %s after %d attempts, want delivered after 1", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestDeliveryRetriesServerErrors(t *testing.T) {
+	r := &receiver{t: t, secret: "whsec_test", statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	delivery := newDelivery()
+	attempts := sendUntilDone(t, &models.Webhook{URL: server.URL, Secret: r.secret}, delivery)
+
+	if delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 3 || r.requests != 3 {
+		t.Fatalf("got status %s after %d attempts (%d requests), want delivered after 3", delivery.Status, delivery.Attempts, r.requests)
+	}
+	if delivery.LastStatusCode != http.StatusOK || delivery.LastError != "" {
+		t.Errorf("got last status code %d and error %q, want 200 and no error", delivery.LastStatusCode, delivery.LastError)
+	}
+
+	// the delay doubles after every failed attempt.
+	for i, want := range []time.Duration{initialRetryDelay, 2 * initialRetryDelay} {
+		if delay := attempts[i+1].Sub(attempts[i]); delay != want {
+			t.Errorf("delay before attempt %d = %s, want %s", i+2, delay, want)
+		}
+	}
+}
+
+func TestDeliveryDeadLettered(t *testing.T) {
+	r := &receiver{t: t, secret: "whsec_test", statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	delivery := newDelivery()
+	sendUntilDone(t, &models.Webhook{URL: server.URL, Secret: r.secret}, delivery)
+
+	if delivery.Status != models.WebhookDeliveryDead {
+		t.Fatalf("got status %s, want dead", delivery.Status)
+	}
+	if delivery.Attempts != MaxDeliveryAttempts || int(r.requests) != MaxDeliveryAttempts {
+		t.Errorf("dead after %d attempts (%d requests), want %d", delivery.Attempts, r.requests, MaxDeliveryAttempts)
+	}
+	if delivery.LastStatusCode != http.StatusServiceUnavailable || delivery.LastError == "" {
+		t.Errorf("got last status code %d and error %q, want 503 and an error", delivery.LastStatusCode, delivery.LastError)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, maxRetryDelay},
+	}
+	for _, test := range tests {
+		if got := retryDelay(test.attempts); got != test.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", test.attempts, got, test.want)
+		}
+	}
+}